@@ -0,0 +1,77 @@
+package game
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	ankiBreakRe = regexp.MustCompile(`(?i)<br\s*/?>`)
+	ankiTagRe   = regexp.MustCompile(`<[^>]*>`)
+)
+
+// loadAnkiTSVFile loads cards from an Anki "Notes in Plain Text" export.
+// Each row is front<TAB>back: the front becomes the title and the back the content.
+// Rows with an empty back are skipped and reported as warnings.
+func loadAnkiTSVFile(path string) ([]CardData, []string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var cards []CardData
+	var warnings []string
+
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+
+		// Skip blank lines and Anki export directives (e.g. "#separator:tab")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		back := ""
+		if len(fields) > 1 {
+			back = ankiToText(fields[1])
+		}
+		if back == "" {
+			warnings = append(warnings, fmt.Sprintf("%s:%d: skipping row with empty back", path, lineNum))
+			continue
+		}
+
+		cards = append(cards, CardData{
+			Content: back,
+			Source:  path,
+			Title:   ankiToText(fields[0]),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to scan file %s: %w", path, err)
+	}
+
+	for i := range cards {
+		cards[i].PartIndex = i + 1
+		cards[i].TotalParts = len(cards)
+	}
+
+	return cards, warnings, nil
+}
+
+// ankiToText converts an Anki HTML field to plain text.
+func ankiToText(field string) string {
+	text := ankiBreakRe.ReplaceAllString(field, "\n")
+	text = ankiTagRe.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+	// Anki pads with &nbsp;, which would otherwise be an untypable character
+	text = strings.ReplaceAll(text, "\u00a0", " ")
+	return strings.TrimSpace(text)
+}
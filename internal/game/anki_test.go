@@ -0,0 +1,93 @@
+package game
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const ankiFixture = "#separator:tab\n" +
+	"#html:true\n" +
+	"Hamlet\tTo be, or not to be:<br>that is the question.\n" +
+	"<b>Proverb</b>\tBread &amp; butter\n" +
+	"Empty\t\n" +
+	"\n" +
+	"Spacing\tOne&nbsp;two<br/><div>three</div>\n"
+
+func TestLoadAnkiTSV(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "deck.tsv")
+	if err := os.WriteFile(path, []byte(ankiFixture), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// .tsv extension auto-selects the Anki format
+	cards, warnings, err := LoadCardsWithOptions([]string{path}, LoadOptions{})
+	if err != nil {
+		t.Fatalf("LoadCardsWithOptions failed: %v", err)
+	}
+
+	if len(cards) != 3 {
+		t.Fatalf("Expected 3 cards, got %d", len(cards))
+	}
+
+	if cards[0].Title != "Hamlet" || cards[0].Content != "To be, or not to be:\nthat is the question." {
+		t.Errorf("Card 1 mismatch: %+v", cards[0])
+	}
+	// Tags stripped and entities decoded
+	if cards[1].Title != "Proverb" || cards[1].Content != "Bread & butter" {
+		t.Errorf("Card 2 mismatch: %+v", cards[1])
+	}
+	if cards[2].Content != "One two\nthree" {
+		t.Errorf("Card 3 mismatch: %q", cards[2].Content)
+	}
+	if cards[2].PartIndex != 3 || cards[2].TotalParts != 3 {
+		t.Errorf("Card 3 indexing wrong: #%d of %d", cards[2].PartIndex, cards[2].TotalParts)
+	}
+
+	if len(warnings) != 1 {
+		t.Errorf("Expected 1 warning for the empty back, got %v", warnings)
+	}
+}
+
+func TestLoadAnkiTSV_FormatOverride(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "deck.txt")
+	if err := os.WriteFile(path, []byte("Front\tBack\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the flag, a .txt file is plain text
+	cards, _, err := LoadCardsWithOptions([]string{path}, LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cards[0].Content != "Front\tBack" {
+		t.Errorf("Expected plain text card, got %q", cards[0].Content)
+	}
+
+	cards, _, err = LoadCardsWithOptions([]string{path}, LoadOptions{Format: FormatAnkiTSV})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cards[0].Title != "Front" || cards[0].Content != "Back" {
+		t.Errorf("Expected Anki card, got %+v", cards[0])
+	}
+
+	// --format=text overrides the .tsv extension
+	tsvPath := filepath.Join(dir, "deck.tsv")
+	if err := os.WriteFile(tsvPath, []byte("Front\tBack\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cards, _, err = LoadCardsWithOptions([]string{tsvPath}, LoadOptions{Format: FormatText})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cards[0].Content != "Front\tBack" {
+		t.Errorf("Expected plain text card, got %q", cards[0].Content)
+	}
+
+	if _, _, err := LoadCardsWithOptions([]string{path}, LoadOptions{Format: "bogus"}); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
@@ -0,0 +1,154 @@
+package game
+
+import (
+	"fmt"
+	"go-mem/internal/state"
+	"strconv"
+	"strings"
+)
+
+// CardOptions are the game options a card sets for itself with an OPTS:
+// header. They win over the session's options; nil fields leave those as
+// they are.
+type CardOptions struct {
+	FirstLetter        *bool
+	LastLetter         *bool
+	NRandom            *int
+	NWords             *int
+	Cloze              *int
+	Preview            *int
+	StrictSymbols      *bool
+	HideSpaces         *bool
+	RequirePunctuation *bool
+	Lenient            *bool
+	ForgiveTypos       *bool
+	TimerLimit         *int // Seconds on the card's own timer, 0 for none
+}
+
+// parseCardOptions parses the comma-separated list of an OPTS: header. Each
+// option is named as its command line flag, with =value for those that take
+// one. On/off options may be given =false, to turn off one set for the session.
+func parseCardOptions(list string) (*CardOptions, error) {
+	o := &CardOptions{}
+	for _, item := range strings.Split(list, ",") {
+		name, value, hasValue := strings.Cut(item, "=")
+		name, value = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(value)
+		if name == "" {
+			continue
+		}
+
+		var err error
+		switch name {
+		case "first-letter":
+			o.FirstLetter, err = switchOption(value, hasValue)
+		case "last-letter":
+			o.LastLetter, err = switchOption(value, hasValue)
+		case "strict-symbols":
+			o.StrictSymbols, err = switchOption(value, hasValue)
+		case "hide-spaces":
+			o.HideSpaces, err = switchOption(value, hasValue)
+		case "require-punctuation":
+			o.RequirePunctuation, err = switchOption(value, hasValue)
+		case "lenient":
+			o.Lenient, err = switchOption(value, hasValue)
+		case "forgive-typos":
+			o.ForgiveTypos, err = switchOption(value, hasValue)
+		case "n-random":
+			o.NRandom, err = countOption(value)
+		case "n-words":
+			o.NWords, err = countOption(value)
+		case "cloze":
+			o.Cloze, err = countOption(value)
+		case "preview":
+			o.Preview, err = countOption(value)
+		case "timer":
+			o.TimerLimit, err = timerOption(value)
+		case "notimer":
+			if hasValue {
+				err = fmt.Errorf("takes no value")
+			}
+			o.TimerLimit = new(int)
+		default:
+			return nil, fmt.Errorf("unknown OPTS: option %q", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid OPTS: option %s: %w", name, err)
+		}
+	}
+	return o, nil
+}
+
+// switchOption parses the value of an on/off option, which is on if given
+// no value.
+func switchOption(value string, hasValue bool) (*bool, error) {
+	if !hasValue {
+		on := true
+		return &on, nil
+	}
+	on, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not true or false", value)
+	}
+	return &on, nil
+}
+
+// countOption parses the value of an option that counts letters, words or
+// seconds.
+func countOption(value string) (*int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("%q is not a number of 0 or more", value)
+	}
+	return &n, nil
+}
+
+// timerOption parses a timer of seconds or MM:SS, as --timer takes.
+func timerOption(value string) (*int, error) {
+	if min, sec, ok := strings.Cut(value, ":"); ok {
+		m, err1 := strconv.Atoi(min)
+		s, err2 := strconv.Atoi(sec)
+		if err1 != nil || err2 != nil || m < 0 || s < 0 {
+			return nil, fmt.Errorf("%q is not seconds or MM:SS", value)
+		}
+		limit := m*60 + s
+		return &limit, nil
+	}
+	limit, err := countOption(value)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not seconds or MM:SS", value)
+	}
+	return limit, nil
+}
+
+// Apply returns opts with the card's options set over them. A nil
+// CardOptions leaves opts as they are.
+func (o *CardOptions) Apply(opts state.GameOptions) state.GameOptions {
+	if o == nil {
+		return opts
+	}
+	override(&opts.FirstLetter, o.FirstLetter)
+	override(&opts.LastLetter, o.LastLetter)
+	override(&opts.NRandom, o.NRandom)
+	override(&opts.NWords, o.NWords)
+	override(&opts.Cloze, o.Cloze)
+	override(&opts.Preview, o.Preview)
+	override(&opts.StrictSymbols, o.StrictSymbols)
+	override(&opts.HideSpaces, o.HideSpaces)
+	override(&opts.RequirePunctuation, o.RequirePunctuation)
+	override(&opts.Lenient, o.Lenient)
+	override(&opts.ForgiveTypos, o.ForgiveTypos)
+	override(&opts.TimerLimit, o.TimerLimit)
+	return opts
+}
+
+// HasOwnTimer reports whether the card sets its own timer, or turns it off,
+// rather than sharing the session's.
+func (o *CardOptions) HasOwnTimer() bool {
+	return o != nil && o.TimerLimit != nil
+}
+
+func override[T any](dst *T, v *T) {
+	if v != nil {
+		*dst = *v
+	}
+}
@@ -0,0 +1,99 @@
+package game
+
+import (
+	"go-mem/internal/state"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseCardOptions(t *testing.T) {
+	tests := []struct {
+		list  string
+		check func(state.GameOptions) bool
+	}{
+		{"first-letter", func(o state.GameOptions) bool { return o.FirstLetter }},
+		{"last-letter", func(o state.GameOptions) bool { return o.LastLetter }},
+		{"strict-symbols", func(o state.GameOptions) bool { return o.StrictSymbols }},
+		{"hide-spaces", func(o state.GameOptions) bool { return o.HideSpaces }},
+		{"require-punctuation", func(o state.GameOptions) bool { return o.RequirePunctuation }},
+		{"lenient", func(o state.GameOptions) bool { return o.Lenient }},
+		{"forgive-typos=true", func(o state.GameOptions) bool { return o.ForgiveTypos }},
+		{"n-random=5", func(o state.GameOptions) bool { return o.NRandom == 5 }},
+		{"n-words=2", func(o state.GameOptions) bool { return o.NWords == 2 }},
+		{"cloze=3", func(o state.GameOptions) bool { return o.Cloze == 3 }},
+		{"preview=10", func(o state.GameOptions) bool { return o.Preview == 10 }},
+		{"timer=90", func(o state.GameOptions) bool { return o.TimerLimit == 90 }},
+		{"timer=1:30", func(o state.GameOptions) bool { return o.TimerLimit == 90 }},
+		{"notimer", func(o state.GameOptions) bool { return o.TimerLimit == 0 }},
+		{" First-Letter , timer = 45 ,", func(o state.GameOptions) bool { return o.FirstLetter && o.TimerLimit == 45 }},
+	}
+	for _, tt := range tests {
+		o, err := parseCardOptions(tt.list)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.list, err)
+			continue
+		}
+		if got := o.Apply(state.GameOptions{TimerLimit: -1}); !tt.check(got) {
+			t.Errorf("%q: options not applied, got %+v", tt.list, got)
+		}
+	}
+
+	for _, list := range []string{"first-leter", "n-random=-1", "n-random=lots", "timer=soon", "timer=1:xx", "lenient=maybe", "notimer=1"} {
+		if _, err := parseCardOptions(list); err == nil {
+			t.Errorf("%q: expected an error", list)
+		}
+	}
+}
+
+func TestCardOptions_Apply(t *testing.T) {
+	session := state.GameOptions{FirstLetter: true, NRandom: 3, TimerLimit: 60, Lenient: true}
+
+	// The card wins where it says something, and leaves the rest alone
+	o, _ := parseCardOptions("first-letter=false, n-random=5, strict-symbols")
+	got := o.Apply(session)
+	if got.FirstLetter || got.NRandom != 5 || !got.StrictSymbols || !got.Lenient || got.TimerLimit != 60 {
+		t.Errorf("Expected the card's options over the session's, got %+v", got)
+	}
+	if o.HasOwnTimer() {
+		t.Error("Expected no timer of the card's own")
+	}
+
+	// No options change nothing
+	var none *CardOptions
+	if got := none.Apply(session); got.FirstLetter != session.FirstLetter || got.NRandom != session.NRandom || got.TimerLimit != session.TimerLimit {
+		t.Errorf("Expected nil options to leave the session's, got %+v", got)
+	}
+	if none.HasOwnTimer() {
+		t.Error("Expected nil options to have no timer")
+	}
+}
+
+func TestLoadCards_Opts(t *testing.T) {
+	path := createTempFile(t, "NAME: Phone\nOPTS: strict-symbols, timer=90\n555-0123\n---\nNo options here.")
+	defer os.Remove(path)
+	cards, err := LoadCards([]string{path})
+	if err != nil {
+		t.Fatalf("LoadCards failed: %v", err)
+	}
+	if len(cards) != 2 {
+		t.Fatalf("Expected 2 cards, got %d", len(cards))
+	}
+	if cards[0].Title != "Phone" || cards[0].Content != "555-0123" || !cards[0].Options.HasOwnTimer() {
+		t.Errorf("Expected the OPTS: header parsed and removed, got %+v", cards[0])
+	}
+	if cards[1].Options != nil {
+		t.Errorf("Expected no options on the second card, got %+v", cards[1].Options)
+	}
+
+	// An unknown option fails the load, naming the file and card
+	bad := createTempFile(t, "Fine.\n---\nOPTS: first-letter, turbo\nNot fine.")
+	defer os.Remove(bad)
+	_, err = LoadCards([]string{bad})
+	if err == nil {
+		t.Fatal("Expected an error for an unknown option")
+	}
+	if msg := err.Error(); !strings.Contains(msg, bad) || !strings.Contains(msg, "card 2") || !strings.Contains(msg, `"turbo"`) {
+		t.Errorf("Expected the file, card and option in the error, got %q", msg)
+	}
+}
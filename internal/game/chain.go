@@ -0,0 +1,110 @@
+package game
+
+import (
+	"go-mem/internal/state"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ChainPrompt asks for the first word of the next card from memory before its
+// board is shown, in chain mode, as the order of the cards is what is being
+// learnt.
+type ChainPrompt struct {
+	Word   string // The card's first word, the answer
+	Typed  string // What has been typed of the answer so far
+	Missed bool   // The first answer was wrong, and this is the retry
+}
+
+// firstWord returns the first word of text with the punctuation around it
+// trimmed, or "" if it has none.
+func firstWord(text string) string {
+	for _, field := range strings.Fields(text) {
+		if w := strings.TrimFunc(field, func(r rune) bool { return !isTypable(r) }); w != "" {
+			return w
+		}
+	}
+	return ""
+}
+
+// startChain asks for the first word of the current card before it is
+// played, in chain mode, and reports whether it did. The card's game starts
+// once the question is answered. The first card, drills, and the turns of
+// other players at the same card in versus mode, aren't asked about.
+func (s *Session) startChain(prevIndex int) bool {
+	s.ChainPrompt = nil
+	if !s.Chain || s.IsFinished() || prevIndex < 0 || s.CurrentIndex == prevIndex {
+		return false
+	}
+	card := s.Cards[s.CurrentIndex]
+	if word := firstWord(card.Content); word != "" && !card.Drill {
+		s.ChainPrompt = &ChainPrompt{Word: word}
+	}
+	return s.ChainPrompt != nil
+}
+
+// ChainKey handles a key pressed at the chain prompt. Characters build up the
+// answer and backspace takes the last one back; enter or space hands it in.
+// A right answer earns a chain bonus. A wrong one can be tried once more, and
+// costs a penalty if it is wrong again. Either way the prompt is closed after
+// that and the card's game starts, and answered reports so, with whether the
+// answer was right. The points go to the session total rather than the
+// card's score, which starts from nothing and could be lost to them.
+func (s *Session) ChainKey(ch string) (answered, right bool) {
+	p := s.ChainPrompt
+	if p == nil {
+		return false, false
+	}
+
+	switch ch {
+	case "backspace":
+		if _, size := utf8.DecodeLastRuneInString(p.Typed); size > 0 {
+			p.Typed = p.Typed[:len(p.Typed)-size]
+		}
+		return false, false
+	case "enter", " ":
+		if p.Typed == "" {
+			return false, false
+		}
+	default:
+		if r, size := utf8.DecodeRuneInString(ch); size == len(ch) && unicode.IsPrint(r) {
+			p.Typed += ch
+		}
+		return false, false
+	}
+
+	right = sameWord(p.Typed, p.Word)
+	if !right && !p.Missed {
+		p.Missed, p.Typed = true, ""
+		return false, false
+	}
+	event := "chainMiss"
+	if right {
+		event = "chainBonus"
+	}
+	points := s.CurrentGame.State.Score.Points(event)
+	s.ChainScore += points
+	s.TotalScore += points
+	if s.IsVersus() {
+		s.PlayerTotals[s.CurrentPlayer] += points
+	}
+	s.ChainPrompt = nil
+	s.startGame()
+	return true, right
+}
+
+// sameWord reports whether typed is word, compared a character at a time as
+// the game compares keys.
+func sameWord(typed, word string) bool {
+	want := []rune(word)
+	got := []rune(typed)
+	if len(got) != len(want) {
+		return false
+	}
+	for i, r := range want {
+		if !state.SameChar(string(got[i]), r) {
+			return false
+		}
+	}
+	return true
+}
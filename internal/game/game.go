@@ -2,15 +2,33 @@ package game
 
 import (
 	"context"
+	"fmt"
 	"go-mem/internal/scoring"
 	"go-mem/internal/state"
 
 	"github.com/charmbracelet/bubbles/textarea"
 )
 
+// GameLike is what a Session, and the UI hosting it, need of a game to play
+// it: keys and timer ticks go in, and what to show and whether it is over come
+// out. Game is the one kind there is; recall and flash play are modes of its
+// State rather than games of their own.
+type GameLike interface {
+	HandleKeyPress(ch string)
+	HandleTick()
+	IsOver() bool
+	Secret() string // The text being played
+	Shown() string  // The text as the player sees it: masked, or typed in recall mode
+}
+
+var _ GameLike = (*Game)(nil)
+
 // Game encapsulates the core game logic, independent of the UI.
 type Game struct {
 	State *state.State
+
+	Recoveries   int   // Times the FSM had to be forced back to idle
+	LastFSMError error // Error from the event that led to the last recovery
 }
 
 // NewGame initializes a new game instance.
@@ -23,32 +41,180 @@ func NewGame(secretMessage string, cardWidth int, ta textarea.Model, scoring sco
 // Init initializes the game state.
 func (g *Game) Init() {
 	g.State.SetBracketedPositions()
-	g.State.InitMask()
 
-	// Apply game modes
-	g.State.ApplyGameModes(g.State.Options)
+	// Flash mode doesn't use the FSM: keys go straight to the state
+	if g.State.Options.Flash {
+		g.State.InitFlash()
+		g.State.Display.SetValue(string(g.State.Mask))
+		return
+	}
 
-	g.State.Textarea.SetValue(string(g.State.Mask))
+	// Recall mode starts from a blank field, so no game modes apply
+	if g.State.Options.Recall {
+		g.State.InitRecall()
+	} else if !g.State.UseStartMask() {
+		g.State.InitMask()
+
+		// Apply game modes
+		g.State.ApplyGameModes(g.State.Options)
+	}
+
+	// Show the unmasked text first if a preview was requested
+	if g.State.Options.Preview > 0 {
+		_ = g.State.FSM.Event(context.Background(), "preview")
+		return
+	}
+
+	if !g.State.Options.Recall {
+		g.State.Display.SetValue(string(g.State.Mask))
+	}
 	// Initialize FSM state
 	_ = g.State.FSM.Event(context.Background(), "initGame")
 }
 
+// IsOver reports whether the game has been won or lost.
+func (g *Game) IsOver() bool {
+	return g.State.Win || g.State.Loss
+}
+
+// Secret returns the text being played.
+func (g *Game) Secret() string {
+	return string(g.State.Secret)
+}
+
+// Shown returns the text as the player sees it: the masked secret, the whole
+// of it during a preview, or what has been typed in recall mode.
+func (g *Game) Shown() string {
+	return g.State.Display.Value()
+}
+
+// EndPreview hides the text and starts the real game.
+func (g *Game) EndPreview() {
+	if !g.State.IsPreviewing() {
+		return
+	}
+	g.State.PreviewRemaining = 0
+	if g.State.Options.Recall {
+		g.State.Display.SetValue("")
+	} else {
+		g.State.Display.SetValue(string(g.State.Mask))
+	}
+	_ = g.State.FSM.Event(context.Background(), "initGame")
+}
+
 // HandleTick processes a timer tick.
 func (g *Game) HandleTick() {
+	g.handleTick()
+	if log := g.State.Options.Record; log != nil {
+		log.Write(state.KeyLogTick, "", "", g.State.Score.CurrentScore)
+	}
+}
+
+func (g *Game) handleTick() {
+	// The preview counts down on its own, without touching the game timer
+	if g.State.IsPreviewing() {
+		g.State.PreviewRemaining--
+		if g.State.PreviewRemaining <= 0 {
+			g.EndPreview()
+		}
+		return
+	}
+
+	// A line shown with Ctrl+L is hidden again after a moment, timer or not
+	if g.State.LinePeek > 0 {
+		g.State.LinePeek--
+	}
+
 	if g.State.Win || g.State.Loss || !g.State.TimerEnabled {
 		return
 	}
-	_ = g.State.FSM.Event(context.Background(), "tick")
+
+	// The grace period counts down before the timer does
+	if g.State.GraceRemaining > 0 {
+		g.State.GraceRemaining--
+		return
+	}
+	g.settle(g.State.FSM.Event(context.Background(), "tick"))
 }
 
 // HandleKeyPress processes a key press and updates the game state.
 func (g *Game) HandleKeyPress(ch string) {
+	log := g.State.Options.Record
+	if log == nil {
+		g.handleKeyPress(ch)
+		return
+	}
+	outcome := g.keyOutcome(func() { g.handleKeyPress(ch) })
+	log.Write(state.KeyLogKey, g.State.Options.Layout.Translate(ch), outcome, g.State.Score.CurrentScore)
+}
+
+// keyOutcome runs press, the handling of a key, and returns what it did.
+func (g *Game) keyOutcome(press func()) string {
+	st := g.State
+	hints, errs := st.Score.HintCount, st.Score.ErrorCount
+	pos, mask, typed := st.Pos, string(st.Mask), len(st.RecallInput)
+	ended, previewing := st.Win || st.Loss, st.IsPreviewing()
+	peek := st.LinePeek
+
+	press()
+
+	switch {
+	case st.Score.HintCount > hints || st.LinePeek > peek:
+		return state.OutcomeHint
+	case st.Score.ErrorCount > errs:
+		return state.OutcomeMismatch
+	case st.Pos != pos || string(st.Mask) != mask || len(st.RecallInput) != typed ||
+		(st.Win || st.Loss) != ended || st.IsPreviewing() != previewing:
+		return state.OutcomeMatch
+	}
+	return state.OutcomeIgnored
+}
+
+func (g *Game) handleKeyPress(ch string) {
 	// If game is already over, exit
-	if g.State.Win || g.State.Loss {
+	if g.IsOver() {
+		return
+	}
+
+	if g.State.Options.Flash {
+		g.State.FlashKey(ch)
+		return
+	}
+
+	// Any key ends the preview; the key itself is not typed, unless it quits
+	// the game, which is then abandoned like any other
+	if g.State.IsPreviewing() {
+		g.EndPreview()
+		if !state.IsExitRequested(ch) {
+			return
+		}
+	}
+
+	// Recall mode doesn't use the FSM either once the game has started
+	if g.State.Options.Recall {
+		g.State.RecallKey(ch)
 		return
 	}
 
 	// Delegate processing to the FSM
 	// We use background context as we don't need cancellation here
-	_ = g.State.FSM.Event(context.Background(), "input", ch)
+	g.settle(g.State.FSM.Event(context.Background(), "input", ch))
+}
+
+// settle makes sure the FSM is ready for the next event. Errors from nested
+// events are not returned by the outer one, so a failed transition can leave
+// the FSM stuck in an intermediate state where "input" is no longer legal.
+// In that case it is forced back to idle.
+func (g *Game) settle(err error) {
+	if g.State.IsSettled() {
+		return
+	}
+	if err == nil {
+		err = fmt.Errorf("event left the game in state %s", g.State.FSM.Current())
+	}
+	g.LastFSMError = err
+	g.Recoveries++
+	if rerr := g.State.FSM.Event(context.Background(), "recover"); rerr != nil {
+		g.LastFSMError = fmt.Errorf("%w (recovery failed: %v)", err, rerr)
+	}
 }
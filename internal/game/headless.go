@@ -0,0 +1,65 @@
+package game
+
+import (
+	"go-mem/internal/scoring"
+	"go-mem/internal/state"
+	"go-mem/internal/ui"
+)
+
+// HeadlessResult is the outcome of a non-interactive run of a card.
+type HeadlessResult struct {
+	Score  int  `json:"score"`
+	Errors int  `json:"errors"`
+	Hints  int  `json:"hints"`
+	Win    bool `json:"win"`
+}
+
+// NewHeadless creates and initializes a game that renders to memory instead
+// of a terminal, for driving the engine programmatically.
+// Nothing ticks on its own: callers that enable the timer or preview call HandleTick.
+func NewHeadless(secret string, opts state.GameOptions, storage scoring.ScoreStorage) (*Game, error) {
+	return newHeadless(secret, "", opts, storage)
+}
+
+func newHeadless(secret, title string, opts state.GameOptions, storage scoring.ScoreStorage) (*Game, error) {
+	sc, err := scoring.InitScoring(secret, title, storage)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Game{
+		State: state.NewStateWithDisplay(secret, ui.LongestLineLen(secret), &state.MemoryDisplay{}, *sc, opts),
+	}
+	g.Init()
+	return g, nil
+}
+
+// RunHeadless plays a card without a UI, feeding input to the game one rune at a time.
+// There is no clock in headless mode, so the timer and preview are always disabled.
+func RunHeadless(card CardData, input string, opts state.GameOptions, storage scoring.ScoreStorage) (HeadlessResult, error) {
+	opts = card.Options.Apply(opts)
+	opts.TimerLimit = 0
+	opts.Preview = 0
+	g, err := newHeadless(card.Content, scoreTitle(card), opts, storage)
+	if err != nil {
+		return HeadlessResult{}, err
+	}
+
+	for _, r := range input {
+		if g.IsOver() {
+			break
+		}
+		g.HandleKeyPress(string(r))
+	}
+	// A recall attempt is handed in once all of it has been typed
+	if opts.Recall {
+		g.HandleKeyPress(state.RecallFinishKey)
+	}
+
+	return HeadlessResult{
+		Score:  g.State.Score.CurrentScore,
+		Errors: g.State.Score.ErrorCount,
+		Hints:  g.State.Score.HintCount,
+		Win:    g.State.Win,
+	}, nil
+}
@@ -0,0 +1,94 @@
+package game
+
+import (
+	"go-mem/internal/state"
+	"testing"
+)
+
+func TestRunHeadless_Win(t *testing.T) {
+	card := CardData{Content: "Hi you", Source: "src"}
+	store := &MockStorage{}
+
+	res, err := RunHeadless(card, "Hi you", state.GameOptions{TimerLimit: -1}, store)
+	if err != nil {
+		t.Fatalf("RunHeadless failed: %v", err)
+	}
+
+	if !res.Win {
+		t.Error("Expected win")
+	}
+	// 5 letters * 25 + 350 (clean last word) + 1000 (message), no time bonus in headless mode
+	if res.Score != 1475 {
+		t.Errorf("Expected score 1475, got %d", res.Score)
+	}
+	if res.Errors != 0 || res.Hints != 0 {
+		t.Errorf("Expected no errors or hints, got %+v", res)
+	}
+	if !store.SaveCalled {
+		t.Error("Score should be saved")
+	}
+}
+
+func TestRunHeadless_Incomplete(t *testing.T) {
+	card := CardData{Content: "Hello", Source: "src"}
+
+	res, err := RunHeadless(card, "Hx", state.GameOptions{}, &MockStorage{})
+	if err != nil {
+		t.Fatalf("RunHeadless failed: %v", err)
+	}
+
+	if res.Win {
+		t.Error("Should not win with partial input")
+	}
+	if res.Errors != 1 {
+		t.Errorf("Expected 1 error, got %d", res.Errors)
+	}
+}
+
+func TestNewHeadless_FullGame(t *testing.T) {
+	store := &MockStorage{}
+	g, err := NewHeadless("Hi [there] you", state.GameOptions{}, store)
+	if err != nil {
+		t.Fatalf("NewHeadless failed: %v", err)
+	}
+
+	if string(g.State.Mask) != "__ there ___" || g.State.Display.Value() != "__ there ___" {
+		t.Fatalf("Unexpected initial mask %q / display %q", string(g.State.Mask), g.State.Display.Value())
+	}
+
+	// Give the score some headroom so the error doesn't end the game
+	g.State.Score.CurrentScore = 1000
+	for _, k := range []string{"h", "x", "i", "y", "o"} {
+		g.HandleKeyPress(k)
+	}
+	if string(g.State.Mask) != "Hi there yo_" {
+		t.Errorf("Unexpected mask mid-game: %q", string(g.State.Mask))
+	}
+	if g.State.Win {
+		t.Fatal("Game should not be won yet")
+	}
+
+	g.HandleKeyPress("u")
+	if !g.State.Win {
+		t.Fatal("Expected win")
+	}
+	if g.State.Display.Value() != "Hi there you" {
+		t.Errorf("Expected the display to show the secret, got %q", g.State.Display.Value())
+	}
+	if g.State.Score.ErrorCount != 1 || !store.SaveCalled {
+		t.Errorf("Expected 1 error and a saved score, got %d errors", g.State.Score.ErrorCount)
+	}
+}
+
+func TestRunHeadless_Recall(t *testing.T) {
+	card := CardData{Content: "Hi you", Source: "src"}
+
+	res, err := RunHeadless(card, "hi you", state.GameOptions{Recall: true, Preview: 10}, &MockStorage{})
+	if err != nil {
+		t.Fatalf("RunHeadless failed: %v", err)
+	}
+	// Handed in at the end of the input: 5 letters * 25 + 2 clean words * 350 + 1000
+	if !res.Win || res.Score != 1825 || res.Errors != 0 {
+		t.Errorf("Expected a perfect recall scoring 1825, got %+v", res)
+	}
+}
@@ -3,9 +3,12 @@ package game
 import (
 	"bufio"
 	"fmt"
+	"go-mem/internal/state"
+	"go-mem/internal/ui"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 )
 
@@ -15,48 +18,285 @@ type CardData struct {
 	Title      string
 	PartIndex  int
 	TotalParts int
+	Tags       []string     // From a TAGS: header, lowercased
+	Options    *CardOptions // From an OPTS: header, nil for none
+	Drill      bool         // A mistake drill added by the session: untimed, and its scores aren't saved
+}
+
+// DisplayTitle returns the title shown in the card banner: the NAME: header if
+// present, otherwise a title derived from the file name, numbered for multi-card files.
+func (c CardData) DisplayTitle() string {
+	if c.Title != "" {
+		return c.Title
+	}
+	fileExt := filepath.Ext(c.Source)
+	title := ui.TitleCaseToTitle(filepath.Base(strings.TrimSuffix(c.Source, fileExt)))
+	if c.TotalParts > 1 {
+		title = fmt.Sprintf("%s #%d", title, c.PartIndex)
+	}
+	return title
+}
+
+// Supported card file formats.
+const (
+	FormatText    = "text"
+	FormatAnkiTSV = "anki-tsv"
+	FormatJSON    = "json"
+	FormatCSV     = "csv"
+)
+
+// LoadOptions controls how card files are parsed.
+type LoadOptions struct {
+	Format           string   // "" auto-detects from the file extension
+	Separator        string   // regex matched against a whole line; "" uses three or more dashes
+	Tags             []string // Only keep cards with all of these tags
+	AnyTags          []string // Only keep cards with at least one of these tags
+	Markdown         bool     // "## " headings also start a card, titled with the heading text
+	NormalizeUnicode bool     // Rewrite typographic quotes, dashes and ellipses in the text as ASCII
+}
+
+// separatorLine wraps a card separator pattern so that it must match a whole
+// line, allowing trailing spaces or tabs.
+const separatorLine = `(?m)^(?:%s)[ \t]*$`
+
+// defaultSeparatorRe matches a line of three or more dashes.
+var defaultSeparatorRe = regexp.MustCompile(fmt.Sprintf(separatorLine, `-{3,}`))
+
+// markdownHeadingRe matches a level two Markdown heading, capturing its text
+// without any closing #s.
+var markdownHeadingRe = regexp.MustCompile(`(?m)^##[ \t]+(.*?)[ \t#]*$`)
+
+// separatorRegexp compiles a user supplied card separator pattern,
+// or returns the default one if the pattern is empty.
+func separatorRegexp(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return defaultSeparatorRe, nil
+	}
+	re, err := regexp.Compile(fmt.Sprintf(separatorLine, pattern))
+	if err != nil {
+		return nil, fmt.Errorf("invalid card separator %q: %w", pattern, err)
+	}
+	return re, nil
 }
 
 // LoadCards loads cards from a list of paths (files or directories).
 func LoadCards(paths []string) ([]CardData, error) {
+	cards, _, err := LoadCardsWithOptions(paths, LoadOptions{})
+	return cards, err
+}
+
+// LoadCardsWithOptions loads cards from a list of paths (files or directories),
+// returning any non-fatal warnings collected while parsing.
+func LoadCardsWithOptions(paths []string, opts LoadOptions) ([]CardData, []string, error) {
+	switch opts.Format {
+	case "", FormatText, FormatAnkiTSV, FormatJSON, FormatCSV:
+	default:
+		return nil, nil, fmt.Errorf("unknown card format: %s", opts.Format)
+	}
+	separatorRe, err := separatorRegexp(opts.Separator)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	paths, err = expandGlobs(paths)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	var cards []CardData
+	var warnings []string
 
 	for _, path := range paths {
 		info, err := os.Stat(path)
 		if err != nil {
-			return nil, fmt.Errorf("failed to access path %s: %w", path, err)
+			return nil, nil, fmt.Errorf("failed to access path %s: %w", path, err)
 		}
 
 		if info.IsDir() {
-			// Read directory
+			// Read directory. ReadDir sorts by file name, so a directory
+			// loads in the same order on every machine.
 			files, err := os.ReadDir(path)
 			if err != nil {
-				return nil, fmt.Errorf("failed to read dir %s: %w", path, err)
+				return nil, nil, fmt.Errorf("failed to read dir %s: %w", path, err)
 			}
 			for _, entry := range files {
 				if !entry.IsDir() {
-					c, err := loadFile(filepath.Join(path, entry.Name()))
+					c, w, err := loadPath(filepath.Join(path, entry.Name()), opts, separatorRe)
 					if err != nil {
 						// Optionally warn instead of fail? strict for now.
-						return nil, err
+						return nil, nil, err
 					}
 					cards = append(cards, c...)
+					warnings = append(warnings, w...)
 				}
 			}
 		} else {
 			// Read file
-			c, err := loadFile(path)
+			c, w, err := loadPath(path, opts, separatorRe)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			cards = append(cards, c...)
+			warnings = append(warnings, w...)
 		}
 	}
 
-	return cards, nil
+	if opts.NormalizeUnicode {
+		for i := range cards {
+			cards[i].Content = state.NormalizeTypography(cards[i].Content)
+		}
+	}
+
+	if len(opts.Tags) > 0 || len(opts.AnyTags) > 0 {
+		cards = FilterByTags(cards, opts.Tags, opts.AnyTags)
+		if len(cards) == 0 {
+			return nil, nil, fmt.Errorf("no cards match the requested tags")
+		}
+	}
+
+	return cards, warnings, nil
 }
 
-func loadFile(path string) ([]CardData, error) {
+// FilterByTags returns the cards that have every tag in allOf and, if anyOf is
+// not empty, at least one of the tags in anyOf. Tags are compared case-insensitively.
+func FilterByTags(cards []CardData, allOf, anyOf []string) []CardData {
+	var filtered []CardData
+	for _, c := range cards {
+		if !slices.ContainsFunc(allOf, c.lacksTag) && (len(anyOf) == 0 || slices.ContainsFunc(anyOf, c.HasTag)) {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered
+}
+
+// HasTag reports whether the card is tagged with tag, ignoring case.
+func (c CardData) HasTag(tag string) bool {
+	return slices.Contains(c.Tags, strings.ToLower(strings.TrimSpace(tag)))
+}
+
+func (c CardData) lacksTag(tag string) bool {
+	return !c.HasTag(tag)
+}
+
+// parseHeaders removes the NAME:, TAGS: and OPTS: header lines, in any order,
+// from the start of a card and returns what they hold.
+func parseHeaders(text string) (content, title string, tags []string, opts *CardOptions, err error) {
+	lines := strings.Split(text, "\n")
+	seenName, seenTags := false, false
+	for len(lines) > 0 {
+		line := strings.TrimSpace(lines[0])
+		if v, ok := strings.CutPrefix(line, "NAME:"); ok && !seenName {
+			title = strings.TrimSpace(v)
+			seenName = true
+		} else if v, ok := strings.CutPrefix(line, "TAGS:"); ok && !seenTags {
+			tags = parseTags(v)
+			seenTags = true
+		} else if v, ok := strings.CutPrefix(line, "OPTS:"); ok && opts == nil {
+			if opts, err = parseCardOptions(v); err != nil {
+				return "", "", nil, nil, err
+			}
+		} else {
+			break
+		}
+		lines = lines[1:]
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")), title, tags, opts, nil
+}
+
+// parseTags splits a comma-separated tag list, trimming and lowercasing each tag.
+func parseTags(list string) []string {
+	var tags []string
+	for _, tag := range strings.Split(list, ",") {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// expandGlobs replaces each path containing wildcards with its matches, so
+// patterns work even where the shell doesn't expand them. Other paths, and
+// existing files whose names happen to contain wildcard characters, are kept as is.
+func expandGlobs(paths []string) ([]string, error) {
+	var expanded []string
+	for _, path := range paths {
+		if !strings.ContainsAny(path, "*?[") {
+			expanded = append(expanded, path)
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			expanded = append(expanded, path)
+			continue
+		}
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", path, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match pattern %s", path)
+		}
+		expanded = append(expanded, matches...)
+	}
+	return expanded, nil
+}
+
+// loadPath loads a single file using the requested format,
+// falling back to detection by extension.
+func loadPath(path string, opts LoadOptions, separatorRe *regexp.Regexp) ([]CardData, []string, error) {
+	format := opts.Format
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".tsv":
+			format = FormatAnkiTSV
+		case ".json":
+			format = FormatJSON
+		case ".csv":
+			format = FormatCSV
+		default:
+			format = FormatText
+		}
+	}
+
+	var cards []CardData
+	var err error
+	switch format {
+	case FormatAnkiTSV:
+		return loadAnkiTSVFile(path)
+	case FormatJSON:
+		cards, err = loadJSONFile(path)
+	case FormatCSV:
+		cards, err = loadCSVFile(path)
+	default:
+		cards, err = loadFile(path, separatorRe, opts.Markdown)
+	}
+	return cards, nil, err
+}
+
+// cardText is the text of one card in a file, before its headers are parsed,
+// and the title given by a Markdown heading, if any.
+type cardText struct {
+	text, title string
+}
+
+// splitHeadings splits text at its "## " headings. The text before the first
+// heading, if any, has no title.
+func splitHeadings(text string) []cardText {
+	matches := markdownHeadingRe.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return []cardText{{text: text}}
+	}
+	parts := []cardText{{text: text[:matches[0][0]]}}
+	for i, m := range matches {
+		end := len(text)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		parts = append(parts, cardText{text: text[m[1]:end], title: text[m[2]:m[3]]})
+	}
+	return parts
+}
+
+func loadFile(path string, separatorRe *regexp.Regexp, markdown bool) ([]CardData, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file %s: %w", path, err)
@@ -74,45 +314,50 @@ func loadFile(path string) ([]CardData, error) {
 
 	content := contentBuilder.String()
 
-	// Split by separator: line starting with 3+ dashes
-	// Regex: (?m)^-{3,}\s*$
-	// Note: We need to handle potential split at EOF?
-
-	separatorRe := regexp.MustCompile(`(?m)^-{3,}[ \t]*$`)
+	// Split by separator: by default a line of 3+ dashes
 	parts := separatorRe.Split(content, -1)
 
-	// Calculate total valid parts first
-	var validParts []string
+	// With Markdown, headings start cards within each part too
+	var texts []cardText
 	for _, part := range parts {
-		trimmed := strings.TrimSpace(part)
+		if markdown {
+			texts = append(texts, splitHeadings(part)...)
+		} else {
+			texts = append(texts, cardText{text: part})
+		}
+	}
+
+	// Calculate total valid parts first
+	var validParts []cardText
+	for _, part := range texts {
+		trimmed := strings.TrimSpace(part.text)
 		if len(trimmed) > 0 {
-			validParts = append(validParts, trimmed)
+			validParts = append(validParts, cardText{text: trimmed, title: part.title})
 		}
 	}
 
 	totalParts := len(validParts)
 	var cards []CardData
 
-	for i, trimmed := range validParts {
-		// Check for NAME: header
-		title := ""
-		lines := strings.Split(trimmed, "\n")
-		if len(lines) > 0 {
-			firstLine := strings.TrimSpace(lines[0])
-			if strings.HasPrefix(firstLine, "NAME:") {
-				title = strings.TrimSpace(strings.TrimPrefix(firstLine, "NAME:"))
-				// Remove first line from content
-				trimmed = strings.Join(lines[1:], "\n")
-				trimmed = strings.TrimSpace(trimmed)
-			}
+	for i, part := range validParts {
+		// Strip the NAME:, TAGS: and OPTS: headers from the playable text.
+		// A NAME: header wins over a heading.
+		content, title, tags, opts, err := parseHeaders(part.text)
+		if err != nil {
+			return nil, fmt.Errorf("%s, card %d: %w", path, i+1, err)
+		}
+		if title == "" {
+			title = part.title
 		}
 
 		cards = append(cards, CardData{
-			Content:    trimmed,
+			Content:    content,
 			Source:     path,
 			Title:      title,
 			PartIndex:  i + 1,
 			TotalParts: totalParts,
+			Tags:       tags,
+			Options:    opts,
 		})
 	}
 
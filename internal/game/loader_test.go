@@ -1,8 +1,12 @@
 package game
 
 import (
+	"fmt"
+	"go-mem/internal/state"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -143,3 +147,338 @@ func createTempFile(t *testing.T, content string) string {
 	f.Close()
 	return f.Name()
 }
+
+func TestLoadCards_CustomSeparator(t *testing.T) {
+	content := `Card 1
+===
+Card 2
+---
+still Card 2
+=====  
+Card 3`
+	path := createTempFile(t, content)
+	defer os.Remove(path)
+
+	cards, _, err := LoadCardsWithOptions([]string{path}, LoadOptions{Separator: "={3,}"})
+	if err != nil {
+		t.Fatalf("LoadCardsWithOptions failed: %v", err)
+	}
+
+	if len(cards) != 3 {
+		t.Fatalf("Expected 3 cards, got %d", len(cards))
+	}
+	if cards[0].Content != "Card 1" {
+		t.Errorf("Card 1 mismatch: %q", cards[0].Content)
+	}
+	if cards[1].Content != "Card 2\n---\nstill Card 2" {
+		t.Errorf("Card 2 mismatch: %q", cards[1].Content)
+	}
+	if cards[2].Content != "Card 3" || cards[2].PartIndex != 3 || cards[2].TotalParts != 3 {
+		t.Errorf("Card 3 mismatch: %+v", cards[2])
+	}
+}
+
+func TestLoadCards_Markdown(t *testing.T) {
+	content := `## Psalm 23 ##
+The Lord is my shepherd;
+I shall not want.
+
+## John 3:16
+For God so loved the world
+`
+	path := createTempFile(t, content)
+	defer os.Remove(path)
+
+	cards, _, err := LoadCardsWithOptions([]string{path}, LoadOptions{Markdown: true})
+	if err != nil {
+		t.Fatalf("LoadCardsWithOptions failed: %v", err)
+	}
+
+	if len(cards) != 2 {
+		t.Fatalf("Expected 2 cards, got %d", len(cards))
+	}
+	if cards[0].Title != "Psalm 23" || cards[0].Content != "The Lord is my shepherd;\nI shall not want." {
+		t.Errorf("Card 1 mismatch: %+v", cards[0])
+	}
+	if cards[1].Title != "John 3:16" || cards[1].Content != "For God so loved the world" {
+		t.Errorf("Card 2 mismatch: %+v", cards[1])
+	}
+	if cards[1].PartIndex != 2 || cards[1].TotalParts != 2 {
+		t.Errorf("Card 2 indexing wrong: #%d of %d", cards[1].PartIndex, cards[1].TotalParts)
+	}
+
+	// Without --markdown the headings are part of a single card
+	if cards, _ := LoadCards([]string{path}); len(cards) != 1 || !strings.HasPrefix(cards[0].Content, "## Psalm 23") {
+		t.Errorf("Expected one card with the headings in it, got %+v", cards)
+	}
+}
+
+func TestLoadCards_MarkdownWithSeparators(t *testing.T) {
+	content := `Untitled card
+---
+## First
+NAME: Named
+TAGS: psalms
+One
+### Not a card
+Two
+---
+No heading here
+## Second
+Three`
+	path := createTempFile(t, content)
+	defer os.Remove(path)
+
+	cards, _, err := LoadCardsWithOptions([]string{path}, LoadOptions{Markdown: true})
+	if err != nil {
+		t.Fatalf("LoadCardsWithOptions failed: %v", err)
+	}
+
+	want := []struct{ title, content string }{
+		{"", "Untitled card"},
+		// A NAME: header wins over the heading, and only ## starts a card
+		{"Named", "One\n### Not a card\nTwo"},
+		{"", "No heading here"},
+		{"Second", "Three"},
+	}
+	if len(cards) != len(want) {
+		t.Fatalf("Expected %d cards, got %d: %+v", len(want), len(cards), cards)
+	}
+	for i, w := range want {
+		if cards[i].Title != w.title || cards[i].Content != w.content {
+			t.Errorf("Card %d: expected %q / %q, got %q / %q", i+1, w.title, w.content, cards[i].Title, cards[i].Content)
+		}
+	}
+	if !cards[1].HasTag("psalms") {
+		t.Errorf("Expected the tags under a heading to be kept, got %v", cards[1].Tags)
+	}
+}
+
+func TestLoadCards_InvalidSeparator(t *testing.T) {
+	path := createTempFile(t, "Card 1")
+	defer os.Remove(path)
+
+	_, _, err := LoadCardsWithOptions([]string{path}, LoadOptions{Separator: "(="})
+	if err == nil || !strings.Contains(err.Error(), "invalid card separator") {
+		t.Errorf("Expected an invalid separator error, got %v", err)
+	}
+}
+
+func TestSeparatorRegexp_DefaultIsShared(t *testing.T) {
+	a, _ := separatorRegexp("")
+	b, _ := separatorRegexp("")
+	if a != defaultSeparatorRe || b != defaultSeparatorRe {
+		t.Error("Expected the default separator to be compiled once and reused")
+	}
+}
+
+func BenchmarkLoadCards_Directory(b *testing.B) {
+	dir := b.TempDir()
+	for i := 0; i < 200; i++ {
+		content := fmt.Sprintf("NAME: Card %d\nSome text\n---\nMore text\n", i)
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("card%03d.txt", i)), []byte(content), 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := LoadCards([]string{dir}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestLoadCards_DirectoryOrder(t *testing.T) {
+	dir := t.TempDir()
+	// Created out of order, so the directory's own order isn't sorted either
+	for _, name := range []string{"c.txt", "a.txt", "d.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("Card "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A file given before the directory stays first, though its name sorts last
+	first := filepath.Join(t.TempDir(), "z.txt")
+	if err := os.WriteFile(first, []byte("Card z.txt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cards, err := LoadCards([]string{first, dir})
+	if err != nil {
+		t.Fatalf("LoadCards failed: %v", err)
+	}
+	var got []string
+	for _, c := range cards {
+		got = append(got, filepath.Base(c.Source))
+	}
+	if want := []string{"z.txt", "a.txt", "b.txt", "c.txt", "d.txt"}; !slices.Equal(got, want) {
+		t.Errorf("Expected cards in the order %v, got %v", want, got)
+	}
+}
+
+func TestLoadCards_Glob(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"one.txt":   "Card one",
+		"two.txt":   "Card two",
+		"three.txt": "Card three",
+		"notes.md":  "Not a card",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cards, err := LoadCards([]string{filepath.Join(dir, "*.txt")})
+	if err != nil {
+		t.Fatalf("LoadCards failed: %v", err)
+	}
+	if len(cards) != 3 {
+		t.Fatalf("Expected 3 cards, got %d", len(cards))
+	}
+	for _, c := range cards {
+		if filepath.Ext(c.Source) != ".txt" {
+			t.Errorf("Unexpected card from %s", c.Source)
+		}
+	}
+
+	// A pattern matching nothing names the pattern
+	pattern := filepath.Join(dir, "*.tsv")
+	_, err = LoadCards([]string{pattern})
+	if err == nil || !strings.Contains(err.Error(), pattern) {
+		t.Errorf("Expected an error naming %s, got %v", pattern, err)
+	}
+}
+
+func TestLoadCards_LiteralPathWithWildcardChars(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "psalm [old].txt")
+	if err := os.WriteFile(path, []byte("The Lord is my shepherd"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cards, err := LoadCards([]string{path})
+	if err != nil {
+		t.Fatalf("LoadCards failed: %v", err)
+	}
+	if len(cards) != 1 || cards[0].Source != path {
+		t.Errorf("Expected the file to be loaded literally, got %+v", cards)
+	}
+}
+
+func TestLoadCards_NormalizeUnicode(t *testing.T) {
+	path := createTempFile(t, "NAME: It’s “quoted”\nIt’s “quoted” — mostly…")
+	defer os.Remove(path)
+
+	cards, _, err := LoadCardsWithOptions([]string{path}, LoadOptions{NormalizeUnicode: true})
+	if err != nil {
+		t.Fatalf("LoadCards failed: %v", err)
+	}
+	if cards[0].Content != `It's "quoted" - mostly...` {
+		t.Errorf("Expected the text in ASCII, got %q", cards[0].Content)
+	}
+	if cards[0].Title != "It’s “quoted”" {
+		t.Errorf("Expected the title left as written, got %q", cards[0].Title)
+	}
+
+	// The game is played, and shown, in ASCII
+	sess, err := NewSession(cards, state.GameOptions{}, &MockStorage{}, InOrder)
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+	if secret := string(sess.CurrentGame.State.Secret); secret != `It's "quoted" - mostly...` {
+		t.Errorf("Expected straight quotes in the secret, got %q", secret)
+	}
+
+	// Without the option the text is kept as written
+	cards, _ = LoadCards([]string{path})
+	if cards[0].Content != "It’s “quoted” — mostly…" {
+		t.Errorf("Expected the text unchanged, got %q", cards[0].Content)
+	}
+}
+
+func TestLoadCards_Tags(t *testing.T) {
+	content := `NAME: Carpe Diem
+TAGS: Latin,  Poetry
+Seize the day.
+---
+TAGS: latin
+NAME: Veni
+I came.
+---
+TAGS: greek
+Know thyself.
+---
+Untagged card.`
+	path := createTempFile(t, content)
+	defer os.Remove(path)
+
+	cards, err := LoadCards([]string{path})
+	if err != nil {
+		t.Fatalf("LoadCards failed: %v", err)
+	}
+
+	tests := []struct {
+		title   string
+		tags    string
+		content string
+	}{
+		{"Carpe Diem", "latin,poetry", "Seize the day."},
+		{"Veni", "latin", "I came."},
+		{"", "greek", "Know thyself."},
+		{"", "", "Untagged card."},
+	}
+	if len(cards) != len(tests) {
+		t.Fatalf("Expected %d cards, got %d", len(tests), len(cards))
+	}
+	for i, tt := range tests {
+		c := cards[i]
+		if c.Title != tt.title || strings.Join(c.Tags, ",") != tt.tags || c.Content != tt.content {
+			t.Errorf("Card %d: got title %q tags %v content %q", i+1, c.Title, c.Tags, c.Content)
+		}
+	}
+}
+
+func TestFilterByTags(t *testing.T) {
+	cards := []CardData{
+		{Title: "A", Tags: []string{"latin", "poetry"}},
+		{Title: "B", Tags: []string{"latin"}},
+		{Title: "C", Tags: []string{"greek", "poetry"}},
+		{Title: "D"},
+	}
+
+	tests := []struct {
+		name       string
+		all, anyOf []string
+		want       string
+	}{
+		{"none", nil, nil, "ABCD"},
+		{"one tag", []string{"latin"}, nil, "AB"},
+		{"all tags", []string{"latin", "poetry"}, nil, "A"},
+		{"case-insensitive", []string{" Latin "}, nil, "AB"},
+		{"any tag", nil, []string{"greek", "latin"}, "ABC"},
+		{"all and any", []string{"poetry"}, []string{"greek"}, "C"},
+		{"no match", []string{"hebrew"}, nil, ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		for _, c := range FilterByTags(cards, tt.all, tt.anyOf) {
+			got += c.Title
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestLoadCards_TagFilterNoMatch(t *testing.T) {
+	path := createTempFile(t, "TAGS: latin\nSeize the day.")
+	defer os.Remove(path)
+
+	if _, _, err := LoadCardsWithOptions([]string{path}, LoadOptions{Tags: []string{"latin"}}); err != nil {
+		t.Errorf("Expected the tagged card to load, got %v", err)
+	}
+	_, _, err := LoadCardsWithOptions([]string{path}, LoadOptions{AnyTags: []string{"greek"}})
+	if err == nil || !strings.Contains(err.Error(), "no cards match") {
+		t.Errorf("Expected an error when no cards match, got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package game
+
+import "go-mem/internal/state"
+
+// Reveal assists that GameOptions.MixedModes picks from for each card.
+const (
+	ModeFirstLetter   = "First-Letter"
+	ModeRandomLetters = "N-Random"
+	ModeNone          = "None"
+)
+
+var mixedModes = []string{ModeFirstLetter, ModeRandomLetters, ModeNone}
+
+// mixedRandomShare is the share of its letters a card given ModeRandomLetters
+// has revealed, one in this many.
+const mixedRandomShare = 5
+
+// pickMode picks the reveal assist of a card at random, and returns it with
+// the options for playing the card with it. The assist replaces the session's
+// first letters and random letters, and the card's own OPTS: still win over it.
+func pickMode(content string, opts state.GameOptions) (string, state.GameOptions) {
+	mode := mixedModes[opts.Intn(len(mixedModes))]
+	opts.FirstLetter, opts.NRandom = false, 0
+	switch mode {
+	case ModeFirstLetter:
+		opts.FirstLetter = true
+	case ModeRandomLetters:
+		letters := 0
+		for _, r := range content {
+			if isTypable(r) {
+				letters++
+			}
+		}
+		opts.NRandom = max(letters/mixedRandomShare, 1)
+	}
+	return mode, opts
+}
+
+// modeOf returns the reveal assist that opts play a card with, for when the
+// card's own OPTS: have overridden the one picked.
+func modeOf(opts state.GameOptions) string {
+	switch {
+	case opts.FirstLetter:
+		return ModeFirstLetter
+	case opts.NRandom > 0:
+		return ModeRandomLetters
+	}
+	return ModeNone
+}
@@ -0,0 +1,111 @@
+package game
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// jsonCard is a card in a JSON card file.
+type jsonCard struct {
+	Title   string   `json:"title"`
+	Content string   `json:"content"`
+	Tags    []string `json:"tags"`
+}
+
+// loadJSONFile loads cards from a JSON array of objects with the fields of
+// jsonCard. The content is taken as it is, so it may hold lines of dashes or
+// anything else that would split a text file. Every card must have content.
+func loadJSONFile(path string) ([]CardData, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+
+	var records []json.RawMessage
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("%s: expected an array of cards: %w", path, err)
+	}
+	var cards []CardData
+	for i, raw := range records {
+		var rec jsonCard
+		if err := json.Unmarshal(raw, &rec); err != nil {
+			return nil, fmt.Errorf("%s, record %d: %w", path, i+1, err)
+		}
+		card, err := recordCard(path, rec.Title, rec.Content, rec.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("%s, record %d: %w", path, i+1, err)
+		}
+		cards = append(cards, card)
+	}
+	numberRecords(cards)
+	return cards, nil
+}
+
+// loadCSVFile loads cards from a CSV file with a title and a content column,
+// and an optional header row naming them. Quoted fields may hold line breaks.
+// Every card must have content. Errors number the records from the first
+// row, a header included.
+func loadCSVFile(path string) ([]CardData, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	// Spreadsheets often save CSV with a byte order mark, which would
+	// otherwise stick to the first field and hide the header
+	br := bufio.NewReader(file)
+	if ch, _, err := br.ReadRune(); err == nil && ch != '\ufeff' {
+		br.UnreadRune()
+	}
+	r := csv.NewReader(br)
+	r.FieldsPerRecord = 2
+	var cards []CardData
+	for i := 1; ; i++ {
+		fields, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s, record %d: %w", path, i, err)
+		}
+		if i == 1 && strings.EqualFold(fields[0], "title") && strings.EqualFold(fields[1], "content") {
+			continue
+		}
+		card, err := recordCard(path, fields[0], fields[1], nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s, record %d: %w", path, i, err)
+		}
+		cards = append(cards, card)
+	}
+	numberRecords(cards)
+	return cards, nil
+}
+
+// recordCard makes a card from the fields of a record in a JSON or CSV file.
+func recordCard(path, title, content string, tags []string) (CardData, error) {
+	content = strings.TrimSpace(strings.ReplaceAll(content, "\r\n", "\n"))
+	if content == "" {
+		return CardData{}, errors.New("the card has no content")
+	}
+	return CardData{
+		Content: content,
+		Source:  path,
+		Title:   strings.TrimSpace(title),
+		Tags:    parseTags(strings.Join(tags, ",")),
+	}, nil
+}
+
+// numberRecords numbers the cards of a file, for the titles of those without one.
+func numberRecords(cards []CardData) {
+	for i := range cards {
+		cards[i].PartIndex = i + 1
+		cards[i].TotalParts = len(cards)
+	}
+}
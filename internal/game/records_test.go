@@ -0,0 +1,114 @@
+package game
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// recordCards are cards that a text file with --- separators can't hold.
+var recordCards = []jsonCard{
+	{Title: "Rules", Content: "First part\n---\nSecond part, after a line of dashes", Tags: []string{"Poems", "long"}},
+	{Title: "Quoted", Content: "She said, \"Don't -- ever -- stop.\"\n\n--- the end"},
+	{Content: "Untitled\nwith NAME: not a header"},
+}
+
+// checkRecordCards checks that cards hold recordCards as they were written.
+func checkRecordCards(t *testing.T, cards []CardData, path string, tags bool) {
+	t.Helper()
+	if len(cards) != len(recordCards) {
+		t.Fatalf("Expected %d cards, got %d: %+v", len(recordCards), len(cards), cards)
+	}
+	for i, want := range recordCards {
+		got := cards[i]
+		if got.Title != want.Title || got.Content != want.Content || got.Source != path {
+			t.Errorf("Card %d: expected %q %q, got %q %q", i+1, want.Title, want.Content, got.Title, got.Content)
+		}
+	}
+	if tags && !slices.Equal(cards[0].Tags, []string{"poems", "long"}) {
+		t.Errorf("Expected the tags lowercased, got %q", cards[0].Tags)
+	}
+	if title := cards[2].DisplayTitle(); !strings.HasSuffix(title, " #3") {
+		t.Errorf("Expected an untitled card numbered by its place, got %q", title)
+	}
+}
+
+func TestLoadJSONFile(t *testing.T) {
+	data, err := json.Marshal(recordCards)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "deck.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// .json selects the format
+	cards, _, err := LoadCardsWithOptions([]string{path}, LoadOptions{})
+	if err != nil {
+		t.Fatalf("LoadCardsWithOptions failed: %v", err)
+	}
+	checkRecordCards(t, cards, path, true)
+}
+
+func TestLoadCSVFile(t *testing.T) {
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	w.Write([]string{"title", "content"})
+	for _, c := range recordCards {
+		w.Write([]string{c.Title, c.Content})
+	}
+	w.Flush()
+
+	// --format wins over the extension
+	path := filepath.Join(t.TempDir(), "deck.txt")
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cards, _, err := LoadCardsWithOptions([]string{path}, LoadOptions{Format: FormatCSV})
+	if err != nil {
+		t.Fatalf("LoadCardsWithOptions failed: %v", err)
+	}
+	checkRecordCards(t, cards, path, false)
+
+	// A byte order mark doesn't keep the header from being recognised
+	if err := os.WriteFile(path, []byte("\ufeff"+sb.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cards, _, err = LoadCardsWithOptions([]string{path}, LoadOptions{Format: FormatCSV})
+	if err != nil {
+		t.Fatalf("LoadCardsWithOptions failed: %v", err)
+	}
+	checkRecordCards(t, cards, path, false)
+}
+
+func TestLoadRecords_Errors(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"empty.json":  `[{"title": "A", "content": "a"}, {"title": "B", "content": "  "}]`,
+		"typed.json":  `[{"title": "A", "content": "a"}, {"title": 2, "content": "b"}]`,
+		"fields.csv":  "A,a\nB\n",
+		"content.csv": "A,a\nB,\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, _, err := LoadCardsWithOptions([]string{path}, LoadOptions{})
+		if err == nil || !strings.Contains(err.Error(), path) || !strings.Contains(err.Error(), "record 2") {
+			t.Errorf("%s: expected an error naming the file and record 2, got %v", name, err)
+		}
+	}
+
+	path := filepath.Join(dir, "object.json")
+	if err := os.WriteFile(path, []byte(`{"title": "A"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := LoadCardsWithOptions([]string{path}, LoadOptions{}); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("Expected an error for a file that isn't an array, got %v", err)
+	}
+}
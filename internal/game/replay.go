@@ -0,0 +1,132 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-mem/internal/scoring"
+	"go-mem/internal/state"
+	"go-mem/internal/ui"
+	"io"
+	"math/rand"
+	"time"
+)
+
+// ReplayResult is the outcome of replaying one game from a key log.
+type ReplayResult struct {
+	Title    string `json:"title"`
+	Score    int    `json:"score"`
+	Recorded int    `json:"recorded"` // Score when the recording of the game stopped
+	Errors   int    `json:"errors"`
+	Hints    int    `json:"hints"`
+	Win      bool   `json:"win"`
+	Match    bool   `json:"match"` // Whether every key did what it did when recorded, to the same score
+}
+
+// ReplayKeyLog plays back the games in a log written by a state.KeyLog
+// against the cards they were played on, which are found among cards by
+// their hash. Replays are never saved to the score history.
+//
+// Keys are played at speed times the pace they were recorded at, or as fast
+// as possible if speed is 0. If progress is not nil, each key is written to
+// it as it is replayed.
+func ReplayKeyLog(r io.Reader, cards []CardData, speed float64, progress io.Writer) ([]ReplayResult, error) {
+	byHash := make(map[string]CardData, len(cards))
+	for _, c := range cards {
+		byHash[scoring.TextHash(c.Content)] = c
+	}
+
+	var (
+		results []ReplayResult
+		g       *Game
+		res     ReplayResult
+		clock   time.Time
+		lastMs  int64
+	)
+	finish := func() {
+		if g == nil {
+			return
+		}
+		res.Score = g.State.Score.CurrentScore
+		res.Errors = g.State.Score.ErrorCount
+		res.Hints = g.State.Score.HintCount
+		res.Win = g.State.Win
+		res.Match = res.Match && res.Score == res.Recorded
+		results = append(results, res)
+	}
+
+	dec := json.NewDecoder(r)
+	for {
+		var e state.KeyLogEntry
+		if err := dec.Decode(&e); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return results, fmt.Errorf("failed to read key log: %w", err)
+		}
+
+		if e.Kind == state.KeyLogCard {
+			finish()
+			if e.Card == nil {
+				return results, fmt.Errorf("key log has a game without its card")
+			}
+			card, ok := byHash[e.Card.Hash]
+			if !ok {
+				return results, fmt.Errorf("card %q is not among the cards given", e.Card.Title)
+			}
+			var err error
+			if g, err = newReplayGame(card, *e.Card, func() time.Time { return clock }); err != nil {
+				return results, err
+			}
+			res = ReplayResult{Title: e.Card.Title, Match: true}
+			clock, lastMs = time.Time{}, 0
+			continue
+		}
+		if g == nil {
+			return results, fmt.Errorf("key log doesn't start with a card")
+		}
+
+		if speed > 0 && e.Ms > lastMs {
+			time.Sleep(time.Duration(float64(e.Ms-lastMs)/speed) * time.Millisecond)
+		}
+		clock, lastMs = time.Time{}.Add(time.Duration(e.Ms)*time.Millisecond), e.Ms
+
+		switch e.Kind {
+		case state.KeyLogKey:
+			outcome := g.keyOutcome(func() { g.HandleKeyPress(e.Key) })
+			if outcome != e.Outcome {
+				res.Match = false
+			}
+			if progress != nil {
+				fmt.Fprintf(progress, "%7.1fs  %-10q %s\n", float64(e.Ms)/1000, e.Key, outcome)
+			}
+		case state.KeyLogTick:
+			g.HandleTick()
+		default:
+			return results, fmt.Errorf("unknown key log entry: %q", e.Kind)
+		}
+		if g.State.Score.CurrentScore != e.Score {
+			res.Match = false
+		}
+		res.Recorded = e.Score
+	}
+	finish()
+	return results, nil
+}
+
+// newReplayGame sets up a recorded game again, on a clock that follows the recording.
+func newReplayGame(card CardData, ref state.KeyLogCardRef, now func() time.Time) (*Game, error) {
+	opts := ref.Options.GameOptions()
+	opts.TimerLimit = ref.TimeLimit
+	opts.Rand = rand.New(rand.NewSource(ref.Seed))
+
+	sc, err := scoring.InitScoring(card.Content, ref.Title, discardStorage{})
+	if err != nil {
+		return nil, err
+	}
+	g := &Game{
+		State: state.NewStateWithDisplay(card.Content, ui.LongestLineLen(card.Content), &state.MemoryDisplay{}, *sc, opts),
+	}
+	g.State.Now = now
+	g.Init()
+	return g, nil
+}
@@ -0,0 +1,110 @@
+package game
+
+import (
+	"bytes"
+	"encoding/json"
+	"go-mem/internal/state"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplayKeyLog_RoundTrip(t *testing.T) {
+	cards := []CardData{
+		{Content: "The quick brown fox", Source: "a"},
+		{Content: "jumps over [the] lazy dog", Source: "b"},
+	}
+
+	var buf bytes.Buffer
+	log := state.NewKeyLog(&buf)
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	log.Now = func() time.Time { return clock }
+	opts := state.GameOptions{
+		TimerLimit: 60,
+		NRandom:    3,
+		Rand:       rand.New(rand.NewSource(7)),
+		Record:     log,
+	}
+	sess, err := NewSession(cards, opts, &MockStorage{}, InOrder)
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+
+	// A scripted game of each card, with a mistake, a hint and some ticks
+	var scores []int
+	for range cards {
+		g := sess.CurrentGame
+		press := func(key string) {
+			clock = clock.Add(350 * time.Millisecond)
+			g.HandleKeyPress(key)
+		}
+		mistaken, hinted := false, false
+		for i := 0; !g.State.Win && !g.State.Loss; i++ {
+			switch {
+			case i >= 8 && !mistaken && g.State.Mask[g.State.Pos] == '_':
+				press("7")
+				mistaken = true
+			case mistaken && !hinted:
+				press("?")
+				hinted = true
+			case i%4 == 0:
+				g.HandleTick()
+				fallthrough
+			default:
+				press(string(g.State.Secret[g.State.Pos]))
+			}
+		}
+		if !g.State.Win {
+			t.Fatalf("Expected the scripted game to be won, mask %q", string(g.State.Mask))
+		}
+		scores = append(scores, g.State.Score.CurrentScore)
+		sess.Update()
+		if _, err := sess.AdvanceOrEnd(); err != nil {
+			t.Fatalf("AdvanceOrEnd failed: %v", err)
+		}
+	}
+
+	outcomes := map[string]int{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var e state.KeyLogEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("Bad key log line %q: %v", line, err)
+		}
+		outcomes[e.Outcome]++
+	}
+	if outcomes[state.OutcomeMismatch] != 2 || outcomes[state.OutcomeHint] != 2 || outcomes[state.OutcomeMatch] == 0 {
+		t.Errorf("Expected a mismatch and a hint per game, got %v", outcomes)
+	}
+
+	results, err := ReplayKeyLog(bytes.NewReader(buf.Bytes()), cards, 0, nil)
+	if err != nil {
+		t.Fatalf("ReplayKeyLog failed: %v", err)
+	}
+	if len(results) != len(cards) {
+		t.Fatalf("Expected %d games replayed, got %d", len(cards), len(results))
+	}
+	for i, res := range results {
+		if !res.Match || !res.Win || res.Score != scores[i] || res.Recorded != scores[i] {
+			t.Errorf("Game %d: expected a matching win scoring %d, got %+v", i, scores[i], res)
+		}
+		if res.Errors != 1 || res.Hints != 1 {
+			t.Errorf("Game %d: expected 1 error and 1 hint, got %+v", i, res)
+		}
+	}
+
+	// A log that doesn't match the card is reported, not passed
+	tampered := strings.Replace(buf.String(), `"outcome":"mismatch"`, `"outcome":"match"`, 1)
+	results, err = ReplayKeyLog(strings.NewReader(tampered), cards, 0, nil)
+	if err != nil {
+		t.Fatalf("ReplayKeyLog failed: %v", err)
+	}
+	if results[0].Match || !results[1].Match {
+		t.Errorf("Expected only the first game to differ, got %+v", results)
+	}
+
+	// The cards must be given to replay against
+	if _, err := ReplayKeyLog(bytes.NewReader(buf.Bytes()), cards[1:], 0, nil); err == nil {
+		t.Error("Expected an error for a card that wasn't given")
+	}
+}
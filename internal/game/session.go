@@ -1,14 +1,86 @@
 package game
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
 	"go-mem/internal/scoring"
 	"go-mem/internal/state"
+	"go-mem/internal/ui"
 	"math/rand"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/bubbles/textarea"
 )
 
+// CardResult records the outcome of a single completed card in a session.
+type CardResult struct {
+	Index     int // Position of the card in the session
+	Title     string
+	Player    string // Empty outside versus mode
+	Score     int
+	Errors    int
+	Correct   int // Letters typed right
+	Hints     int
+	HighScore bool
+	Started   time.Time // When the card started
+	Finished  time.Time // When it was won
+}
+
+// CardOrder controls the order in which a session presents its cards.
+type CardOrder int
+
+const (
+	InOrder      CardOrder = iota // As loaded
+	RandomOrder                   // Shuffled
+	ReverseOrder                  // Last card first
+	SortByTitle                   // By display title
+	SortByLength                  // Shortest text first
+	SortBySource                  // By file path
+)
+
+// ParseSortOrder returns the CardOrder for a --sort key.
+func ParseSortOrder(key string) (CardOrder, error) {
+	switch key {
+	case "title":
+		return SortByTitle, nil
+	case "length":
+		return SortByLength, nil
+	case "source":
+		return SortBySource, nil
+	}
+	return InOrder, fmt.Errorf("unknown sort key: %s (use title, length or source)", key)
+}
+
+// drillContext is the number of words either side of a mistake that a drill includes.
+const drillContext = 2
+
+// AttemptLimitError is returned when starting a card that has already been
+// played GameOptions.MaxAttemptsPerDay times today.
+type AttemptLimitError struct {
+	Title    string
+	Attempts int
+	Resets   time.Time // The next local midnight, when the card can be played again
+}
+
+func (e *AttemptLimitError) Error() string {
+	return fmt.Sprintf("%s has already been played %d times today; it can be played again from %s",
+		e.Title, e.Attempts, e.Resets.Format("Mon Jan 2 15:04"))
+}
+
+// SessionOutcome describes what happens to the session once the current game is over.
+type SessionOutcome int
+
+const (
+	Continue        SessionOutcome = iota // Another card follows
+	SessionComplete                       // Every card has been played
+	SessionLost                           // The session ended early (timer expiry or score loss)
+)
+
 type Session struct {
 	Cards        []CardData
 	CurrentIndex int
@@ -20,26 +92,98 @@ type Session struct {
 	TotalScore     int
 	TotalTimeLimit int
 	TimeRemaining  int
+	Results        []CardResult
+	StartedAt      time.Time        // When the session was created
+	Now            func() time.Time // Clock for the elapsed time and the day attempts are counted on (replaceable in tests)
 
 	// Batch State
-	IsBatch   bool
-	Randomize bool
+	IsBatch bool
+	Order   CardOrder
+
+	// Skipped holds the titles of the cards skipped in batch mode for having
+	// been played GameOptions.MaxAttemptsPerDay times today, in play order.
+	Skipped []string
+
+	// Passed holds the titles of the cards the player skipped with Skip, in
+	// play order. They aren't scored.
+	Passed []string
+
+	// DrillMistakes follows each card won with errors by a drill of just the
+	// parts that were mistyped. Drills don't count towards the totals.
+	DrillMistakes bool
+
+	// Chain asks for the first word of each card after the first from
+	// memory before it is played, for learning the cards' order. The
+	// question is ChainPrompt, nil while there is none. Its points go to
+	// the session total, not the card's score, and are counted in ChainScore.
+	Chain       bool
+	ChainPrompt *ChainPrompt
+	ChainScore  int
+
+	// Versus State: each card is played once per player, in turn.
+	// Each player has their own time budget and running total.
+	Players       []string
+	CurrentPlayer int
+	PlayerTotals  []int
+
+	// By-line State: with GameOptions.ByLine a card of several lines is
+	// played a line at a time, each line a game of its own that carries on
+	// the card's score, time and history entry.
+	Lines []string // The current card's lines, nil when it is played whole
+	Line  int      // Index in Lines of the line being played
+
+	// Mode is the reveal assist picked for the current card with
+	// GameOptions.MixedModes, one of the Mode constants, or "" without it.
+	Mode string
+
+	linesSaved    bool         // Whether the card has been saved as a whole
+	cardState     *state.State // The whole of a card played by line, with its reveals and the errors made on its lines so far
+	lineMasks     [][]rune     // The mask each of Lines starts from, cut from cardState's; nil if it couldn't be cut to fit
+	lineStarts    []int        // Where each of Lines starts in cardState's secret
+	cardStartedAt time.Time    // When the card, or its first line, started
+
+	playerTime     []int           // Time remaining per player
+	playerOut      []bool          // Players whose run ended on a timer or score loss
+	resultRecorded bool            // Whether the current game's result has been added to Results
+	cardTimes      []time.Duration // How long each card won took, flash cards included, for ETA
+	aggregateSaved bool            // Whether SaveAggregate has saved the session's entry
+}
+
+func NewSession(cards []CardData, opts state.GameOptions, storage scoring.ScoreStorage, order CardOrder) (*Session, error) {
+	return newSession(cards, opts, storage, order, nil)
+}
+
+// NewVersusSession creates a hot-seat session in which every card is played
+// by each of the players in turn.
+func NewVersusSession(cards []CardData, opts state.GameOptions, storage scoring.ScoreStorage, order CardOrder, players []string) (*Session, error) {
+	if len(players) < 2 {
+		return nil, fmt.Errorf("versus mode needs at least two players, got %d", len(players))
+	}
+	return newSession(cards, opts, storage, order, players)
 }
 
-func NewSession(cards []CardData, opts state.GameOptions, storage scoring.ScoreStorage, randomize bool) (*Session, error) {
+// sessionClock is the clock new sessions start with, replaceable in tests that
+// need it before the first card is dealt.
+var sessionClock = time.Now
+
+func newSession(cards []CardData, opts state.GameOptions, storage scoring.ScoreStorage, order CardOrder, players []string) (*Session, error) {
 	s := &Session{
 		Cards:        cards,
 		GameOptions:  opts,
 		ScoreStorage: storage,
 		IsBatch:      len(cards) > 1,
-		Randomize:    randomize,
+		Order:        order,
+		Players:      players,
+		PlayerTotals: make([]int, len(players)),
+		playerOut:    make([]bool, len(players)),
+		StartedAt:    sessionClock(),
+		Now:          sessionClock,
 	}
 
-	// Randomize if requested AND batch mode
-	if s.IsBatch && s.Randomize {
-		rand.Shuffle(len(s.Cards), func(i, j int) {
-			s.Cards[i], s.Cards[j] = s.Cards[j], s.Cards[i]
-		})
+	// Reorder if requested AND batch mode.
+	// Cards keep their PartIndex, so titles still show the original numbering.
+	if s.IsBatch {
+		s.orderCards(s.Cards)
 	}
 
 	// Calculate Total Time Limit
@@ -47,26 +191,10 @@ func NewSession(cards []CardData, opts state.GameOptions, storage scoring.ScoreS
 		// Fixed time for the whole batch
 		s.TotalTimeLimit = opts.TimerLimit
 	} else if opts.TimerLimit == -1 {
-		// Auto calculate sum of all cards
-		totalLen := 0
-		for _, c := range cards {
-			totalLen += len(c.Content)
-		}
-		// Logic: len * 2 seconds. Min 10 * num_cards?
-		// Or just simple sum.
-		// state.NewState uses max(10, len*2).
-		// We should replicate that per card or just use total len?
-		// "calculated ... for all the cards".
-		// Let's sum the individual auto-calcs to be generous.
-
-		totalTime := 0
-		for _, c := range cards {
-			// Length / 3 seconds.
-			l := len(c.Content) / 3
-			if l < 10 {
-				l = 10
-			}
-			totalTime += l
+		// Auto: sum the limits suggested for each card
+		totalTime, err := s.autoTimeLimit(cards)
+		if err != nil {
+			return nil, err
 		}
 		s.TotalTimeLimit = totalTime
 	} else {
@@ -75,21 +203,43 @@ func NewSession(cards []CardData, opts state.GameOptions, storage scoring.ScoreS
 	}
 
 	s.TimeRemaining = s.TotalTimeLimit
+	s.playerTime = make([]int, len(players))
+	for i := range s.playerTime {
+		s.playerTime[i] = s.TotalTimeLimit
+	}
 
 	// Initialize first game
-	if err := s.NextGame(); err != nil {
+	started, err := s.startPlayable(-1)
+	if err != nil {
 		return nil, err
 	}
+	if !started {
+		return nil, fmt.Errorf("every card has already been played %d times today", opts.MaxAttemptsPerDay)
+	}
 
 	return s, nil
 }
 
+// NextGame starts the game for the current card.
 func (s *Session) NextGame() error {
+	if err := s.newGame(); err != nil {
+		return err
+	}
+	s.startGame()
+	return nil
+}
+
+// newGame sets up the game for the current card as CurrentGame, ready to be
+// started with startGame.
+func (s *Session) newGame() error {
 	if s.CurrentIndex >= len(s.Cards) {
 		return fmt.Errorf("no more cards")
 	}
 
 	card := s.Cards[s.CurrentIndex]
+	if s.IsVersus() {
+		s.TimeRemaining = s.playerTime[s.CurrentPlayer]
+	}
 
 	// Construct options for this specific game
 	gameOpts := s.GameOptions
@@ -100,83 +250,805 @@ func (s *Session) NextGame() error {
 	// NewGame -> NewState sets TimeLimit = passed value.
 	// So Card 2 starts with TimeLimit = 50 (if 50 remained).
 	// This works.
-	if s.TotalTimeLimit > 0 {
+	if s.TotalTimeLimit > 0 && !card.Drill {
 		gameOpts.TimerLimit = s.TimeRemaining
 	} else {
 		gameOpts.TimerLimit = 0
 	}
+	// Drills are practice: they start from nothing, so a low score mustn't
+	// lose them, and with them the batch
+	if card.Drill {
+		gameOpts.NoScoreFloor = true
+	}
+	// With mixed modes each card gets a reveal assist of its own; drills are played as they are
+	s.Mode = ""
+	if gameOpts.MixedModes && !card.Drill {
+		s.Mode, gameOpts = pickMode(card.Content, gameOpts)
+	}
+	// The card's OPTS: win over the session's, and the assist picked for it.
+	// A timer of its own runs apart from the session clock.
+	gameOpts = card.Options.Apply(gameOpts)
+	if s.Mode != "" {
+		s.Mode = modeOf(gameOpts)
+	}
 
-	// Title logic
-	title := card.Title
-	if title == "" {
-		title = card.Source
-		if card.TotalParts > 1 {
-			title = fmt.Sprintf("%s #%d", title, card.PartIndex)
-		}
+	// A card played by line isn't recorded, and the ghost and shading, which
+	// go by word, are left out.
+	s.Lines, s.Line, s.linesSaved = cardLines(card, gameOpts), 0, false
+	s.cardState, s.lineMasks, s.lineStarts = nil, nil, nil
+	if s.Lines != nil {
+		gameOpts.Record = nil
+		gameOpts.Ghost = false
+		gameOpts.NoConfidence = true
 	}
 
-	ta := textarea.New()
-	ta.ShowLineNumbers = false
-	ta.CharLimit = len(card.Content)
-	ta.Prompt = " " // We render manually, but just in case.
+	title := s.scoreTitle(card)
+	storage := s.ScoreStorage
+	if card.Drill {
+		storage = discardStorage{}
+	}
+
+	ta := newTextarea(len(card.Content))
 
-	sc, err := scoring.InitScoring(card.Content, title, s.ScoreStorage)
+	sc, err := scoring.InitPlayerScoring(card.Content, title, s.PlayerName(), storage)
 	if err != nil {
 		return err
 	}
 	// Inherit score? No, Scoring is per card.
 	// We aggregate manually.
 
-	g := NewGame(card.Content, 0, ta, *sc, gameOpts)
-	// Note: cardWidth is passed as 0 here?
-	// main.go calculated cardWidth for styling.
-	// Game uses cardWidth for... setting Textarea width in Update?
-	// And State uses it.
-	// We need to calculate cardWidth for each card!
-	// main.go had `longestLineLen`.
-	// I should duplicate that helper here or export it.
-	// I'll calculate it inline for now.
+	if limit := s.GameOptions.MaxAttemptsPerDay; limit > 0 && !card.Drill {
+		now := s.Now()
+		if n := sc.AttemptsToday(now); n >= limit {
+			year, month, day := now.Date()
+			return &AttemptLimitError{
+				Title:    card.DisplayTitle(),
+				Attempts: n,
+				Resets:   time.Date(year, month, day+1, 0, 0, 0, 0, now.Location()),
+			}
+		}
+	}
 
-	cw := longestLineLen(card.Content) + 1
-	g.State.CardWidth = cw
+	// A recorded game draws its reveals from a seed of its own, so that it
+	// can be replayed without the games before it. Drills aren't recorded.
+	var seed int64
+	if card.Drill {
+		gameOpts.Record = nil
+	} else if gameOpts.Record != nil {
+		seed = rand.Int63()
+		if gameOpts.Rand != nil {
+			seed = gameOpts.Rand.Int63()
+		}
+		gameOpts.Rand = rand.New(rand.NewSource(seed))
+	}
 
-	g.Init()
+	cw := ui.ComputeCardWidth(card.Content, ui.BannerText(card.DisplayTitle(), card.Source))
+
+	// A card played by line has its reveals drawn for the whole card, and
+	// starts with the first line left with something to type
+	secret := card.Content
+	var startMask []rune
+	if s.Lines != nil {
+		s.drawLines(card.Content, cw, sc, gameOpts)
+		if s.Line = s.nextLine(-1); s.Line < 0 {
+			s.Line = playableLine(s.Lines, -1)
+		}
+		secret = s.Lines[s.Line]
+		gameOpts.MoreLines = s.nextLine(s.Line) >= 0
+		if s.lineMasks != nil {
+			startMask = s.lineMasks[s.Line]
+		}
+	}
 
+	g := NewGame(secret, cw, ta, *sc, gameOpts)
+	g.State.StartMask = startMask
+	if gameOpts.Record != nil {
+		// Adaptive reveals are recorded as the count they came to, as the
+		// replay doesn't have the score history they were drawn from
+		logOpts := gameOpts.KeyLogOptions()
+		if gameOpts.Adaptive {
+			logOpts.NRandom += g.State.AdaptiveReveals()
+		}
+		gameOpts.Record.StartCard(state.KeyLogCardRef{
+			Hash:      scoring.TextHash(card.Content),
+			Title:     card.DisplayTitle(),
+			Seed:      seed,
+			TimeLimit: g.State.TimeLimit,
+			Options:   logOpts,
+		})
+	}
 	s.CurrentGame = g
+	s.resultRecorded = false
 	return nil
 }
 
+// startGame starts the current card's game: its board is set up and its
+// clock starts.
+func (s *Session) startGame() {
+	s.CurrentGame.Init()
+	s.cardStartedAt = s.Now()
+}
+
+// newTextarea returns the display for a game of up to limit characters.
+func newTextarea(limit int) textarea.Model {
+	ta := textarea.New()
+	ta.ShowLineNumbers = false
+	ta.CharLimit = limit
+	ta.Prompt = " " // We render manually, but just in case.
+	return ta
+}
+
+// cardLines returns the lines of a card that is played by line, or nil if it
+// is played whole: by-line play is off, or the card is a drill, isn't typed
+// letter by letter, or has fewer than two lines with anything to type.
+func cardLines(card CardData, opts state.GameOptions) []string {
+	if !opts.ByLine || card.Drill || opts.Flash || opts.Recall {
+		return nil
+	}
+	lines := strings.Split(card.Content, "\n")
+	if first := playableLine(lines, -1); first < 0 || playableLine(lines, first) < 0 {
+		return nil
+	}
+	return lines
+}
+
+// playableLine returns the index of the first line after the one at i that
+// has a letter or digit to type, or -1 if there is none. Blank lines are
+// only shown.
+func playableLine(lines []string, i int) int {
+	for i++; i < len(lines); i++ {
+		if strings.ContainsFunc(lines[i], isTypable) {
+			return i
+		}
+	}
+	return -1
+}
+
+func isTypable(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// drawLines draws the reveals of a card played by line once, for the whole
+// card, as cardState, so that its lines reveal no more between them than the
+// card played whole, and the card's score takes the multiplier of the whole.
+// The mask is cut at the line breaks into lineMasks, one per line, unless it
+// can't be cut to fit them, as when brackets span lines.
+func (s *Session) drawLines(content string, cw int, sc *scoring.Scoring, opts state.GameOptions) {
+	cs := state.NewState(content, cw, newTextarea(len(content)), *sc, opts)
+	cs.SetBracketedPositions()
+	cs.InitMask()
+	cs.ApplyGameModes(opts)
+	sc.SetMultiplier(cs.HiddenShare())
+	cs.Score.SetMultiplier(cs.HiddenShare())
+	s.cardState = cs
+
+	var masks [][]rune
+	starts := []int{0}
+	for i, r := range cs.Secret {
+		if r == '\n' {
+			masks = append(masks, cs.Mask[starts[len(starts)-1]:i])
+			starts = append(starts, i+1)
+		}
+	}
+	masks = append(masks, cs.Mask[starts[len(starts)-1]:])
+	if len(masks) == len(s.Lines) {
+		s.lineMasks, s.lineStarts = masks, starts
+	}
+}
+
+// nextLine returns the index of the first line after the one at i that has
+// something left to type, or -1 if there is none. Blank lines, and lines the
+// reveals gave away in full, are only shown.
+func (s *Session) nextLine(i int) int {
+	for i = playableLine(s.Lines, i); i >= 0; i = playableLine(s.Lines, i) {
+		if s.lineMasks == nil || slices.Contains(s.lineMasks[i], '_') {
+			return i
+		}
+	}
+	return -1
+}
+
+// addLineErrors adds the errors made on the current line of a card played by
+// line to cardState's, at their places in the whole card.
+func (s *Session) addLineErrors() {
+	if s.lineMasks == nil {
+		return
+	}
+	for pos := range s.CurrentGame.State.ErrorPositions {
+		s.cardState.ErrorPositions[s.lineStarts[s.Line]+pos] = true
+	}
+}
+
+// advanceLine starts the next line of a card played by line, once the
+// current one has been won. Its game carries on from the last: the card's
+// score and history entry, the time left and the hints left. It returns
+// false if the card has no more lines.
+func (s *Session) advanceLine() bool {
+	next := s.nextLine(s.Line)
+	if next < 0 {
+		return false
+	}
+	prev := s.CurrentGame.State
+
+	opts := prev.Options
+	opts.TimerLimit = 0
+	if prev.TimerEnabled {
+		opts.TimerLimit = prev.TimeRemaining
+	}
+	opts.Preview, opts.Grace = 0, 0
+	opts.MoreLines = s.nextLine(next) >= 0
+
+	g := NewGame(s.Lines[next], prev.CardWidth, newTextarea(len(s.Lines[next])), prev.Score, opts)
+	g.State.HintsLeft = prev.HintsLeft
+	if s.lineMasks != nil {
+		g.State.StartMask = s.lineMasks[next]
+	}
+	g.Init()
+
+	s.Line = next
+	s.CurrentGame = g
+	return true
+}
+
+// saveLines saves the score of a card played by line, once it has ended, as
+// the card's. The play time is the card's, a win's share of the most it could
+// score is of the whole card, and the word errors are those of all its lines.
+// The word timings are left out, as the games of its lines time words by line.
+func (s *Session) saveLines() error {
+	st := s.CurrentGame.State
+	sc := &st.Score
+	sc.SetWordTimings(nil)
+	sc.SetWordOffsets(nil)
+	sc.SetWordErrors(nil)
+	if s.lineMasks != nil {
+		sc.SetWordErrors(s.cardState.WordErrors())
+	}
+	sc.SetPlayTime(s.Now().Sub(s.cardStartedAt))
+	if st.Win {
+		sc.SetDuration(s.Now().Sub(s.cardStartedAt))
+		sc.SetPercentOfMax(s.MaxScore())
+	}
+	return sc.SaveEntries()
+}
+
+// MaxScore returns the most the current card could score, all of its lines
+// for a card played by line.
+func (s *Session) MaxScore() int {
+	st := s.CurrentGame.State
+	if s.Lines == nil {
+		return st.MaxScore()
+	}
+	return s.cardState.MaxScore()
+}
+
+// LineContext returns the lines around the one being played of a card played
+// by line: those before it as they are, and those after it with their
+// letters and digits masked, or as they are while the card is previewed or
+// once it has been revealed. Both are nil for a card played whole.
+func (s *Session) LineContext() (before, after []string) {
+	if s.Lines == nil || s.CurrentGame == nil {
+		return nil, nil
+	}
+	st := s.CurrentGame.State
+	show := st.IsPreviewing() || st.Revealed
+	for _, line := range s.Lines[s.Line+1:] {
+		if !show {
+			line = strings.Map(func(r rune) rune {
+				if isTypable(r) {
+					return '_'
+				}
+				return r
+			}, line)
+		}
+		after = append(after, line)
+	}
+	return s.Lines[:s.Line], after
+}
+
+// startPlayable starts the game for the current card, the one after the card
+// at prevIndex. In batch mode, cards that have reached the daily attempt
+// limit are skipped; it returns false if that left none to play. In chain
+// mode the game waits for the card's first word to be recalled.
+func (s *Session) startPlayable(prevIndex int) (bool, error) {
+	for !s.IsFinished() {
+		err := s.newGame()
+		var limitErr *AttemptLimitError
+		if !s.IsBatch || !errors.As(err, &limitErr) {
+			if err == nil && !s.startChain(prevIndex) {
+				s.startGame()
+			}
+			return err == nil, err
+		}
+		s.Skipped = append(s.Skipped, limitErr.Title)
+		s.CurrentIndex++
+	}
+	return false, nil
+}
+
+// Elapsed returns the wall-clock time since the session started, whatever
+// the timer is doing.
+func (s *Session) Elapsed() time.Duration {
+	return s.Now().Sub(s.StartedAt)
+}
+
 func (s *Session) Update() {
 	// Sync session state from current game
 	if s.CurrentGame == nil {
 		return
 	}
 
-	// Sync Timer
-	if s.TotalTimeLimit > 0 {
+	st := s.CurrentGame.State
+
+	// Sync Timer (drills are untimed, and cards with their own timer leave
+	// the session clock alone)
+	if s.TotalTimeLimit > 0 && st.TimerEnabled && !s.Cards[s.CurrentIndex].Options.HasOwnTimer() {
 		// The game's timer ticked down.
 		// We update our master TimeRemaining.
 		s.TimeRemaining = s.CurrentGame.State.TimeRemaining
+		if s.IsVersus() {
+			s.playerTime[s.CurrentPlayer] = s.TimeRemaining
+		}
+	}
+
+	if s.IsVersus() && st.Loss && !st.Revealed {
+		// In versus mode a timer or score loss only ends that player's run
+		s.playerOut[s.CurrentPlayer] = true
+	}
+
+	// A card played by line goes straight on to its next line, and is saved
+	// as a whole once it ends
+	if s.Lines != nil && (st.Win || st.Loss) && !s.linesSaved {
+		s.addLineErrors()
+		if st.Win && s.advanceLine() {
+			return
+		}
+		s.linesSaved = true
+		s.saveLines()
 	}
 
-	// Check Win
-	if s.CurrentGame.State.Win {
+	// Check Win (only record each game once, Update may be called again before advancing)
+	if st.Win && !s.resultRecorded {
+		s.resultRecorded = true
+		if !s.Cards[s.CurrentIndex].Drill {
+			s.cardTimes = append(s.cardTimes, s.Now().Sub(s.cardStartedAt))
+		}
+		// Drills and flash mode reading aren't scored
+		if s.Cards[s.CurrentIndex].Drill || s.GameOptions.Flash {
+			return
+		}
+		sc := &s.CurrentGame.State.Score
+
 		// Add score
-		s.TotalScore += s.CurrentGame.State.Score.CurrentScore
+		s.TotalScore += sc.CurrentScore
+		if s.IsVersus() {
+			s.PlayerTotals[s.CurrentPlayer] += sc.CurrentScore
+		}
+		s.Results = append(s.Results, CardResult{
+			Index:     s.CurrentIndex,
+			Title:     scoreTitle(s.Cards[s.CurrentIndex]),
+			Player:    s.PlayerName(),
+			Score:     sc.CurrentScore,
+			Errors:    sc.ErrorCount,
+			Correct:   sc.CorrectCount,
+			Hints:     sc.HintCount,
+			HighScore: sc.GotHighScore(),
+			Started:   s.cardStartedAt,
+			Finished:  s.Now(),
+		})
+
+		s.queueDrill()
 
 		// Note: We used to advance automatically here.
 		// Now we leave the session in this state and let the main loop advance it.
 	}
 }
 
+// orderCards puts cards in the session's presentation order.
+func (s *Session) orderCards(cards []CardData) {
+	switch s.Order {
+	case RandomOrder:
+		s.GameOptions.Shuffle(len(cards), func(i, j int) {
+			cards[i], cards[j] = cards[j], cards[i]
+		})
+	case ReverseOrder:
+		slices.Reverse(cards)
+	case SortByTitle, SortByLength, SortBySource:
+		sortCards(cards, s.Order)
+	}
+}
+
+// Reload replaces the cards still to come with a fresh load of the card files,
+// for picking up edits mid-session. The cards played so far, the current one
+// and any drill queued after it stay where they are, and cards with the same
+// text as one of them are left out. It returns how many of the cards to come
+// are new or changed. With the auto timer the time limit is worked out again
+// for the cards to come, and the time they gain or lose is added to the time
+// remaining; if that fails, the cards are still reloaded.
+func (s *Session) Reload(cards []CardData) (int, error) {
+	keep := min(s.CurrentIndex+1, len(s.Cards))
+	for keep < len(s.Cards) && s.Cards[keep].Drill {
+		keep++
+	}
+
+	played := make(map[string]bool)
+	for _, c := range s.Cards[:keep] {
+		played[c.Content] = true
+	}
+	upcoming := make(map[string]bool)
+	for _, c := range s.Cards[keep:] {
+		upcoming[c.Content] = true
+	}
+
+	var rest []CardData
+	changed := 0
+	for _, c := range cards {
+		if played[c.Content] {
+			continue
+		}
+		if !upcoming[c.Content] {
+			changed++
+		}
+		rest = append(rest, c)
+	}
+	s.orderCards(rest)
+
+	var err error
+	if s.GameOptions.TimerLimit == -1 {
+		err = s.retime(s.Cards[keep:], rest)
+	}
+	s.Cards = append(s.Cards[:keep:keep], rest...)
+	s.IsBatch = s.IsBatch || len(s.Cards) > 1
+	return changed, err
+}
+
+// retime adjusts the auto time limit for the cards to come changing from
+// before to after, by the difference between the limits suggested for them.
+// The time remaining, each player's in versus mode, changes with it, but
+// never runs out for it.
+func (s *Session) retime(before, after []CardData) error {
+	was, err := s.autoTimeLimit(before)
+	if err != nil {
+		return err
+	}
+	now, err := s.autoTimeLimit(after)
+	if err != nil {
+		return err
+	}
+	delta := now - was
+	s.TotalTimeLimit = max(s.TotalTimeLimit+delta, 1)
+	s.TimeRemaining = max(s.TimeRemaining+delta, 1)
+	for i := range s.playerTime {
+		s.playerTime[i] = max(s.playerTime[i]+delta, 1)
+	}
+	return nil
+}
+
+// autoTimeLimit sums the time limits the auto timer suggests for cards, from
+// the WPM target if there is one, or the player's best times where there are
+// any. Drills and cards with a timer of their own are left out. A scores file
+// with a corrupt tail still gives the entries before it.
+func (s *Session) autoTimeLimit(cards []CardData) (int, error) {
+	entries, err := s.ScoreStorage.LoadAll()
+	if err != nil && !errors.Is(err, scoring.ErrCorruptScores) {
+		return 0, fmt.Errorf("could not load score history: %w", err)
+	}
+	total := 0
+	for _, c := range cards {
+		if c.Drill || c.Options.HasOwnTimer() {
+			continue
+		}
+		total += s.GameOptions.AutoTimeLimit(c.Content, entries)
+	}
+	return total, nil
+}
+
+// Replay starts the current card over as a fresh game with the full time
+// limit, for playing a single card again and again. If the card was followed by
+// a drill, it is the card that is replayed; drills are dropped.
+func (s *Session) Replay() error {
+	for s.CurrentIndex > 0 && s.Cards[s.CurrentIndex].Drill {
+		s.CurrentIndex--
+	}
+	s.Cards = slices.DeleteFunc(s.Cards, func(c CardData) bool { return c.Drill })
+	s.TimeRemaining = s.TotalTimeLimit
+	return s.NextGame()
+}
+
+// queueDrill inserts a mistake drill for the current card right after it, if
+// drills are enabled and the card was played with errors.
+func (s *Session) queueDrill() {
+	if !s.DrillMistakes || s.IsVersus() {
+		return
+	}
+	// A card played by line is drilled on the mistakes of all its lines
+	st := s.CurrentGame.State
+	if s.lineMasks != nil {
+		st = s.cardState
+	}
+	secret := st.MistakeDrill(drillContext)
+	if secret == "" {
+		return
+	}
+
+	card := s.Cards[s.CurrentIndex]
+	drill := CardData{
+		Content: secret,
+		Source:  card.Source,
+		Title:   card.DisplayTitle() + " — drill",
+		Drill:   true,
+	}
+	s.Cards = slices.Insert(s.Cards, s.CurrentIndex+1, drill)
+}
+
+// SaveAggregate saves the session's total score as an entry of its own, for
+// the deck as a whole, so that the best full run through a deck is kept. It
+// is called when a batch is completed, and saves once; single cards, versus
+// sessions, flash mode and batches with cards skipped by the player aren't
+// saved. The deck is the cards that were played, leaving out those skipped
+// for their attempt limit.
+func (s *Session) SaveAggregate() error {
+	if !s.IsBatch || s.IsVersus() || s.GameOptions.Flash || len(s.Passed) > 0 || s.aggregateSaved {
+		return nil
+	}
+	s.aggregateSaved = true
+
+	var texts []string
+	for _, r := range s.Results {
+		if c := s.Cards[r.Index]; !c.Drill {
+			texts = append(texts, c.Content)
+		}
+	}
+	if len(texts) == 0 {
+		return nil
+	}
+	err := scoring.SaveEntry(s.ScoreStorage, scoring.ScoreHistoryEntry{
+		Hash:        scoring.DeckHash(texts),
+		Score:       s.TotalScore,
+		Timestamp:   s.Now().Format(time.RFC3339),
+		Title:       fmt.Sprintf("Deck of %d cards", len(texts)),
+		DurationSec: int(s.Elapsed().Seconds()),
+		Outcome:     scoring.OutcomeWon,
+		HashVersion: scoring.HashVersion,
+	})
+	if err != nil {
+		return fmt.Errorf("could not save the deck's score: %w", err)
+	}
+	return nil
+}
+
+// TotalErrors returns the sum of errors across all completed cards.
+func (s *Session) TotalErrors() int {
+	total := 0
+	for _, r := range s.Results {
+		total += r.Errors
+	}
+	return total
+}
+
+// Outcome reports the outcome the current game leads to, without advancing.
+// The boolean is false while the current game is still in progress.
+func (s *Session) Outcome() (SessionOutcome, bool) {
+	if s.IsFinished() || s.CurrentGame == nil {
+		return SessionComplete, true
+	}
+
+	if !s.CurrentGame.IsOver() {
+		return Continue, false
+	}
+	st := s.CurrentGame.State
+
+	// Revealing a card with Ctrl+R gives up on that card only.
+	// Any other loss (timer expiry, score below zero) ends the whole batch,
+	// or in versus mode just that player's run.
+	if st.Loss && !st.Revealed && !s.IsVersus() {
+		return SessionLost, true
+	}
+	if s.IsLastGame() {
+		return SessionComplete, true
+	}
+	return Continue, true
+}
+
+// AdvanceOrEnd moves the session past the current (finished) game.
+// On Continue, CurrentGame is the next card's game.
+func (s *Session) AdvanceOrEnd() (SessionOutcome, error) {
+	s.Update()
+
+	outcome, over := s.Outcome()
+	if !over {
+		return Continue, fmt.Errorf("current game is still in progress")
+	}
+	if outcome == SessionLost || s.IsFinished() {
+		return outcome, nil
+	}
+
+	if outcome == SessionComplete {
+		s.CurrentIndex = len(s.Cards)
+		return SessionComplete, s.SaveAggregate()
+	}
+	prevIndex := s.CurrentIndex
+	s.CurrentIndex, s.CurrentPlayer, _ = s.nextTurn()
+
+	started, err := s.startPlayable(prevIndex)
+	if err != nil {
+		return outcome, err
+	}
+	if !started {
+		// The rest of the cards were skipped
+		return SessionComplete, s.SaveAggregate()
+	}
+	return Continue, nil
+}
+
+// CanSkip reports whether the current card can be skipped: it is part of a
+// batch and still being played.
+func (s *Session) CanSkip() bool {
+	if !s.IsBatch || s.IsFinished() || s.CurrentGame == nil {
+		return false
+	}
+	_, over := s.Outcome()
+	return !over
+}
+
+// Skip gives up on the current card without scoring it and moves on to the
+// next one. Unlike a loss this doesn't end the batch, and skipping the last
+// card completes it.
+func (s *Session) Skip() (SessionOutcome, error) {
+	if !s.CanSkip() {
+		return Continue, fmt.Errorf("the current card can't be skipped")
+	}
+	s.Passed = append(s.Passed, s.Cards[s.CurrentIndex].DisplayTitle())
+
+	index, player, ok := s.nextTurn()
+	if !ok {
+		s.CurrentIndex = len(s.Cards)
+		return SessionComplete, nil
+	}
+	prevIndex := s.CurrentIndex
+	s.CurrentIndex, s.CurrentPlayer = index, player
+
+	started, err := s.startPlayable(prevIndex)
+	if err != nil {
+		return Continue, err
+	}
+	if !started {
+		return SessionComplete, nil
+	}
+	return Continue, nil
+}
+
+// CardProgress returns how many of the session's cards have been played, won
+// or not, out of its total. Drills aren't counted.
+func (s *Session) CardProgress() (done, total int) {
+	for i, card := range s.Cards {
+		if card.Drill {
+			continue
+		}
+		total++
+		if i < s.CurrentIndex {
+			done++
+		}
+	}
+	if !s.IsFinished() && !s.Cards[s.CurrentIndex].Drill {
+		if _, over := s.Outcome(); over {
+			done++
+		}
+	}
+	return done, total
+}
+
+// ETA estimates how long the cards left take to play, from the average time
+// taken by the cards won so far, less the time spent on the current card.
+// Skipped cards don't count towards the average, and drills are left out; the
+// cards of a flash batch, which aren't scored, count. The boolean is false
+// before a card has been won, when there is nothing to go by.
+func (s *Session) ETA(now time.Time) (time.Duration, bool) {
+	if len(s.cardTimes) == 0 {
+		return 0, false
+	}
+	var taken time.Duration
+	for _, d := range s.cardTimes {
+		taken += d
+	}
+	perCard := taken / time.Duration(len(s.cardTimes))
+
+	done, total := s.CardProgress()
+	eta := perCard * time.Duration(total-done)
+	if _, over := s.Outcome(); !over {
+		eta -= min(now.Sub(s.cardStartedAt), perCard)
+	}
+	return max(eta, 0), true
+}
+
+// TitleFor returns the banner title of the i-th card in play order.
+func (s *Session) TitleFor(i int) string {
+	if i < 0 || i >= len(s.Cards) {
+		return ""
+	}
+	return s.Cards[i].DisplayTitle()
+}
+
 func (s *Session) IsFinished() bool {
 	return s.CurrentIndex >= len(s.Cards)
 }
 
 func (s *Session) IsLastGame() bool {
+	if s.IsVersus() {
+		_, _, ok := s.nextTurn()
+		return !ok
+	}
 	return s.CurrentIndex == len(s.Cards)-1
 }
 
+// IsVersus reports whether the session is a multi-player hot-seat session.
+func (s *Session) IsVersus() bool {
+	return len(s.Players) > 0
+}
+
+// PlayerName returns the name of the player whose turn it is, or "" outside versus mode.
+func (s *Session) PlayerName() string {
+	if !s.IsVersus() {
+		return ""
+	}
+	return s.Players[s.CurrentPlayer]
+}
+
+// NextPlayerName returns the name of the player who takes the next turn, or ""
+// if there is none.
+func (s *Session) NextPlayerName() string {
+	if !s.IsVersus() {
+		return ""
+	}
+	if _, player, ok := s.nextTurn(); ok {
+		return s.Players[player]
+	}
+	return ""
+}
+
+// IsLastTurnOfCard reports whether no other player will play the current card.
+func (s *Session) IsLastTurnOfCard() bool {
+	if !s.IsVersus() {
+		return true
+	}
+	idx, _, ok := s.nextTurn()
+	return !ok || idx != s.CurrentIndex
+}
+
+// nextTurn returns the card and player that follow the current turn.
+// Players are skipped once their run has ended; ok is false when no turns remain.
+func (s *Session) nextTurn() (index, player int, ok bool) {
+	if !s.IsVersus() {
+		return s.CurrentIndex + 1, 0, s.CurrentIndex+1 < len(s.Cards)
+	}
+
+	index, player = s.CurrentIndex, s.CurrentPlayer
+	for {
+		player++
+		if player == len(s.Players) {
+			player = 0
+			index++
+		}
+		if index >= len(s.Cards) {
+			return index, 0, false
+		}
+		if !s.isOut(player) {
+			return index, player, true
+		}
+	}
+}
+
+// isOut reports whether a player's run has ended, including on the game in progress.
+func (s *Session) isOut(player int) bool {
+	if s.playerOut[player] {
+		return true
+	}
+	if player != s.CurrentPlayer || s.CurrentGame == nil {
+		return false
+	}
+	st := s.CurrentGame.State
+	return st.Loss && !st.Revealed
+}
+
 func (s *Session) IsSessionLoss() bool {
 	if s.CurrentGame != nil && s.CurrentGame.State.Loss {
 		return true
@@ -184,31 +1056,50 @@ func (s *Session) IsSessionLoss() bool {
 	return false
 }
 
-// Helper duplicated from main (should be shared utils package really)
-func longestLineLen(str string) int {
-	max := 0
-	// naive split
-	for _, line := range splitLines(str) {
-		if len(line) > max {
-			max = len(line)
-		}
+// discardStorage is the score storage for drills, which have no history and
+// are never saved.
+type discardStorage struct{}
+
+func (discardStorage) LoadAll() ([]scoring.ScoreHistoryEntry, error) { return nil, nil }
+
+func (discardStorage) SaveAll([]scoring.ScoreHistoryEntry) error { return nil }
+
+// scoreTitle returns the title used to record the current player's scores for a
+// card, so that each player in versus mode has their own high scores.
+func (s *Session) scoreTitle(card CardData) string {
+	title := scoreTitle(card)
+	if s.IsVersus() {
+		title = fmt.Sprintf("%s (%s)", title, s.PlayerName())
 	}
-	return max
+	return title
 }
 
-func splitLines(s string) []string {
-	var lines []string
-	cur := ""
-	for _, r := range s {
-		if r == '\n' {
-			lines = append(lines, cur)
-			cur = ""
-		} else {
-			cur += string(r)
+// sortCards orders cards by the given sort key, breaking ties by source path
+// and then by position within the file.
+func sortCards(cards []CardData, order CardOrder) {
+	slices.SortStableFunc(cards, func(a, b CardData) int {
+		var c int
+		switch order {
+		case SortByTitle:
+			c = strings.Compare(a.DisplayTitle(), b.DisplayTitle())
+		case SortByLength:
+			c = cmp.Compare(utf8.RuneCountInString(a.Content), utf8.RuneCountInString(b.Content))
+		}
+		if c != 0 {
+			return c
+		}
+		return cmp.Or(strings.Compare(a.Source, b.Source), cmp.Compare(a.PartIndex, b.PartIndex))
+	})
+}
+
+// scoreTitle returns the title used to record scores for a card.
+func scoreTitle(card CardData) string {
+	title := card.Title
+	if title == "" {
+		title = card.Source
+		if card.TotalParts > 1 {
+			title = fmt.Sprintf("%s #%d", title, card.PartIndex)
 		}
 	}
-	if cur != "" {
-		lines = append(lines, cur)
-	}
-	return lines
+	return title
 }
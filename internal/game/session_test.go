@@ -1,8 +1,18 @@
 package game
 
 import (
+	"errors"
+	"fmt"
+	"go-mem/internal/scoring"
 	"go-mem/internal/state"
+	"maps"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestSession_Init(t *testing.T) {
@@ -13,7 +23,7 @@ func TestSession_Init(t *testing.T) {
 	opts := state.GameOptions{TimerLimit: -1} // Auto
 	store := &MockStorage{}
 
-	sess, err := NewSession(cards, opts, store, false)
+	sess, err := NewSession(cards, opts, store, InOrder)
 	if err != nil {
 		t.Fatalf("NewSession failed: %v", err)
 	}
@@ -48,7 +58,7 @@ func TestSession_Progression(t *testing.T) {
 	opts := state.GameOptions{TimerLimit: 0} // No timer
 	store := &MockStorage{}
 
-	sess, _ := NewSession(cards, opts, store, false)
+	sess, _ := NewSession(cards, opts, store, InOrder)
 
 	// Win Game 1
 	sess.CurrentGame.HandleKeyPress("A") // Win
@@ -85,10 +95,10 @@ func TestSession_Progression(t *testing.T) {
 	}
 
 	// Check score aggregation
-	// Each game: 25 pts (char) + 1000 pts (message) = 1025.
-	// Total: 2050.
-	if sess.TotalScore != 2050 {
-		t.Errorf("Expected total score 2050, got %d", sess.TotalScore)
+	// Each game: 25 pts (char) + 350 pts (clean word) + 1000 pts (message) = 1375.
+	// Total: 2750.
+	if sess.TotalScore != 2750 {
+		t.Errorf("Expected total score 2750, got %d", sess.TotalScore)
 	}
 }
 
@@ -101,7 +111,7 @@ func TestSession_TimePersistence(t *testing.T) {
 	opts := state.GameOptions{TimerLimit: 100}
 	store := &MockStorage{}
 
-	sess, _ := NewSession(cards, opts, store, false)
+	sess, _ := NewSession(cards, opts, store, InOrder)
 
 	// Simulate 10s passing in Game 1
 	// We manually decrement Game 1 state?
@@ -127,3 +137,1263 @@ func TestSession_TimePersistence(t *testing.T) {
 		t.Errorf("Game 2 limit should be 90, got %d", sess.CurrentGame.State.TimeLimit)
 	}
 }
+
+func TestSession_Results(t *testing.T) {
+	cards := []CardData{
+		{Content: "A", Source: "src1", Title: "First"},
+		{Content: "BC", Source: "src2"},
+	}
+	opts := state.GameOptions{TimerLimit: 0}
+	store := &MockStorage{}
+
+	sess, _ := NewSession(cards, opts, store, InOrder)
+
+	// Card 1: one correct letter.
+	sess.CurrentGame.HandleKeyPress("A")
+	sess.Update()
+	// A second Update before advancing must not record the card twice.
+	sess.Update()
+
+	sess.CurrentIndex++
+	_ = sess.NextGame()
+
+	// Card 2: one error, then correct.
+	sess.CurrentGame.State.Score.CurrentScore = 1000
+	sess.CurrentGame.HandleKeyPress("z")
+	sess.CurrentGame.HandleKeyPress("b")
+	sess.CurrentGame.HandleKeyPress("c")
+	sess.Update()
+
+	if len(sess.Results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(sess.Results))
+	}
+
+	// 25 (char) + 350 (clean word) + 1000 (message) = 1375
+	first := sess.Results[0]
+	if first.Title != "First" || first.Score != 1375 || first.Errors != 0 {
+		t.Errorf("Unexpected first result: %+v", first)
+	}
+
+	// 1000 (seed) - 50 (error) + 2*25 (chars) + 250 (word) + 1000 (message) = 2250
+	second := sess.Results[1]
+	if second.Title != "src2" || second.Score != 2250 || second.Errors != 1 {
+		t.Errorf("Unexpected second result: %+v", second)
+	}
+
+	if sess.TotalScore != first.Score+second.Score {
+		t.Errorf("Expected total %d, got %d", first.Score+second.Score, sess.TotalScore)
+	}
+	if sess.TotalErrors() != 1 {
+		t.Errorf("Expected 1 total error, got %d", sess.TotalErrors())
+	}
+}
+
+func TestSession_AdvanceOrEnd_Continue(t *testing.T) {
+	cards := []CardData{
+		{Content: "A", Source: "src1"},
+		{Content: "B", Source: "src2"},
+	}
+	sess, _ := NewSession(cards, state.GameOptions{TimerLimit: 0}, &MockStorage{}, InOrder)
+
+	if _, err := sess.AdvanceOrEnd(); err == nil {
+		t.Error("Expected error advancing an in-progress game")
+	}
+
+	sess.CurrentGame.HandleKeyPress("A")
+	outcome, err := sess.AdvanceOrEnd()
+	if err != nil {
+		t.Fatalf("AdvanceOrEnd failed: %v", err)
+	}
+	if outcome != Continue {
+		t.Errorf("Expected Continue, got %v", outcome)
+	}
+	if sess.CurrentIndex != 1 || string(sess.CurrentGame.State.Secret) != "B" {
+		t.Errorf("Expected to be on card B, got index %d", sess.CurrentIndex)
+	}
+}
+
+func TestSession_AdvanceOrEnd_Complete(t *testing.T) {
+	cards := []CardData{
+		{Content: "A", Source: "src1"},
+		{Content: "B", Source: "src2"},
+	}
+	sess, _ := NewSession(cards, state.GameOptions{TimerLimit: 0}, &MockStorage{}, InOrder)
+
+	sess.CurrentGame.HandleKeyPress("A")
+	sess.AdvanceOrEnd()
+	sess.CurrentGame.HandleKeyPress("B")
+
+	outcome, err := sess.AdvanceOrEnd()
+	if err != nil {
+		t.Fatalf("AdvanceOrEnd failed: %v", err)
+	}
+	if outcome != SessionComplete {
+		t.Errorf("Expected SessionComplete, got %v", outcome)
+	}
+	if !sess.IsFinished() {
+		t.Error("Session should be finished")
+	}
+	if sess.TotalScore != 2750 {
+		t.Errorf("Expected total score 2750, got %d", sess.TotalScore)
+	}
+}
+
+func TestSession_SaveAggregate(t *testing.T) {
+	cards := []CardData{
+		{Content: "A", Source: "src1"},
+		{Content: "B", Source: "src2"},
+	}
+	store := &MockStorage{}
+	sess, _ := NewSession(cards, state.GameOptions{}, store, InOrder)
+	sess.Now = func() time.Time { return sess.StartedAt.Add(30 * time.Second) }
+
+	sess.CurrentGame.HandleKeyPress("A")
+	sess.AdvanceOrEnd()
+	sess.CurrentGame.HandleKeyPress("B")
+	if outcome, err := sess.AdvanceOrEnd(); outcome != SessionComplete || err != nil {
+		t.Fatalf("Expected the batch to be complete, got %v (%v)", outcome, err)
+	}
+
+	// The deck's entry is saved with those of its cards, whatever their order
+	hash := scoring.DeckHash([]string{"B", "A"})
+	var deck []scoring.ScoreHistoryEntry
+	for _, e := range store.Entries {
+		if e.Hash == hash {
+			deck = append(deck, e)
+		}
+	}
+	if len(deck) != 1 || len(store.Entries) != 3 {
+		t.Fatalf("Expected one deck entry among 3, got %+v", store.Entries)
+	}
+	if deck[0].Score != sess.TotalScore || deck[0].Score != 2750 || deck[0].DurationSec != 30 || deck[0].Outcome != scoring.OutcomeWon {
+		t.Errorf("Expected the deck entry to have the summed score, got %+v", deck[0])
+	}
+
+	// Only once
+	sess.SaveAggregate()
+	if len(store.Entries) != 3 {
+		t.Errorf("Expected the deck entry to be saved once, got %d entries", len(store.Entries))
+	}
+
+	// A lost batch saves no deck entry
+	store = &MockStorage{}
+	sess, _ = NewSession(cards, state.GameOptions{}, store, InOrder)
+	sess.CurrentGame.State.Score.CurrentScore = -1
+	sess.CurrentGame.HandleKeyPress("x")
+	if outcome, _ := sess.AdvanceOrEnd(); outcome != SessionLost {
+		t.Fatalf("Expected the batch to be lost, got %v", outcome)
+	}
+	for _, e := range store.Entries {
+		if e.Hash == hash {
+			t.Errorf("Expected no deck entry for a lost batch, got %+v", e)
+		}
+	}
+
+	// A deck entry that can't be saved is reported
+	store = &MockStorage{}
+	sess, _ = NewSession(cards, state.GameOptions{}, store, InOrder)
+	sess.CurrentGame.HandleKeyPress("A")
+	sess.AdvanceOrEnd()
+	sess.CurrentGame.HandleKeyPress("B")
+	store.SaveErr = errors.New("disk full")
+	if outcome, err := sess.AdvanceOrEnd(); outcome != SessionComplete || err == nil {
+		t.Errorf("Expected the batch to complete with the save error, got %v (%v)", outcome, err)
+	}
+}
+
+func TestSession_ByLine(t *testing.T) {
+	content := "ab cd\n\nef\ngh"
+	store := &MockStorage{}
+	sess, err := NewSession([]CardData{{Content: content, Title: "Poem"}}, state.GameOptions{ByLine: true, TimerLimit: 60}, store, InOrder)
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+	play := func(keys string) {
+		for _, r := range keys {
+			sess.CurrentGame.HandleKeyPress(string(r))
+		}
+		sess.Update()
+	}
+
+	if len(sess.Lines) != 4 || sess.Line != 0 || string(sess.CurrentGame.State.Secret) != "ab cd" {
+		t.Fatalf("Expected to start on the first of 4 lines, got line %d of %q", sess.Line, sess.Lines)
+	}
+	before, after := sess.LineContext()
+	if len(before) != 0 || !slices.Equal(after, []string{"", "__", "__"}) {
+		t.Errorf("Expected the lines to come masked, got %q and %q", before, after)
+	}
+
+	// Winning a line moves on to the next one with something to type,
+	// carrying on the score and the time left
+	sess.CurrentGame.HandleTick()
+	play("abcd")
+	score := sess.CurrentGame.State.Score.CurrentScore
+	if sess.Line != 2 || string(sess.CurrentGame.State.Secret) != "ef" {
+		t.Fatalf("Expected the blank line to be passed over, got line %d", sess.Line)
+	}
+	if _, over := sess.Outcome(); over || score <= 0 || sess.TimeRemaining != 59 || sess.CurrentGame.State.TimeRemaining != 59 {
+		t.Errorf("Expected the card to go on with a score of %d and 59s left, got over=%v and %ds", score, over, sess.CurrentGame.State.TimeRemaining)
+	}
+	if before, after := sess.LineContext(); !slices.Equal(before, []string{"ab cd", ""}) || !slices.Equal(after, []string{"__"}) {
+		t.Errorf("Expected the lines done shown as they are, got %q and %q", before, after)
+	}
+	if len(store.Entries) != 0 {
+		t.Fatalf("Expected nothing saved before the card ends, got %+v", store.Entries)
+	}
+
+	play("ef")
+	play("gh")
+	if outcome, over := sess.Outcome(); !over || outcome != SessionComplete {
+		t.Fatalf("Expected the card to be complete, got %v", outcome)
+	}
+
+	// One entry for the whole card, with one message and time bonus, as if
+	// played whole: 8 letters at 25, the last word at 350, 1000 and 59s at 10
+	if len(store.Entries) != 1 || len(sess.Results) != 1 {
+		t.Fatalf("Expected one entry and one result, got %+v and %+v", store.Entries, sess.Results)
+	}
+	e := store.Entries[0]
+	if e.Hash != scoring.TextHash(content) || e.Title != "Poem" || e.Outcome != scoring.OutcomeWon {
+		t.Errorf("Expected the entry to be the card's win, got %+v", e)
+	}
+	if e.Score != 2140 || e.Score != sess.TotalScore || e.PercentOfMax <= 0 || e.PercentOfMax > 100 {
+		t.Errorf("Expected the card's score out of the whole card, got %+v", e)
+	}
+	if e.WordErrors != nil || e.WordTimings != nil {
+		t.Errorf("Expected no per-word data for a card played by line, got %+v", e)
+	}
+
+	// A card of a single line is played whole
+	sess, _ = NewSession([]CardData{{Content: "ab cd\n"}}, state.GameOptions{ByLine: true}, &MockStorage{}, InOrder)
+	if sess.Lines != nil {
+		t.Errorf("Expected a single line to be played whole, got %q", sess.Lines)
+	}
+}
+
+func TestSession_ByLineMatchesWhole(t *testing.T) {
+	content := "one two\nthree\nfour five six"
+	letters := strings.NewReplacer(" ", "", "\n", "").Replace(content)
+
+	// play types the card through, with a mistake in "four" and "six",
+	// and returns its saved entry
+	play := func(opts state.GameOptions) (*Session, scoring.ScoreHistoryEntry) {
+		t.Helper()
+		store := &MockStorage{}
+		sess, err := NewSession([]CardData{{Content: content, Title: "Count"}}, opts, store, InOrder)
+		if err != nil {
+			t.Fatalf("NewSession failed: %v", err)
+		}
+		sess.DrillMistakes = true
+		for i, r := range letters {
+			if i == 12 || i == 20 {
+				sess.CurrentGame.HandleKeyPress("x")
+			}
+			sess.CurrentGame.HandleKeyPress(string(r))
+			sess.Update()
+		}
+		if len(store.Entries) != 1 || !sess.CurrentGame.State.Win {
+			t.Fatalf("Expected the card won and saved, got %+v", store.Entries)
+		}
+		return sess, store.Entries[0]
+	}
+
+	// The same keys score the same by line as whole, out of the same most
+	opts := state.GameOptions{FirstLetter: true, NoScoreFloor: true}
+	whole, wholeEntry := play(opts)
+	opts.ByLine = true
+	byLine, lineEntry := play(opts)
+	if byLine.Lines == nil || whole.Lines != nil {
+		t.Fatalf("Expected one card by line and one whole, got %q and %q", byLine.Lines, whole.Lines)
+	}
+	if lineEntry.Score != wholeEntry.Score || lineEntry.PercentOfMax != wholeEntry.PercentOfMax {
+		t.Errorf("Expected the same score by line as whole, got %d (%.1f%%) and %d (%.1f%%)",
+			lineEntry.Score, lineEntry.PercentOfMax, wholeEntry.Score, wholeEntry.PercentOfMax)
+	}
+
+	// Its word errors, and its drill, are of all its lines
+	if want := map[int]int{3: 1, 5: 1}; !maps.Equal(lineEntry.WordErrors, want) || !maps.Equal(wholeEntry.WordErrors, want) {
+		t.Errorf("Expected errors in words 3 and 5, got %v by line and %v whole", lineEntry.WordErrors, wholeEntry.WordErrors)
+	}
+	if len(byLine.Cards) != 2 || len(whole.Cards) != 2 || byLine.Cards[1].Content != whole.Cards[1].Content {
+		t.Errorf("Expected the same drill by line as whole, got %+v and %+v", byLine.Cards, whole.Cards)
+	}
+
+	// Random letters are drawn once for the card, not for each line
+	sess, err := NewSession([]CardData{{Content: content}}, state.GameOptions{ByLine: true, NRandom: 2}, &MockStorage{}, InOrder)
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+	revealed := 0
+	for !sess.linesSaved {
+		st := sess.CurrentGame.State
+		revealed += len(st.Mask) - strings.Count(string(st.Mask), "_") - strings.Count(string(st.Mask), " ")
+		for _, r := range strings.ReplaceAll(string(st.Secret), " ", "") {
+			sess.CurrentGame.HandleKeyPress(string(r))
+		}
+		sess.Update()
+	}
+	if revealed != 2 {
+		t.Errorf("Expected 2 letters revealed across the lines, got %d", revealed)
+	}
+}
+
+func TestSession_Skip(t *testing.T) {
+	cards := []CardData{
+		{Content: "A", Title: "One"},
+		{Content: "B", Title: "Two"},
+		{Content: "C", Title: "Three"},
+	}
+	store := &MockStorage{}
+	sess, _ := NewSession(cards, state.GameOptions{NoScoreFloor: true}, store, InOrder)
+
+	sess.CurrentGame.HandleKeyPress("x") // A mistake, which isn't kept
+	if outcome, err := sess.Skip(); outcome != Continue || err != nil {
+		t.Fatalf("Expected to continue, got %v (%v)", outcome, err)
+	}
+	if sess.CurrentIndex != 1 || string(sess.CurrentGame.State.Secret) != "B" {
+		t.Fatalf("Expected to be on card B, got index %d", sess.CurrentIndex)
+	}
+	if sess.TotalScore != 0 || len(sess.Results) != 0 || len(store.Entries) != 0 {
+		t.Errorf("Expected nothing scored for a skipped card, got %d, %+v, %+v", sess.TotalScore, sess.Results, store.Entries)
+	}
+
+	// A finished card can't be skipped
+	sess.CurrentGame.HandleKeyPress("B")
+	if sess.CanSkip() {
+		t.Error("Expected a won card not to be skippable")
+	}
+	sess.AdvanceOrEnd()
+
+	// Skipping the last card completes the batch, with no deck score
+	if outcome, err := sess.Skip(); outcome != SessionComplete || err != nil {
+		t.Fatalf("Expected the batch to be complete, got %v (%v)", outcome, err)
+	}
+	if !sess.IsFinished() || sess.TotalScore != 1375 || !slices.Equal(sess.Passed, []string{"One", "Three"}) {
+		t.Errorf("Expected only card B scored, got %d with %v skipped", sess.TotalScore, sess.Passed)
+	}
+	if len(store.Entries) != 1 {
+		t.Errorf("Expected only card B's score entry, got %+v", store.Entries)
+	}
+
+	// A single card can't be skipped
+	single, _ := NewSession(cards[:1], state.GameOptions{}, &MockStorage{}, InOrder)
+	if single.CanSkip() {
+		t.Error("Expected a single card not to be skippable")
+	}
+	if _, err := single.Skip(); err == nil || single.CurrentIndex != 0 {
+		t.Error("Expected an error skipping a single card")
+	}
+}
+
+func TestSession_AdvanceOrEnd_TimerExpiry(t *testing.T) {
+	cards := []CardData{
+		{Content: "A", Source: "src1"},
+		{Content: "Bee", Source: "src2"},
+		{Content: "C", Source: "src3"},
+	}
+	sess, _ := NewSession(cards, state.GameOptions{TimerLimit: 5}, &MockStorage{}, InOrder)
+
+	sess.CurrentGame.HandleKeyPress("A")
+	if outcome, _ := sess.AdvanceOrEnd(); outcome != Continue {
+		t.Fatalf("Expected Continue after card 1, got %v", outcome)
+	}
+
+	// Run out the clock on card 2 of 3
+	for i := 0; i < 10; i++ {
+		sess.CurrentGame.HandleTick()
+		sess.Update()
+	}
+	if !sess.CurrentGame.State.Loss {
+		t.Fatal("Card 2 should be lost on timer expiry")
+	}
+
+	outcome, err := sess.AdvanceOrEnd()
+	if err != nil {
+		t.Fatalf("AdvanceOrEnd failed: %v", err)
+	}
+	if outcome != SessionLost {
+		t.Errorf("Expected SessionLost, got %v", outcome)
+	}
+	// The session stays on the lost card so the "Time's up" screen can render it
+	if sess.CurrentIndex != 1 {
+		t.Errorf("Expected to stay on card index 1, got %d", sess.CurrentIndex)
+	}
+	if sess.CurrentGame.State.TimeRemaining > 0 {
+		t.Errorf("Expected no time remaining, got %d", sess.CurrentGame.State.TimeRemaining)
+	}
+}
+
+func TestSession_AdvanceOrEnd_RevealContinues(t *testing.T) {
+	cards := []CardData{
+		{Content: "A", Source: "src1"},
+		{Content: "B", Source: "src2"},
+	}
+	sess, _ := NewSession(cards, state.GameOptions{TimerLimit: 0}, &MockStorage{}, InOrder)
+
+	// Giving up on a card moves on to the next one
+	sess.CurrentGame.HandleKeyPress("ctrl+r")
+	outcome, err := sess.AdvanceOrEnd()
+	if err != nil {
+		t.Fatalf("AdvanceOrEnd failed: %v", err)
+	}
+	if outcome != Continue {
+		t.Errorf("Expected Continue after reveal, got %v", outcome)
+	}
+	if sess.CurrentIndex != 1 {
+		t.Errorf("Expected index 1, got %d", sess.CurrentIndex)
+	}
+}
+
+func TestSession_PreviewKeepsBatchTime(t *testing.T) {
+	cards := []CardData{
+		{Content: "A", Source: "src1"},
+		{Content: "B", Source: "src2"},
+	}
+	sess, _ := NewSession(cards, state.GameOptions{TimerLimit: 100, Preview: 5}, &MockStorage{}, InOrder)
+
+	for i := 0; i < 5; i++ {
+		sess.CurrentGame.HandleTick()
+		sess.Update()
+	}
+	if sess.TimeRemaining != 100 {
+		t.Errorf("Preview must not consume batch time, got %d", sess.TimeRemaining)
+	}
+
+	sess.CurrentGame.HandleTick()
+	sess.Update()
+	if sess.TimeRemaining != 99 {
+		t.Errorf("Expected 99 after preview, got %d", sess.TimeRemaining)
+	}
+}
+
+func TestSession_GracePerCard(t *testing.T) {
+	cards := []CardData{
+		{Content: "A", Source: "src1"},
+		{Content: "B", Source: "src2"},
+	}
+	sess, _ := NewSession(cards, state.GameOptions{TimerLimit: 100, Grace: 2}, &MockStorage{}, InOrder)
+
+	tick := func(n int) {
+		for i := 0; i < n; i++ {
+			sess.CurrentGame.HandleTick()
+			sess.Update()
+		}
+	}
+	tick(3)
+	if sess.TimeRemaining != 99 {
+		t.Errorf("Expected only the tick after the grace period to count, got %d", sess.TimeRemaining)
+	}
+
+	// The next card starts with a grace period of its own
+	sess.CurrentGame.HandleKeyPress("A")
+	sess.AdvanceOrEnd()
+	tick(2)
+	if sess.TimeRemaining != 99 {
+		t.Errorf("Grace must not consume batch time, got %d", sess.TimeRemaining)
+	}
+	tick(1)
+	if sess.TimeRemaining != 98 {
+		t.Errorf("Expected 98 after the second grace period, got %d", sess.TimeRemaining)
+	}
+}
+
+func TestSession_Replay(t *testing.T) {
+	cards := []CardData{{Content: "Hi", Source: "src1"}}
+	storage := &MockStorage{}
+	sess, _ := NewSession(cards, state.GameOptions{TimerLimit: 30}, storage, InOrder)
+
+	first := sess.CurrentGame
+	first.HandleTick()
+	first.HandleKeyPress("H")
+	first.HandleKeyPress("i")
+	sess.Update()
+	if outcome, _ := sess.Outcome(); outcome != SessionComplete || len(storage.Entries) != 1 {
+		t.Fatalf("Expected a completed card with one saved entry, got %v and %d entries", outcome, len(storage.Entries))
+	}
+
+	if err := sess.Replay(); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	second := sess.CurrentGame
+	if second == first || string(second.State.Secret) != "Hi" || second.State.Win {
+		t.Fatalf("Expected a fresh game for the same card, got win=%v secret %q", second.State.Win, string(second.State.Secret))
+	}
+	if sess.CurrentIndex != 0 || second.State.TimeRemaining != 30 || sess.TimeRemaining != 30 {
+		t.Errorf("Expected card 0 with the full time, got index %d, time %d", sess.CurrentIndex, second.State.TimeRemaining)
+	}
+
+	// Each attempt is recorded on its own
+	storage.SaveCalled = false
+	second.HandleKeyPress("H")
+	second.HandleKeyPress("i")
+	sess.Update()
+	if !storage.SaveCalled || len(sess.Results) != 2 {
+		t.Errorf("Expected the second attempt to be saved and recorded, got saved=%v and %d results", storage.SaveCalled, len(sess.Results))
+	}
+}
+
+func TestSession_Versus(t *testing.T) {
+	cards := []CardData{
+		{Content: "A", Source: "src1"},
+		{Content: "B", Source: "src2"},
+	}
+	store := &MockStorage{}
+	sess, err := NewVersusSession(cards, state.GameOptions{TimerLimit: 0}, store, InOrder, []string{"Ann", "Ben"})
+	if err != nil {
+		t.Fatalf("NewVersusSession failed: %v", err)
+	}
+
+	// Each turn: the scripted keys for the current player, then advance
+	turns := []struct {
+		player string
+		card   string
+		seed   int // Starting score, so an early error doesn't end the run
+		keys   []string
+	}{
+		{"Ann", "A", 0, []string{"a"}},         // 1025
+		{"Ben", "A", 1000, []string{"z", "a"}}, // 1000 - 50 + 25 + 1000 = 1975
+		{"Ann", "B", 0, []string{"ctrl+r"}},    // Gives up: 0
+		{"Ben", "B", 0, []string{"b"}},         // 1025
+	}
+	for i, turn := range turns {
+		if sess.PlayerName() != turn.player || string(sess.CurrentGame.State.Secret) != turn.card {
+			t.Fatalf("Turn %d: expected %s on %s, got %s on %s", i, turn.player, turn.card, sess.PlayerName(), string(sess.CurrentGame.State.Secret))
+		}
+		// A fresh mask for every turn
+		if string(sess.CurrentGame.State.Mask) != "_" {
+			t.Errorf("Turn %d: expected a fresh mask, got %q", i, string(sess.CurrentGame.State.Mask))
+		}
+		if got := sess.IsLastTurnOfCard(); got != (turn.player == "Ben") {
+			t.Errorf("Turn %d: IsLastTurnOfCard = %v", i, got)
+		}
+		sess.CurrentGame.State.Score.CurrentScore = turn.seed
+		for _, k := range turn.keys {
+			sess.CurrentGame.HandleKeyPress(k)
+		}
+
+		outcome, err := sess.AdvanceOrEnd()
+		if err != nil {
+			t.Fatalf("Turn %d: AdvanceOrEnd failed: %v", i, err)
+		}
+		want := Continue
+		if i == len(turns)-1 {
+			want = SessionComplete
+		}
+		if outcome != want {
+			t.Errorf("Turn %d: expected outcome %v, got %v", i, want, outcome)
+		}
+	}
+
+	if sess.PlayerTotals[0] != 1375 || sess.PlayerTotals[1] != 3600 {
+		t.Errorf("Expected totals [1375 3600], got %v", sess.PlayerTotals)
+	}
+	if len(sess.Results) != 3 || sess.Results[1].Player != "Ben" || sess.Results[1].Index != 0 {
+		t.Errorf("Unexpected results: %+v", sess.Results)
+	}
+
+	// High scores are kept apart per player
+	hashes := map[string]string{}
+	for _, e := range store.Entries {
+		hashes[e.Title] = e.Hash
+	}
+	if hashes["src1 (Ann)"] == "" || hashes["src1 (Ann)"] == hashes["src1 (Ben)"] {
+		t.Errorf("Expected separate per-player histories, got %v", hashes)
+	}
+}
+
+func TestSession_Versus_PerPlayerTimer(t *testing.T) {
+	cards := []CardData{
+		{Content: "Ab", Source: "src1"},
+		{Content: "C", Source: "src2"},
+	}
+	sess, _ := NewVersusSession(cards, state.GameOptions{TimerLimit: 10}, &MockStorage{}, InOrder, []string{"Ann", "Ben"})
+
+	// Ann uses 4s on card 1
+	for i := 0; i < 4; i++ {
+		sess.CurrentGame.HandleTick()
+	}
+	sess.CurrentGame.HandleKeyPress("a")
+	sess.CurrentGame.HandleKeyPress("b")
+	sess.AdvanceOrEnd()
+
+	// Ben starts with his own full budget, and runs out
+	if sess.CurrentGame.State.TimeLimit != 10 {
+		t.Errorf("Ben should start with 10s, got %d", sess.CurrentGame.State.TimeLimit)
+	}
+	for i := 0; i < 10; i++ {
+		sess.CurrentGame.HandleTick()
+	}
+	if outcome, _ := sess.AdvanceOrEnd(); outcome != Continue {
+		t.Fatalf("Ben's timeout must not end Ann's run, got %v", outcome)
+	}
+
+	// Ann continues with what she had left; Ben's turns are skipped
+	if sess.PlayerName() != "Ann" || sess.CurrentGame.State.TimeLimit != 6 {
+		t.Errorf("Expected Ann with 6s, got %s with %d", sess.PlayerName(), sess.CurrentGame.State.TimeLimit)
+	}
+	if !sess.IsLastTurnOfCard() || !sess.IsLastGame() {
+		t.Error("Ann's turn on card 2 should be the last one")
+	}
+	sess.CurrentGame.HandleKeyPress("c")
+	if outcome, _ := sess.AdvanceOrEnd(); outcome != SessionComplete {
+		t.Errorf("Expected SessionComplete, got %v", outcome)
+	}
+}
+
+func TestNewVersusSession_NeedsTwoPlayers(t *testing.T) {
+	cards := []CardData{{Content: "A", Source: "src1"}}
+	if _, err := NewVersusSession(cards, state.GameOptions{}, &MockStorage{}, InOrder, []string{"Ann"}); err == nil {
+		t.Error("Expected an error with a single player")
+	}
+}
+
+func TestSession_ReverseOrder(t *testing.T) {
+	cards := []CardData{
+		{Content: "One", Source: "deck.txt", PartIndex: 1, TotalParts: 3},
+		{Content: "Two", Source: "deck.txt", PartIndex: 2, TotalParts: 3},
+		{Content: "Three", Source: "deck.txt", PartIndex: 3, TotalParts: 3},
+	}
+	sess, err := NewSession(cards, state.GameOptions{TimerLimit: 0}, &MockStorage{}, ReverseOrder)
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+
+	if string(sess.CurrentGame.State.Secret) != "Three" {
+		t.Errorf("Expected the originally last card first, got %q", string(sess.CurrentGame.State.Secret))
+	}
+	// Titles keep the original numbering
+	if title := sess.Cards[0].DisplayTitle(); title != "Deck #3" {
+		t.Errorf("Expected title 'Deck #3', got %q", title)
+	}
+	if sess.Cards[2].PartIndex != 1 {
+		t.Errorf("Expected part 1 last, got part %d", sess.Cards[2].PartIndex)
+	}
+}
+
+func TestSession_SortOrders(t *testing.T) {
+	newCards := func() []CardData {
+		return []CardData{
+			{Content: "Medium text", Source: "b/zebra.txt"},
+			{Content: "Short", Source: "a/apple.txt", Title: "Yak"},
+			{Content: "The longest text", Source: "c/mango.txt"},
+			{Content: "Tiny", Source: "a/mango.txt"},
+		}
+	}
+
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		// Untitled cards sort by their derived title; Mango ties break on source
+		{"title", []string{"Tiny", "The longest text", "Short", "Medium text"}},
+		{"length", []string{"Tiny", "Short", "Medium text", "The longest text"}},
+		{"source", []string{"Short", "Tiny", "Medium text", "The longest text"}},
+	}
+	for _, tt := range tests {
+		order, err := ParseSortOrder(tt.key)
+		if err != nil {
+			t.Fatalf("ParseSortOrder(%q) failed: %v", tt.key, err)
+		}
+		sess, err := NewSession(newCards(), state.GameOptions{TimerLimit: 0}, &MockStorage{}, order)
+		if err != nil {
+			t.Fatalf("NewSession failed: %v", err)
+		}
+
+		var got []string
+		for _, c := range sess.Cards {
+			got = append(got, c.Content)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("sort=%s: expected %v, got %v", tt.key, tt.want, got)
+		}
+		if string(sess.CurrentGame.State.Secret) != tt.want[0] {
+			t.Errorf("sort=%s: expected to start with %q", tt.key, tt.want[0])
+		}
+	}
+
+	if _, err := ParseSortOrder("color"); err == nil {
+		t.Error("Expected an error for an unknown sort key")
+	}
+}
+
+func TestSession_TitleFor(t *testing.T) {
+	cards := []CardData{
+		{Content: "A", Source: "quotes.txt", Title: "Hamlet Quote", PartIndex: 1, TotalParts: 3},
+		{Content: "B", Source: "quotes.txt", PartIndex: 2, TotalParts: 3},
+		{Content: "C", Source: "dir/MyFavoritePoem.txt", PartIndex: 1, TotalParts: 1},
+	}
+	sess, _ := NewSession(cards, state.GameOptions{TimerLimit: 0}, &MockStorage{}, InOrder)
+
+	want := []string{"Hamlet Quote", "Quotes #2", "My Favorite Poem"}
+	for i, w := range want {
+		if got := sess.TitleFor(i); got != w {
+			t.Errorf("TitleFor(%d) = %q, expected %q", i, got, w)
+		}
+	}
+	if sess.TitleFor(3) != "" || sess.TitleFor(-1) != "" {
+		t.Error("Expected no title out of range")
+	}
+}
+
+func TestSession_AutoTimerUsesHistory(t *testing.T) {
+	cards := []CardData{
+		{Content: "A well practiced card with plenty of text in it", Source: "src1"}, // 48 chars: 16s by length
+		{Content: "New", Source: "src2"},                                             // No history: 10s minimum
+	}
+
+	// Record a previous 8s completion of the first card
+	store := &MockStorage{}
+	sc, _ := scoring.InitScoring(cards[0].Content, "src1", store)
+	sc.SetDuration(8 * time.Second)
+	if err := sc.SaveEntries(); err != nil {
+		t.Fatalf("SaveEntries failed: %v", err)
+	}
+
+	sess, err := NewSession(cards, state.GameOptions{TimerLimit: -1}, store, InOrder)
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+
+	// 8s * 1.25 = 10s for the practiced card, plus 10s for the new one
+	if sess.TotalTimeLimit != 20 {
+		t.Errorf("Expected a 20s batch limit, got %d", sess.TotalTimeLimit)
+	}
+}
+
+func TestSession_DrillMistakes(t *testing.T) {
+	cards := []CardData{
+		{Content: "one two three four five six seven eight nine ten", Source: "src1", Title: "Count"},
+		{Content: "B", Source: "src2"},
+	}
+	storage := &MockStorage{}
+	sess, _ := NewSession(cards, state.GameOptions{TimerLimit: 30}, storage, InOrder)
+	sess.DrillMistakes = true
+	sess.CurrentGame.State.Score.CurrentScore = 1000 // Headroom for penalties
+
+	// One mistake in "two" and one in "nine"
+	for _, r := range "onextwothreefourfivesixseveneightnixneten" {
+		sess.CurrentGame.HandleKeyPress(string(r))
+	}
+	sess.Update()
+	if !sess.CurrentGame.State.Win || sess.CurrentGame.State.Score.ErrorCount != 2 {
+		t.Fatalf("Expected a win with 2 errors, got win=%v errors=%d", sess.CurrentGame.State.Win, sess.CurrentGame.State.Score.ErrorCount)
+	}
+
+	if len(sess.Cards) != 3 {
+		t.Fatalf("Expected a drill card to be inserted, got %d cards", len(sess.Cards))
+	}
+	drill := sess.Cards[1]
+	if !drill.Drill || drill.Title != "Count — drill" {
+		t.Errorf("Unexpected drill card: %+v", drill)
+	}
+	if want := "one two three four\nseven eight nine ten"; drill.Content != want {
+		t.Errorf("Expected drill %q, got %q", want, drill.Content)
+	}
+
+	if outcome, err := sess.AdvanceOrEnd(); err != nil || outcome != Continue {
+		t.Fatalf("Expected to continue to the drill, got %v %v", outcome, err)
+	}
+	if sess.CurrentGame.State.TimerEnabled {
+		t.Error("Expected the drill to be untimed")
+	}
+
+	// Drills are neither saved nor totalled, and mistakes in them aren't drilled again
+	saved := len(storage.Entries)
+	total := sess.TotalScore
+	if sess.CurrentGame.State.Score.CurrentScore != 0 {
+		t.Fatalf("Expected the drill to start from 0, got %d", sess.CurrentGame.State.Score.CurrentScore)
+	}
+	// A mistake takes the drill below the score floor without losing it
+	for _, r := range "xone two three four\nseven eight nine ten" {
+		sess.CurrentGame.HandleKeyPress(string(r))
+	}
+	sess.Update()
+	if !sess.CurrentGame.State.Win || sess.CurrentGame.State.Score.ErrorCount != 1 {
+		t.Fatalf("Expected the drill to be won with 1 error, mask %q", string(sess.CurrentGame.State.Mask))
+	}
+	if len(storage.Entries) != saved || sess.TotalScore != total || len(sess.Results) != 1 {
+		t.Errorf("Expected the drill not to be recorded, got %d entries, total %d, %d results", len(storage.Entries), sess.TotalScore, len(sess.Results))
+	}
+	if len(sess.Cards) != 3 {
+		t.Errorf("Expected no drill of a drill, got %d cards", len(sess.Cards))
+	}
+
+	// The session clock carries on from where the first card left it
+	if sess.TimeRemaining <= 0 {
+		t.Errorf("Expected the batch time to be kept, got %d", sess.TimeRemaining)
+	}
+}
+
+func TestSession_DrillMistakes_NoErrors(t *testing.T) {
+	cards := []CardData{{Content: "A", Source: "src1"}}
+	sess, _ := NewSession(cards, state.GameOptions{TimerLimit: 0}, &MockStorage{}, InOrder)
+	sess.DrillMistakes = true
+
+	sess.CurrentGame.HandleKeyPress("A")
+	if outcome, _ := sess.AdvanceOrEnd(); outcome != SessionComplete || len(sess.Cards) != 1 {
+		t.Errorf("Expected no drill after a clean card, got %v with %d cards", outcome, len(sess.Cards))
+	}
+}
+
+func TestSession_SeedIsReproducible(t *testing.T) {
+	newCards := func() []CardData {
+		var cards []CardData
+		for i := range 8 {
+			cards = append(cards, CardData{Content: "The quick brown fox jumps over the lazy dog", Source: fmt.Sprintf("src%d", i)})
+		}
+		return cards
+	}
+	play := func(seed int64) (order, masks []string) {
+		opts := state.GameOptions{NRandom: 5, NWords: 1, Rand: rand.New(rand.NewSource(seed))}
+		sess, err := NewSession(newCards(), opts, &MockStorage{}, RandomOrder)
+		if err != nil {
+			t.Fatalf("NewSession failed: %v", err)
+		}
+		for _, c := range sess.Cards {
+			order = append(order, c.Source)
+		}
+		for {
+			masks = append(masks, string(sess.CurrentGame.State.Mask))
+			sess.CurrentGame.HandleKeyPress("ctrl+r")
+			if outcome, _ := sess.AdvanceOrEnd(); outcome != Continue {
+				break
+			}
+		}
+		return order, masks
+	}
+
+	order1, masks1 := play(42)
+	order2, masks2 := play(42)
+	if !slices.Equal(order1, order2) || !slices.Equal(masks1, masks2) {
+		t.Errorf("Expected the same seed to replay the session:\n%v %q\n%v %q", order1, masks1, order2, masks2)
+	}
+	if len(masks1) != 8 {
+		t.Fatalf("Expected to play all 8 cards, got %d", len(masks1))
+	}
+
+	order3, masks3 := play(7)
+	if slices.Equal(order1, order3) && slices.Equal(masks1, masks3) {
+		t.Error("Expected a different seed to give a different session")
+	}
+}
+
+// attemptsClock is the fixed time the attempt limit tests are played at.
+var attemptsClock = time.Date(2025, 6, 15, 12, 0, 0, 0, time.Local)
+
+// fixClock makes sessions created during the test run at now.
+func fixClock(t *testing.T, now time.Time) {
+	t.Helper()
+	sessionClock = func() time.Time { return now }
+	t.Cleanup(func() { sessionClock = time.Now })
+}
+
+// playedToday returns history entries for playing each card n times on the
+// day of attemptsClock.
+func playedToday(t *testing.T, n int, cards ...CardData) []scoring.ScoreHistoryEntry {
+	t.Helper()
+	var entries []scoring.ScoreHistoryEntry
+	for _, card := range cards {
+		store := &MockStorage{}
+		if _, err := RunHeadless(card, card.Content, state.GameOptions{}, store); err != nil {
+			t.Fatalf("RunHeadless failed: %v", err)
+		}
+		for i := 0; i < n; i++ {
+			e := store.Entries[0]
+			e.Timestamp = attemptsClock.Add(-time.Duration(i) * time.Second).Format(time.RFC3339)
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+func TestSession_MaxAttemptsPerDay_Batch(t *testing.T) {
+	cards := []CardData{
+		{Content: "ab", Title: "One"},
+		{Content: "cd", Title: "Two"},
+		{Content: "ef", Title: "Three"},
+		{Content: "gh", Title: "Four"},
+	}
+	fixClock(t, attemptsClock)
+	store := &MockStorage{Entries: playedToday(t, 2, cards[1], cards[3])}
+	opts := state.GameOptions{MaxAttemptsPerDay: 2}
+
+	sess, err := NewSession(cards, opts, store, InOrder)
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+	sess.CurrentGame.HandleKeyPress("a")
+	sess.CurrentGame.HandleKeyPress("b")
+
+	// "Two" is skipped on the way to "Three"
+	if outcome, err := sess.AdvanceOrEnd(); outcome != Continue || err != nil {
+		t.Fatalf("Expected to continue, got %v (%v)", outcome, err)
+	}
+	if sess.CurrentIndex != 2 || !slices.Equal(sess.Skipped, []string{"Two"}) {
+		t.Fatalf("Expected to skip to card 3, at %d with %v skipped", sess.CurrentIndex, sess.Skipped)
+	}
+
+	// Skipping the last card ends the batch
+	sess.CurrentGame.HandleKeyPress("e")
+	sess.CurrentGame.HandleKeyPress("f")
+	if outcome, err := sess.AdvanceOrEnd(); outcome != SessionComplete || err != nil {
+		t.Fatalf("Expected the batch to be complete, got %v (%v)", outcome, err)
+	}
+	if !sess.IsFinished() || len(sess.Skipped) != 2 || len(sess.Results) != 2 {
+		t.Errorf("Expected 2 cards played and 2 skipped, got %+v / %v", sess.Results, sess.Skipped)
+	}
+
+	// The deck saved is that of the cards played
+	deck := scoring.DeckHash([]string{"ab", "ef"})
+	if !slices.ContainsFunc(store.Entries, func(e scoring.ScoreHistoryEntry) bool { return e.Hash == deck }) {
+		t.Errorf("Expected a deck entry for the cards played, got %+v", store.Entries)
+	}
+
+	// Below the limit, nothing is skipped
+	opts.MaxAttemptsPerDay = 3
+	sess, _ = NewSession(cards, opts, &MockStorage{Entries: store.Entries[:4]}, InOrder)
+	if len(sess.Skipped) != 0 {
+		t.Errorf("Expected no cards skipped below the limit, got %v", sess.Skipped)
+	}
+}
+
+func TestSession_MaxAttemptsPerDay_Refused(t *testing.T) {
+	card := CardData{Content: "ab", Title: "One"}
+	fixClock(t, attemptsClock)
+	store := &MockStorage{Entries: playedToday(t, 1, card)}
+
+	_, err := NewSession([]CardData{card}, state.GameOptions{MaxAttemptsPerDay: 1}, store, InOrder)
+	var limitErr *AttemptLimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("Expected a single card over the limit to be refused, got %v", err)
+	}
+	if midnight := time.Date(2025, 6, 16, 0, 0, 0, 0, time.Local); limitErr.Attempts != 1 || !limitErr.Resets.Equal(midnight) {
+		t.Errorf("Expected the limit to reset at the next midnight, got %+v", limitErr)
+	}
+
+	// A batch with nothing left to play is refused too
+	other := CardData{Content: "cd", Title: "Two"}
+	store.Entries = playedToday(t, 1, card, other)
+	if _, err := NewSession([]CardData{card, other}, state.GameOptions{MaxAttemptsPerDay: 1}, store, InOrder); err == nil {
+		t.Error("Expected a batch of cards all over the limit to be refused")
+	}
+}
+
+func TestSession_CardOptions(t *testing.T) {
+	own, _ := parseCardOptions("first-letter=false, timer=30")
+	cards := []CardData{
+		{Content: "Alpha beta", Source: "src1", Options: own},
+		{Content: "Gamma delta", Source: "src2"},
+	}
+	sess, err := NewSession(cards, state.GameOptions{TimerLimit: 100, FirstLetter: true}, &MockStorage{}, InOrder)
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+
+	// The card's options win over the session's
+	st := sess.CurrentGame.State
+	if st.Options.FirstLetter || st.Mask[0] != '_' {
+		t.Error("Expected the card to turn off first-letter")
+	}
+	if st.TimeLimit != 30 {
+		t.Errorf("Expected the card's own 30s timer, got %d", st.TimeLimit)
+	}
+
+	// Its own timer runs apart from the session clock
+	sess.CurrentGame.HandleTick()
+	sess.Update()
+	if sess.TimeRemaining != 100 {
+		t.Errorf("Expected the session clock untouched, got %d", sess.TimeRemaining)
+	}
+
+	sess.CurrentIndex++
+	if err := sess.NextGame(); err != nil {
+		t.Fatalf("NextGame failed: %v", err)
+	}
+	st = sess.CurrentGame.State
+	if !st.Options.FirstLetter || st.TimeLimit != 100 {
+		t.Errorf("Expected the next card to play with the session's options, got first-letter %v and %ds", st.Options.FirstLetter, st.TimeLimit)
+	}
+}
+
+func TestSession_Chain(t *testing.T) {
+	cards := []CardData{
+		{Content: "alpha", Title: "One"},
+		{Content: "beta gamma", Title: "Two"},
+		{Content: "\"Delta,\" said he", Title: "Three"},
+		{Content: "epsilon", Title: "Four"},
+	}
+	sess, err := NewSession(cards, state.GameOptions{}, &MockStorage{}, InOrder)
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+	sess.Chain = true
+	if sess.ChainPrompt != nil {
+		t.Fatalf("Expected no prompt before the first card, got %+v", sess.ChainPrompt)
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	sess.Now = func() time.Time { return now }
+
+	// next wins the current card and moves on to the next, which is asked about
+	next := func(word string) int {
+		t.Helper()
+		for _, r := range strings.ReplaceAll(string(sess.CurrentGame.State.Secret), " ", "") {
+			sess.CurrentGame.HandleKeyPress(string(r))
+		}
+		if _, err := sess.AdvanceOrEnd(); err != nil {
+			t.Fatalf("AdvanceOrEnd failed: %v", err)
+		}
+		if sess.ChainPrompt == nil || sess.ChainPrompt.Word != word {
+			t.Fatalf("Expected to be asked for %q, got %+v", word, sess.ChainPrompt)
+		}
+		return sess.TotalScore
+	}
+	// answer hands in typed, a minute after the question was asked
+	answer := func(typed string) (answered, right bool) {
+		now = now.Add(time.Minute)
+		for _, r := range typed {
+			sess.ChainKey(string(r))
+		}
+		return sess.ChainKey("enter")
+	}
+
+	// Right first time, whatever the case
+	before := next("beta")
+	if answered, right := answer("BETA"); !answered || !right || sess.ChainPrompt != nil {
+		t.Errorf("Expected the answer to be right, got answered=%v right=%v", answered, right)
+	}
+	if got := sess.TotalScore - before; got != 200 {
+		t.Errorf("Expected a chain bonus of 200, got %d", got)
+	}
+	// The card starts once the question is answered, and its score is its own
+	if sess.cardStartedAt != now || sess.CurrentGame.State.Score.CurrentScore != 0 {
+		t.Errorf("Expected the card to start at %v with no score, got %v and %d", now, sess.cardStartedAt, sess.CurrentGame.State.Score.CurrentScore)
+	}
+
+	// Wrong, then right on the retry, with the punctuation left out
+	before = next("Delta")
+	if answered, _ := answer("dleta"); answered || !sess.ChainPrompt.Missed || sess.ChainPrompt.Typed != "" {
+		t.Fatalf("Expected one more try, got %+v", sess.ChainPrompt)
+	}
+	sess.ChainKey("x")
+	sess.ChainKey("backspace")
+	if answered, right := answer("delta"); !answered || !right {
+		t.Errorf("Expected the retry to be right, got answered=%v right=%v", answered, right)
+	}
+	if got := sess.TotalScore - before; got != 200 {
+		t.Errorf("Expected a chain bonus of 200 after a retry, got %d", got)
+	}
+
+	// Wrong twice
+	before = next("epsilon")
+	answer("eta")
+	if answered, right := answer("zeta"); !answered || right || sess.ChainPrompt != nil {
+		t.Errorf("Expected the second wrong answer to close the prompt, got answered=%v right=%v", answered, right)
+	}
+	if got := sess.TotalScore - before; got != -100 {
+		t.Errorf("Expected a chain penalty of 100, got %d", got)
+	}
+	if sess.ChainScore != 300 {
+		t.Errorf("Expected 300 chain points in all, got %d", sess.ChainScore)
+	}
+
+	// The card is played as usual after a miss, and counts in full
+	before = sess.TotalScore
+	for _, r := range "epsilon" {
+		sess.CurrentGame.HandleKeyPress(string(r))
+	}
+	sess.Update()
+	st := sess.CurrentGame.State
+	if !st.Win || st.Score.CurrentScore <= 0 || sess.TotalScore != before+st.Score.CurrentScore {
+		t.Errorf("Expected the card to be won and added to the total, got win=%v score=%d total %d", st.Win, st.Score.CurrentScore, sess.TotalScore)
+	}
+	if outcome, err := sess.AdvanceOrEnd(); outcome != SessionComplete || err != nil {
+		t.Errorf("Expected the batch to be complete, got %v %v", outcome, err)
+	}
+}
+
+func TestSession_MixedModes(t *testing.T) {
+	var cards []CardData
+	for i := range 8 {
+		cards = append(cards, CardData{Content: fmt.Sprintf("card number %d of the deck", i), Title: fmt.Sprint(i)})
+	}
+	modes := func(seed int64) []string {
+		opts := state.GameOptions{MixedModes: true, Rand: rand.New(rand.NewSource(seed))}
+		sess, err := NewSession(slices.Clone(cards), opts, &MockStorage{}, InOrder)
+		if err != nil {
+			t.Fatalf("NewSession failed: %v", err)
+		}
+		var got []string
+		for {
+			st := sess.CurrentGame.State
+			switch sess.Mode {
+			case ModeFirstLetter:
+				if !st.Options.FirstLetter || st.Mask[0] != 'c' {
+					t.Errorf("Card %d: expected first letters revealed, got %q", sess.CurrentIndex, string(st.Mask))
+				}
+			case ModeRandomLetters:
+				if st.Options.FirstLetter || st.Options.NRandom != 4 {
+					t.Errorf("Card %d: expected 4 random letters, got %+v", sess.CurrentIndex, st.Options)
+				}
+			case ModeNone:
+				if st.HiddenShare() != 1 {
+					t.Errorf("Card %d: expected nothing revealed, got %q", sess.CurrentIndex, string(st.Mask))
+				}
+			}
+			// Easier modes score less
+			if sess.Mode != ModeNone && st.HiddenShare() >= 1 {
+				t.Errorf("Card %d: expected %s to lower the multiplier", sess.CurrentIndex, sess.Mode)
+			}
+			got = append(got, sess.Mode)
+			if sess.IsLastGame() {
+				return got
+			}
+			if _, err := sess.Skip(); err != nil {
+				t.Fatalf("Skip failed: %v", err)
+			}
+		}
+	}
+
+	want := []string{ModeNone, ModeFirstLetter, ModeFirstLetter, ModeFirstLetter, ModeNone, ModeNone, ModeRandomLetters, ModeRandomLetters}
+	if got := modes(7); !slices.Equal(got, want) {
+		t.Errorf("Expected modes %v, got %v", want, got)
+	}
+	if !slices.Equal(modes(7), modes(7)) {
+		t.Error("Expected the same seed to give the same modes")
+	}
+
+	// A card's OPTS: win over the assist picked, and the mode shown is theirs
+	opts, err := parseCardOptions("first-letter")
+	if err != nil {
+		t.Fatalf("parseCardOptions failed: %v", err)
+	}
+	card := CardData{Content: cards[0].Content, Title: "0", Options: opts}
+	sess, err := NewSession([]CardData{card}, state.GameOptions{MixedModes: true, Rand: rand.New(rand.NewSource(7))}, &MockStorage{}, InOrder)
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+	if sess.Mode != ModeFirstLetter || !sess.CurrentGame.State.Options.FirstLetter {
+		t.Errorf("Expected the card's first letters to set the mode, got %q", sess.Mode)
+	}
+}
+
+func TestSession_ReloadRetimes(t *testing.T) {
+	// At 60 WPM a card gets a second a word, and at least 10
+	words := func(n int) string { return strings.TrimSpace(strings.Repeat("word ", n)) }
+	cards := []CardData{{Content: words(20)}, {Content: words(30)}}
+	opts := state.GameOptions{TimerLimit: -1, WPMTarget: 60}
+	sess, err := NewSession(slices.Clone(cards), opts, &MockStorage{}, InOrder)
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+	if sess.TotalTimeLimit != 50 {
+		t.Fatalf("Expected 50s for the deck, got %d", sess.TotalTimeLimit)
+	}
+	sess.TimeRemaining = 45
+
+	// The second card grows by 10 words and a third is added
+	if _, err := sess.Reload([]CardData{cards[0], {Content: words(40)}, {Content: words(15)}}); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if sess.TotalTimeLimit != 75 || sess.TimeRemaining != 70 {
+		t.Errorf("Expected 25s more, got a limit of %d with %d remaining", sess.TotalTimeLimit, sess.TimeRemaining)
+	}
+
+	// A fixed limit stays as it is
+	opts.TimerLimit = 100
+	sess, _ = NewSession(slices.Clone(cards), opts, &MockStorage{}, InOrder)
+	sess.Reload([]CardData{cards[0], {Content: words(40)}})
+	if sess.TotalTimeLimit != 100 || sess.TimeRemaining != 100 {
+		t.Errorf("Expected a fixed limit to be kept, got %d with %d remaining", sess.TotalTimeLimit, sess.TimeRemaining)
+	}
+}
+
+func TestSession_AutoTimerCorruptScores(t *testing.T) {
+	// A best time of 20s for the card, then a record cut off
+	path := filepath.Join(t.TempDir(), "scores.json")
+	content := fmt.Sprintf(`{"hash":%q,"score":100,"timestamp":"t1","title":"One","durationMs":20000}
+{"hash":"def","sco`, scoring.TextHash("ab"))
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := NewSession([]CardData{{Content: "ab"}}, state.GameOptions{TimerLimit: -1}, scoring.NewJSONFileStorageAt(path), InOrder)
+	if err != nil {
+		t.Fatalf("Expected the game to start on the readable entries, got %v", err)
+	}
+	if sess.TotalTimeLimit != 25 {
+		t.Errorf("Expected the best time plus 25%%, 25s, got %d", sess.TotalTimeLimit)
+	}
+}
+
+func TestSession_ETA(t *testing.T) {
+	cards := []CardData{{Content: "a"}, {Content: "b"}, {Content: "c"}, {Content: "d"}, {Content: "e"}}
+	sess, err := NewSession(cards, state.GameOptions{}, &MockStorage{}, InOrder)
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+	clock := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	sess.Now = func() time.Time { return clock }
+	sess.cardStartedAt = clock
+
+	if eta, ok := sess.ETA(clock); ok {
+		t.Errorf("Expected no estimate before a card is won, got %v", eta)
+	}
+
+	// Won in 60s
+	clock = clock.Add(60 * time.Second)
+	sess.CurrentGame.HandleKeyPress("a")
+	sess.Update()
+	if done, total := sess.CardProgress(); done != 1 || total != 5 {
+		t.Errorf("Expected 1/5 cards played, got %d/%d", done, total)
+	}
+	if eta, _ := sess.ETA(clock); eta != 4*time.Minute {
+		t.Errorf("Expected 4 cards at 60s each, got %v", eta)
+	}
+
+	// Skipped after 100s, which doesn't count towards the average
+	if _, err := sess.AdvanceOrEnd(); err != nil {
+		t.Fatalf("AdvanceOrEnd failed: %v", err)
+	}
+	clock = clock.Add(100 * time.Second)
+	if _, err := sess.Skip(); err != nil {
+		t.Fatalf("Skip failed: %v", err)
+	}
+
+	// Won in 120s, for an average of 90s
+	clock = clock.Add(120 * time.Second)
+	sess.CurrentGame.HandleKeyPress("c")
+	sess.Update()
+	if done, total := sess.CardProgress(); done != 3 || total != 5 {
+		t.Errorf("Expected 3/5 cards played, skips included, got %d/%d", done, total)
+	}
+	if eta, _ := sess.ETA(clock); eta != 180*time.Second {
+		t.Errorf("Expected 2 cards at 90s each, got %v", eta)
+	}
+
+	// Time on the card being played comes off it, up to a card's worth
+	if _, err := sess.AdvanceOrEnd(); err != nil {
+		t.Fatalf("AdvanceOrEnd failed: %v", err)
+	}
+	if eta, _ := sess.ETA(clock.Add(30 * time.Second)); eta != 150*time.Second {
+		t.Errorf("Expected 30s off the estimate, got %v", eta)
+	}
+	if eta, _ := sess.ETA(clock.Add(10 * time.Minute)); eta != 90*time.Second {
+		t.Errorf("Expected the last card still estimated when the current one overruns, got %v", eta)
+	}
+
+	// Flash cards aren't scored, but still time the batch
+	sess, err = NewSession(cards, state.GameOptions{Flash: true}, &MockStorage{}, InOrder)
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+	clock = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	sess.Now = func() time.Time { return clock }
+	sess.cardStartedAt = clock
+	clock = clock.Add(20 * time.Second)
+	sess.CurrentGame.HandleKeyPress(" ")
+	sess.CurrentGame.HandleKeyPress("backspace")
+	sess.Update()
+	if eta, ok := sess.ETA(clock); !ok || eta != 80*time.Second || len(sess.Results) != 0 {
+		t.Errorf("Expected 4 flash cards at 20s each, got %v (%v) with %d results", eta, ok, len(sess.Results))
+	}
+}
@@ -0,0 +1,71 @@
+package game
+
+import (
+	"go-mem/internal/scoring"
+	"sort"
+	"time"
+)
+
+// StaleCard is a card that hasn't been practised recently, as found by
+// StaleCards.
+type StaleCard struct {
+	Card       CardData
+	Played     bool      // Whether the card has a score entry with a readable timestamp
+	LastPlayed time.Time // When its most recent entry was saved, zero if never played
+	DaysSince  int       // Whole days since LastPlayed, -1 if never played
+	BestScore  int       // Its highest score, 0 if never played or only abandoned
+}
+
+// StaleCards returns the cards whose most recent score entry is more than
+// days old at now, or that have never been played, the stalest first: cards
+// never played, then the longest since they were played. Entries with a
+// malformed timestamp are ignored, so a card with only those counts as never
+// played.
+func StaleCards(cards []CardData, entries []scoring.ScoreHistoryEntry, now time.Time, days int) []StaleCard {
+	type history struct {
+		last   time.Time
+		best   int
+		scored bool // Whether best is set, by an entry that wasn't abandoned
+	}
+	byHash := make(map[string]history)
+	for _, e := range entries {
+		t, err := time.Parse(time.RFC3339, e.Timestamp)
+		if err != nil {
+			continue
+		}
+		h := byHash[e.Hash]
+		if t.After(h.last) {
+			h.last = t
+		}
+		if e.Outcome != scoring.OutcomeAbandoned && (!h.scored || e.Score > h.best) {
+			h.best = e.Score
+			h.scored = true
+		}
+		byHash[e.Hash] = h
+	}
+
+	cutoff := now.Add(-time.Duration(days) * 24 * time.Hour)
+	stale := []StaleCard{}
+	for _, card := range cards {
+		h, played := byHash[scoring.TextHash(card.Content)]
+		if played && h.last.After(cutoff) {
+			continue
+		}
+		s := StaleCard{Card: card, DaysSince: -1}
+		if played {
+			s.Played = true
+			s.LastPlayed = h.last
+			s.DaysSince = int(now.Sub(h.last).Hours() / 24)
+			s.BestScore = h.best
+		}
+		stale = append(stale, s)
+	}
+
+	sort.SliceStable(stale, func(i, j int) bool {
+		if stale[i].Played != stale[j].Played {
+			return !stale[i].Played
+		}
+		return stale[i].LastPlayed.Before(stale[j].LastPlayed)
+	})
+	return stale
+}
@@ -0,0 +1,56 @@
+package game
+
+import (
+	"go-mem/internal/scoring"
+	"testing"
+	"time"
+)
+
+func TestStaleCards(t *testing.T) {
+	now := time.Date(2026, 3, 20, 9, 0, 0, 0, time.UTC)
+	daysAgo := func(n int) string { return now.AddDate(0, 0, -n).Format(time.RFC3339) }
+
+	cards := []CardData{
+		{Content: "fresh", Title: "Fresh"},
+		{Content: "old", Title: "Old"},
+		{Content: "never", Title: "Never"},
+		{Content: "older", Title: "Older"},
+		{Content: "garbled", Title: "Garbled"},
+	}
+	entries := []scoring.ScoreHistoryEntry{
+		{Hash: scoring.TextHash("fresh"), Score: 500, Timestamp: daysAgo(2)},
+		{Hash: scoring.TextHash("fresh"), Score: 900, Timestamp: daysAgo(30)},
+		{Hash: scoring.TextHash("old"), Score: 300, Timestamp: daysAgo(10)},
+		{Hash: scoring.TextHash("old"), Score: 700, Timestamp: daysAgo(12)},
+		{Hash: scoring.TextHash("old"), Score: 1000, Timestamp: daysAgo(11), Outcome: scoring.OutcomeAbandoned},
+		{Hash: scoring.TextHash("older"), Score: 100, Timestamp: daysAgo(40)},
+		{Hash: scoring.TextHash("garbled"), Score: 800, Timestamp: "yesterday"},
+	}
+
+	stale := StaleCards(cards, entries, now, 7)
+	var titles []string
+	for _, s := range stale {
+		titles = append(titles, s.Card.Title)
+	}
+	want := []string{"Never", "Garbled", "Older", "Old"}
+	if len(titles) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, titles)
+	}
+	for i := range want {
+		if titles[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, titles)
+		}
+	}
+
+	if s := stale[3]; !s.Played || s.DaysSince != 10 || s.BestScore != 700 {
+		t.Errorf("Expected Old last played 10 days ago with a best of 700, not the abandoned 1000, got %+v", s)
+	}
+	if s := stale[1]; s.Played || s.DaysSince != -1 || s.BestScore != 0 {
+		t.Errorf("Expected a malformed timestamp to count as never played, got %+v", s)
+	}
+
+	// A longer window leaves only the cards not played within it
+	if stale := StaleCards(cards, entries, now, 35); len(stale) != 3 || stale[2].Card.Title != "Older" {
+		t.Errorf("Expected Never, Garbled and Older, got %+v", stale)
+	}
+}
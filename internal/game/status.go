@@ -0,0 +1,60 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-mem/internal/scoring"
+)
+
+// Status is a snapshot of the session's progress, for status bars and other
+// tools watching it from outside.
+type Status struct {
+	Card          int    `json:"card"`                    // Number of the current card, from 1
+	Total         int    `json:"total"`                   // Number of cards in the session
+	Score         int    `json:"score"`                   // Session total, with the current card's score so far
+	TimeRemaining int    `json:"timeRemaining,omitempty"` // Seconds left, if the timer is on
+	Outcome       string `json:"outcome"`                 // playing, won or lost for the current card, or complete
+}
+
+// Status returns the session's progress.
+func (s *Session) Status() Status {
+	st := Status{
+		Card:    min(s.CurrentIndex+1, len(s.Cards)),
+		Total:   len(s.Cards),
+		Score:   s.TotalScore,
+		Outcome: "playing",
+	}
+	if s.IsFinished() || s.CurrentGame == nil {
+		st.Outcome = "complete"
+		return st
+	}
+
+	gs := s.CurrentGame.State
+	if gs.TimerEnabled {
+		st.TimeRemaining = gs.TimeRemaining
+	}
+	switch {
+	case gs.Win:
+		st.Outcome = "won" // Its score is already in the total
+	case gs.Loss:
+		st.Outcome = "lost"
+	case !s.Cards[s.CurrentIndex].Drill && !s.GameOptions.Flash:
+		st.Score += gs.Score.CurrentScore
+	}
+	return st
+}
+
+// WriteStatus writes the session's Status to path as JSON. The file is
+// replaced by renaming a temporary file over it, so a reader never sees it
+// half written.
+func (s *Session) WriteStatus(path string) error {
+	data, err := json.Marshal(s.Status())
+	if err != nil {
+		return fmt.Errorf("error encoding status: %w", err)
+	}
+
+	if err := scoring.WriteFileAtomic(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("error writing status file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package game
+
+import (
+	"encoding/json"
+	"go-mem/internal/state"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSession_WriteStatus(t *testing.T) {
+	cards := []CardData{{Content: "A"}, {Content: "B"}}
+	sess, err := NewSession(cards, state.GameOptions{TimerLimit: 60}, &MockStorage{}, InOrder)
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "status.json")
+
+	read := func() Status {
+		t.Helper()
+		if err := sess.WriteStatus(path); err != nil {
+			t.Fatalf("WriteStatus failed: %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Could not read status file: %v", err)
+		}
+		var got Status
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Status file is not JSON: %v\n%s", err, data)
+		}
+		return got
+	}
+
+	want := Status{Card: 1, Total: 2, Score: sess.CurrentGame.State.Score.CurrentScore, TimeRemaining: 60, Outcome: "playing"}
+	if got := read(); got != want {
+		t.Errorf("Expected %+v at the start, got %+v", want, got)
+	}
+
+	sess.CurrentGame.HandleTick()
+	sess.CurrentGame.HandleKeyPress("A")
+	sess.Update()
+	want = Status{Card: 1, Total: 2, Score: sess.TotalScore, TimeRemaining: 59, Outcome: "won"}
+	if got := read(); got != want {
+		t.Errorf("Expected %+v after a win, got %+v", want, got)
+	}
+
+	if _, err := sess.AdvanceOrEnd(); err != nil {
+		t.Fatalf("AdvanceOrEnd failed: %v", err)
+	}
+	if got := read(); got.Card != 2 || got.Outcome != "playing" {
+		t.Errorf("Expected the second card to be playing, got %+v", got)
+	}
+
+	sess.CurrentGame.HandleKeyPress("B")
+	if _, err := sess.AdvanceOrEnd(); err != nil {
+		t.Fatalf("AdvanceOrEnd failed: %v", err)
+	}
+	want = Status{Card: 2, Total: 2, Score: sess.TotalScore, Outcome: "complete"}
+	if got := read(); got != want {
+		t.Errorf("Expected %+v once complete, got %+v", want, got)
+	}
+
+	// Only the status file is left, without temporary files
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the status file in the directory, got %d entries", len(entries))
+	}
+
+	if err := sess.WriteStatus(filepath.Join(dir, "missing", "status.json")); err == nil {
+		t.Error("Expected an error writing to a missing directory")
+	}
+}
@@ -0,0 +1,52 @@
+package game
+
+import (
+	"go-mem/internal/scoring"
+	"time"
+)
+
+// StudyTime is how long a card has been studied, as found by StudyTimes.
+type StudyTime struct {
+	Card     CardData
+	Attempts int           // Attempts with a recorded play time
+	Total    time.Duration // Play time of all its attempts
+	ThisWeek time.Duration // Play time of its attempts in the last 7 days
+}
+
+// Average returns the mean play time of the card's attempts, 0 if it has none.
+func (s StudyTime) Average() time.Duration {
+	if s.Attempts == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Attempts)
+}
+
+// StudyTimes returns how long each card has been studied at now, from the
+// play times recorded in entries, in the order of cards. Entries saved before
+// play times were recorded have none, and are left out, so they don't drag
+// the averages down. An attempt with a malformed timestamp still counts
+// towards the total, but not towards this week.
+func StudyTimes(cards []CardData, entries []scoring.ScoreHistoryEntry, now time.Time) []StudyTime {
+	weekAgo := now.AddDate(0, 0, -7)
+	byHash := make(map[string]StudyTime)
+	for _, e := range entries {
+		if e.DurationSec <= 0 {
+			continue
+		}
+		d := time.Duration(e.DurationSec) * time.Second
+		s := byHash[e.Hash]
+		s.Attempts++
+		s.Total += d
+		if t, err := time.Parse(time.RFC3339, e.Timestamp); err == nil && t.After(weekAgo) {
+			s.ThisWeek += d
+		}
+		byHash[e.Hash] = s
+	}
+
+	times := make([]StudyTime, len(cards))
+	for i, card := range cards {
+		times[i] = byHash[scoring.TextHash(card.Content)]
+		times[i].Card = card
+	}
+	return times
+}
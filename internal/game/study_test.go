@@ -0,0 +1,38 @@
+package game
+
+import (
+	"go-mem/internal/scoring"
+	"testing"
+	"time"
+)
+
+func TestStudyTimes(t *testing.T) {
+	now := time.Date(2026, 3, 20, 9, 0, 0, 0, time.UTC)
+	daysAgo := func(n int) string { return now.AddDate(0, 0, -n).Format(time.RFC3339) }
+
+	cards := []CardData{
+		{Content: "psalm", Title: "Psalm"},
+		{Content: "never", Title: "Never"},
+		{Content: "old", Title: "Old"},
+	}
+	entries := []scoring.ScoreHistoryEntry{
+		{Hash: scoring.TextHash("psalm"), DurationSec: 60, Timestamp: daysAgo(1)},
+		{Hash: scoring.TextHash("psalm"), DurationSec: 90, Timestamp: daysAgo(10)},
+		{Hash: scoring.TextHash("psalm"), DurationSec: 30, Timestamp: "yesterday"},
+		{Hash: scoring.TextHash("old"), Timestamp: daysAgo(2)}, // Saved before play times were recorded
+	}
+
+	times := StudyTimes(cards, entries, now)
+	if len(times) != 3 || times[0].Card.Title != "Psalm" || times[1].Card.Title != "Never" {
+		t.Fatalf("Expected a time for each card in order, got %+v", times)
+	}
+	psalm := times[0]
+	if psalm.Attempts != 3 || psalm.Total != 3*time.Minute || psalm.ThisWeek != time.Minute || psalm.Average() != time.Minute {
+		t.Errorf("Expected 3 attempts, 3m in all, 1m this week and 1m each, got %+v", psalm)
+	}
+	for _, s := range times[1:] {
+		if s.Attempts != 0 || s.Total != 0 || s.Average() != 0 {
+			t.Errorf("Expected no study time for %s, got %+v", s.Card.Title, s)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package game
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CardWatcher notices edits to card files, so that a session can pick them up
+// between cards.
+type CardWatcher struct {
+	paths  []string
+	opts   LoadOptions
+	mtimes map[string]time.Time
+}
+
+// NewCardWatcher starts watching the files that paths (as given to
+// LoadCardsWithOptions) refer to.
+func NewCardWatcher(paths []string, opts LoadOptions) (*CardWatcher, error) {
+	w := &CardWatcher{paths: paths, opts: opts}
+	mtimes, err := w.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	w.mtimes = mtimes
+	return w, nil
+}
+
+// Changed reports whether any card file was modified, added or removed since
+// the last call. A file that can't be read counts as removed.
+func (w *CardWatcher) Changed() bool {
+	mtimes, err := w.snapshot()
+	if err != nil {
+		mtimes = map[string]time.Time{}
+	}
+	changed := !maps.EqualFunc(w.mtimes, mtimes, time.Time.Equal)
+	w.mtimes = mtimes
+	return changed
+}
+
+// Load loads the watched cards again, with the options they were first loaded with.
+func (w *CardWatcher) Load() ([]CardData, error) {
+	cards, _, err := LoadCardsWithOptions(w.paths, w.opts)
+	return cards, err
+}
+
+// snapshot returns the modification time of every watched file.
+// Directories are looked into, like LoadCardsWithOptions does.
+func (w *CardWatcher) snapshot() (map[string]time.Time, error) {
+	paths, err := expandGlobs(w.paths)
+	if err != nil {
+		return nil, err
+	}
+
+	mtimes := make(map[string]time.Time)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to access path %s: %w", path, err)
+		}
+		if !info.IsDir() {
+			mtimes[path] = info.ModTime()
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dir %s: %w", path, err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return nil, fmt.Errorf("failed to access path %s: %w", filepath.Join(path, entry.Name()), err)
+			}
+			mtimes[filepath.Join(path, entry.Name())] = info.ModTime()
+		}
+	}
+	return mtimes, nil
+}
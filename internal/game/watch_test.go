@@ -0,0 +1,74 @@
+package game
+
+import (
+	"go-mem/internal/state"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCardWatcher_ReloadMidSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cards.txt")
+	write := func(content string, mtime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now().Add(-time.Hour)
+	write("A\n---\nB\n---\nC\n", start)
+
+	watcher, err := NewCardWatcher([]string{path}, LoadOptions{})
+	if err != nil {
+		t.Fatalf("NewCardWatcher failed: %v", err)
+	}
+	cards, err := watcher.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	sess, _ := NewSession(cards, state.GameOptions{TimerLimit: 0}, &MockStorage{}, InOrder)
+
+	sess.CurrentGame.HandleKeyPress("A")
+	if watcher.Changed() {
+		t.Error("Expected no change before the file is edited")
+	}
+
+	// Edit the file between cards: the played card stays, C is replaced
+	write("A\n---\nB\n---\nD\n---\nE\n", start.Add(time.Minute))
+	if !watcher.Changed() {
+		t.Fatal("Expected the edit to be noticed")
+	}
+	if watcher.Changed() {
+		t.Error("Expected the change to be reported only once")
+	}
+	cards, err = watcher.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if changed, err := sess.Reload(cards); changed != 2 || err != nil {
+		t.Errorf("Expected 2 changed cards, got %d (%v)", changed, err)
+	}
+
+	var got string
+	for _, c := range sess.Cards {
+		got += c.Content
+	}
+	if got != "ABDE" {
+		t.Errorf("Expected cards ABDE, got %s", got)
+	}
+
+	// Progress carries on with the next card, and the first result is kept
+	if outcome, err := sess.AdvanceOrEnd(); err != nil || outcome != Continue {
+		t.Fatalf("Expected to continue, got %v %v", outcome, err)
+	}
+	if sess.CurrentIndex != 1 || string(sess.CurrentGame.State.Secret) != "B" {
+		t.Errorf("Expected to be on card B, got index %d", sess.CurrentIndex)
+	}
+	if len(sess.Results) != 1 || sess.Results[0].Title == "" {
+		t.Errorf("Expected the first card's result to be kept, got %+v", sess.Results)
+	}
+}
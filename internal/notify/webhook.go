@@ -0,0 +1,148 @@
+// Package notify tells other services about finished sessions, such as a
+// habit tracker logging study activity.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go-mem/internal/game"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is the longest Send takes, over both of its attempts, so a
+// slow webhook holds up the exit for at most this long.
+const DefaultTimeout = 3 * time.Second
+
+// SignatureHeader carries the HMAC-SHA256 of the body, keyed with the webhook
+// secret, as "sha256=" and the hex digest.
+const SignatureHeader = "X-Go-Mem-Signature"
+
+// SessionReport is the summary of a session sent to a webhook.
+type SessionReport struct {
+	TotalScore     int          `json:"totalScore"`
+	CardsCompleted int          `json:"cardsCompleted"` // Cards won, by every player in versus mode
+	Cards          int          `json:"cards"`          // Cards in the session
+	DurationSec    int          `json:"durationSec"`
+	Accuracy       float64      `json:"accuracy"` // Percentage of the letters typed in completed cards that were right
+	Results        []CardReport `json:"results"`
+}
+
+// CardReport is the result of one completed card in a SessionReport.
+type CardReport struct {
+	Title     string  `json:"title"`
+	Player    string  `json:"player,omitempty"`
+	Score     int     `json:"score"`
+	Errors    int     `json:"errors"`
+	Hints     int     `json:"hints"`
+	Accuracy  float64 `json:"accuracy"`
+	HighScore bool    `json:"highScore"`
+}
+
+// NewSessionReport summarises sess as it stands at now.
+func NewSessionReport(sess *game.Session, now time.Time) SessionReport {
+	r := SessionReport{
+		TotalScore:     sess.TotalScore,
+		CardsCompleted: len(sess.Results),
+		Cards:          len(sess.Cards),
+		DurationSec:    int(now.Sub(sess.StartedAt).Seconds()),
+		Accuracy:       100,
+		Results:        []CardReport{},
+	}
+	correct, errors := 0, 0
+	for _, res := range sess.Results {
+		r.Results = append(r.Results, CardReport{
+			Title:     res.Title,
+			Player:    res.Player,
+			Score:     res.Score,
+			Errors:    res.Errors,
+			Hints:     res.Hints,
+			Accuracy:  accuracy(res.Correct, res.Errors),
+			HighScore: res.HighScore,
+		})
+		correct += res.Correct
+		errors += res.Errors
+	}
+	r.Accuracy = accuracy(correct, errors)
+	return r
+}
+
+// accuracy returns the percentage of typed letters that were right, 100 if
+// none were typed.
+func accuracy(correct, errors int) float64 {
+	if correct+errors == 0 {
+		return 100
+	}
+	return float64(correct) / float64(correct+errors) * 100
+}
+
+// Webhook posts session reports as JSON to a URL.
+type Webhook struct {
+	url     string
+	secret  string
+	client  *http.Client
+	Timeout time.Duration // The most Send takes, shared by both attempts
+}
+
+// NewWebhook returns a webhook for url. If secret isn't empty, each report
+// is signed with it in the SignatureHeader.
+func NewWebhook(url, secret string) (*Webhook, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("invalid webhook URL: %q", url)
+	}
+	return &Webhook{url: url, secret: secret, client: &http.Client{}, Timeout: DefaultTimeout}, nil
+}
+
+// Send posts the report, trying once more if the first attempt fails. Each
+// attempt gets half of the Timeout.
+func (w *Webhook) Send(report SessionReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("error encoding session report: %w", err)
+	}
+	err = w.post(body)
+	if err != nil {
+		err = w.post(body)
+	}
+	return err
+}
+
+// post makes one attempt at sending body.
+func (w *Webhook) post(body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), w.Timeout/2)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(body, w.secret))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error reaching webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the SignatureHeader value for body, keyed with secret.
+func Sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,128 @@
+package notify
+
+import (
+	"encoding/json"
+	"go-mem/internal/game"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewSessionReport(t *testing.T) {
+	start := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	sess := &game.Session{
+		Cards:      make([]game.CardData, 3),
+		TotalScore: 1500,
+		StartedAt:  start,
+		Results: []game.CardResult{
+			{Title: "A", Score: 1000, Correct: 9, Errors: 1, HighScore: true},
+			{Title: "B", Score: 500, Correct: 6, Errors: 4, Hints: 2},
+		},
+	}
+
+	r := NewSessionReport(sess, start.Add(90*time.Second))
+	if r.TotalScore != 1500 || r.CardsCompleted != 2 || r.Cards != 3 || r.DurationSec != 90 {
+		t.Errorf("Unexpected totals: %+v", r)
+	}
+	if r.Accuracy != 75 {
+		t.Errorf("Expected 75%% accuracy over both cards, got %g", r.Accuracy)
+	}
+	if len(r.Results) != 2 || r.Results[0].Accuracy != 90 || !r.Results[0].HighScore || r.Results[1].Hints != 2 {
+		t.Errorf("Unexpected card results: %+v", r.Results)
+	}
+
+	// A session with nothing completed still reports a list
+	empty := NewSessionReport(&game.Session{StartedAt: start}, start)
+	if data, _ := json.Marshal(empty); !json.Valid(data) || empty.Results == nil || empty.Accuracy != 100 {
+		t.Errorf("Unexpected empty report: %s", data)
+	}
+}
+
+func TestWebhook_Send(t *testing.T) {
+	var got SessionReport
+	var signature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+		if r.Method != http.MethodPost || Sign(body, "s3cret") != signature {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.Unmarshal(body, &got)
+	}))
+	defer srv.Close()
+
+	hook, err := NewWebhook(srv.URL, "s3cret")
+	if err != nil {
+		t.Fatalf("NewWebhook failed: %v", err)
+	}
+	report := SessionReport{TotalScore: 42, CardsCompleted: 1, Results: []CardReport{{Title: "A", Score: 42}}}
+	if err := hook.Send(report); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if got.TotalScore != 42 || len(got.Results) != 1 || got.Results[0].Title != "A" {
+		t.Errorf("Expected the report to arrive, got %+v", got)
+	}
+
+	// Without a secret there is no signature
+	hook, _ = NewWebhook(srv.URL, "")
+	hook.Send(report)
+	if signature != "" {
+		t.Errorf("Expected no signature without a secret, got %q", signature)
+	}
+
+	if _, err := NewWebhook("ftp://example.com", ""); err == nil {
+		t.Error("Expected an error for a URL that isn't http")
+	}
+}
+
+func TestWebhook_Retry(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	hook, _ := NewWebhook(srv.URL, "")
+	if err := hook.Send(SessionReport{}); err != nil || calls.Load() != 2 {
+		t.Errorf("Expected the second attempt to get through, got %v after %d calls", err, calls.Load())
+	}
+
+	// Both attempts failing is an error
+	var failures atomic.Int32
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failures.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	hook, _ = NewWebhook(failing.URL, "")
+	if err := hook.Send(SessionReport{}); err == nil || failures.Load() != 2 {
+		t.Errorf("Expected an error after two attempts, got %v after %d calls", err, failures.Load())
+	}
+}
+
+func TestWebhook_Timeout(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done // Never answers while the test runs
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	hook, _ := NewWebhook(srv.URL, "")
+	hook.Timeout = 200 * time.Millisecond
+	start := time.Now()
+	err := hook.Send(SessionReport{})
+	if err == nil {
+		t.Fatal("Expected an error from a webhook that never answers")
+	}
+	if elapsed := time.Since(start); elapsed > hook.Timeout+150*time.Millisecond {
+		t.Errorf("Expected Send to give up within %v, took %v", hook.Timeout, elapsed)
+	}
+}
@@ -0,0 +1,215 @@
+package scoring
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// An encrypted scores file starts with a header that holds everything needed
+// to decrypt it apart from the passphrase:
+//
+//	magic    "GMEM"
+//	version  1 byte, currently 1
+//	salt     16 bytes, for scrypt
+//	nonce    12 bytes, for AES-GCM
+//
+// The rest is the JSON entries, sealed with AES-256-GCM. The header is
+// authenticated along with them, so it can't be changed unnoticed either.
+const (
+	encMagic    = "GMEM"
+	encVersion  = 1
+	encSaltSize = 16
+	encKeySize  = 32
+)
+
+// scrypt cost parameters. Changing them needs a new version, as they aren't
+// stored in the file.
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+var (
+	// ErrWrongPassphrase is returned by LoadAll when an encrypted scores file
+	// can't be decrypted. AES-GCM can't tell a wrong passphrase from a file
+	// that was changed, so this covers both.
+	ErrWrongPassphrase = errors.New("wrong passphrase, or the scores file has been tampered with")
+	// ErrNotEncrypted is returned when an encrypted storage finds a plaintext scores file.
+	ErrNotEncrypted = errors.New("scores file is not encrypted (run go-mem migrate-encrypt first)")
+	// ErrEncrypted is returned when a plaintext storage finds an encrypted scores file.
+	ErrEncrypted = errors.New("scores file is encrypted (play with --encrypt-scores)")
+	// ErrAlreadyEncrypted is returned when migrating a scores file that is already encrypted.
+	ErrAlreadyEncrypted = errors.New("scores file is already encrypted")
+)
+
+// EncryptedJSONStorage is a ScoreStorage that keeps the entries of a
+// JSONFileStorage encrypted with a key derived from a passphrase, so that
+// card titles and hashes can't be read from the file.
+type EncryptedJSONStorage struct {
+	file       *JSONFileStorage
+	passphrase []byte
+	// The salt of the file last read or written, and the key derived from it,
+	// so that scrypt only runs once per session
+	salt, key []byte
+	// The last file couldn't be decrypted, so saving would overwrite it
+	wrongKey bool
+}
+
+// NewEncryptedJSONStorage encrypts the scores kept by file with passphrase.
+func NewEncryptedJSONStorage(file *JSONFileStorage, passphrase string) (*EncryptedJSONStorage, error) {
+	if passphrase == "" {
+		return nil, errors.New("the scores passphrase can't be empty")
+	}
+	return &EncryptedJSONStorage{file: file, passphrase: []byte(passphrase)}, nil
+}
+
+// LoadAll decrypts and decodes all score entries from the file.
+func (s *EncryptedJSONStorage) LoadAll() ([]ScoreHistoryEntry, error) {
+	data, err := os.ReadFile(s.file.path)
+	// If the file doesn't exist, it's not an error; return an empty slice.
+	if os.IsNotExist(err) {
+		return []ScoreHistoryEntry{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening scores file for reading: %w", err)
+	}
+	if len(data) == 0 {
+		return []ScoreHistoryEntry{}, nil
+	}
+
+	plain, err := s.open(data)
+	if err != nil {
+		return nil, err
+	}
+	return decodeEntries(bytes.NewReader(plain))
+}
+
+// SaveAll encrypts and writes all score entries to the file, replacing it
+// like JSONFileStorage does. The file is only readable by its owner.
+func (s *EncryptedJSONStorage) SaveAll(entries []ScoreHistoryEntry) error {
+	if s.wrongKey {
+		return ErrWrongPassphrase
+	}
+	plain, err := encodeEntries(entries)
+	if err != nil {
+		return err
+	}
+	sealed, err := s.seal(plain)
+	if err != nil {
+		return err
+	}
+	return s.file.writeFile(sealed, 0600)
+}
+
+// Lock takes the same lock as the underlying JSONFileStorage.
+func (s *EncryptedJSONStorage) Lock() (func(), error) {
+	return s.file.Lock()
+}
+
+// MigratePlaintext encrypts the existing plaintext scores file in place and
+// returns the number of entries in it. Nothing is written unless every entry
+// could be read.
+func (s *EncryptedJSONStorage) MigratePlaintext() (int, error) {
+	unlock, err := s.Lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	data, err := os.ReadFile(s.file.path)
+	if err != nil {
+		return 0, fmt.Errorf("error opening scores file for reading: %w", err)
+	}
+	if bytes.HasPrefix(data, []byte(encMagic)) {
+		return 0, ErrAlreadyEncrypted
+	}
+	entries, err := decodeEntries(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), s.SaveAll(entries)
+}
+
+// open checks the header of an encrypted file and decrypts the rest.
+func (s *EncryptedJSONStorage) open(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(encMagic)) {
+		return nil, ErrNotEncrypted
+	}
+	if len(data) <= len(encMagic) {
+		return nil, fmt.Errorf("%w: encrypted header is cut short", ErrCorruptScores)
+	}
+	if version := data[len(encMagic)]; version != encVersion {
+		return nil, fmt.Errorf("unsupported encrypted scores version %d", version)
+	}
+
+	saltStart := len(encMagic) + 1
+	salt := data[saltStart : saltStart+min(encSaltSize, len(data)-saltStart)]
+	if len(salt) < encSaltSize {
+		return nil, fmt.Errorf("%w: encrypted header is cut short", ErrCorruptScores)
+	}
+	aead, err := s.aead(salt)
+	if err != nil {
+		return nil, err
+	}
+	headerSize := saltStart + encSaltSize + aead.NonceSize()
+	if len(data) < headerSize {
+		return nil, fmt.Errorf("%w: encrypted header is cut short", ErrCorruptScores)
+	}
+
+	header := data[:headerSize]
+	plain, err := aead.Open(nil, header[headerSize-aead.NonceSize():], data[headerSize:], header)
+	s.wrongKey = err != nil
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plain, nil
+}
+
+// seal encrypts plain under a fresh nonce and prepends the header. The salt
+// of the file that was loaded is kept, or a new one is made for a new file.
+func (s *EncryptedJSONStorage) seal(plain []byte) ([]byte, error) {
+	salt := s.salt
+	if salt == nil {
+		salt = make([]byte, encSaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("error generating salt: %w", err)
+		}
+	}
+	aead, err := s.aead(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("error generating nonce: %w", err)
+	}
+	header := append([]byte(encMagic), encVersion)
+	header = append(header, salt...)
+	header = append(header, nonce...)
+	return aead.Seal(header, nonce, plain, bytes.Clone(header)), nil
+}
+
+// aead returns the cipher for the key derived from the passphrase and salt.
+func (s *EncryptedJSONStorage) aead(salt []byte) (cipher.AEAD, error) {
+	if s.key == nil || !bytes.Equal(salt, s.salt) {
+		key, err := scrypt.Key(s.passphrase, salt, scryptN, scryptR, scryptP, encKeySize)
+		if err != nil {
+			return nil, fmt.Errorf("error deriving key: %w", err)
+		}
+		s.salt, s.key = bytes.Clone(salt), key
+	}
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,229 @@
+package scoring
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// httpTimeout bounds each request to the score server.
+const httpTimeout = 10 * time.Second
+
+// HTTPStorage is a ScoreStorage kept on a server, so that several machines
+// can share a history. The server holds the entries as a JSON array at
+// <url>/entries: GET returns them and PUT replaces them. Requests carry the
+// token as a bearer token.
+//
+// Everything is also kept in a local cache, which is used while the server
+// can't be reached. Entries saved meanwhile are merged into the server's on
+// the next save that gets through. Saves are sent to the server in the
+// background, so that a slow server doesn't hold up the game.
+type HTTPStorage struct {
+	url    string
+	token  string
+	client *http.Client
+	cache  ScoreStorage
+
+	mu      sync.Mutex     // Guards syncErr and saves
+	syncErr error          // Why the server couldn't be reached the last time it was tried
+	saves   int            // Saves made so far, so that a push can tell it was overtaken
+	pushMu  sync.Mutex     // Keeps to one request to replace the server's entries at a time
+	pushing sync.WaitGroup // Saves still being sent to the server
+}
+
+// NewHTTPStorage returns a storage for the server at url, caching the entries in cache.
+func NewHTTPStorage(url, token string, cache ScoreStorage) (*HTTPStorage, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("invalid score server URL: %q", url)
+	}
+	return &HTTPStorage{
+		url:    strings.TrimSuffix(url, "/") + "/entries",
+		token:  token,
+		client: &http.Client{Timeout: httpTimeout},
+		cache:  cache,
+	}, nil
+}
+
+// LoadAll returns the server's entries merged with the cached ones, or just
+// the cached ones if the server can't be reached.
+func (h *HTTPStorage) LoadAll() ([]ScoreHistoryEntry, error) {
+	local, err := h.cache.LoadAll()
+	if err != nil && !errors.Is(err, ErrCorruptScores) {
+		return nil, err
+	}
+
+	remote, err := h.fetch()
+	if err != nil {
+		h.setSyncErr(err)
+		return local, nil
+	}
+	merged := MergeEntries(local, remote)
+	if err := h.cache.SaveAll(merged); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// SaveAll saves the entries to the cache, then, in the background, to the
+// server together with any entries another machine saved since they were
+// loaded. A save still waiting to be sent when a later one is made is
+// dropped, as the later one holds its entries too. Failing to reach the
+// server isn't an error, see SyncErr.
+func (h *HTTPStorage) SaveAll(entries []ScoreHistoryEntry) error {
+	if err := h.cache.SaveAll(entries); err != nil {
+		return err
+	}
+
+	entries = slices.Clone(entries)
+	h.mu.Lock()
+	h.saves++
+	save := h.saves
+	h.mu.Unlock()
+
+	h.pushing.Add(1)
+	go func() {
+		defer h.pushing.Done()
+		h.pushMu.Lock()
+		defer h.pushMu.Unlock()
+
+		h.mu.Lock()
+		overtaken := save != h.saves
+		h.mu.Unlock()
+		if overtaken {
+			return
+		}
+		remote, err := h.fetch()
+		if err == nil {
+			err = h.put(MergeEntries(entries, remote))
+		}
+		h.setSyncErr(err)
+	}()
+	return nil
+}
+
+// ReplaceAll replaces the entries on the server and in the cache with
+// entries, without merging in the server's, for rewrites that drop or re-key
+// entries, such as clearing the history. Unlike SaveAll it waits for the
+// server, and fails if it can't be reached, leaving the cache as it was, as
+// the server would otherwise bring the dropped entries back on the next save.
+func (h *HTTPStorage) ReplaceAll(entries []ScoreHistoryEntry) error {
+	h.Flush()
+	h.pushMu.Lock()
+	defer h.pushMu.Unlock()
+
+	if err := h.put(entries); err != nil {
+		h.setSyncErr(err)
+		return err
+	}
+	h.setSyncErr(nil)
+	return h.cache.SaveAll(entries)
+}
+
+// Flush waits for the saves still being sent to the server.
+func (h *HTTPStorage) Flush() {
+	h.pushing.Wait()
+}
+
+// Lock takes the cache's lock, if it has one, so that games on this machine
+// don't overwrite each other's entries.
+func (h *HTTPStorage) Lock() (func(), error) {
+	if l, ok := h.cache.(Locker); ok {
+		return l.Lock()
+	}
+	return func() {}, nil
+}
+
+// SyncErr returns why the server couldn't be reached the last time it was
+// tried, or nil if it could, once the saves still being sent are through.
+// The entries are in the cache either way.
+func (h *HTTPStorage) SyncErr() error {
+	h.Flush()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.syncErr
+}
+
+func (h *HTTPStorage) setSyncErr(err error) {
+	h.mu.Lock()
+	h.syncErr = err
+	h.mu.Unlock()
+}
+
+// fetch gets the server's entries.
+func (h *HTTPStorage) fetch() ([]ScoreHistoryEntry, error) {
+	body, err := h.do(http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	var entries []ScoreHistoryEntry
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return nil, fmt.Errorf("error decoding entries from score server: %w", err)
+	}
+	return entries, nil
+}
+
+// put replaces the server's entries.
+func (h *HTTPStorage) put(entries []ScoreHistoryEntry) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("error encoding entries for score server: %w", err)
+	}
+	_, err = h.do(http.MethodPut, data)
+	return err
+}
+
+// do sends a request for the entries and returns the response body.
+func (h *HTTPStorage) do(method string, data []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, h.url, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request to score server: %w", err)
+	}
+	if h.token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.token)
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error reaching score server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response from score server: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("score server returned %s for %s %s", resp.Status, method, h.url)
+	}
+	return body, nil
+}
+
+// MergeEntries combines two lists of entries, dropping duplicates. Entries
+// are the same if they are for the same text at the same time; the local one
+// is kept, as it may have been saved again since. Local entries come first,
+// then the remote ones that are new, each in their own order.
+func MergeEntries(local, remote []ScoreHistoryEntry) []ScoreHistoryEntry {
+	type key struct{ hash, timestamp string }
+	seen := make(map[key]bool, len(local)+len(remote))
+	merged := make([]ScoreHistoryEntry, 0, len(local)+len(remote))
+	for _, list := range [][]ScoreHistoryEntry{local, remote} {
+		for _, entry := range list {
+			k := key{entry.Hash, entry.Timestamp}
+			if !seen[k] {
+				seen[k] = true
+				merged = append(merged, entry)
+			}
+		}
+	}
+	return merged
+}
@@ -0,0 +1,238 @@
+package scoring
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMergeEntries(t *testing.T) {
+	a := ScoreHistoryEntry{Hash: "a", Timestamp: "2025-01-01T10:00:00Z", Score: 100}
+	aResaved := ScoreHistoryEntry{Hash: "a", Timestamp: "2025-01-01T10:00:00Z", Score: 150}
+	b := ScoreHistoryEntry{Hash: "b", Timestamp: "2025-01-01T10:00:00Z", Score: 200}
+	aLater := ScoreHistoryEntry{Hash: "a", Timestamp: "2025-01-02T10:00:00Z", Score: 300}
+
+	tests := []struct {
+		name          string
+		local, remote []ScoreHistoryEntry
+		want          []ScoreHistoryEntry
+	}{
+		{"both empty", nil, nil, []ScoreHistoryEntry{}},
+		{"only local", []ScoreHistoryEntry{a}, nil, []ScoreHistoryEntry{a}},
+		{"only remote", nil, []ScoreHistoryEntry{b}, []ScoreHistoryEntry{b}},
+		{"disjoint", []ScoreHistoryEntry{a}, []ScoreHistoryEntry{b, aLater}, []ScoreHistoryEntry{a, b, aLater}},
+		{"identical", []ScoreHistoryEntry{a, b}, []ScoreHistoryEntry{a, b}, []ScoreHistoryEntry{a, b}},
+		// Same text and time: the local save wins
+		{"conflict", []ScoreHistoryEntry{aResaved}, []ScoreHistoryEntry{a, b}, []ScoreHistoryEntry{aResaved, b}},
+		// Same time on different texts isn't a duplicate
+		{"same time", []ScoreHistoryEntry{b}, []ScoreHistoryEntry{a}, []ScoreHistoryEntry{b, a}},
+		{"duplicates within a list", []ScoreHistoryEntry{a, a}, nil, []ScoreHistoryEntry{a}},
+	}
+	for _, tt := range tests {
+		got := MergeEntries(tt.local, tt.remote)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if !containsEntry(tt.want[i:i+1], got[i]) {
+				t.Errorf("%s: entry %d: expected %+v, got %+v", tt.name, i, tt.want[i], got[i])
+			}
+		}
+	}
+}
+
+// scoreServer is a minimal in-memory implementation of the score server API.
+type scoreServer struct {
+	mu      sync.Mutex
+	token   string
+	entries []ScoreHistoryEntry
+}
+
+func (s *scoreServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.URL.Path != "/entries" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+s.token {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(s.entries)
+	case http.MethodPut:
+		var entries []ScoreHistoryEntry
+		if err := json.NewDecoder(r.Body).Decode(&entries); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.entries = entries
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func TestHTTPStorage_Sync(t *testing.T) {
+	remote := ScoreHistoryEntry{Hash: "r", Timestamp: "2025-01-01T10:00:00Z", Score: 100}
+	server := &scoreServer{token: "secret", entries: []ScoreHistoryEntry{remote}}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	cache := NewJSONFileStorageAt(filepath.Join(t.TempDir(), "scores.json"))
+	storage, err := NewHTTPStorage(ts.URL+"/", "secret", cache)
+	if err != nil {
+		t.Fatalf("NewHTTPStorage returned error: %v", err)
+	}
+
+	entries, err := storage.LoadAll()
+	if err != nil || len(entries) != 1 || !containsEntry(entries, remote) {
+		t.Fatalf("Expected the server's entry, got %v (%v)", entries, err)
+	}
+	if cached, _ := cache.LoadAll(); len(cached) != 1 {
+		t.Errorf("Expected the server's entries to be cached, got %v", cached)
+	}
+
+	// Another machine saves meanwhile; both entries must survive
+	other := ScoreHistoryEntry{Hash: "o", Timestamp: "2025-01-02T10:00:00Z", Score: 200}
+	server.entries = append(server.entries, other)
+	local := ScoreHistoryEntry{Hash: "l", Timestamp: "2025-01-03T10:00:00Z", Score: 300}
+	if err := storage.SaveAll(append(entries, local)); err != nil {
+		t.Fatalf("SaveAll returned error: %v", err)
+	}
+	if storage.SyncErr() != nil {
+		t.Errorf("Expected no sync error, got %v", storage.SyncErr())
+	}
+	if len(server.entries) != 3 {
+		t.Errorf("Expected 3 entries on the server, got %v", server.entries)
+	}
+
+	wrongToken, _ := NewHTTPStorage(ts.URL, "wrong", cache)
+	wrongToken.LoadAll()
+	if wrongToken.SyncErr() == nil {
+		t.Error("Expected a wrong token to be reported as a sync error")
+	}
+
+	if _, err := NewHTTPStorage("example.com", "", cache); err == nil {
+		t.Error("Expected a URL without a scheme to be rejected")
+	}
+}
+
+func TestHTTPStorage_Offline(t *testing.T) {
+	server := &scoreServer{token: "secret"}
+	ts := httptest.NewServer(server)
+	url := ts.URL
+	ts.Close() // Unreachable from the start
+
+	cache := NewJSONFileStorageAt(filepath.Join(t.TempDir(), "scores.json"))
+	storage, _ := NewHTTPStorage(url, "secret", cache)
+
+	// Scores are still loaded and saved, locally
+	if entries, err := storage.LoadAll(); err != nil || len(entries) != 0 {
+		t.Fatalf("Expected an empty history while offline, got %v (%v)", entries, err)
+	}
+	offline := ScoreHistoryEntry{Hash: "a", Timestamp: "2025-01-01T10:00:00Z", Score: 100}
+	if err := storage.SaveAll([]ScoreHistoryEntry{offline}); err != nil {
+		t.Fatalf("Expected saving while offline not to fail, got %v", err)
+	}
+	if storage.SyncErr() == nil {
+		t.Error("Expected the failure to reach the server to be reported")
+	}
+	if cached, _ := cache.LoadAll(); len(cached) != 1 || !containsEntry(cached, offline) {
+		t.Errorf("Expected the entry to be cached, got %v", cached)
+	}
+
+	// Back online, the offline entry is merged into the server's on the next save
+	ts = httptest.NewServer(server)
+	defer ts.Close()
+	server.entries = []ScoreHistoryEntry{{Hash: "b", Timestamp: "2025-01-02T10:00:00Z", Score: 200}}
+	storage, _ = NewHTTPStorage(ts.URL, "secret", cache)
+
+	entries, _ := storage.LoadAll()
+	online := ScoreHistoryEntry{Hash: "c", Timestamp: "2025-01-03T10:00:00Z", Score: 300}
+	if err := storage.SaveAll(append(entries, online)); err != nil || storage.SyncErr() != nil {
+		t.Fatalf("Expected the save to get through, got %v / %v", err, storage.SyncErr())
+	}
+	if len(server.entries) != 3 {
+		t.Errorf("Expected the offline, remote and new entries on the server, got %v", server.entries)
+	}
+}
+
+func TestHTTPStorage_SavesInBackground(t *testing.T) {
+	server := &scoreServer{token: "secret"}
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release // A slow server
+		server.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	cache := NewJSONFileStorageAt(filepath.Join(t.TempDir(), "scores.json"))
+	storage, _ := NewHTTPStorage(ts.URL, "secret", cache)
+
+	entry := ScoreHistoryEntry{Hash: "a", Timestamp: "2025-01-01T10:00:00Z", Score: 100}
+	saved := make(chan error)
+	go func() { saved <- storage.SaveAll([]ScoreHistoryEntry{entry}) }()
+	select {
+	case err := <-saved:
+		if err != nil {
+			t.Fatalf("SaveAll returned error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected SaveAll not to wait for the server")
+	}
+	if cached, _ := cache.LoadAll(); len(cached) != 1 {
+		t.Errorf("Expected the entry to be cached straight away, got %v", cached)
+	}
+
+	close(release)
+	if err := storage.SyncErr(); err != nil {
+		t.Errorf("Expected no sync error, got %v", err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.entries) != 1 || !containsEntry(server.entries, entry) {
+		t.Errorf("Expected the entry on the server once sent, got %v", server.entries)
+	}
+}
+
+func TestHTTPStorage_ReplaceAll(t *testing.T) {
+	a := ScoreHistoryEntry{Hash: "a", Timestamp: "2025-01-01T10:00:00Z", Score: 100}
+	b := ScoreHistoryEntry{Hash: "b", Timestamp: "2025-01-02T10:00:00Z", Score: 200}
+	c := ScoreHistoryEntry{Hash: "c", Timestamp: "2025-01-03T10:00:00Z", Score: 300}
+	server := &scoreServer{token: "secret", entries: []ScoreHistoryEntry{a, b}}
+	ts := httptest.NewServer(server)
+
+	cache := NewJSONFileStorageAt(filepath.Join(t.TempDir(), "scores.json"))
+	storage, _ := NewHTTPStorage(ts.URL, "secret", cache)
+	if entries, _ := storage.LoadAll(); len(entries) != 2 {
+		t.Fatalf("Expected the server's 2 entries, got %v", entries)
+	}
+
+	// Dropping an entry drops it from the server too, for good
+	if err := ReplaceAll(storage, []ScoreHistoryEntry{a}); err != nil {
+		t.Fatalf("ReplaceAll returned error: %v", err)
+	}
+	if err := storage.SaveAll([]ScoreHistoryEntry{a, c}); err != nil {
+		t.Fatalf("SaveAll returned error: %v", err)
+	}
+	storage.Flush()
+	if len(server.entries) != 2 || containsEntry(server.entries, b) {
+		t.Errorf("Expected the dropped entry to stay gone from the server, got %v", server.entries)
+	}
+
+	// Offline, nothing is replaced, so the server can't bring entries back
+	ts.Close()
+	if err := ReplaceAll(storage, nil); err == nil {
+		t.Error("Expected replacing the entries to fail while offline")
+	}
+	if cached, _ := cache.LoadAll(); len(cached) != 2 {
+		t.Errorf("Expected the cache to be left as it was, got %v", cached)
+	}
+}
@@ -0,0 +1,33 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly || windows)
+
+package scoring
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Lock files older than this are assumed to be left over from a crash.
+const staleLockAge = 30 * time.Second
+
+// lockFile falls back to an exclusively created lock file where no OS file
+// locking is available, waiting for any other holder to remove it.
+func lockFile(path string) (func(), error) {
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("error creating lock file: %w", err)
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			os.Remove(path)
+			continue
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
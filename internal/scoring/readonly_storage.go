@@ -0,0 +1,23 @@
+package scoring
+
+// ReadOnlyStorage is a ScoreStorage that loads from another storage but never
+// writes to it, for playing where nothing should be persisted, such as a
+// shared or read-only filesystem. Previous bests are still shown.
+type ReadOnlyStorage struct {
+	storage ScoreStorage
+}
+
+// NewReadOnlyStorage returns a storage that loads from storage and discards saves.
+func NewReadOnlyStorage(storage ScoreStorage) *ReadOnlyStorage {
+	return &ReadOnlyStorage{storage: storage}
+}
+
+// LoadAll returns the wrapped storage's entries.
+func (r *ReadOnlyStorage) LoadAll() ([]ScoreHistoryEntry, error) {
+	return r.storage.LoadAll()
+}
+
+// SaveAll does nothing, leaving the wrapped storage as it was.
+func (r *ReadOnlyStorage) SaveAll(entries []ScoreHistoryEntry) error {
+	return nil
+}
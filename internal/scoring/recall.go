@@ -0,0 +1,89 @@
+package scoring
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// RecallGrade is how close a text typed from memory came to the original, in words.
+type RecallGrade struct {
+	Words int // Words in the original
+	Typed int // Words in the attempt
+	Edits int // Words inserted, deleted, changed or swapped to turn the attempt into the original
+}
+
+// GradeRecall compares an attempt at recalling secret word by word. Case and
+// punctuation are ignored, as are line breaks and how many spaces are used.
+func GradeRecall(secret, attempt string) RecallGrade {
+	want, got := recallWords(secret), recallWords(attempt)
+	return RecallGrade{Words: len(want), Typed: len(got), Edits: WordEdits(want, got)}
+}
+
+// Similarity returns how similar the attempt was to the original, from 0
+// (nothing in common) to 1 (the same words in the same order).
+func (g RecallGrade) Similarity() float64 {
+	if g.Edits == 0 {
+		return 1
+	}
+	return 1 - float64(g.Edits)/float64(max(g.Words, g.Typed))
+}
+
+// recallWords splits text into lowercase words, without their punctuation.
+func recallWords(text string) []string {
+	var words []string
+	for _, field := range strings.Fields(text) {
+		word := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, field)
+		if word != "" {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// WordEdits returns the fewest edits that turn got into want, where an edit
+// inserts, deletes or changes one word, or swaps two neighbouring ones. This is
+// the optimal string alignment distance, taken over words instead of letters.
+func WordEdits(want, got []string) int {
+	// d[i][j] is the distance between the first i words of want and the first j of got
+	d := make([][]int, len(want)+1)
+	for i := range d {
+		d[i] = make([]int, len(got)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(want); i++ {
+		for j := 1; j <= len(got); j++ {
+			cost := 1
+			if want[i-1] == got[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && want[i-1] == got[j-2] && want[i-2] == got[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(want)][len(got)]
+}
+
+// ScoreRecall scores an attempt at recalling the text from memory, as the
+// share of max given by its similarity. The words recalled and the edits are
+// counted as right and wrong letters, so that accuracy follows the grade.
+func (s *Scoring) ScoreRecall(grade RecallGrade, max int) {
+	s.CorrectCount = grade.Words - min(grade.Edits, grade.Words)
+	s.ErrorCount = grade.Edits
+	s.CurrentScore = int(math.Round(grade.Similarity() * float64(max)))
+	if s.history.CurrentScore != nil {
+		s.history.CurrentScore.Score = s.CurrentScore
+		s.history.CurrentScore.Accuracy = s.Accuracy()
+	}
+}
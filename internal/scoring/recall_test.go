@@ -0,0 +1,108 @@
+package scoring
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWordEdits(t *testing.T) {
+	tests := []struct {
+		name      string
+		want, got string
+		edits     int
+	}{
+		{"identical", "the quick brown fox", "the quick brown fox", 0},
+		{"both empty", "", "", 0},
+		{"nothing typed", "the quick brown fox", "", 4},
+		{"only extra words", "", "the fox", 2},
+		{"insertion", "the brown fox", "the quick brown fox", 1},
+		{"insertion at the end", "the brown fox", "the brown fox jumps", 1},
+		{"deletion", "the quick brown fox", "the brown fox", 1},
+		{"deletion at the start", "the quick brown fox", "quick brown fox", 1},
+		{"substitution", "the quick brown fox", "the quick red fox", 1},
+		{"transposition", "the quick brown fox", "the brown quick fox", 1},
+		{"transposition at the end", "the quick brown fox", "the quick fox brown", 1},
+		{"two transpositions", "a b c d", "b a d c", 2},
+		// A swap of words that aren't neighbours is two changes
+		{"distant swap", "a b c", "c b a", 2},
+		{"insertion and deletion", "the quick brown fox", "quick brown fox jumps", 2},
+		{"all different", "one two three", "four five six", 3},
+		{"repeated words", "to be or not to be", "to be or to be", 1},
+	}
+	for _, tt := range tests {
+		got := WordEdits(strings.Fields(tt.want), strings.Fields(tt.got))
+		if got != tt.edits {
+			t.Errorf("%s: expected %d edits, got %d", tt.name, tt.edits, got)
+		}
+		// Turning one into the other takes as many edits either way
+		if back := WordEdits(strings.Fields(tt.got), strings.Fields(tt.want)); back != got {
+			t.Errorf("%s: expected the distance to be symmetric, got %d and %d", tt.name, got, back)
+		}
+	}
+}
+
+func TestGradeRecall(t *testing.T) {
+	secret := "The Lord is my shepherd;\nI shall not want."
+
+	tests := []struct {
+		name       string
+		attempt    string
+		edits      int
+		similarity float64
+	}{
+		{"exact", "The Lord is my shepherd;\nI shall not want.", 0, 1},
+		// Case, punctuation and line breaks don't matter
+		{"loose", "the lord is my shepherd i  shall not want", 0, 1},
+		{"missing word", "The Lord is my shepherd I shall want", 1, 1 - 1.0/9},
+		{"swapped words", "The Lord my is shepherd I shall not want", 1, 1 - 1.0/9},
+		{"changed word", "The Lord is my shepherd I will not want", 1, 1 - 1.0/9},
+		// Extra words count against the longer of the two
+		{"extra words", "The Lord is my good shepherd and I shall not want", 2, 1 - 2.0/11},
+		{"nothing", "", 9, 0},
+		{"punctuation only", ".,;", 9, 0},
+	}
+	for _, tt := range tests {
+		grade := GradeRecall(secret, tt.attempt)
+		if grade.Words != 9 {
+			t.Errorf("%s: expected 9 words, got %d", tt.name, grade.Words)
+		}
+		if grade.Edits != tt.edits {
+			t.Errorf("%s: expected %d edits, got %d", tt.name, tt.edits, grade.Edits)
+		}
+		if got := grade.Similarity(); got < tt.similarity-1e-9 || got > tt.similarity+1e-9 {
+			t.Errorf("%s: expected similarity %.3f, got %.3f", tt.name, tt.similarity, got)
+		}
+	}
+
+	if got := GradeRecall("", "").Similarity(); got != 1 {
+		t.Errorf("Expected an empty text recalled as nothing to be similar, got %.3f", got)
+	}
+}
+
+func TestScoreRecall(t *testing.T) {
+	storage := &MockScoreStorage{}
+	sc, _ := InitScoring("one two three four", "Title", storage)
+
+	sc.ScoreRecall(RecallGrade{Words: 4, Typed: 4, Edits: 1}, 2000)
+	if sc.CurrentScore != 1500 {
+		t.Errorf("Expected three quarters of the max, got %d", sc.CurrentScore)
+	}
+	if sc.CorrectCount != 3 || sc.ErrorCount != 1 || sc.Accuracy() != 75 {
+		t.Errorf("Expected 3 right and 1 wrong (75%%), got %d, %d (%.0f%%)", sc.CorrectCount, sc.ErrorCount, sc.Accuracy())
+	}
+
+	// The attempt is saved to the same history as a typed game of the text
+	if err := sc.SaveEntries(); err != nil {
+		t.Fatalf("SaveEntries returned error: %v", err)
+	}
+	typed, _ := InitScoring("one two three four", "Title", storage)
+	if typed.GetAttempts() != 1 || typed.GetHighScore().Score != 1500 || typed.GetHighScore().Accuracy != 75 {
+		t.Errorf("Expected the recall attempt in the text's history, got %+v", typed.GetHighScore())
+	}
+
+	// More edits than words is no score, not a negative one
+	sc.ScoreRecall(RecallGrade{Words: 2, Typed: 6, Edits: 6}, 2000)
+	if sc.CurrentScore != 0 || sc.CorrectCount != 0 {
+		t.Errorf("Expected nothing recalled to score 0, got %d with %d right", sc.CurrentScore, sc.CorrectCount)
+	}
+}
@@ -2,6 +2,7 @@ package scoring
 
 import (
 	"sort"
+	"time"
 )
 
 // ScoreHistory holds the score data for a particular text, including
@@ -15,10 +16,34 @@ type ScoreHistory struct {
 
 // ScoreHistoryEntry represents a single score record for a given text.
 type ScoreHistoryEntry struct {
-	Hash      string `json:"hash"`
-	Score     int    `json:"score"`
-	Timestamp string `json:"timestamp"`
-	Title     string `json:"title"`
+	Hash          string       `json:"hash"`
+	Score         int          `json:"score"`
+	Timestamp     string       `json:"timestamp"`
+	Title         string       `json:"title"`
+	Accuracy      float64      `json:"accuracy,omitempty"`     // Percentage of correctly typed letters
+	DurationMs    int64        `json:"durationMs,omitempty"`   // Time taken to complete the text, wins only
+	DurationSec   int          `json:"durationSec,omitempty"`  // Time from the first keypress to the end of the attempt, won or lost
+	PercentOfMax  float64      `json:"percentOfMax,omitempty"` // Score of a win as a percentage of the most it could have been
+	WordTimings   []WordTiming `json:"wordTimings,omitempty"`
+	WordOffsetsMs []int64      `json:"wordOffsetsMs,omitempty"` // When each word was completed from the start of a win, for --ghost
+	WordErrors    map[int]int  `json:"wordErrors,omitempty"`    // Letters mistyped in each word, keyed by word index
+	Outcome       string       `json:"outcome,omitempty"`       // How the attempt ended, one of the Outcome constants; empty for entries saved before it was recorded
+	HashVersion   int          `json:"hashVersion,omitempty"`   // How Hash was computed; see HashVersion
+}
+
+// Outcomes of an attempt, as recorded in ScoreHistoryEntry.Outcome.
+const (
+	OutcomeWon       = "won"
+	OutcomeLost      = "lost"
+	OutcomeRevealed  = "revealed"  // The player gave up and revealed the text
+	OutcomeAbandoned = "abandoned" // The player quit the game before it ended
+)
+
+// WordTiming records how long it took to complete a single word of a text.
+type WordTiming struct {
+	Index      int    `json:"index"` // Position of the word within the text
+	Word       string `json:"word"`
+	DurationMs int64  `json:"durationMs"`
 }
 
 // GetHighScoreEntry returns the highest score entry from the loaded history.
@@ -37,6 +62,19 @@ func (sh ScoreHistory) GetNScoreEntries(n int) []ScoreHistoryEntry {
 		entries = append(entries, *sh.CurrentScore)
 	}
 
+	return topEntries(entries, n)
+}
+
+// GetNHistoricalEntries returns the top N previously saved score entries,
+// sorted by score. Unlike GetNScoreEntries, the current score is left out.
+func (sh ScoreHistory) GetNHistoricalEntries(n int) []ScoreHistoryEntry {
+	entries := make([]ScoreHistoryEntry, len(sh.Entries))
+	copy(entries, sh.Entries)
+	return topEntries(entries, n)
+}
+
+// topEntries sorts entries by score, highest first, and returns up to n of them.
+func topEntries(entries []ScoreHistoryEntry, n int) []ScoreHistoryEntry {
 	sort.Slice(entries, func(i, j int) bool {
 		return entries[i].Score > entries[j].Score
 	})
@@ -47,12 +85,105 @@ func (sh ScoreHistory) GetNScoreEntries(n int) []ScoreHistoryEntry {
 	return entries[:n]
 }
 
+// canBeHighScore reports whether an attempt that ended with outcome may be a
+// high score: one abandoned, or given up on by revealing the text, never is.
+func canBeHighScore(outcome string) bool {
+	return outcome != OutcomeAbandoned && outcome != OutcomeRevealed
+}
+
 // GotHighScore checks if the current score is greater than or equal to the
-// previously recorded high score.
+// previously recorded high score. An abandoned or revealed attempt never is
+// one.
 func (sh ScoreHistory) GotHighScore() bool {
+	return sh.BeatHighScore() || sh.TiedHighScore()
+}
+
+// BeatHighScore checks if the current score is greater than the previously
+// recorded high score. An abandoned or revealed attempt never is one.
+func (sh ScoreHistory) BeatHighScore() bool {
+	if sh.CurrentScore != nil && !canBeHighScore(sh.CurrentScore.Outcome) {
+		return false
+	}
 	if sh.HighScoreEntry == nil || sh.CurrentScore == nil {
 		// If there's no high score or no current score, it's vacuously a "high score".
 		return true
 	}
-	return sh.CurrentScore.Score >= sh.HighScoreEntry.Score
+	return sh.CurrentScore.Score > sh.HighScoreEntry.Score
+}
+
+// TiedHighScore checks if the current score equals the previously recorded
+// high score. An abandoned or revealed attempt never does.
+func (sh ScoreHistory) TiedHighScore() bool {
+	if sh.CurrentScore == nil || sh.HighScoreEntry == nil || !canBeHighScore(sh.CurrentScore.Outcome) {
+		return false
+	}
+	return sh.CurrentScore.Score == sh.HighScoreEntry.Score
+}
+
+// AttemptsToday counts the entries for hash saved on the same day as now, in
+// now's time zone. Entries with a malformed timestamp are ignored.
+func AttemptsToday(entries []ScoreHistoryEntry, hash string, now time.Time) int {
+	year, month, day := now.Date()
+	count := 0
+	for _, e := range entries {
+		if e.Hash != hash {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, e.Timestamp)
+		if err != nil {
+			continue
+		}
+		if y, m, d := t.In(now.Location()).Date(); y == year && m == month && d == day {
+			count++
+		}
+	}
+	return count
+}
+
+// MergeWordErrors adds up the letters mistyped in each word over all the
+// entries, keyed by word index. It returns nil if none were.
+func MergeWordErrors(entries []ScoreHistoryEntry) map[int]int {
+	var merged map[int]int
+	for _, e := range entries {
+		for word, n := range e.WordErrors {
+			if n <= 0 {
+				continue
+			}
+			if merged == nil {
+				merged = make(map[int]int)
+			}
+			merged[word] += n
+		}
+	}
+	return merged
+}
+
+// MaxTroubleLevel is the highest level TroubleLevel returns.
+const MaxTroubleLevel = 3
+
+// TroubleLevel buckets the letters mistyped in a word over earlier attempts
+// into how much it should stand out: 0 for none, then 1 for a single slip,
+// 2 for a few and MaxTroubleLevel for a word mistyped again and again.
+func TroubleLevel(errors int) int {
+	switch {
+	case errors <= 0:
+		return 0
+	case errors == 1:
+		return 1
+	case errors <= 3:
+		return 2
+	}
+	return MaxTroubleLevel
+}
+
+// RemoveEntries returns the entries that aren't for hash, and how many were
+// left out.
+func RemoveEntries(entries []ScoreHistoryEntry, hash string) ([]ScoreHistoryEntry, int) {
+	kept := make([]ScoreHistoryEntry, 0, len(entries))
+	for _, e := range entries {
+		if e.Hash != hash {
+			kept = append(kept, e)
+		}
+	}
+	return kept, len(entries) - len(kept)
 }
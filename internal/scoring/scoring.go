@@ -2,9 +2,14 @@ package scoring
 
 import (
 	"crypto/sha256"
+	"errors"
 	"fmt"
+	"math"
+	"slices"
 	"sort"
+	"strings"
 	"time"
+	"unicode"
 )
 
 // Scoring manages the game's scoring logic, including event handling,
@@ -13,8 +18,13 @@ type Scoring struct {
 	// public
 	CurrentScore   int
 	HintCount      int
+	WordHintCount  int // Whole words revealed with a word hint
+	NearMissCount  int // Keys next to the right one, when typos are forgiven
 	ErrorCount     int
+	CorrectCount   int
 	PotentialScore int
+	Multiplier     float64 // Difficulty: the share of the text hidden at the start, applied to the score of a win
+	MinAccuracy    float64 // Accuracy, as a percentage, an attempt needs for a high score
 	// private
 	storage    ScoreStorage // The interface for loading/saving scores.
 	history    ScoreHistory
@@ -25,17 +35,29 @@ type Scoring struct {
 // InitScoring creates and initializes a new Scoring object.
 // It loads the score history for the given text using the provided storage interface.
 func InitScoring(secretMessage string, title string, storage ScoreStorage) (*Scoring, error) {
+	return InitPlayerScoring(secretMessage, title, "", storage)
+}
+
+// InitPlayerScoring is like InitScoring, but keeps a separate score history
+// for the named player. An empty player uses the shared history.
+func InitPlayerScoring(secretMessage string, title string, player string, storage ScoreStorage) (*Scoring, error) {
+	key := NormalizeForHash(secretMessage)
+	if player != "" {
+		key += "\x00" + player
+	}
 	s := &Scoring{
+		Multiplier: 1,
 		scoreTable: getScoreTable(),
 		storage:    storage,
-		textHash:   calculateHash(secretMessage),
+		textHash:   calculateHash(key),
 	}
 	s.PotentialScore = s.scoreTable["baseScore"] * len(secretMessage)
 	s.CurrentScore = 0
 
 	// Load all historical entries from storage.
+	// A corrupt tail only loses the unreadable entries.
 	allEntries, err := s.storage.LoadAll()
-	if err != nil {
+	if err != nil && !errors.Is(err, ErrCorruptScores) {
 		return nil, fmt.Errorf("could not load score history: %w", err)
 	}
 
@@ -54,70 +76,209 @@ func InitScoring(secretMessage string, title string, storage ScoreStorage) (*Sco
 
 	s.history.Entries = filteredEntries
 	s.history.Attempts = len(filteredEntries)
-	if len(filteredEntries) > 0 {
-		s.history.HighScoreEntry = &filteredEntries[0]
-	}
+	s.findHighScore()
 
 	// Initialize the current session's score entry.
 	s.history.CurrentScore = &ScoreHistoryEntry{
-		Hash:      s.textHash,
-		Score:     s.CurrentScore,
-		Timestamp: time.Now().Format(time.RFC3339),
-		Title:     title,
+		Hash:        s.textHash,
+		Score:       s.CurrentScore,
+		Timestamp:   time.Now().Format(time.RFC3339),
+		Title:       title,
+		Accuracy:    s.Accuracy(),
+		HashVersion: HashVersion,
 	}
 
 	return s, nil
 }
 
+// Points returns what a scoring event adds to the score, negative for a
+// penalty.
+func (s *Scoring) Points(event string) int {
+	return s.scoreTable[event]
+}
+
 // ScoreEvent updates the score based on a given game event.
 func (s *Scoring) ScoreEvent(event string) {
 	switch event {
-	case "hint":
+	case "hint", "autoHint":
 		s.HintCount++
+	case "wordReveal":
+		s.WordHintCount++
+	case "nearMiss":
+		s.NearMissCount++
 	case "wrongLetter":
 		s.ErrorCount++
+	case "rightLetter":
+		s.CorrectCount++
 	}
 	s.CurrentScore += s.scoreTable[event]
 
 	// Update the current score entry in the history.
 	if s.history.CurrentScore != nil {
 		s.history.CurrentScore.Score = s.CurrentScore
+		s.history.CurrentScore.Accuracy = s.Accuracy()
+	}
+}
+
+// Accuracy returns the percentage (0-100) of typed letters that were correct.
+// Before anything has been typed it is 100.
+func (s *Scoring) Accuracy() float64 {
+	total := s.CorrectCount + s.ErrorCount
+	if total == 0 {
+		return 100
+	}
+	return float64(s.CorrectCount) / float64(total) * 100
+}
+
+// SetMultiplier sets the difficulty multiplier from how much of the text was
+// hidden at the start, from 0 (all of it revealed) to 1 (none of it).
+func (s *Scoring) SetMultiplier(m float64) {
+	s.Multiplier = max(0, min(m, 1))
+}
+
+// ApplyMultiplier scales the score by the difficulty multiplier, so that
+// assisted games score less. Call it once, when the game is won.
+func (s *Scoring) ApplyMultiplier() {
+	s.CurrentScore = int(math.Round(float64(s.CurrentScore) * s.Multiplier))
+	if s.history.CurrentScore != nil {
+		s.history.CurrentScore.Score = s.CurrentScore
+	}
+}
+
+// MaxPossible returns the most a perfect game can score: keys typed right,
+// words earning a word bonus, clean, the card's bonus, and, if timed, the
+// whole time limit left over as a time bonus. It is scaled by the difficulty
+// multiplier, so letters revealed at the start count for nothing.
+func (s *Scoring) MaxPossible(keys, words int, timed bool, timeLimit int) int {
+	max := keys*s.scoreTable["rightLetter"] + words*(s.scoreTable["wordBonus"]+s.scoreTable["cleanWordBonus"]) + s.scoreTable["messageBonus"]
+	if timed {
+		max += timeLimit * s.scoreTable["timeBonus"]
+	}
+	return int(math.Round(float64(max) * s.Multiplier))
+}
+
+// PercentOfMax returns the current score as a percentage of max.
+func (s *Scoring) PercentOfMax(max int) float64 {
+	if max <= 0 {
+		return 0
+	}
+	return float64(s.CurrentScore) / float64(max) * 100
+}
+
+// SetPercentOfMax records the current score as a percentage of max on the
+// current score entry. Call it before SaveEntries so it is persisted.
+func (s *Scoring) SetPercentOfMax(max int) {
+	if s.history.CurrentScore != nil {
+		s.history.CurrentScore.PercentOfMax = s.PercentOfMax(max)
 	}
 }
 
 func (s *Scoring) AddTimeBonus(seconds int) {
-	bonus := seconds * 10
+	bonus := seconds * s.scoreTable["timeBonus"]
 	s.CurrentScore += bonus
 	if s.history.CurrentScore != nil {
 		s.history.CurrentScore.Score = s.CurrentScore
 	}
 }
 
+// SetWordTimings attaches per-word timings to the current score entry.
+// Call it before SaveEntries so the timings are persisted.
+func (s *Scoring) SetWordTimings(timings []WordTiming) {
+	if s.history.CurrentScore != nil {
+		s.history.CurrentScore.WordTimings = timings
+	}
+}
+
+// SetWordOffsets attaches the time each word was completed at to the current
+// score entry. Call it before SaveEntries so the offsets are persisted.
+func (s *Scoring) SetWordOffsets(offsets []int64) {
+	if s.history.CurrentScore != nil {
+		s.history.CurrentScore.WordOffsetsMs = offsets
+	}
+}
+
+// SetWordErrors attaches the letters mistyped in each word to the current
+// score entry. Call it before SaveEntries so the counts are persisted.
+func (s *Scoring) SetWordErrors(errors map[int]int) {
+	if s.history.CurrentScore != nil {
+		s.history.CurrentScore.WordErrors = errors
+	}
+}
+
+// SetOutcome records how the current attempt ended, one of the Outcome
+// constants. Call it before SaveEntries so the outcome is persisted.
+func (s *Scoring) SetOutcome(outcome string) {
+	if s.history.CurrentScore != nil {
+		s.history.CurrentScore.Outcome = outcome
+	}
+}
+
+// WordErrorHistory returns the letters mistyped in each word of the text
+// over all its earlier attempts, nil if there are none.
+func (s *Scoring) WordErrorHistory() map[int]int {
+	return MergeWordErrors(s.history.Entries)
+}
+
+// GetWordTimings returns the per-word timings attached to the current score entry.
+func (s *Scoring) GetWordTimings() []WordTiming {
+	if s.history.CurrentScore == nil {
+		return nil
+	}
+	return s.history.CurrentScore.WordTimings
+}
+
+// SetDuration records how long the current game took to complete.
+// Call it before SaveEntries so the duration is persisted.
+func (s *Scoring) SetDuration(d time.Duration) {
+	if s.history.CurrentScore != nil {
+		s.history.CurrentScore.DurationMs = d.Milliseconds()
+	}
+}
+
+// SetPlayTime records how long the attempt lasted, won or lost, in whole seconds.
+// Call it before SaveEntries so the time is persisted.
+func (s *Scoring) SetPlayTime(d time.Duration) {
+	if s.history.CurrentScore != nil {
+		s.history.CurrentScore.DurationSec = int(d.Round(time.Second) / time.Second)
+	}
+}
+
 // SaveEntries persists the score for the completed game.
 // It reads all scores, updates the list, and writes it back using the storage interface.
+// Scores saved by other sessions in the meantime are kept, and if the storage
+// supports locking it is held for the whole read-modify-write.
 func (s *Scoring) SaveEntries() error {
 	if s.history.CurrentScore == nil {
 		return nil // Nothing to save.
 	}
 
+	if l, ok := s.storage.(Locker); ok {
+		unlock, err := l.Lock()
+		if err != nil {
+			return fmt.Errorf("could not lock scores for saving: %w", err)
+		}
+		defer unlock()
+	}
+
+	// A corrupt tail is dropped: the readable entries are rewritten below.
 	allEntries, err := s.storage.LoadAll()
-	if err != nil {
+	if err != nil && !errors.Is(err, ErrCorruptScores) {
 		return fmt.Errorf("could not load scores for saving: %w", err)
 	}
 
-	// Create a new list of entries, excluding any previous scores for the current text.
-	updatedEntries := make([]ScoreHistoryEntry, 0)
+	// Keep everything on disk except an earlier save of this same session.
+	current := *s.history.CurrentScore
+	updatedEntries := make([]ScoreHistoryEntry, 0, len(allEntries)+1)
 	for _, entry := range allEntries {
-		if entry.Hash != s.textHash {
+		if entry.Hash != s.textHash || entry.Timestamp != current.Timestamp {
 			updatedEntries = append(updatedEntries, entry)
 		}
 	}
+	updatedEntries = append(updatedEntries, current)
 
-	// Add the current session's score and all other historical scores for this text.
-	updatedEntries = append(updatedEntries, *s.history.CurrentScore)
+	// Restore any historical scores for this text that are no longer on disk.
 	for _, entry := range s.history.Entries {
-		// Ensure we don't add the current session twice if it was already in history (edge case).
-		if entry.Timestamp != s.history.CurrentScore.Timestamp {
+		if entry.Timestamp != current.Timestamp && !containsEntry(allEntries, entry) {
 			updatedEntries = append(updatedEntries, entry)
 		}
 	}
@@ -126,27 +287,205 @@ func (s *Scoring) SaveEntries() error {
 	return s.storage.SaveAll(updatedEntries)
 }
 
+// RehashEntries moves the version 0 entries of each of texts, saved under
+// the hash of the text exactly as it was, to its hash now, and returns how
+// many it moved. It is a one-time migration: the entries moved are marked
+// with HashVersion, and storage isn't touched at all unless one of texts
+// hashes differently now. Entries kept per player in versus mode, and those
+// of decks, aren't moved, as their old hashes can't be told from the texts.
+func RehashEntries(storage ScoreStorage, texts []string) (int, error) {
+	rekey := make(map[string]string)
+	for _, text := range texts {
+		if old, now := calculateHash(text), TextHash(text); old != now {
+			rekey[old] = now
+		}
+	}
+	if len(rekey) == 0 {
+		return 0, nil
+	}
+
+	if l, ok := storage.(Locker); ok {
+		unlock, err := l.Lock()
+		if err != nil {
+			return 0, fmt.Errorf("could not lock scores for rehashing: %w", err)
+		}
+		defer unlock()
+	}
+
+	entries, err := storage.LoadAll()
+	if err != nil && !errors.Is(err, ErrCorruptScores) {
+		return 0, fmt.Errorf("could not load scores for rehashing: %w", err)
+	}
+	moved := 0
+	for i, e := range entries {
+		if now, ok := rekey[e.Hash]; ok && e.HashVersion < HashVersion {
+			entries[i].Hash = now
+			entries[i].HashVersion = HashVersion
+			moved++
+		}
+	}
+	if moved == 0 {
+		return 0, nil
+	}
+	// The entries under the old hashes mustn't be merged back in
+	return moved, ReplaceAll(storage, entries)
+}
+
+// SaveEntry adds entry to the scores in storage, keeping the rest as they are.
+func SaveEntry(storage ScoreStorage, entry ScoreHistoryEntry) error {
+	if l, ok := storage.(Locker); ok {
+		unlock, err := l.Lock()
+		if err != nil {
+			return fmt.Errorf("could not lock scores for saving: %w", err)
+		}
+		defer unlock()
+	}
+
+	entries, err := storage.LoadAll()
+	if err != nil && !errors.Is(err, ErrCorruptScores) {
+		return fmt.Errorf("could not load scores for saving: %w", err)
+	}
+	return storage.SaveAll(append(entries, entry))
+}
+
+// containsEntry reports whether entries holds a score for the same text, time and value.
+func containsEntry(entries []ScoreHistoryEntry, e ScoreHistoryEntry) bool {
+	for _, x := range entries {
+		if x.Hash == e.Hash && x.Timestamp == e.Timestamp && x.Score == e.Score {
+			return true
+		}
+	}
+	return false
+}
+
 // Accessor methods for score history, delegating to the history object.
 func (s *Scoring) GetHighScore() *ScoreHistoryEntry {
 	return s.history.GetHighScoreEntry()
 }
 
+// GetBestWin returns the highest scoring saved attempt at the text that was
+// won, or nil if none was.
+func (s *Scoring) GetBestWin() *ScoreHistoryEntry {
+	for i, entry := range s.history.Entries {
+		if entry.Outcome == OutcomeWon {
+			return &s.history.Entries[i]
+		}
+	}
+	return nil
+}
+
+// HasFailed reports whether a saved attempt at the text was lost or given up
+// by revealing it.
+func (s *Scoring) HasFailed() bool {
+	for _, entry := range s.history.Entries {
+		if entry.Outcome == OutcomeLost || entry.Outcome == OutcomeRevealed {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Scoring) GetAttempts() int {
 	return s.history.Attempts
 }
 
+// GotHighScore reports whether the current score is a high score, either
+// beating or tying the best so far. An attempt less accurate than MinAccuracy
+// never is, whatever it scored.
 func (s *Scoring) GotHighScore() bool {
-	return s.history.GotHighScore()
+	return s.BeatHighScore() || s.TiedHighScore()
+}
+
+// BeatHighScore reports whether the current score is a new high score, above
+// the best so far, with MinAccuracy met.
+func (s *Scoring) BeatHighScore() bool {
+	return s.Accuracy() >= s.MinAccuracy && s.history.BeatHighScore()
+}
+
+// TiedHighScore reports whether the current score matches the best so far,
+// with MinAccuracy met.
+func (s *Scoring) TiedHighScore() bool {
+	return s.Accuracy() >= s.MinAccuracy && s.history.TiedHighScore()
+}
+
+// SetMinAccuracy sets the accuracy, as a percentage, that an attempt needs
+// for its score to count as a high score, 0 for any. The high score to beat
+// becomes the best one that met it; entries saved before accuracy was
+// recorded still count.
+func (s *Scoring) SetMinAccuracy(min float64) {
+	s.MinAccuracy = min
+	s.findHighScore()
+}
+
+// findHighScore sets the high score to beat: the best entry that met
+// MinAccuracy and wasn't abandoned.
+func (s *Scoring) findHighScore() {
+	s.history.HighScoreEntry = nil
+	for i, entry := range s.history.Entries {
+		if !canBeHighScore(entry.Outcome) {
+			continue
+		}
+		if entry.Accuracy == 0 || entry.Accuracy >= s.MinAccuracy {
+			s.history.HighScoreEntry = &s.history.Entries[i]
+			break
+		}
+	}
 }
 
 func (s *Scoring) GetNScoreEntries(n int) []ScoreHistoryEntry {
 	return s.history.GetNScoreEntries(n)
 }
 
+func (s *Scoring) GetNHistoricalEntries(n int) []ScoreHistoryEntry {
+	return s.history.GetNHistoricalEntries(n)
+}
+
+// AttemptsToday returns how many attempts at the text were saved on the same
+// day as now, before this one.
+func (s *Scoring) AttemptsToday(now time.Time) int {
+	return AttemptsToday(s.history.Entries, s.textHash, now)
+}
+
 func (s *Scoring) GetNumPrevious() int {
 	return len(s.history.Entries)
 }
 
+// HashVersion is the version of the text hashes that entries are saved with.
+// Version 1 hashes the text as NormalizeForHash leaves it; entries from
+// before it, version 0, hashed the text exactly as it was played, so a change
+// to the whitespace around a card or its line endings lost its scores.
+// RehashEntries moves such entries over, once.
+const HashVersion = 1
+
+// NormalizeForHash returns text as it is hashed: with Windows and old Mac
+// line endings as newlines, and the whitespace at the end of each line and
+// around the whole text trimmed. None of it is typed.
+func NormalizeForHash(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRightFunc(line, unicode.IsSpace)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// TextHash returns the hash that identifies a text in the score history.
+func TextHash(text string) string {
+	return calculateHash(NormalizeForHash(text))
+}
+
+// DeckHash returns the hash that identifies a deck of texts in the score
+// history, whatever order they are played in.
+func DeckHash(texts []string) string {
+	sorted := make([]string, len(texts))
+	for i, t := range texts {
+		sorted[i] = NormalizeForHash(t)
+	}
+	slices.Sort(sorted)
+	return calculateHash("deck\x00" + strings.Join(sorted, "\x00"))
+}
+
 // calculateHash generates a SHA256 hash for the given text.
 func calculateHash(text string) string {
 	return fmt.Sprintf("%x", sha256.Sum256([]byte(text)))
@@ -155,11 +494,20 @@ func calculateHash(text string) string {
 // getScoreTable returns the predefined values for different scoring events.
 func getScoreTable() map[string]int {
 	return map[string]int{
-		"baseScore":    10,
-		"rightLetter":  25,
-		"wrongLetter":  -50,
-		"hint":         -100,
-		"wordBonus":    250,
-		"messageBonus": 1000,
+		"baseScore":      10,
+		"rightLetter":    25,
+		"wrongLetter":    -50,
+		"nearMiss":       -20,
+		"hint":           -100,
+		"autoHint":       -50, // A hint given after --auto-hint seconds without a key
+		"wordReveal":     -200,
+		"revealAll":      -500, // Giving up and revealing the whole card
+		"chainBonus":     200,  // Recalling the first word of a card in chain mode
+		"chainMiss":      -100, // Failing to recall it twice
+		"linePeek":       -100, // Showing the current line for a moment with Ctrl+L
+		"wordBonus":      250,
+		"cleanWordBonus": 100, // On top of wordBonus, for a word typed without a mistake
+		"messageBonus":   1000,
+		"timeBonus":      10, // Per second left
 	}
 }
@@ -1,7 +1,11 @@
 package scoring
 
 import (
+	"errors"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 // MockScoreStorage is a mock implementation of the ScoreStorage interface
@@ -93,6 +97,118 @@ func TestInitScoring_WithHistory(t *testing.T) {
 	}
 }
 
+func TestGotHighScore_MinAccuracy(t *testing.T) {
+	secret := "hello world"
+	hash := calculateHash(secret)
+	mockStorage := &MockScoreStorage{
+		Entries: []ScoreHistoryEntry{
+			{Hash: hash, Score: 3000, Accuracy: 60}, // Sloppy, but high
+			{Hash: hash, Score: 1000, Accuracy: 95},
+		},
+	}
+
+	scoring, _ := InitScoring(secret, "Test", mockStorage)
+	scoring.SetMinAccuracy(90)
+	if high := scoring.GetHighScore(); high == nil || high.Score != 1000 {
+		t.Fatalf("expected the accurate 1000 to be the high score to beat, got %v", high)
+	}
+
+	// A low-accuracy win with a high raw score
+	for range 10 {
+		scoring.ScoreEvent("rightLetter")
+	}
+	for range 3 {
+		scoring.ScoreEvent("wrongLetter")
+	}
+	scoring.CurrentScore = 5000
+	scoring.history.CurrentScore.Score = 5000
+	if scoring.GotHighScore() {
+		t.Errorf("expected %.0f%% accuracy not to make a high score under a 90%% gate", scoring.Accuracy())
+	}
+
+	// Still saved
+	if err := scoring.SaveEntries(); err != nil {
+		t.Fatalf("SaveEntries returned an unexpected error: %v", err)
+	}
+	if len(mockStorage.Entries) != 3 {
+		t.Errorf("expected the attempt to be saved anyway, got %d entries", len(mockStorage.Entries))
+	}
+
+	// Without the gate it would have been one
+	scoring.SetMinAccuracy(0)
+	if !scoring.GotHighScore() {
+		t.Error("expected 5000 to be a high score without a gate")
+	}
+}
+
+func TestBeatAndTiedHighScore(t *testing.T) {
+	secret := "hello world"
+	hash := calculateHash(secret)
+	tests := []struct {
+		name       string
+		score      int
+		beat, tied bool
+	}{
+		{"beat", 1001, true, false},
+		{"tie", 1000, false, true},
+		{"below", 999, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := &MockScoreStorage{Entries: []ScoreHistoryEntry{{Hash: hash, Score: 1000, Outcome: OutcomeWon}}}
+			scoring, _ := InitScoring(secret, "Test", mockStorage)
+			scoring.CurrentScore = tt.score
+			scoring.history.CurrentScore.Score = tt.score
+
+			if got := scoring.BeatHighScore(); got != tt.beat {
+				t.Errorf("BeatHighScore() = %v, want %v", got, tt.beat)
+			}
+			if got := scoring.TiedHighScore(); got != tt.tied {
+				t.Errorf("TiedHighScore() = %v, want %v", got, tt.tied)
+			}
+			if got := scoring.GotHighScore(); got != (tt.beat || tt.tied) {
+				t.Errorf("GotHighScore() = %v, want %v", got, tt.beat || tt.tied)
+			}
+		})
+	}
+
+	// A first attempt has nothing to tie, so it sets a record
+	scoring, _ := InitScoring(secret, "Test", &MockScoreStorage{})
+	if !scoring.BeatHighScore() || scoring.TiedHighScore() {
+		t.Error("expected a first attempt to beat the high score, not tie it")
+	}
+}
+
+func TestGotHighScore_Abandoned(t *testing.T) {
+	secret := "hello world"
+	hash := calculateHash(secret)
+	for _, outcome := range []string{OutcomeAbandoned, OutcomeRevealed} {
+		mockStorage := &MockScoreStorage{
+			Entries: []ScoreHistoryEntry{
+				{Hash: hash, Score: 3000, Outcome: outcome},
+				{Hash: hash, Score: 1000, Outcome: OutcomeWon},
+			},
+		}
+
+		scoring, _ := InitScoring(secret, "Test", mockStorage)
+		if high := scoring.GetHighScore(); high == nil || high.Score != 1000 {
+			t.Fatalf("expected the %s 3000 not to be the high score to beat, got %v", outcome, high)
+		}
+		if scoring.GetAttempts() != 2 {
+			t.Errorf("expected the %s attempt to still count, got %d attempts", outcome, scoring.GetAttempts())
+		}
+
+		scoring.history.CurrentScore.Score = 2000
+		if !scoring.GotHighScore() {
+			t.Errorf("expected 2000 to beat the best attempt that wasn't %s", outcome)
+		}
+		scoring.SetOutcome(outcome)
+		if scoring.GotHighScore() {
+			t.Errorf("expected a %s attempt never to be a high score", outcome)
+		}
+	}
+}
+
 // TestScoreEvent checks that various game events correctly modify the score.
 func TestScoreEvent(t *testing.T) {
 	mockStorage := &MockScoreStorage{}
@@ -168,6 +284,43 @@ func TestGetNScoreEntries_IncludesCurrent(t *testing.T) {
 	}
 }
 
+// TestGetNHistoricalEntries_ExcludesCurrent verifies that GetNHistoricalEntries
+// returns only the saved scores, sorted, without the current session's score.
+func TestGetNHistoricalEntries_ExcludesCurrent(t *testing.T) {
+	secret := "test text"
+	hash := calculateHash(secret)
+
+	mockStorage := &MockScoreStorage{
+		Entries: []ScoreHistoryEntry{
+			{Hash: hash, Score: 100, Title: "Low"},
+			{Hash: hash, Score: 300, Title: "High"},
+			{Hash: hash, Score: 200, Title: "Mid"},
+		},
+	}
+
+	scoring, _ := InitScoring(secret, "Test", mockStorage)
+	scoring.ScoreEvent("rightLetter") // Current score: 25, would come last
+
+	entries := scoring.GetNHistoricalEntries(5)
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+	for i, want := range []int{300, 200, 100} {
+		if entries[i].Score != want {
+			t.Errorf("entry %d: expected score %d, got %d", i, want, entries[i].Score)
+		}
+	}
+
+	if top := scoring.GetNHistoricalEntries(2); len(top) != 2 || top[1].Score != 200 {
+		t.Errorf("expected the top 2 historical scores, got %+v", top)
+	}
+
+	// The combined list still includes the current score
+	if all := scoring.GetNScoreEntries(5); len(all) != 4 || all[3].Score != 25 {
+		t.Errorf("expected the current score in GetNScoreEntries, got %+v", all)
+	}
+}
+
 // TestGetNumPrevious verifies that GetNumPrevious returns only the count of historical entries.
 func TestGetNumPrevious(t *testing.T) {
 	secret := "test text"
@@ -191,3 +344,296 @@ func TestGetNumPrevious(t *testing.T) {
 		t.Errorf("expected 3 previous entries, got %d", count)
 	}
 }
+
+// TestSetWordTimings verifies that word timings are attached to the saved entry.
+func TestSetWordTimings(t *testing.T) {
+	mockStorage := &MockScoreStorage{}
+	scoring, _ := InitScoring("alpha beta", "Test", mockStorage)
+
+	timings := []WordTiming{
+		{Index: 1, Word: "beta", DurationMs: 2500},
+		{Index: 0, Word: "alpha", DurationMs: 1200},
+	}
+	scoring.SetWordTimings(timings)
+
+	if err := scoring.SaveEntries(); err != nil {
+		t.Fatalf("SaveEntries returned an unexpected error: %v", err)
+	}
+
+	if len(mockStorage.Entries) != 1 {
+		t.Fatalf("expected 1 saved entry, got %d", len(mockStorage.Entries))
+	}
+	saved := mockStorage.Entries[0].WordTimings
+	if len(saved) != 2 || saved[0].Word != "beta" || saved[0].DurationMs != 2500 {
+		t.Errorf("unexpected saved timings: %+v", saved)
+	}
+}
+
+func TestMaxPossible(t *testing.T) {
+	scoring, _ := InitScoring("Hi, all!", "Test", &MockScoreStorage{})
+
+	// 5 keys, 2 clean words and the card
+	if got := scoring.MaxPossible(5, 2, false, 0); got != 5*25+2*(250+100)+1000 {
+		t.Errorf("expected 1825 untimed, got %d", got)
+	}
+	// Plus the whole time limit as a bonus
+	if got := scoring.MaxPossible(5, 2, true, 30); got != 1825+300 {
+		t.Errorf("expected 2125 timed, got %d", got)
+	}
+
+	// Text revealed at the start is worth nothing
+	scoring.SetMultiplier(0.4)
+	if got := scoring.MaxPossible(5, 2, false, 0); got != 730 {
+		t.Errorf("expected 730 with a 0.4 multiplier, got %d", got)
+	}
+
+	scoring.CurrentScore = 365
+	scoring.SetPercentOfMax(730)
+	if err := scoring.SaveEntries(); err != nil {
+		t.Fatalf("SaveEntries returned an unexpected error: %v", err)
+	}
+	if got := scoring.history.CurrentScore.PercentOfMax; got != 50 {
+		t.Errorf("expected 50%% of max to be recorded, got %v", got)
+	}
+}
+
+// TestAccuracy verifies the correct/(correct+errors) computation.
+func TestAccuracy(t *testing.T) {
+	mockStorage := &MockScoreStorage{}
+	scoring, _ := InitScoring("test", "Test", mockStorage)
+
+	// Nothing typed yet
+	if scoring.Accuracy() != 100 {
+		t.Errorf("expected 100%% accuracy at start, got %v", scoring.Accuracy())
+	}
+
+	for i := 0; i < 3; i++ {
+		scoring.ScoreEvent("rightLetter")
+	}
+	scoring.ScoreEvent("wrongLetter")
+
+	if scoring.CorrectCount != 3 {
+		t.Errorf("expected correct count 3, got %d", scoring.CorrectCount)
+	}
+	if scoring.Accuracy() != 75 {
+		t.Errorf("expected 75%% accuracy, got %v", scoring.Accuracy())
+	}
+
+	// Hints don't affect accuracy
+	scoring.ScoreEvent("hint")
+	if scoring.Accuracy() != 75 {
+		t.Errorf("expected hints to leave accuracy at 75%%, got %v", scoring.Accuracy())
+	}
+
+	if err := scoring.SaveEntries(); err != nil {
+		t.Fatalf("SaveEntries returned an unexpected error: %v", err)
+	}
+	if mockStorage.Entries[0].Accuracy != 75 {
+		t.Errorf("expected saved accuracy 75, got %v", mockStorage.Entries[0].Accuracy)
+	}
+}
+
+func TestSuggestTimeLimit(t *testing.T) {
+	secret := strings.Repeat("word ", 20) // 100 chars: 33s by length
+	hash := TextHash(secret)
+
+	tests := []struct {
+		name    string
+		entries []ScoreHistoryEntry
+		want    int
+	}{
+		{"no history", nil, 33},
+		{"history without durations", []ScoreHistoryEntry{{Hash: hash, Score: 500}}, 33},
+		{"other texts only", []ScoreHistoryEntry{{Hash: "other", DurationMs: 4000}}, 33},
+		// Best of 20s and 16s, plus 25%
+		{"best time", []ScoreHistoryEntry{
+			{Hash: hash, DurationMs: 20000},
+			{Hash: hash, DurationMs: 16000},
+			{Hash: "other", DurationMs: 1000},
+		}, 20},
+		// Rounded up to a whole second
+		{"rounded", []ScoreHistoryEntry{{Hash: hash, DurationMs: 12100}}, 16},
+		// 4s is held at the 10s minimum
+		{"short card", []ScoreHistoryEntry{{Hash: hash, DurationMs: 3100}}, 10},
+	}
+	for _, tt := range tests {
+		if got := SuggestTimeLimit(secret, tt.entries, -1); got != tt.want {
+			t.Errorf("%s: expected %ds, got %ds", tt.name, tt.want, got)
+		}
+	}
+
+	if got := SuggestTimeLimit("Hi", nil, -1); got != 10 {
+		t.Errorf("Expected the 10s minimum for a short text, got %d", got)
+	}
+}
+
+func TestLengthTimeLimit(t *testing.T) {
+	ascii := strings.Repeat("a", 120)
+	greek := strings.Repeat("α", 120) // 240 bytes
+
+	tests := []struct {
+		name   string
+		secret string
+		cpm    int
+		want   int
+	}{
+		{"default", ascii, -1, 40},
+		{"faster", ascii, 360, 20},
+		{"slower", ascii, 60, 120},
+		{"multibyte counts runes", greek, -1, 40},
+		{"minimum", ascii, 6000, 10},
+	}
+	for _, tt := range tests {
+		if got := LengthTimeLimit(tt.secret, tt.cpm); got != tt.want {
+			t.Errorf("%s: expected %ds, got %ds", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestAttemptsToday(t *testing.T) {
+	zone := time.FixedZone("UTC-5", -5*60*60)
+	now := time.Date(2025, 3, 10, 0, 30, 0, 0, zone)
+
+	entries := []ScoreHistoryEntry{
+		{Hash: "a", Timestamp: "2025-03-10T00:10:00-05:00"}, // Earlier today
+		{Hash: "a", Timestamp: "2025-03-10T05:30:00Z"},      // Today, saved in another zone
+		{Hash: "a", Timestamp: "2025-03-10T23:59:59-05:00"}, // Later today
+		{Hash: "a", Timestamp: "2025-03-10T04:59:59Z"},      // 23:59:59 yesterday, locally
+		{Hash: "a", Timestamp: "2025-03-09T23:59:59-05:00"}, // Yesterday
+		{Hash: "a", Timestamp: "2025-03-11T00:00:00-05:00"}, // Tomorrow
+		{Hash: "a", Timestamp: "2025-03-10 00:10:00"},       // Malformed
+		{Hash: "a", Timestamp: ""},                          // Malformed
+		{Hash: "b", Timestamp: "2025-03-10T00:10:00-05:00"}, // Another text
+	}
+	if got := AttemptsToday(entries, "a", now); got != 3 {
+		t.Errorf("Expected 3 attempts today, got %d", got)
+	}
+
+	// Just before midnight, the attempts are yesterday's
+	if got := AttemptsToday(entries, "a", now.Add(-31*time.Minute)); got != 2 {
+		t.Errorf("Expected 2 attempts on the day before, got %d", got)
+	}
+	if got := AttemptsToday(nil, "a", now); got != 0 {
+		t.Errorf("Expected no attempts in an empty history, got %d", got)
+	}
+}
+
+func TestRemoveEntries(t *testing.T) {
+	entries := []ScoreHistoryEntry{
+		{Hash: "a", Score: 100, Title: "A"},
+		{Hash: "b", Score: 200, Title: "B"},
+		{Hash: "a", Score: 300, Title: "A"},
+		{Hash: "c", Score: 400, Title: "C"},
+	}
+	storage := &MockScoreStorage{}
+	if err := storage.SaveAll(entries); err != nil {
+		t.Fatalf("SaveAll failed: %v", err)
+	}
+
+	loaded, _ := storage.LoadAll()
+	kept, removed := RemoveEntries(loaded, "a")
+	if removed != 2 {
+		t.Errorf("Expected 2 entries removed, got %d", removed)
+	}
+	if err := storage.SaveAll(kept); err != nil {
+		t.Fatalf("SaveAll failed: %v", err)
+	}
+
+	// The other texts' entries are all still there, in order
+	loaded, _ = storage.LoadAll()
+	if len(loaded) != 2 || loaded[0].Hash != "b" || loaded[0].Score != 200 || loaded[1].Hash != "c" || loaded[1].Score != 400 {
+		t.Errorf("Expected only the entries for b and c to be kept, got %+v", loaded)
+	}
+
+	// An unknown hash removes nothing
+	if kept, removed := RemoveEntries(loaded, "z"); removed != 0 || len(kept) != 2 {
+		t.Errorf("Expected nothing removed for an unknown hash, got %d removed, %d kept", removed, len(kept))
+	}
+}
+
+func TestMergeWordErrors(t *testing.T) {
+	entries := []ScoreHistoryEntry{
+		{Hash: "a", WordErrors: map[int]int{0: 1, 3: 2}},
+		{Hash: "a"}, // A clean attempt, or one saved before word errors were
+		{Hash: "a", WordErrors: map[int]int{3: 1, 5: 4}},
+	}
+	merged := MergeWordErrors(entries)
+	if len(merged) != 3 || merged[0] != 1 || merged[3] != 3 || merged[5] != 4 {
+		t.Errorf("Expected the counts summed by word, got %v", merged)
+	}
+	if merged := MergeWordErrors(entries[1:2]); merged != nil {
+		t.Errorf("Expected nil without any word errors, got %v", merged)
+	}
+
+	// The counts survive a save and load
+	storage := NewJSONFileStorageAt(filepath.Join(t.TempDir(), "scores.json"))
+	if err := storage.SaveAll(entries); err != nil {
+		t.Fatalf("SaveAll failed: %v", err)
+	}
+	loaded, _ := storage.LoadAll()
+	if merged := MergeWordErrors(loaded); merged[5] != 4 {
+		t.Errorf("Expected the word errors to be persisted, got %v", merged)
+	}
+}
+
+func TestTroubleLevel(t *testing.T) {
+	for errors, want := range map[int]int{-1: 0, 0: 0, 1: 1, 2: 2, 3: 2, 4: MaxTroubleLevel, 50: MaxTroubleLevel} {
+		if got := TroubleLevel(errors); got != want {
+			t.Errorf("TroubleLevel(%d): expected %d, got %d", errors, want, got)
+		}
+	}
+}
+
+func TestTextHash_Normalized(t *testing.T) {
+	card := "Roses are red,\nViolets are blue"
+	for _, variant := range []string{
+		card + "\n",
+		"  " + card + " \t",
+		"Roses are red,  \nViolets are blue",
+		"Roses are red,\r\nViolets are blue\r\n",
+	} {
+		if TextHash(variant) != TextHash(card) {
+			t.Errorf("Expected %q to hash like %q", variant, card)
+		}
+	}
+	if TextHash("Roses are  red") == TextHash("Roses are red") {
+		t.Error("Expected whitespace within a line to count")
+	}
+
+	// Scoring keys the history by the same hash
+	sc, _ := InitScoring(card+"\n", "Test", &MockScoreStorage{})
+	if sc.textHash != TextHash(card) {
+		t.Errorf("Expected InitScoring to use the normalized hash")
+	}
+}
+
+func TestRehashEntries(t *testing.T) {
+	card := "Roses are red,\r\nViolets are blue"
+	old := calculateHash(card)
+	store := &MockScoreStorage{Entries: []ScoreHistoryEntry{
+		{Hash: old, Score: 500},
+		{Hash: "other", Score: 300},
+	}}
+
+	moved, err := RehashEntries(store, []string{card, "Already normal"})
+	if err != nil || moved != 1 {
+		t.Fatalf("Expected one entry moved, got %d (%v)", moved, err)
+	}
+	if e := store.Entries[0]; e.Hash != TextHash(card) || e.HashVersion != HashVersion {
+		t.Errorf("Expected the entry re-keyed to the new hash, got %+v", e)
+	}
+	if store.Entries[1].Hash != "other" {
+		t.Errorf("Expected other entries left alone, got %+v", store.Entries[1])
+	}
+
+	// Only once
+	if moved, _ := RehashEntries(store, []string{card}); moved != 0 {
+		t.Errorf("Expected nothing more to move, got %d", moved)
+	}
+
+	// Storage isn't touched when no text hashes differently
+	broken := &MockScoreStorage{err: errors.New("unreachable")}
+	if _, err := RehashEntries(broken, []string{"Already normal"}); err != nil {
+		t.Errorf("Expected storage not to be loaded, got %v", err)
+	}
+}
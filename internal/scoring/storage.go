@@ -2,12 +2,20 @@ package scoring
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
+// ErrCorruptScores is returned (wrapped) by LoadAll when the scores file ends in
+// a record that can't be decoded. The entries before it are still returned.
+var ErrCorruptScores = errors.New("scores file is truncated or corrupt")
+
 // ScoreStorage defines the interface for loading and saving score data.
 // This allows for mocking the storage layer during tests.
 type ScoreStorage interface {
@@ -17,20 +25,64 @@ type ScoreStorage interface {
 	SaveAll(entries []ScoreHistoryEntry) error
 }
 
+// Replacer is implemented by storages whose SaveAll keeps entries saved
+// elsewhere, such as on a server, and so can't drop any, for the rewrites
+// that must, see ReplaceAll.
+type Replacer interface {
+	// ReplaceAll saves entries as the whole of the storage's data.
+	ReplaceAll(entries []ScoreHistoryEntry) error
+}
+
+// ReplaceAll saves entries as the whole of storage's data, dropping any not
+// among them: with its ReplaceAll if it is a Replacer, else with SaveAll. It
+// is for rewrites such as clearing the history, which SaveAll could undo.
+func ReplaceAll(storage ScoreStorage, entries []ScoreHistoryEntry) error {
+	if r, ok := storage.(Replacer); ok {
+		return r.ReplaceAll(entries)
+	}
+	return storage.SaveAll(entries)
+}
+
+// Locker is implemented by storages that can guard a load-modify-save cycle
+// against other processes sharing the same data.
+type Locker interface {
+	// Lock blocks until the storage is held exclusively, and returns a function to release it.
+	Lock() (unlock func(), err error)
+}
+
 // JSONFileStorage is an implementation of ScoreStorage that uses a JSON file.
 type JSONFileStorage struct {
 	path string
 }
 
+// NewJSONFileStorageAt creates a JSONFileStorage that keeps the scores in the
+// file at path.
+func NewJSONFileStorageAt(path string) *JSONFileStorage {
+	return &JSONFileStorage{path: path}
+}
+
 // NewJSONFileStorage creates a new instance of JSONFileStorage,
 // automatically determining the path for the scores file.
 func NewJSONFileStorage() (*JSONFileStorage, error) {
+	return NewProfileStorage("")
+}
+
+// NewProfileStorage is like NewJSONFileStorage, but keeps the scores of a
+// named profile in their own file, under profiles/<name>/ in the config
+// directory. An empty profile uses the default scores file.
+func NewProfileStorage(profile string) (*JSONFileStorage, error) {
+	if profile != "" && (profile == "." || profile == ".." || strings.ContainsAny(profile, `/\`)) {
+		return nil, fmt.Errorf("invalid profile name: %q", profile)
+	}
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return nil, fmt.Errorf("could not get user home directory: %w", err)
 	}
-	scoreFilePath := filepath.Join(homeDir, ".config", "go-mem", "scores.json")
-	return &JSONFileStorage{path: scoreFilePath}, nil
+	configDir := filepath.Join(homeDir, ".config", "go-mem")
+	if profile != "" {
+		configDir = filepath.Join(configDir, "profiles", profile)
+	}
+	return NewJSONFileStorageAt(filepath.Join(configDir, "scores.json")), nil
 }
 
 // LoadAll reads and decodes all score entries from the JSON file.
@@ -45,8 +97,18 @@ func (jfs *JSONFileStorage) LoadAll() ([]ScoreHistoryEntry, error) {
 	}
 	defer file.Close()
 
+	// Decoding an encrypted file would only find it corrupt, and then lose it on the next save
+	reader := bufio.NewReader(file)
+	if magic, _ := reader.Peek(len(encMagic)); string(magic) == encMagic {
+		return nil, ErrEncrypted
+	}
+	return decodeEntries(reader)
+}
+
+// decodeEntries decodes a stream of JSON score entries.
+func decodeEntries(r io.Reader) ([]ScoreHistoryEntry, error) {
 	entries := make([]ScoreHistoryEntry, 0)
-	decoder := json.NewDecoder(file)
+	decoder := json.NewDecoder(r)
 	// Use a loop to decode a stream of JSON objects.
 	for decoder.More() {
 		var entry ScoreHistoryEntry
@@ -55,7 +117,8 @@ func (jfs *JSONFileStorage) LoadAll() ([]ScoreHistoryEntry, error) {
 			if err.Error() == "EOF" {
 				break
 			}
-			return nil, fmt.Errorf("error decoding JSON entry: %w", err)
+			// Keep what was readable, e.g. if a write was cut short
+			return entries, fmt.Errorf("%w: error decoding JSON entry %d: %v", ErrCorruptScores, len(entries)+1, err)
 		}
 		entries = append(entries, entry)
 	}
@@ -64,29 +127,85 @@ func (jfs *JSONFileStorage) LoadAll() ([]ScoreHistoryEntry, error) {
 }
 
 // SaveAll encodes and writes all score entries to the JSON file.
+// The entries are written to a temporary file which then replaces the scores
+// file, so readers never see a partially written file.
 func (jfs *JSONFileStorage) SaveAll(entries []ScoreHistoryEntry) error {
-	// Ensure the directory exists.
-	dir := filepath.Dir(jfs.path)
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return fmt.Errorf("error creating scores directory: %w", err)
+	data, err := encodeEntries(entries)
+	if err != nil {
+		return err
+	}
+	return jfs.writeFile(data, 0644)
+}
+
+// encodeEntries encodes score entries as a stream of JSON objects, one per line.
+func encodeEntries(entries []ScoreHistoryEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return nil, fmt.Errorf("error encoding JSON entry: %w", err)
 		}
 	}
+	return buf.Bytes(), nil
+}
+
+// writeFile replaces the scores file with data, by way of a temporary file.
+func (jfs *JSONFileStorage) writeFile(data []byte, perm os.FileMode) error {
+	if err := jfs.ensureDir(); err != nil {
+		return err
+	}
+	if err := WriteFileAtomic(jfs.path, data, perm); err != nil {
+		return fmt.Errorf("error saving scores file: %w", err)
+	}
+	return nil
+}
 
-	file, err := os.OpenFile(jfs.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+// WriteFileAtomic writes data to path with the given permissions by writing
+// a temporary file next to it and renaming it over path, so that a reader, or
+// a crash part way through, never leaves path half written.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*.tmp")
 	if err != nil {
-		return fmt.Errorf("error opening scores file for writing: %w", err)
+		return fmt.Errorf("error creating temporary file: %w", err)
 	}
+	tmpPath := file.Name()
+	// Clean up if anything fails before the rename; harmless afterwards.
+	defer os.Remove(tmpPath)
 	defer file.Close()
 
-	writer := bufio.NewWriter(file)
-	encoder := json.NewEncoder(writer)
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("error writing temporary file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("error syncing temporary file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("error closing temporary file: %w", err)
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return fmt.Errorf("error setting file permissions: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("error replacing %s: %w", path, err)
+	}
+	return nil
+}
+
+// Lock takes an advisory lock on a lock file next to the scores file.
+func (jfs *JSONFileStorage) Lock() (func(), error) {
+	if err := jfs.ensureDir(); err != nil {
+		return nil, err
+	}
+	return lockFile(jfs.path + ".lock")
+}
 
-	for _, entry := range entries {
-		if err := encoder.Encode(entry); err != nil {
-			return fmt.Errorf("error encoding JSON entry: %w", err)
+// ensureDir creates the directory holding the scores file if needed.
+func (jfs *JSONFileStorage) ensureDir() error {
+	dir := filepath.Dir(jfs.path)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("error creating scores directory: %w", err)
 		}
 	}
-
-	return writer.Flush()
+	return nil
 }
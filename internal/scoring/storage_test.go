@@ -1,8 +1,13 @@
 package scoring
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -17,19 +22,7 @@ func TestJSONFileStorage_SaveAndLoad(t *testing.T) {
 	// Define a custom path for the storage
 	testPath := filepath.Join(tmpDir, "scores.json")
 
-	// Initialize storage with the custom path manually
-	// (Since NewJSONFileStorage uses user home dir, we bypass it or assume we can struct init)
-	// struct is exported? No, JSONFileStorage is exported. Fields? path is unexported.
-	// Check storage.go: type JSONFileStorage struct { path string }
-	// We need a way to create it with a custom path or use NewJSONFileStorage and mock UserHomeDir?
-	// mocking os.UserHomeDir is hard in Go without a wrapper.
-
-	// Let's modify storage.go to allow injecting the path or just create a new constructor for testing?
-	// Or we can rely on `NewJSONFileStorage` but that writes to real disk. Not good.
-	// Best approach: Modifying storage.go to verify we can set the path, OR
-	// Just duplicate the struct initialization here since it's in the same package (internal/scoring).
-
-	storage := &JSONFileStorage{path: testPath}
+	storage := NewJSONFileStorageAt(testPath)
 
 	// 1. Test Load on non-existent file (should return empty)
 	entries, err := storage.LoadAll()
@@ -87,7 +80,7 @@ func TestJSONFileStorage_CorruptFile(t *testing.T) {
 		t.Fatalf("Failed to write corrupt file: %v", err)
 	}
 
-	storage := &JSONFileStorage{path: testPath}
+	storage := NewJSONFileStorageAt(testPath)
 
 	_, err = storage.LoadAll()
 	if err == nil {
@@ -111,7 +104,7 @@ func TestJSONFileStorage_EmptyFile(t *testing.T) {
 		t.Fatalf("Failed to write empty file: %v", err)
 	}
 
-	storage := &JSONFileStorage{path: testPath}
+	storage := NewJSONFileStorageAt(testPath)
 
 	entries, err := storage.LoadAll()
 	if err != nil {
@@ -121,3 +114,374 @@ func TestJSONFileStorage_EmptyFile(t *testing.T) {
 		t.Errorf("Expected 0 entries from empty file, got %d", len(entries))
 	}
 }
+
+func TestJSONFileStorage_WordTimings(t *testing.T) {
+	tmpDir := t.TempDir()
+	testPath := filepath.Join(tmpDir, "scores.json")
+
+	// Entries written before word timings existed must still load
+	legacy := `{"hash":"abc","score":100,"timestamp":"2023-01-01","title":"Old"}` + "\n"
+	if err := os.WriteFile(testPath, []byte(legacy), 0644); err != nil {
+		t.Fatalf("Failed to write legacy file: %v", err)
+	}
+
+	storage := NewJSONFileStorageAt(testPath)
+	entries, err := storage.LoadAll()
+	if err != nil {
+		t.Fatalf("LoadAll returned error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].WordTimings != nil {
+		t.Fatalf("Unexpected legacy entries: %+v", entries)
+	}
+
+	entries = append(entries, ScoreHistoryEntry{
+		Hash:        "def",
+		Score:       200,
+		WordTimings: []WordTiming{{Index: 3, Word: "slow", DurationMs: 4200}},
+	})
+	if err := storage.SaveAll(entries); err != nil {
+		t.Fatalf("SaveAll returned error: %v", err)
+	}
+
+	// Entries without timings should not write the field at all
+	raw, _ := os.ReadFile(testPath)
+	if strings.Count(string(raw), "wordTimings") != 1 {
+		t.Errorf("Expected wordTimings to be omitted when empty, got %s", raw)
+	}
+
+	loaded, err := storage.LoadAll()
+	if err != nil {
+		t.Fatalf("LoadAll returned error: %v", err)
+	}
+	if len(loaded) != 2 || len(loaded[1].WordTimings) != 1 || loaded[1].WordTimings[0].DurationMs != 4200 {
+		t.Errorf("Word timings did not round-trip: %+v", loaded)
+	}
+}
+
+func TestJSONFileStorage_Accuracy(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "scores.json")
+	storage := NewJSONFileStorageAt(testPath)
+
+	entries := []ScoreHistoryEntry{
+		{Hash: "abc", Score: 100, Accuracy: 97.5},
+		{Hash: "def", Score: 200}, // Written before accuracy was tracked
+	}
+	if err := storage.SaveAll(entries); err != nil {
+		t.Fatalf("SaveAll returned error: %v", err)
+	}
+
+	raw, _ := os.ReadFile(testPath)
+	if strings.Count(string(raw), "accuracy") != 1 {
+		t.Errorf("Expected accuracy to be omitted when unset, got %s", raw)
+	}
+
+	loaded, err := storage.LoadAll()
+	if err != nil {
+		t.Fatalf("LoadAll returned error: %v", err)
+	}
+	if loaded[0].Accuracy != 97.5 || loaded[1].Accuracy != 0 {
+		t.Errorf("Accuracy did not round-trip: %+v", loaded)
+	}
+}
+
+func TestJSONFileStorage_ConcurrentSavesKeepAllEntries(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "scores.json")
+
+	// Each game loads the history first, then all of them save at once,
+	// each through its own storage as separate processes would.
+	const games = 20
+	var scorings []*Scoring
+	for i := 0; i < games; i++ {
+		sc, err := InitScoring(fmt.Sprintf("text %d", i%5), "Test", NewJSONFileStorageAt(testPath))
+		if err != nil {
+			t.Fatalf("InitScoring failed: %v", err)
+		}
+		sc.CurrentScore = i
+		sc.history.CurrentScore.Score = i
+		sc.history.CurrentScore.Timestamp = fmt.Sprintf("2024-01-01T00:00:%02dZ", i)
+		scorings = append(scorings, sc)
+	}
+
+	var wg sync.WaitGroup
+	for _, sc := range scorings {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sc.SaveEntries(); err != nil {
+				t.Errorf("SaveEntries failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries, err := NewJSONFileStorageAt(testPath).LoadAll()
+	if err != nil {
+		t.Fatalf("LoadAll failed: %v", err)
+	}
+	if len(entries) != games {
+		t.Errorf("Expected %d entries, got %d", games, len(entries))
+	}
+
+	// Only the scores file and its lock file remain; no temporary files
+	files, _ := os.ReadDir(filepath.Dir(testPath))
+	for _, f := range files {
+		if f.Name() != "scores.json" && f.Name() != "scores.json.lock" {
+			t.Errorf("Unexpected file left behind: %s", f.Name())
+		}
+	}
+}
+
+func TestJSONFileStorage_PartialRecord(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "scores.json")
+	content := `{"hash":"abc","score":100,"timestamp":"t1","title":"One"}
+{"hash":"def","score":200,"timestamp":"t2","title":"Two"}
+{"hash":"ghi","sco`
+	if err := os.WriteFile(testPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	storage := NewJSONFileStorageAt(testPath)
+
+	entries, err := storage.LoadAll()
+	if !errors.Is(err, ErrCorruptScores) {
+		t.Errorf("Expected ErrCorruptScores, got %v", err)
+	}
+	if len(entries) != 2 || entries[1].Score != 200 {
+		t.Errorf("Expected the 2 complete entries, got %+v", entries)
+	}
+
+	// Playing on is still possible, and saving repairs the file
+	sc, err := InitScoring("new text", "New", storage)
+	if err != nil {
+		t.Fatalf("InitScoring should tolerate a partial record: %v", err)
+	}
+	if err := sc.SaveEntries(); err != nil {
+		t.Fatalf("SaveEntries failed: %v", err)
+	}
+	entries, err = storage.LoadAll()
+	if err != nil || len(entries) != 3 {
+		t.Errorf("Expected 3 entries and no error after saving, got %d (%v)", len(entries), err)
+	}
+}
+
+func TestNewJSONFileStorageAt(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "custom", "my-scores.json")
+
+	storage := NewJSONFileStorageAt(path)
+	if err := storage.SaveAll([]ScoreHistoryEntry{{Hash: "h", Score: 100, Timestamp: "a"}}); err != nil {
+		t.Fatalf("SaveAll failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(data), `"score":100`) {
+		t.Fatalf("Expected the scores written to %s, got %q (%v)", path, data, err)
+	}
+
+	// Another storage for the same path reads them back
+	entries, err := NewJSONFileStorageAt(path).LoadAll()
+	if err != nil || len(entries) != 1 || entries[0].Score != 100 {
+		t.Errorf("Expected the saved score from %s, got %+v (%v)", path, entries, err)
+	}
+
+	// And one for another path doesn't
+	if entries, _ := NewJSONFileStorageAt(filepath.Join(dir, "other.json")).LoadAll(); len(entries) != 0 {
+		t.Errorf("Expected no scores at another path, got %+v", entries)
+	}
+}
+
+func TestNewProfileStorage(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home) // os.UserHomeDir on Windows
+
+	def, err := NewProfileStorage("")
+	if err != nil {
+		t.Fatalf("NewProfileStorage failed: %v", err)
+	}
+	if want := filepath.Join(home, ".config", "go-mem", "scores.json"); def.path != want {
+		t.Errorf("Expected the default profile at %s, got %s", want, def.path)
+	}
+
+	alice, _ := NewProfileStorage("alice")
+	bob, _ := NewProfileStorage("bob")
+	if want := filepath.Join(home, ".config", "go-mem", "profiles", "alice", "scores.json"); alice.path != want {
+		t.Errorf("Expected alice's scores at %s, got %s", want, alice.path)
+	}
+
+	if err := alice.SaveAll([]ScoreHistoryEntry{{Hash: "h", Score: 100, Timestamp: "a"}}); err != nil {
+		t.Fatalf("SaveAll failed: %v", err)
+	}
+	if err := bob.SaveAll([]ScoreHistoryEntry{{Hash: "h", Score: 200, Timestamp: "b"}}); err != nil {
+		t.Fatalf("SaveAll failed: %v", err)
+	}
+
+	for _, tt := range []struct {
+		storage *JSONFileStorage
+		want    int
+	}{{alice, 100}, {bob, 200}} {
+		entries, err := tt.storage.LoadAll()
+		if err != nil || len(entries) != 1 || entries[0].Score != tt.want {
+			t.Errorf("%s: expected only score %d, got %+v (%v)", tt.storage.path, tt.want, entries, err)
+		}
+	}
+	if entries, _ := def.LoadAll(); len(entries) != 0 {
+		t.Errorf("Expected the default profile to be empty, got %+v", entries)
+	}
+
+	for _, name := range []string{"..", "a/b", `a\b`} {
+		if _, err := NewProfileStorage(name); err == nil {
+			t.Errorf("Expected an error for profile %q", name)
+		}
+	}
+}
+
+func TestEncryptedJSONStorage_RoundTrip(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "scores.json")
+	storage, err := NewEncryptedJSONStorage(NewJSONFileStorageAt(testPath), "correct horse")
+	if err != nil {
+		t.Fatalf("NewEncryptedJSONStorage returned error: %v", err)
+	}
+
+	testEntries := []ScoreHistoryEntry{
+		{Hash: "abc", Score: 100, Title: "Dear Diary", Timestamp: "2023-01-01"},
+		{Hash: "def", Score: 200, Title: "Test2", Timestamp: "2023-01-02"},
+	}
+	if err := storage.SaveAll(testEntries); err != nil {
+		t.Fatalf("SaveAll returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(testPath)
+	if err != nil {
+		t.Fatalf("Failed to read scores file: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "GMEM\x01") {
+		t.Errorf("Expected the file to start with the version 1 header, got %q", data[:5])
+	}
+	if strings.Contains(string(data), "Dear Diary") || strings.Contains(string(data), "abc") {
+		t.Error("Expected titles and hashes not to be readable in the file")
+	}
+
+	// A fresh storage has to derive the key from the salt in the file
+	reopened, _ := NewEncryptedJSONStorage(NewJSONFileStorageAt(testPath), "correct horse")
+	loaded, err := reopened.LoadAll()
+	if err != nil {
+		t.Fatalf("LoadAll returned error: %v", err)
+	}
+	if len(loaded) != 2 || loaded[0].Title != "Dear Diary" || loaded[1].Score != 200 {
+		t.Errorf("Expected the saved entries back, got %+v", loaded)
+	}
+
+	wrong, _ := NewEncryptedJSONStorage(NewJSONFileStorageAt(testPath), "wrong horse")
+	if entries, err := wrong.LoadAll(); !errors.Is(err, ErrWrongPassphrase) || len(entries) != 0 {
+		t.Errorf("Expected ErrWrongPassphrase and no entries, got %v and %d entries", err, len(entries))
+	}
+	if err := wrong.SaveAll(testEntries[:1]); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected saving after a wrong passphrase to be refused, got %v", err)
+	}
+
+	if _, err := NewEncryptedJSONStorage(NewJSONFileStorageAt(testPath), ""); err == nil {
+		t.Error("Expected an empty passphrase to be rejected")
+	}
+}
+
+func TestEncryptedJSONStorage_Tampering(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "scores.json")
+	storage, _ := NewEncryptedJSONStorage(NewJSONFileStorageAt(testPath), "correct horse")
+	if err := storage.SaveAll([]ScoreHistoryEntry{{Hash: "abc", Score: 100}}); err != nil {
+		t.Fatalf("SaveAll returned error: %v", err)
+	}
+	original, _ := os.ReadFile(testPath)
+
+	tests := []struct {
+		name string
+		pos  int
+		want error
+	}{
+		{"salt", 5, ErrWrongPassphrase},
+		{"nonce", 21, ErrWrongPassphrase},
+		{"ciphertext", len(original) - 20, ErrWrongPassphrase},
+		{"tag", len(original) - 1, ErrWrongPassphrase},
+	}
+	for _, tt := range tests {
+		data := bytes.Clone(original)
+		data[tt.pos] ^= 0x01
+		if err := os.WriteFile(testPath, data, 0600); err != nil {
+			t.Fatalf("Failed to write scores file: %v", err)
+		}
+		reopened, _ := NewEncryptedJSONStorage(NewJSONFileStorageAt(testPath), "correct horse")
+		if _, err := reopened.LoadAll(); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	// A future version isn't mistaken for a wrong passphrase
+	data := bytes.Clone(original)
+	data[4] = 2
+	os.WriteFile(testPath, data, 0600)
+	if _, err := storage.LoadAll(); err == nil || errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected an unsupported version error, got %v", err)
+	}
+
+	os.WriteFile(testPath, original[:10], 0600)
+	if _, err := storage.LoadAll(); !errors.Is(err, ErrCorruptScores) {
+		t.Errorf("Expected a cut short header to be ErrCorruptScores, got %v", err)
+	}
+}
+
+func TestEncryptedJSONStorage_MigratePlaintext(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "scores.json")
+	plain := NewJSONFileStorageAt(testPath)
+	if err := plain.SaveAll([]ScoreHistoryEntry{{Hash: "abc", Score: 100}, {Hash: "def", Score: 200}}); err != nil {
+		t.Fatalf("SaveAll returned error: %v", err)
+	}
+
+	storage, _ := NewEncryptedJSONStorage(plain, "correct horse")
+	if _, err := storage.LoadAll(); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("Expected ErrNotEncrypted before migrating, got %v", err)
+	}
+
+	n, err := storage.MigratePlaintext()
+	if err != nil {
+		t.Fatalf("MigratePlaintext returned error: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 entries to be migrated, got %d", n)
+	}
+
+	reopened, _ := NewEncryptedJSONStorage(NewJSONFileStorageAt(testPath), "correct horse")
+	loaded, err := reopened.LoadAll()
+	if err != nil || len(loaded) != 2 || loaded[1].Hash != "def" {
+		t.Errorf("Expected the migrated entries back, got %+v (%v)", loaded, err)
+	}
+	if info, _ := os.Stat(testPath); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the encrypted file to be private, got %v", info.Mode().Perm())
+	}
+
+	// Without the passphrase the file is left alone rather than read as corrupt
+	if _, err := plain.LoadAll(); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("Expected ErrEncrypted from a plaintext storage, got %v", err)
+	}
+
+	if _, err := reopened.MigratePlaintext(); !errors.Is(err, ErrAlreadyEncrypted) {
+		t.Errorf("Expected ErrAlreadyEncrypted on a second migration, got %v", err)
+	}
+}
+
+func TestReadOnlyStorage(t *testing.T) {
+	mock := &MockScoreStorage{Entries: []ScoreHistoryEntry{{Hash: "abc", Score: 500}}}
+	storage := NewReadOnlyStorage(mock)
+
+	entries, err := storage.LoadAll()
+	if err != nil || len(entries) != 1 || entries[0].Score != 500 {
+		t.Fatalf("Expected the wrapped entries, got %v, %v", entries, err)
+	}
+
+	if err := SaveEntry(storage, ScoreHistoryEntry{Hash: "abc", Score: 900}); err != nil {
+		t.Fatalf("SaveEntry failed: %v", err)
+	}
+	if err := storage.SaveAll(nil); err != nil {
+		t.Fatalf("SaveAll failed: %v", err)
+	}
+	if len(mock.Entries) != 1 || mock.Entries[0].Score != 500 {
+		t.Errorf("Expected the wrapped entries unchanged, got %v", mock.Entries)
+	}
+}
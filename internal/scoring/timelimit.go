@@ -0,0 +1,54 @@
+package scoring
+
+import (
+	"math"
+	"unicode/utf8"
+)
+
+// historyTimeFactor is the slack given over the best recorded time.
+const historyTimeFactor = 1.25
+
+// minTimeLimit is the shortest time limit the auto timer gives, in seconds.
+const minTimeLimit = 10
+
+// DefaultCPM is the typing rate, in characters per minute, that the auto timer
+// allows for when no rate is given.
+const DefaultCPM = 180
+
+// LengthTimeLimit is the auto timer limit for a text with no usable history:
+// the time to type every character at cpm characters per minute, at least 10 seconds.
+// Characters are counted as runes, so multibyte text isn't penalised.
+// A cpm of zero or less (e.g. -1 for auto) uses DefaultCPM.
+func LengthTimeLimit(secret string, cpm int) int {
+	if cpm <= 0 {
+		cpm = DefaultCPM
+	}
+	return max(utf8.RuneCountInString(secret)*60/cpm, minTimeLimit)
+}
+
+// WordsTimeLimit is the time limit that finishing a text of the given number of
+// words within means typing at wpm words per minute, at least 10 seconds.
+func WordsTimeLimit(words, wpm int) int {
+	return max(int(math.Ceil(float64(words)*60/float64(wpm))), minTimeLimit)
+}
+
+// SuggestTimeLimit returns the auto timer limit in seconds for a text.
+// If the history holds completed attempts at the text, the limit is the best
+// time plus 25%, at least 10 seconds; otherwise it falls back to
+// LengthTimeLimit.
+func SuggestTimeLimit(secret string, entries []ScoreHistoryEntry, cpm int) int {
+	hash := TextHash(secret)
+	var best int64
+	for _, e := range entries {
+		if e.Hash != hash || e.DurationMs <= 0 {
+			continue
+		}
+		if best == 0 || e.DurationMs < best {
+			best = e.DurationMs
+		}
+	}
+	if best == 0 {
+		return LengthTimeLimit(secret, cpm)
+	}
+	return max(int(math.Ceil(float64(best)*historyTimeFactor/1000)), minTimeLimit)
+}
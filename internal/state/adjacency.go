@@ -0,0 +1,57 @@
+package state
+
+import (
+	"strings"
+	"unicode"
+)
+
+// qwertyRows are the unshifted rows of a QWERTY keyboard. Each row sits a
+// little to the right of the one above, so a key touches the keys at the same
+// column and the next one in the row above, and at the same column and the
+// previous one in the row below.
+var qwertyRows = []string{
+	"1234567890-=",
+	"qwertyuiop[]",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+}
+
+// qwertyNeighbours maps each key to the keys physically next to it.
+var qwertyNeighbours = buildNeighbours(qwertyRows)
+
+func buildNeighbours(rows []string) map[rune][]rune {
+	grid := make([][]rune, len(rows))
+	for i, row := range rows {
+		grid[i] = []rune(row)
+	}
+	at := func(row, col int) (rune, bool) {
+		if row < 0 || row >= len(grid) || col < 0 || col >= len(grid[row]) {
+			return 0, false
+		}
+		return grid[row][col], true
+	}
+
+	neighbours := make(map[rune][]rune)
+	for r, row := range grid {
+		for c, key := range row {
+			for _, p := range [][2]int{{r, c - 1}, {r, c + 1}, {r - 1, c}, {r - 1, c + 1}, {r + 1, c - 1}, {r + 1, c}} {
+				if n, ok := at(p[0], p[1]); ok {
+					neighbours[key] = append(neighbours[key], n)
+				}
+			}
+		}
+	}
+	return neighbours
+}
+
+// IsNearMiss reports whether ch was typed with a key right next to the one
+// for the current character of the secret. With a layout the keys are compared
+// by where they sit on the physical QWERTY keyboard.
+func (s State) IsNearMiss(ch string) bool {
+	if s.Pos >= len(s.Secret) || len([]rune(ch)) != 1 {
+		return false
+	}
+	typed := unicode.ToLower(s.Options.Layout.Physical([]rune(ch)[0]))
+	want := unicode.ToLower(s.Options.Layout.Physical(s.Secret[s.Pos]))
+	return strings.ContainsRune(string(qwertyNeighbours[want]), typed)
+}
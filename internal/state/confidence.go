@@ -0,0 +1,41 @@
+package state
+
+import "go-mem/internal/scoring"
+
+// initTrouble shades the words of the text by how often they were mistyped
+// in earlier attempts, unless turned off. A text with no such history, and
+// recall and flash modes, which have no mask to shade, get none.
+func (s *State) initTrouble() {
+	s.Trouble = nil
+	if s.Options.NoConfidence || s.Options.Recall || s.Options.Flash {
+		return
+	}
+	history := s.Score.WordErrorHistory()
+	for i, w := range s.wordSpans() {
+		level := scoring.TroubleLevel(history[i])
+		if level == 0 {
+			continue
+		}
+		if s.Trouble == nil {
+			s.Trouble = make(map[int]int)
+		}
+		for pos := w.start; pos < w.end; pos++ {
+			s.Trouble[pos] = level
+		}
+	}
+}
+
+// WordErrors returns the letters mistyped in each word of the game, keyed by
+// word index, nil if there were none.
+func (s *State) WordErrors() map[int]int {
+	var errors map[int]int
+	for pos := range s.ErrorPositions {
+		if i := s.wordIndexAt(pos); i >= 0 {
+			if errors == nil {
+				errors = make(map[int]int)
+			}
+			errors[i]++
+		}
+	}
+	return errors
+}
@@ -0,0 +1,27 @@
+package state
+
+import "github.com/charmbracelet/bubbles/textarea"
+
+// Display is the surface the game writes the board to as it progresses.
+type Display interface {
+	Value() string
+	SetValue(string)
+}
+
+// TextareaDisplay adapts a bubbles textarea to Display for the TUI.
+type TextareaDisplay struct {
+	textarea.Model
+}
+
+// MemoryDisplay keeps the board in memory, for callers without a UI.
+type MemoryDisplay struct {
+	value string
+}
+
+func (d *MemoryDisplay) Value() string {
+	return d.value
+}
+
+func (d *MemoryDisplay) SetValue(v string) {
+	d.value = v
+}
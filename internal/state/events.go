@@ -0,0 +1,61 @@
+package state
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Event types written to an EventLog.
+const (
+	EventCorrect  = "correct"  // A hidden or revealed character was typed correctly
+	EventWrong    = "wrong"    // A wrong character was typed
+	EventNearMiss = "nearMiss" // A neighbouring key was typed, with --forgive-typos
+	EventHint     = "hint"     // The next character was revealed
+	EventWordHint = "wordHint" // The rest of the next word was revealed
+	EventTick     = "tick"     // A second of the timer passed
+	EventWin      = "win"      // The card was finished; the score is the final one
+	EventLoss     = "loss"     // The card was lost, quit or revealed
+)
+
+// Event is one line of an EventLog.
+type Event struct {
+	Type     string    `json:"type"`
+	Pos      int       `json:"pos"`
+	Score    int       `json:"score"`
+	TimeLeft int       `json:"timeLeft,omitempty"` // Seconds left, if the timer is on
+	Time     time.Time `json:"time"`
+}
+
+// EventLog writes what happens in games as JSON lines, for overlays and other
+// tools following a game live. One log can be shared by every game in a session.
+type EventLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewEventLog returns an EventLog that writes to w.
+func NewEventLog(w io.Writer) *EventLog {
+	return &EventLog{enc: json.NewEncoder(w)}
+}
+
+// Write writes an event. Errors are ignored, so that a reader going away
+// doesn't stop the game.
+func (l *EventLog) Write(e Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.enc.Encode(e)
+}
+
+// emit writes an event of the given type to the game's event log, if any.
+func (s *State) emit(eventType string) {
+	if s.Options.Events == nil {
+		return
+	}
+	e := Event{Type: eventType, Pos: s.Pos, Score: s.Score.CurrentScore, Time: s.Now()}
+	if s.TimerEnabled {
+		e.TimeLeft = s.TimeRemaining
+	}
+	s.Options.Events.Write(e)
+}
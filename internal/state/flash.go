@@ -0,0 +1,86 @@
+package state
+
+import "unicode"
+
+// Flash mode is for reading a text before trying to recall it. Nothing is
+// typed: space reveals the next word and backspace hides the last one again.
+// It is finished once every word has been revealed and hidden at least once.
+// There is no scoring, timer or score history in flash mode.
+
+// flashSpans returns the whitespace-separated words of the secret, so that
+// punctuation is revealed along with the word it belongs to.
+func (s *State) flashSpans() []wordSpan {
+	var words []wordSpan
+	start := -1
+	for i, ch := range s.Secret {
+		if unicode.IsSpace(ch) {
+			if start >= 0 {
+				words = append(words, wordSpan{start, i})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, wordSpan{start, len(s.Secret)})
+	}
+	return words
+}
+
+// InitFlash hides every word of the secret for flash mode. A secret without
+// any words has nothing to read, so it is finished straight away.
+func (s *State) InitFlash() {
+	mask := make([]rune, len(s.Secret))
+	for i, ch := range s.Secret {
+		if unicode.IsSpace(ch) {
+			mask[i] = ch
+		} else {
+			mask[i] = '_'
+		}
+	}
+	s.Mask = mask
+	s.FlashRevealed = 0
+	s.flashHidden = make([]bool, len(s.flashSpans()))
+	s.Win = len(s.flashHidden) == 0
+}
+
+// FlashWordCount returns the number of words revealed one at a time in flash mode.
+func (s *State) FlashWordCount() int {
+	return len(s.flashHidden)
+}
+
+// FlashKey handles a key in flash mode: space reveals the next word and
+// backspace hides the last revealed one. Other keys are ignored.
+func (s *State) FlashKey(ch string) {
+	words := s.flashSpans()
+	switch ch {
+	case " ":
+		if s.FlashRevealed >= len(words) {
+			return
+		}
+		w := words[s.FlashRevealed]
+		copy(s.Mask[w.start:w.end], s.Secret[w.start:w.end])
+		s.FlashRevealed++
+	case "backspace":
+		if s.FlashRevealed == 0 {
+			return
+		}
+		s.FlashRevealed--
+		w := words[s.FlashRevealed]
+		for i := w.start; i < w.end; i++ {
+			s.Mask[i] = '_'
+		}
+		s.flashHidden[s.FlashRevealed] = true
+	default:
+		return
+	}
+	s.Display.SetValue(string(s.Mask))
+
+	for _, hidden := range s.flashHidden {
+		if !hidden {
+			return
+		}
+	}
+	s.Win = true
+}
@@ -0,0 +1,126 @@
+package state
+
+import (
+	"go-mem/internal/scoring"
+	"slices"
+	"time"
+)
+
+// Ghost is the pace of a previous attempt at a card, to race against: where
+// that attempt was in the text at each moment, and when it got to each place.
+// It is built from the times at which the attempt completed each word, and
+// moves evenly through the letters in between.
+type Ghost struct {
+	ends  []int           // Rune position just past each word
+	times []time.Duration // When each word was completed, from the start of the game
+}
+
+// NewGhost returns the ghost of an attempt that completed the words ending at
+// ends after the given numbers of milliseconds. It returns nil if the timings
+// don't fit the words, as when the attempt was saved without any.
+func NewGhost(ends []int, offsetsMs []int64) *Ghost {
+	if len(ends) == 0 || len(offsetsMs) != len(ends) {
+		return nil
+	}
+	g := &Ghost{ends: ends}
+	for _, ms := range offsetsMs {
+		g.times = append(g.times, time.Duration(ms)*time.Millisecond)
+	}
+	return g
+}
+
+// PosAt returns the rune position the ghost had reached after elapsed.
+// Once the ghost has finished, it is the end of the last word.
+func (g *Ghost) PosAt(elapsed time.Duration) int {
+	i, _ := slices.BinarySearch(g.times, elapsed)
+	if i == len(g.times) {
+		return g.ends[len(g.ends)-1]
+	}
+	fromPos, fromTime := g.point(i - 1)
+	span := g.times[i] - fromTime
+	if span <= 0 {
+		return g.ends[i]
+	}
+	return fromPos + int(int64(g.ends[i]-fromPos)*int64(elapsed-fromTime)/int64(span))
+}
+
+// TimeAt returns when the ghost reached rune position pos. Past the end of
+// the last word, it is when the ghost finished.
+func (g *Ghost) TimeAt(pos int) time.Duration {
+	i, _ := slices.BinarySearch(g.ends, pos)
+	if i == len(g.ends) {
+		return g.times[len(g.times)-1]
+	}
+	fromPos, fromTime := g.point(i - 1)
+	if g.ends[i] <= fromPos {
+		return g.times[i]
+	}
+	return fromTime + (g.times[i]-fromTime)*time.Duration(pos-fromPos)/time.Duration(g.ends[i]-fromPos)
+}
+
+// point returns the position and time at which word i was completed, with
+// the start of the game as word -1.
+func (g *Ghost) point(i int) (int, time.Duration) {
+	if i < 0 {
+		return 0, 0
+	}
+	return g.ends[i], g.times[i]
+}
+
+// initGhost sets up the ghost of the best previous win at the card whose
+// word timings were saved, if asked for. Wins saved without them, or with
+// timings for other words, are passed over. Recall mode has no cursor to race.
+func (s *State) initGhost() {
+	s.Ghost = nil
+	if !s.Options.Ghost || s.Options.Recall {
+		return
+	}
+	var ends []int
+	for _, w := range s.wordSpans() {
+		ends = append(ends, w.end)
+	}
+	for _, e := range s.Score.GetNHistoricalEntries(s.Score.GetNumPrevious()) {
+		if e.Outcome != scoring.OutcomeWon && e.Outcome != "" {
+			continue
+		}
+		if s.Ghost = NewGhost(ends, e.WordOffsetsMs); s.Ghost != nil {
+			return
+		}
+	}
+}
+
+// GhostPos returns the rune position the ghost is at now, or -1 while there
+// is no ghost to show.
+func (s *State) GhostPos() int {
+	if s.Ghost == nil || s.Win || s.Loss || s.IsPreviewing() {
+		return -1
+	}
+	return s.Ghost.PosAt(s.Now().Sub(s.startedAt))
+}
+
+// GhostLead returns how far ahead of the ghost the game is: how much later
+// than now the ghost got to the cursor, negative if it got there earlier.
+// ok is false while there is no ghost to race.
+func (s *State) GhostLead() (lead time.Duration, ok bool) {
+	if s.GhostPos() < 0 {
+		return 0, false
+	}
+	return s.Ghost.TimeAt(s.Pos) - s.Now().Sub(s.startedAt), true
+}
+
+// wordOffsets returns when each word was completed, in milliseconds from the
+// start of the game, for the ghost of a later attempt. A word that was never
+// typed, such as one given away by a hint, counts as completed along with the
+// word before it.
+func (s *State) wordOffsets() []int64 {
+	words := s.wordSpans()
+	offsets := make([]int64, len(words))
+	var last time.Duration
+	for i := range words {
+		if at, ok := s.wordDoneAt[i]; ok && at > last {
+			last = at
+		}
+		offsets[i] = last.Milliseconds()
+	}
+	return offsets
+}
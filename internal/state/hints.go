@@ -0,0 +1,79 @@
+package state
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Strategies for which letter a hint reveals, for GameOptions.HintStrategy.
+const (
+	HintNext      = "next"      // The next letter to type
+	HintRare      = "rare"      // The least common letter left in the word
+	HintConsonant = "consonant" // The first consonant left in the word
+)
+
+// HintStrategies lists the hint strategies, the default first.
+var HintStrategies = []string{HintNext, HintRare, HintConsonant}
+
+// letterFrequency holds the English letters from the most to the least
+// common in running text.
+const letterFrequency = "etaoinshrdlcumwfgypbvkjxqz"
+
+// PickHintPosition returns the index in secret a hint reveals, given the mask
+// and pos, the next hidden letter. A strategy other than HintNext picks among
+// the hidden letters of the word holding pos, and falls back to pos when
+// there is no choice to make.
+func PickHintPosition(secret, mask []rune, pos int, strategy string) int {
+	if strategy == HintNext || strategy == "" || pos >= len(secret) || !isWordRune(secret[pos]) {
+		return pos
+	}
+
+	start, end := pos, pos
+	for start > 0 && isWordRune(secret[start-1]) {
+		start--
+	}
+	for end < len(secret) && isWordRune(secret[end]) {
+		end++
+	}
+	var hidden []int
+	for i := start; i < end; i++ {
+		if mask[i] == '_' && unicode.IsLetter(secret[i]) {
+			hidden = append(hidden, i)
+		}
+	}
+	if len(hidden) < 2 {
+		return pos
+	}
+
+	switch strategy {
+	case HintRare:
+		best := hidden[0]
+		for _, i := range hidden[1:] {
+			if letterRank(secret[i]) > letterRank(secret[best]) {
+				best = i
+			}
+		}
+		return best
+	case HintConsonant:
+		for _, i := range hidden {
+			if !strings.ContainsRune("aeiou", unicode.ToLower(ASCIIEquivalent(secret[i]))) {
+				return i
+			}
+		}
+	}
+	return pos
+}
+
+// isWordRune reports whether r is part of a word for PickHintPosition.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// letterRank returns how rare r is in English, from 0 for 'e'. Letters not in
+// letterFrequency count as rarer than any in it.
+func letterRank(r rune) int {
+	if i := strings.IndexRune(letterFrequency, unicode.ToLower(r)); i >= 0 {
+		return i
+	}
+	return len(letterFrequency)
+}
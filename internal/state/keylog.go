@@ -0,0 +1,171 @@
+package state
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Kinds of KeyLogEntry.
+const (
+	KeyLogCard = "card" // A game started; the entries after it are its keys and ticks
+	KeyLogKey  = "key"  // A key was pressed
+	KeyLogTick = "tick" // A second of the timer or preview passed
+)
+
+// What a key did, as recorded in a KeyLog.
+const (
+	OutcomeMatch    = "match"    // The key was right, or otherwise moved the game on
+	OutcomeMismatch = "mismatch" // The key was wrong and cost an error
+	OutcomeHint     = "hint"     // The key asked for a hint
+	OutcomeIgnored  = "ignored"  // The key changed nothing
+)
+
+// KeyLogEntry is one line of a KeyLog.
+type KeyLogEntry struct {
+	Kind    string         `json:"kind"`
+	Ms      int64          `json:"ms"`                // Milliseconds since the game started
+	Key     string         `json:"key,omitempty"`     // As the practised layout would type it
+	Outcome string         `json:"outcome,omitempty"` // What the key did, for keys
+	Score   int            `json:"score"`             // Score after the key or tick
+	Card    *KeyLogCardRef `json:"card,omitempty"`    // The card and options, for the start of a game
+}
+
+// KeyLogCardRef identifies the card a recorded game was played on, and
+// everything needed to set it up again the same way. The text itself is not
+// recorded; the card is found again by its hash.
+type KeyLogCardRef struct {
+	Hash      string        `json:"hash"` // scoring.TextHash of the card's text
+	Title     string        `json:"title"`
+	Seed      int64         `json:"seed"`      // Seed of the game's random reveals
+	TimeLimit int           `json:"timeLimit"` // Seconds on the timer, 0 if it was off
+	Options   KeyLogOptions `json:"options"`
+}
+
+// KeyLogOptions are the game options that change what keys do.
+// The timer limit is recorded once resolved, as KeyLogCardRef.TimeLimit.
+type KeyLogOptions struct {
+	FirstLetter        bool    `json:"firstLetter,omitempty"`
+	LastLetter         bool    `json:"lastLetter,omitempty"`
+	NRandom            int     `json:"nRandom,omitempty"`
+	NWords             int     `json:"nWords,omitempty"`
+	Cloze              int     `json:"cloze,omitempty"`
+	Preview            int     `json:"preview,omitempty"`
+	StrictSymbols      bool    `json:"strictSymbols,omitempty"`
+	HideSpaces         bool    `json:"hideSpaces,omitempty"`
+	RequirePunctuation bool    `json:"requirePunctuation,omitempty"`
+	RequireEnter       bool    `json:"requireEnter,omitempty"`
+	Flash              bool    `json:"flash,omitempty"`
+	Recall             bool    `json:"recall,omitempty"`
+	Grace              int     `json:"grace,omitempty"`
+	Lenient            bool    `json:"lenient,omitempty"`
+	ForgiveTypos       bool    `json:"forgiveTypos,omitempty"`
+	NoTypeThrough      bool    `json:"noTypeThrough,omitempty"`
+	MinAccuracy        float64 `json:"minAccuracy,omitempty"`
+	ScoreFloor         int     `json:"scoreFloor,omitempty"`
+	NoScoreFloor       bool    `json:"noScoreFloor,omitempty"`
+	LegacyWordSplit    bool    `json:"legacyWordSplit,omitempty"`
+	LimitHints         bool    `json:"limitHints,omitempty"`
+	MaxHints           int     `json:"maxHints,omitempty"`
+	NumberLeniency     bool    `json:"numberLeniency,omitempty"`
+	AutoHint           int     `json:"autoHint,omitempty"`
+	HintStrategy       string  `json:"hintStrategy,omitempty"`
+	LenientDigits      bool    `json:"lenientDigits,omitempty"`
+}
+
+// KeyLogOptions returns the options to record for a game played with o.
+func (o GameOptions) KeyLogOptions() KeyLogOptions {
+	return KeyLogOptions{
+		FirstLetter:        o.FirstLetter,
+		LastLetter:         o.LastLetter,
+		NRandom:            o.NRandom,
+		NWords:             o.NWords,
+		Cloze:              o.Cloze,
+		Preview:            o.Preview,
+		StrictSymbols:      o.StrictSymbols,
+		HideSpaces:         o.HideSpaces,
+		RequirePunctuation: o.RequirePunctuation,
+		RequireEnter:       o.RequireEnter,
+		Flash:              o.Flash,
+		Recall:             o.Recall,
+		Grace:              o.Grace,
+		Lenient:            o.Lenient,
+		ForgiveTypos:       o.ForgiveTypos,
+		NoTypeThrough:      o.NoTypeThrough,
+		MinAccuracy:        o.MinAccuracy,
+		ScoreFloor:         o.ScoreFloor,
+		NoScoreFloor:       o.NoScoreFloor,
+		LegacyWordSplit:    o.LegacyWordSplit,
+		LimitHints:         o.LimitHints,
+		MaxHints:           o.MaxHints,
+		NumberLeniency:     o.NumberLeniency,
+		AutoHint:           o.AutoHint,
+		HintStrategy:       o.HintStrategy,
+		LenientDigits:      o.LenientDigits,
+	}
+}
+
+// GameOptions returns the options to replay a recorded game with. There is
+// no layout, since the keys were recorded as the layout translated them.
+func (o KeyLogOptions) GameOptions() GameOptions {
+	return GameOptions{
+		FirstLetter:        o.FirstLetter,
+		LastLetter:         o.LastLetter,
+		NRandom:            o.NRandom,
+		NWords:             o.NWords,
+		Cloze:              o.Cloze,
+		Preview:            o.Preview,
+		StrictSymbols:      o.StrictSymbols,
+		HideSpaces:         o.HideSpaces,
+		RequirePunctuation: o.RequirePunctuation,
+		RequireEnter:       o.RequireEnter,
+		Flash:              o.Flash,
+		Recall:             o.Recall,
+		Grace:              o.Grace,
+		Lenient:            o.Lenient,
+		ForgiveTypos:       o.ForgiveTypos,
+		NoTypeThrough:      o.NoTypeThrough,
+		MinAccuracy:        o.MinAccuracy,
+		ScoreFloor:         o.ScoreFloor,
+		NoScoreFloor:       o.NoScoreFloor,
+		LegacyWordSplit:    o.LegacyWordSplit,
+		LimitHints:         o.LimitHints,
+		MaxHints:           o.MaxHints,
+		NumberLeniency:     o.NumberLeniency,
+		AutoHint:           o.AutoHint,
+		HintStrategy:       o.HintStrategy,
+		LenientDigits:      o.LenientDigits,
+	}
+}
+
+// KeyLog writes every key pressed in games as JSON lines, with when it was
+// pressed and what it did, so that the games can be replayed. One log can be
+// shared by every game in a session.
+type KeyLog struct {
+	enc     *json.Encoder
+	started time.Time
+	Now     func() time.Time // Clock for the timestamps (replaceable in tests)
+}
+
+// NewKeyLog returns a KeyLog that writes to w.
+func NewKeyLog(w io.Writer) *KeyLog {
+	return &KeyLog{enc: json.NewEncoder(w), Now: time.Now}
+}
+
+// StartCard records the start of a game on a card. Later keys are timed from here.
+// Errors are ignored, as for an EventLog.
+func (l *KeyLog) StartCard(card KeyLogCardRef) {
+	l.started = l.Now()
+	l.enc.Encode(KeyLogEntry{Kind: KeyLogCard, Card: &card})
+}
+
+// Write records a key or tick of the current game.
+func (l *KeyLog) Write(kind, key, outcome string, score int) {
+	l.enc.Encode(KeyLogEntry{
+		Kind:    kind,
+		Ms:      l.Now().Sub(l.started).Milliseconds(),
+		Key:     key,
+		Outcome: outcome,
+		Score:   score,
+	})
+}
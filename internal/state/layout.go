@@ -0,0 +1,114 @@
+package state
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// KeyLayout translates the characters produced by the physical keyboard into
+// the ones a different layout would produce, for practising that layout.
+type KeyLayout struct {
+	Name string
+	Map  map[rune]rune
+}
+
+// Built-in layouts, as pairs of QWERTY characters and their translations.
+var builtinLayouts = map[string][2]string{
+	"qwerty-to-colemak": {
+		"qwertyuiopasdfghjkl;zxcvbnmQWERTYUIOPASDFGHJKL:ZXCVBNM",
+		"qwfpgjluy;arstdhneiozxcvbkmQWFPGJLUY:ARSTDHNEIOZXCVBKM",
+	},
+	"qwerty-to-dvorak": {
+		"qwertyuiop[]asdfghjkl;'zxcvbnm,./-=" + `QWERTYUIOP{}ASDFGHJKL:"ZXCVBNM<>?_+`,
+		"',.pyfgcrl/=aoeuidhtns-;qjkxbmwvz[]" + `"<>PYFGCRL?+AOEUIDHTNS_:QJKXBMWVZ{}`,
+	},
+}
+
+// ParseLayout returns the layout for a --layout value: the name of a built-in
+// layout, or "file:<path>" for a file of two-column "from to" lines.
+func ParseLayout(spec string) (*KeyLayout, error) {
+	if path, ok := strings.CutPrefix(spec, "file:"); ok {
+		return loadLayoutFile(path)
+	}
+
+	pairs, ok := builtinLayouts[spec]
+	if !ok {
+		return nil, fmt.Errorf("unknown layout: %s (use qwerty-to-colemak, qwerty-to-dvorak or file:<path>)", spec)
+	}
+	from, to := []rune(pairs[0]), []rune(pairs[1])
+	m := make(map[rune]rune, len(from))
+	for i, r := range from {
+		m[r] = to[i]
+	}
+	return &KeyLayout{Name: strings.TrimPrefix(spec, "qwerty-to-"), Map: m}, nil
+}
+
+func loadLayoutFile(path string) (*KeyLayout, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open layout file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	m := make(map[rune]rune)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 || utf8.RuneCountInString(fields[0]) != 1 || utf8.RuneCountInString(fields[1]) != 1 {
+			return nil, fmt.Errorf("layout file %s line %d: expected two single characters", path, line)
+		}
+		from, _ := utf8.DecodeRuneInString(fields[0])
+		to, _ := utf8.DecodeRuneInString(fields[1])
+		m[from] = to
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read layout file %s: %w", path, err)
+	}
+	return &KeyLayout{Name: path, Map: m}, nil
+}
+
+// Translate maps a single printable character through the layout.
+// Anything else, such as "ctrl+r" or "tab", is returned unchanged.
+func (l *KeyLayout) Translate(ch string) string {
+	if l == nil || utf8.RuneCountInString(ch) != 1 {
+		return ch
+	}
+	r, _ := utf8.DecodeRuneInString(ch)
+	if !unicode.IsPrint(r) {
+		return ch
+	}
+	if to, ok := l.Map[r]; ok {
+		return string(to)
+	}
+	return ch
+}
+
+// Physical returns the QWERTY key that produces r under the layout, the
+// reverse of Translate. Characters the layout doesn't produce are their own key.
+// When several keys produce r, as a layout file may have them, the lowest is
+// taken, so the answer doesn't change from one run to the next.
+func (l *KeyLayout) Physical(r rune) rune {
+	if l == nil {
+		return r
+	}
+	key := rune(-1)
+	for from, to := range l.Map {
+		if to == r && (key < 0 || from < key) {
+			key = from
+		}
+	}
+	if key >= 0 {
+		return key
+	}
+	if _, remapped := l.Map[r]; remapped {
+		return 0 // The key for r now types something else
+	}
+	return r
+}
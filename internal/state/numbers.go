@@ -0,0 +1,43 @@
+package state
+
+import "strings"
+
+// numberWords spells out each digit, for NumberLeniency.
+var numberWords = [...]string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine"}
+
+// numberWordAt returns the word for the digit at index i of the secret, if it
+// is a number of a single digit.
+func (s State) numberWordAt(i int) (string, bool) {
+	isDigit := func(j int) bool { return j >= 0 && j < len(s.Secret) && s.Secret[j] >= '0' && s.Secret[j] <= '9' }
+	if !isDigit(i) || isDigit(i-1) || isDigit(i+1) {
+		return "", false
+	}
+	return numberWords[s.Secret[i]-'0'], true
+}
+
+// spellNumber follows the hidden digit at Pos being spelled out as a word,
+// with NumberLeniency. It returns whether ch goes on with the word, and
+// whether it finishes it, in which case the digit counts as typed. A key that
+// doesn't go on with the word starts it over, and is checked as usual.
+func (s *State) spellNumber(ch string) (spelling, done bool) {
+	word, ok := s.numberWordAt(s.Pos)
+	if !ok || s.Mask[s.Pos] != '_' || len([]rune(ch)) != 1 {
+		s.numberSpelled = ""
+		return false, false
+	}
+	if s.numberSpelledAt != s.Pos {
+		s.numberSpelled, s.numberSpelledAt = "", s.Pos
+	}
+
+	spelled := s.numberSpelled + strings.ToLower(ch)
+	switch {
+	case spelled == word:
+		s.numberSpelled = ""
+		return true, true
+	case strings.HasPrefix(word, spelled):
+		s.numberSpelled = spelled
+		return true, false
+	}
+	s.numberSpelled = ""
+	return false, false
+}
@@ -0,0 +1,97 @@
+package state
+
+import (
+	"go-mem/internal/scoring"
+	"slices"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Recall mode is a different exercise: after the preview, the whole text is
+// typed from memory into a blank field, with nothing checked along the way.
+// Ctrl+D hands it in, and it is graded word by word against the secret, see
+// scoring.GradeRecall. The score is the share of the most a perfect game could
+// score given by the grade, and is saved to the same history as typed games.
+// There is no timer in recall mode.
+
+// RecallFinishKey hands in a recall attempt.
+const RecallFinishKey = "ctrl+d"
+
+// InitRecall hides the whole secret for recall mode and clears the attempt.
+func (s *State) InitRecall() {
+	mask := make([]rune, len(s.Secret))
+	for i, ch := range s.Secret {
+		if unicode.IsSpace(ch) {
+			mask[i] = ch
+		} else {
+			mask[i] = '_'
+		}
+	}
+	s.Mask = mask
+	s.RecallInput = nil
+	s.Display.SetValue("")
+}
+
+// RecallKey handles a key in recall mode: printable characters and enter are
+// added to the attempt, backspace takes the last one back, and
+// RecallFinishKey hands it in. Quitting abandons the attempt, as in a typed
+// game. Other keys are ignored.
+func (s *State) RecallKey(ch string) {
+	if s.firstKeyAt.IsZero() {
+		s.firstKeyAt = s.Now()
+	}
+	if IsExitRequested(ch) {
+		s.abandonRecall()
+		return
+	}
+	switch ch {
+	case RecallFinishKey:
+		s.FinishRecall()
+		return
+	case "backspace":
+		if len(s.RecallInput) == 0 {
+			return
+		}
+		s.RecallInput = s.RecallInput[:len(s.RecallInput)-1]
+	case "enter":
+		s.RecallInput = append(s.RecallInput, '\n')
+	default:
+		ch = s.Options.Layout.Translate(ch)
+		r, size := utf8.DecodeRuneInString(ch)
+		if size == 0 || size != len(ch) || (!unicode.IsPrint(r) && r != '\n') {
+			return
+		}
+		s.RecallInput = append(s.RecallInput, r)
+	}
+	s.Display.SetValue(string(s.RecallInput))
+}
+
+// FinishRecall grades the attempt, shows the secret and saves the score.
+func (s *State) FinishRecall() {
+	if s.Win || s.Loss {
+		return
+	}
+	s.RecallGrade = scoring.GradeRecall(string(s.Secret), string(s.RecallInput))
+	s.Score.ScoreRecall(s.RecallGrade, s.MaxScore())
+	s.Score.SetPercentOfMax(s.MaxScore())
+	s.Score.SetDuration(s.Now().Sub(s.startedAt))
+	s.Score.SetPlayTime(s.Now().Sub(s.firstKeyAt))
+
+	s.Mask = slices.Clone(s.Secret)
+	s.Display.SetValue(string(s.Mask))
+	s.Win = true
+	s.Score.SetOutcome(scoring.OutcomeWon)
+	s.Score.SaveEntries()
+	s.emit(EventWin)
+}
+
+// abandonRecall ends a recall attempt the player quit, ungraded, and saves it
+// as abandoned.
+func (s *State) abandonRecall() {
+	s.Loss = true
+	s.Abandoned = true
+	s.Score.SetPlayTime(s.Now().Sub(s.firstKeyAt))
+	s.Score.SetOutcome(s.outcome())
+	s.Score.SaveEntries()
+	s.emit(EventLoss)
+}
@@ -5,7 +5,7 @@ import (
 	"go-mem/internal/scoring"
 	"math/rand"
 	"slices"
-	"strings"
+	"time"
 	"unicode"
 
 	"github.com/charmbracelet/bubbles/textarea"
@@ -14,22 +14,86 @@ import (
 
 // ... GameOptions and State structs remain the same ...
 type GameOptions struct {
-	TimerLimit  int // -1 auto, 0 off, >0 seconds
-	FirstLetter bool
-	NRandom     int
-	NWords      int
+	TimerLimit         int // -1 auto, 0 off, >0 seconds
+	FirstLetter        bool
+	LastLetter         bool // Reveal the last letter of each word; composes with FirstLetter
+	NRandom            int
+	NWords             int
+	Cloze              int        // Reveal everything but N random words, which are the only ones typed and scored
+	Preview            int        // Seconds to show the unmasked text before the game starts, 0 off
+	StrictSymbols      bool       // Mask punctuation too, so everything but whitespace must be typed
+	HideSpaces         bool       // Mask spaces too, so they must be typed
+	RequirePunctuation bool       // Mask .,!?;: too; '?' is still a hint unless it's the next character
+	RequireEnter       bool       // Mask line breaks too, so Enter must be pressed at the end of each line
+	Layout             *KeyLayout // Translates typed characters before they are matched, nil for none
+	Flash              bool       // Read-through mode: space reveals words, nothing is typed or scored
+	Recall             bool       // Type the whole text from memory and have it graded at the end
+	CPM                int        // Typing rate the auto timer allows for, -1 for scoring.DefaultCPM
+	WPMTarget          int        // Goal speed in words per minute that sets the auto timer, 0 for none
+	Grace              int        // Seconds before the timer starts counting down, 0 for none
+	Rand               *rand.Rand // Source for random reveals and card shuffling, nil for math/rand's
+	Lenient            bool       // A wrong letter is penalised and revealed, but doesn't have to be corrected
+	ForgiveTypos       bool       // A key next to the right one costs less and isn't a mistake
+	NoTypeThrough      bool       // Keys are always checked against Pos, never typed over revealed letters
+	Events             *EventLog  // Receives what happens in the game as it is played, nil for none
+	Record             *KeyLog    // Receives every key pressed, for replaying the game, nil for none
+	MinAccuracy        float64    // Accuracy, as a percentage, needed for a high score, 0 for any
+	ScoreFloor         int        // The game is lost when the score drops below this, 0 or less
+	LegacyWordSplit    bool       // Split words at apostrophes, as "don" and "t", like older versions
+	Ghost              bool       // Race the best previous attempt, if its word timings were saved
+	NoScoreFloor       bool       // Never lose for a low score, only to the timer or a reveal
+	MaxAttemptsPerDay  int        // Attempts a card can be played a day before it's refused, 0 for no limit
+	Adaptive           bool       // Reveal more or fewer random letters depending on how the text has gone before
+	LimitHints         bool       // Allow only MaxHints letter hints per card
+	MaxHints           int        // Letter hints allowed per card with LimitHints, 0 for none
+	NoConfidence       bool       // Don't shade the words mistyped in earlier attempts
+	ByLine             bool       // Play a card of several lines a line at a time, as a game per line
+	NumberLeniency     bool       // A single digit can also be typed spelled out, as "two" for 2
+	AutoHint           int        // Seconds without a key before the next character is revealed, for less than a hint; 0 off. Timed games only
+	MixedModes         bool       // Give each card of a session a reveal assist at random: first letters, random letters or none
+	HintStrategy       string     // Which letter of the word a hint reveals, one of HintStrategies; "" for HintNext
+	LenientDigits      bool       // Give digits away like punctuation, so numbers needn't be typed
+	MoreLines          bool       // The game is a line of a card played by line with more to come: winning it earns no finishing bonuses and isn't saved
+}
+
+// AutoTimeLimit returns the auto timer limit for a text: the time that a
+// WPMTarget allows for its words, or else the one suggested by the history.
+func (o GameOptions) AutoTimeLimit(secret string, entries []scoring.ScoreHistoryEntry) int {
+	if o.WPMTarget > 0 {
+		return scoring.WordsTimeLimit(WordCount(secret), o.WPMTarget)
+	}
+	return scoring.SuggestTimeLimit(secret, entries, o.CPM)
+}
+
+// Intn returns a random number in [0, n) from the options' random source.
+func (o GameOptions) Intn(n int) int {
+	if o.Rand != nil {
+		return o.Rand.Intn(n)
+	}
+	return rand.Intn(n)
+}
+
+// Shuffle shuffles n elements using the options' random source.
+func (o GameOptions) Shuffle(n int, swap func(i, j int)) {
+	if o.Rand != nil {
+		o.Rand.Shuffle(n, swap)
+		return
+	}
+	rand.Shuffle(n, swap)
 }
 
 type State struct {
-	Textarea             textarea.Model
+	Display              Display
 	Mask                 []rune
 	Secret               []rune
 	Pos                  int
 	Win                  bool // To determine if the user has won
 	Loss                 bool // To determine if the user has lost
 	Revealed             bool // To determine if the user revealed the card
+	Abandoned            bool // To determine if the user quit before the game ended
 	WrongLetter          bool // To determine if the last typed character was wrong
 	RevealedCharMistakes map[int]bool
+	ErrorPositions       map[int]bool // Hidden positions where a wrong character was typed
 	Score                scoring.Scoring
 	CardWidth            int
 	BracketedPositions   []int
@@ -38,7 +102,35 @@ type State struct {
 	TimerEnabled         bool
 	TimeLimit            int // Total time in seconds
 	TimeRemaining        int // Current time remaining in seconds
+	PreviewRemaining     int // Seconds left in the read-through preview
+	GraceRemaining       int // Seconds left before the timer starts counting down
 	Options              GameOptions
+	Now                  func() time.Time      // Clock used for word timings (replaceable in tests)
+	WordDurations        map[int]time.Duration // Time taken per completed word, keyed by word index
+	lastWordAt           time.Time             // When the previous word was completed
+	startedAt            time.Time             // When typing started, after any preview
+	firstKeyAt           time.Time             // When the first key was pressed, for the attempt's play time
+	FlashRevealed        int                   // Words currently revealed in flash mode
+	flashHidden          []bool                // Flash mode words that have been hidden again
+	clozeBlanks          []wordSpan            // The words left hidden in cloze mode, in order
+	errorsThisWord       int                   // Wrong letters typed since the last word bonus
+	wordDoneAt           map[int]time.Duration // When each word was completed, from startedAt, keyed by word index
+	Ghost                *Ghost                // The best previous attempt, to race against, nil for none
+	RecallInput          []rune                // What has been typed in recall mode
+	RecallGrade          scoring.RecallGrade   // How the recall attempt was graded, once handed in
+	HintsLeft            int                   // Letter hints left with Options.LimitHints
+	Trouble              map[int]int           // How much each position's word was mistyped before, as a scoring.TroubleLevel, nil for none
+	numberSpelled        string                // Letters typed so far of the digit at numberSpelledAt spelled out, with Options.NumberLeniency
+	numberSpelledAt      int
+	idleSeconds          int  // Timer ticks since the last key, for Options.AutoHint
+	autoHinting          bool // The character being revealed is an auto hint
+	LinePeek             int  // Ticks left showing the current line in full after Ctrl+L, 0 for none
+
+	// StartMask, if set before the game starts, is the mask it starts from
+	// in place of one drawn by the game modes, as for a line of a card played
+	// by line, whose reveals are drawn for the whole card. The score's
+	// multiplier is then left as it was set for the whole card.
+	StartMask []rune
 }
 
 // ... NewState ...
@@ -48,31 +140,43 @@ func NewState(
 	ta textarea.Model,
 	scoring scoring.Scoring,
 	opts GameOptions,
+) *State {
+	return NewStateWithDisplay(secretMessage, cardWidth, &TextareaDisplay{Model: ta}, scoring, opts)
+}
+
+// NewStateWithDisplay creates a State that renders the board to the given Display.
+func NewStateWithDisplay(
+	secretMessage string,
+	cardWidth int,
+	display Display,
+	score scoring.Scoring,
+	opts GameOptions,
 ) *State {
 	s := &State{
-		Textarea:             ta,
+		Display:              display,
 		Secret:               []rune(secretMessage),
 		Pos:                  0,
 		WrongLetter:          false,
 		RevealedCharMistakes: make(map[int]bool),
-		Score:                scoring,
+		ErrorPositions:       make(map[int]bool),
+		Score:                score,
 		CardWidth:            cardWidth,
-		TimerEnabled:         opts.TimerLimit != 0,
+		TimerEnabled:         opts.TimerLimit != 0 && !opts.Flash && !opts.Recall,
 		Options:              opts,
+		Now:                  time.Now,
+		WordDurations:        make(map[int]time.Duration),
+		wordDoneAt:           make(map[int]time.Duration),
+		HintsLeft:            opts.MaxHints,
 	}
 
 	if s.TimerEnabled {
 		limit := opts.TimerLimit
 		if limit == -1 {
-			// Calculate time limit: Length / 3 seconds (approx 180 CPM).
-			// At least 10 seconds.
-			limit = len(s.Secret) / 3
-			if limit < 10 {
-				limit = 10
-			}
+			limit = opts.AutoTimeLimit(secretMessage, nil)
 		}
 		s.TimeLimit = limit
 		s.TimeRemaining = limit
+		s.GraceRemaining = opts.Grace
 	}
 
 	s.FSM = fsm.NewFSM(
@@ -86,11 +190,22 @@ func NewState(
 
 // ... ApplyGameModes, Reveal methods ...
 func (s *State) ApplyGameModes(opts GameOptions) {
+	// Cloze goes first, so that the other modes give hints to its blanks
+	if opts.Cloze > 0 {
+		s.HideClozeWords(opts.Cloze)
+	}
 	if opts.FirstLetter {
 		s.RevealFirstLetters()
 	}
-	if opts.NRandom > 0 {
-		s.RevealRandomLetters(opts.NRandom)
+	if opts.LastLetter {
+		s.RevealLastLetters()
+	}
+	nRandom := opts.NRandom
+	if opts.Adaptive {
+		nRandom += s.AdaptiveReveals()
+	}
+	if nRandom > 0 {
+		s.RevealRandomLetters(nRandom)
 	}
 	if opts.NWords > 0 {
 		s.RevealRandomWords(opts.NWords)
@@ -99,19 +214,77 @@ func (s *State) ApplyGameModes(opts GameOptions) {
 	s.SkipIgnorable()
 }
 
+// UseStartMask starts the game from StartMask rather than drawing the reveals
+// with the game modes, and reports whether it could: the mask must fit the
+// secret. In cloze mode the words it leaves hidden are the blanks.
+func (s *State) UseStartMask() bool {
+	if s.StartMask == nil || len(s.StartMask) != len(s.Secret) {
+		return false
+	}
+	s.Mask = slices.Clone(s.StartMask)
+	if s.Options.Cloze > 0 {
+		s.clozeBlanks = nil
+		for _, span := range s.wordSpans() {
+			if slices.Contains(s.Mask[span.start:span.end], '_') {
+				s.clozeBlanks = append(s.clozeBlanks, span)
+			}
+		}
+		if len(s.clozeBlanks) > 0 {
+			s.Pos = s.clozeBlanks[0].start
+		}
+	}
+	s.SkipIgnorable()
+	return true
+}
+
 func (s *State) RevealFirstLetters() {
-	inWord := false
-	for i, ch := range s.Secret {
+	for _, span := range s.wordSpans() {
+		s.Mask[span.start] = s.Secret[span.start]
+	}
+}
+
+// RevealLastLetters reveals the final letter or digit of each word. Bracketed
+// text is left as InitMask set it.
+func (s *State) RevealLastLetters() {
+	for _, span := range s.wordSpans() {
+		last := span.end - 1
+		if slices.Contains(s.BracketedPositions, last) {
+			continue
+		}
+		s.Mask[last] = s.Secret[last]
+	}
+}
+
+// Shares of the letters that adaptive mode reveals, by how the text has gone
+// before.
+const (
+	adaptiveStrongShare = 0.10 // A best win of at least adaptiveStrongPercent
+	adaptiveMediumShare = 0.25 // No attempts yet, only weaker wins, or no telling
+	adaptiveFailedShare = 0.50 // Attempts, but none of them won
+
+	adaptiveStrongPercent = 80.0
+)
+
+// AdaptiveReveals returns how many random letters adaptive mode reveals for
+// the text, from its score history: few after a strong win, more when it was
+// lost or revealed and never won, and a medium number for a new text. Entries
+// saved without an outcome or a share of the maximum don't tell either way.
+func (s *State) AdaptiveReveals() int {
+	share := adaptiveMediumShare
+	if best := s.Score.GetBestWin(); best != nil {
+		if best.PercentOfMax >= adaptiveStrongPercent {
+			share = adaptiveStrongShare
+		}
+	} else if s.Score.HasFailed() {
+		share = adaptiveFailedShare
+	}
+	letters := 0
+	for _, ch := range s.Secret {
 		if unicode.IsLetter(ch) || unicode.IsDigit(ch) {
-			if !inWord {
-				// Start of word
-				s.Mask[i] = ch
-				inWord = true
-			}
-		} else {
-			inWord = false
+			letters++
 		}
 	}
+	return int(float64(letters) * share)
 }
 
 func (s *State) RevealRandomLetters(n int) {
@@ -124,7 +297,7 @@ func (s *State) RevealRandomLetters(n int) {
 	}
 
 	// Shuffle and pick n
-	rand.Shuffle(len(candidates), func(i, j int) {
+	s.Options.Shuffle(len(candidates), func(i, j int) {
 		candidates[i], candidates[j] = candidates[j], candidates[i]
 	})
 
@@ -140,33 +313,9 @@ func (s *State) RevealRandomLetters(n int) {
 }
 
 func (s *State) RevealRandomWords(n int) {
-	type wordSpan struct {
-		start, end int
-	}
-	var words []wordSpan
-	inWord := false
-	start := 0
+	words := s.wordSpans()
 
-	for i, ch := range s.Secret {
-		isAlphanum := unicode.IsLetter(ch) || unicode.IsDigit(ch)
-		if isAlphanum {
-			if !inWord {
-				start = i
-				inWord = true
-			}
-		} else {
-			if inWord {
-				words = append(words, wordSpan{start, i})
-				inWord = false
-			}
-		}
-	}
-	// Check last word
-	if inWord {
-		words = append(words, wordSpan{start, len(s.Secret)})
-	}
-
-	rand.Shuffle(len(words), func(i, j int) {
+	s.Options.Shuffle(len(words), func(i, j int) {
 		words[i], words[j] = words[j], words[i]
 	})
 
@@ -183,10 +332,47 @@ func (s *State) RevealRandomWords(n int) {
 	}
 }
 
+// HideClozeWords reveals the whole text apart from n random words, for
+// fill-in-the-blank study: the inverse of RevealRandomWords. Words within
+// brackets are never picked, as bracketed text is always shown.
+func (s *State) HideClozeWords(n int) {
+	var words []wordSpan
+	for _, span := range s.wordSpans() {
+		if !slices.Contains(s.BracketedPositions, span.start) {
+			words = append(words, span)
+		}
+	}
+	copy(s.Mask, s.Secret)
+
+	s.Options.Shuffle(len(words), func(i, j int) {
+		words[i], words[j] = words[j], words[i]
+	})
+	s.clozeBlanks = words[:min(n, len(words))]
+	slices.SortFunc(s.clozeBlanks, func(a, b wordSpan) int { return a.start - b.start })
+
+	for _, span := range s.clozeBlanks {
+		for j := span.start; j < span.end; j++ {
+			s.Mask[j] = '_'
+		}
+	}
+	// Start on the first blank
+	s.Pos = 0
+	if len(s.clozeBlanks) > 0 {
+		s.Pos = s.clozeBlanks[0].start
+	}
+}
+
+// skipsRevealed reports whether the cursor moves past the revealed letter at
+// Pos, as in cloze mode.
+func (s *State) skipsRevealed() bool {
+	return s.Options.Cloze > 0 && s.Mask[s.Pos] != '_'
+}
+
 func (s *State) SkipIgnorable() {
 	// Skip spaces and punctuation (ShouldIgnore) AND bracketed content.
-	// STOP at revealed letters (Mask != '_' but NOT Ignorable).
-	for s.Pos < len(s.Secret) && (s.ShouldIgnore(string(s.Secret[s.Pos])) || slices.Contains(s.BracketedPositions, s.Pos)) {
+	// STOP at revealed letters (Mask != '_' but NOT Ignorable), unless in cloze
+	// mode, where only the blanks are typed.
+	for s.Pos < len(s.Secret) && (s.ShouldIgnore(string(s.Secret[s.Pos])) || slices.Contains(s.BracketedPositions, s.Pos) || s.skipsRevealed()) {
 		// Ensure Mask is updated for skipped chars (usually InitMask handled it, but just in case)
 		if s.Mask[s.Pos] == '_' {
 			s.Mask[s.Pos] = s.Secret[s.Pos]
@@ -195,10 +381,40 @@ func (s *State) SkipIgnorable() {
 	}
 }
 
+// scoreFinish adds the bonuses for finishing the card: the message bonus, and
+// the time bonus if it is timed. A line with more of its card to come earns
+// neither.
+func (s *State) scoreFinish() {
+	if s.Options.MoreLines {
+		return
+	}
+	s.Score.ScoreEvent("messageBonus")
+	if s.TimerEnabled {
+		s.Score.AddTimeBonus(s.TimeRemaining)
+	}
+}
+
+// LinePeekSeconds is how long Ctrl+L shows the current line for.
+const LinePeekSeconds = 2
+
+// intermediateStates are the states the FSM passes through while handling a
+// single input or tick. It should always settle back in idle or endState.
+var intermediateStates = []string{
+	"checkGameState", "processChar", "revealingAll", "jumping",
+	"revealNextChar", "revealNextWord", "peekingLine", "checkCorrectness", "gotMatch", "noMatch",
+	"updateMask", "advancing", "updateScore", "evaluating", "timeCheck",
+}
+
+// IsSettled reports whether the FSM is waiting for input or the game is over.
+func (s *State) IsSettled() bool {
+	return s.FSM.Current() == "idle" || s.FSM.Current() == "endState"
+}
+
 // ... getStateTransitions ...
 func getStateTransitions() []fsm.EventDesc {
 	return fsm.Events{
-		{Name: "initGame", Src: []string{"start"}, Dst: "idle"},
+		{Name: "preview", Src: []string{"start"}, Dst: "previewing"},
+		{Name: "initGame", Src: []string{"start", "previewing"}, Dst: "idle"},
 		{Name: "input", Src: []string{"idle"}, Dst: "checkGameState"},
 
 		// Game State Checking
@@ -210,13 +426,16 @@ func getStateTransitions() []fsm.EventDesc {
 		// Character Processing
 		{Name: "ignore", Src: []string{"processChar"}, Dst: "evaluating"},
 		{Name: "reveal", Src: []string{"processChar"}, Dst: "revealNextChar"},
+		{Name: "revealWord", Src: []string{"processChar"}, Dst: "revealNextWord"},
+		{Name: "peekLine", Src: []string{"processChar"}, Dst: "peekingLine"},
 		{Name: "check", Src: []string{"processChar"}, Dst: "checkCorrectness"},
 
 		// Actions
-		{Name: "revealed", Src: []string{"revealNextChar"}, Dst: "updateMask"},
+		{Name: "revealed", Src: []string{"revealNextChar", "revealNextWord"}, Dst: "updateMask"},
+		{Name: "revealedAhead", Src: []string{"revealNextChar"}, Dst: "updateScore"}, // The cursor stays put
 		{Name: "match", Src: []string{"checkCorrectness"}, Dst: "gotMatch"},
 		{Name: "mismatch", Src: []string{"checkCorrectness"}, Dst: "noMatch"},
-		{Name: "proceedOnMiss", Src: []string{"checkCorrectness"}, Dst: "advancing"},
+		{Name: "proceedOnMiss", Src: []string{"checkCorrectness", "noMatch"}, Dst: "advancing"},
 
 		{Name: "matched", Src: []string{"gotMatch"}, Dst: "updateMask"},
 		{Name: "gameEnd", Src: []string{"gotMatch"}, Dst: "endState"}, // Allow early exit from gotMatch
@@ -224,6 +443,7 @@ func getStateTransitions() []fsm.EventDesc {
 
 		{Name: "advance", Src: []string{"updateMask"}, Dst: "advancing"},
 		{Name: "jumped", Src: []string{"jumping"}, Dst: "evaluating"},
+		{Name: "peeked", Src: []string{"peekingLine"}, Dst: "evaluating"},
 
 		{Name: "advanced", Src: []string{"advancing"}, Dst: "evaluating"},
 		{Name: "scoreCalculated", Src: []string{"updateScore"}, Dst: "evaluating"},
@@ -233,25 +453,65 @@ func getStateTransitions() []fsm.EventDesc {
 		{Name: "tick", Src: []string{"idle"}, Dst: "timeCheck"},
 		{Name: "timePassed", Src: []string{"timeCheck"}, Dst: "idle"},
 		{Name: "timeExpired", Src: []string{"timeCheck"}, Dst: "endState"},
+		{Name: "autoHint", Src: []string{"timeCheck"}, Dst: "revealNextChar"},
+
+		// Escape hatch if a nested event fails and leaves the FSM mid-way
+		{Name: "recover", Src: intermediateStates, Dst: "idle"},
 	}
 }
 
 // ... getStateCallbacks ...
 func getStateCallbacks(s *State) map[string]fsm.Callback {
 	return fsm.Callbacks{
+		"after_initGame": func(ctx context.Context, e *fsm.Event) {
+			// Word timings and the game duration are measured from the moment the game starts
+			s.startedAt = s.Now()
+			s.lastWordAt = s.startedAt
+			s.initGhost()
+			s.initTrouble()
+
+			// Whatever the game modes revealed makes the game easier, and scores less
+			if s.StartMask == nil {
+				s.Score.SetMultiplier(s.HiddenShare())
+			}
+			s.Score.SetMinAccuracy(s.Options.MinAccuracy)
+		},
+		"enter_previewing": func(ctx context.Context, e *fsm.Event) {
+			// Show the unmasked text until the preview ends
+			s.PreviewRemaining = s.Options.Preview
+			s.Display.SetValue(string(s.Secret))
+		},
 		"enter_timeCheck": func(ctx context.Context, e *fsm.Event) {
 			s.TimeRemaining--
+			s.emit(EventTick)
 			if s.TimeRemaining <= 0 {
 				s.Loss = true
 				e.FSM.Event(ctx, "timeExpired")
 				return
 			}
+			// Left idle for long enough, the next character is given away
+			if s.Options.AutoHint > 0 {
+				s.idleSeconds++
+				if s.idleSeconds >= s.Options.AutoHint {
+					s.idleSeconds = 0
+					s.autoHinting = true
+					e.FSM.Event(ctx, "autoHint")
+					return
+				}
+			}
 			e.FSM.Event(ctx, "timePassed")
 		},
 		"enter_checkGameState": func(ctx context.Context, e *fsm.Event) {
-			// Capture the input character
+			// Capture the input character, as the practised layout would type it
 			if len(e.Args) > 0 {
-				s.CurrentChar = e.Args[0].(string)
+				s.CurrentChar = s.Options.Layout.Translate(e.Args[0].(string))
+				if s.Options.RequireEnter && s.CurrentChar == "enter" {
+					s.CurrentChar = "\n" // Enter types the line break
+				}
+				if s.firstKeyAt.IsZero() {
+					s.firstKeyAt = s.Now()
+				}
+				s.idleSeconds = 0
 			} else {
 				s.CurrentChar = ""
 			}
@@ -266,12 +526,13 @@ func getStateCallbacks(s *State) map[string]fsm.Callback {
 			// Check for exit request
 			if IsExitRequested(s.CurrentChar) {
 				s.Loss = true
+				s.Abandoned = true
 				e.FSM.Event(ctx, "gameEnd")
 				return
 			}
 
 			// Check if previous move caused loss (e.g. score drop)
-			if s.Score.CurrentScore < 0 {
+			if s.BelowScoreFloor() {
 				s.Loss = true
 				e.FSM.Event(ctx, "gameEnd")
 				return
@@ -294,9 +555,10 @@ func getStateCallbacks(s *State) map[string]fsm.Callback {
 		"enter_revealingAll": func(ctx context.Context, e *fsm.Event) {
 			s.Mask = make([]rune, len(s.Secret))
 			copy(s.Mask, s.Secret)
-			s.Textarea.SetValue(string(s.Mask))
+			s.Display.SetValue(string(s.Mask))
 			s.Loss = true // User gave up
 			s.Revealed = true
+			s.Score.ScoreEvent("revealAll")
 			e.FSM.Event(ctx, "gameEnd")
 		},
 		"enter_processChar": func(ctx context.Context, e *fsm.Event) {
@@ -304,16 +566,13 @@ func getStateCallbacks(s *State) map[string]fsm.Callback {
 			s.SkipIgnorable()
 
 			// Update UI to show skipped chars immediately
-			s.Textarea.SetValue(string(s.Mask))
+			s.Display.SetValue(string(s.Mask))
 
 			// Check if we reached end after skipping
 			if s.Pos >= len(s.Secret) {
 				if string(s.Mask) == string(s.Secret) {
 					s.Win = true
-					s.Score.ScoreEvent("messageBonus") // Bonus logic
-					if s.TimerEnabled {
-						s.Score.AddTimeBonus(s.TimeRemaining)
-					}
+					s.scoreFinish()
 					e.FSM.Event(ctx, "gameEnd")
 					return
 				}
@@ -322,6 +581,18 @@ func getStateCallbacks(s *State) map[string]fsm.Callback {
 				return
 			}
 
+			// A digit spelled out is taken a letter at a time, and typed once the word is done
+			if s.Options.NumberLeniency {
+				if spelling, done := s.spellNumber(s.CurrentChar); done {
+					s.CurrentChar = string(s.Secret[s.Pos])
+					e.FSM.Event(ctx, "check")
+					return
+				} else if spelling {
+					e.FSM.Event(ctx, "ignore")
+					return
+				}
+			}
+
 			// PRIORITY: If the user typed the CORRECT next letter, accept it!
 			// This prevents mistakenly ignoring a character because it appeared previously.
 			if s.IsCorrectLetter(s.CurrentChar) {
@@ -331,7 +602,8 @@ func getStateCallbacks(s *State) map[string]fsm.Callback {
 
 			// Check if user typed a character that is ALREADY REVEALED immediately before Pos
 			// Scan backwards from Pos-1 to find the contiguous block of revealed characters
-			for i := s.Pos - 1; i >= 0; i-- {
+			// Without type-through, the key is checked against Pos like any other
+			for i := s.Pos - 1; i >= 0 && !s.Options.NoTypeThrough; i-- {
 				// If we hit an unrevealed char (shouldn't happen if Pos is correct, but safe check) or a gap?
 				// Wait, SkipRevealed skips spaces too.
 				// Spaces are in Mask as ' '.
@@ -340,15 +612,14 @@ func getStateCallbacks(s *State) map[string]fsm.Callback {
 					break // End of the revealed block (going backwards)
 				}
 
-				// Stop scanning if we hit a word boundary (space or punctuation)
+				// Stop scanning if we hit a word boundary (space, line break or punctuation)
 				// This prevents matching letters from previous words in the same line
-				if s.ShouldIgnore(string(s.Secret[i])) {
+				if s.ShouldIgnore(string(s.Secret[i])) || unicode.IsSpace(s.Secret[i]) {
 					break
 				}
 
-				charStr := string(s.Secret[i])
-				// Case-insensitive check
-				if strings.EqualFold(charStr, s.CurrentChar) {
+				// Case-insensitive check, with typographic quotes and dashes as their ASCII
+				if SameChar(s.CurrentChar, s.Secret[i]) {
 					// User typed a character that is in the revealed block immediately preceding the current position.
 					// Assume they are "typing through" the revealed text.
 					e.FSM.Event(ctx, "ignore")
@@ -362,11 +633,28 @@ func getStateCallbacks(s *State) map[string]fsm.Callback {
 				return
 			}
 
-			// Check for hint request
-			if s.CurrentChar == "?" {
+			// Check for hint request; once the hints run out, asking does nothing
+			if s.IsHintRequested(s.CurrentChar) {
+				if !s.HasHintsLeft() {
+					e.FSM.Event(ctx, "ignore")
+					return
+				}
 				e.FSM.Event(ctx, "reveal")
 				return
 			}
+			if IsWordHintRequested(s.CurrentChar) {
+				e.FSM.Event(ctx, "revealWord")
+				return
+			}
+			// A line peek uses up a letter hint too
+			if IsLinePeekRequested(s.CurrentChar) {
+				if !s.HasHintsLeft() {
+					e.FSM.Event(ctx, "ignore")
+					return
+				}
+				e.FSM.Event(ctx, "peekLine")
+				return
+			}
 
 			// Normal check
 			e.FSM.Event(ctx, "check")
@@ -406,23 +694,29 @@ func getStateCallbacks(s *State) map[string]fsm.Callback {
 		"enter_gotMatch": func(ctx context.Context, e *fsm.Event) {
 			s.Mask[s.Pos] = s.Secret[s.Pos]
 			s.Score.ScoreEvent("rightLetter")
+			s.GraceRemaining = 0 // Typing has started, so the timer does too
+			s.emit(EventCorrect)
 
 			// Check word completion BEFORE we advance Pos
-			// (GotCompletedWord checks s.Secret[s.Pos] which is current char)
+			// (GotCompletedWord checks s.Secret[s.Pos] which is current char).
+			// The last letter of the card completes the final word, once, even
+			// when punctuation after it ends the game later
 			if s.GotCompletedWord() {
 				s.Score.ScoreEvent("wordBonus")
+				if s.errorsThisWord == 0 {
+					s.Score.ScoreEvent("cleanWordBonus")
+				}
+				s.errorsThisWord = 0
 			}
 
 			// If the message is complete (reached end of content), win immediately
 			// Note: We check Pos, not just Mask equality, to force typing through revealed chars.
 			if s.Pos >= len(s.Secret)-1 {
+				s.recordWordTiming()
 				s.Win = true
-				s.Score.ScoreEvent("messageBonus") // Apply bonus here as it won't be applied in evaluating
-				if s.TimerEnabled {
-					s.Score.AddTimeBonus(s.TimeRemaining)
-				}
-				s.Textarea.SetValue(string(s.Mask)) // Update UI one last time before ending
-				e.FSM.Event(ctx, "gameEnd")         // Skip updateMask/advance, go straight to end
+				s.scoreFinish()                    // Apply bonus here as it won't be applied in evaluating
+				s.Display.SetValue(string(s.Mask)) // Update UI one last time before ending
+				e.FSM.Event(ctx, "gameEnd")        // Skip updateMask/advance, go straight to end
 				return
 			}
 
@@ -443,41 +737,125 @@ func getStateCallbacks(s *State) map[string]fsm.Callback {
 			e.FSM.Event(ctx, "jumped")
 		},
 		"enter_noMatch": func(ctx context.Context, e *fsm.Event) {
+			// A near miss costs a little, but isn't a mistake: stay and wait for the right key
+			if s.Options.ForgiveTypos && s.Pos < len(s.Mask) && s.Mask[s.Pos] == '_' && s.IsNearMiss(s.CurrentChar) {
+				s.Score.ScoreEvent("nearMiss")
+				s.emit(EventNearMiss)
+				e.FSM.Event(ctx, "notMatched")
+				return
+			}
+
 			s.WrongLetter = true
 			// Only apply penalty if the character was NOT revealed
 			if s.Pos < len(s.Mask) && s.Mask[s.Pos] == '_' {
 				s.Score.ScoreEvent("wrongLetter")
+				s.ErrorPositions[s.Pos] = true
+				s.errorsThisWord++
+			}
+			s.emit(EventWrong)
+
+			// In lenient mode the right letter is shown as a mistake and play moves on
+			if s.Options.Lenient && s.Pos < len(s.Secret) {
+				s.Mask[s.Pos] = s.Secret[s.Pos]
+				s.RevealedCharMistakes[s.Pos] = true
+				s.WrongLetter = false
+				// A miss that ends the word ends its mistakes too, so they
+				// don't cost the next word its clean word bonus
+				if s.completesWord(s.Pos) {
+					s.errorsThisWord = 0
+				}
+				e.FSM.Event(ctx, "proceedOnMiss")
+				return
 			}
 			e.FSM.Event(ctx, "notMatched")
 		},
 		"enter_revealNextChar": func(ctx context.Context, e *fsm.Event) {
-			// Hint logic: Find next hidden char
+			// Hint logic: Find next hidden char. An auto hint costs less, and
+			// isn't one of the hints limited by LimitHints
+			auto := s.autoHinting
+			s.autoHinting = false
 			tempPos := s.Pos
 			for tempPos < len(s.Secret) && (s.ShouldIgnore(string(s.Secret[tempPos])) || s.Mask[tempPos] != '_') {
 				tempPos++
 			}
 
+			// A hint key may reveal another letter of the word, by the hint
+			// strategy, which leaves the cursor where it is
+			ahead := false
+			if !auto && !s.WrongLetter && tempPos == s.Pos {
+				tempPos = PickHintPosition(s.Secret, s.Mask, tempPos, s.Options.HintStrategy)
+				ahead = tempPos != s.Pos
+			}
+
 			if tempPos < len(s.Secret) && s.Mask[tempPos] == '_' {
 				s.Mask[tempPos] = s.Secret[tempPos]
-				s.Score.ScoreEvent("hint")
+				switch {
+				case auto:
+					s.Score.ScoreEvent("autoHint")
+				case s.Options.LimitHints:
+					s.HintsLeft--
+					fallthrough
+				default:
+					s.Score.ScoreEvent("hint")
+				}
+				s.emit(EventHint)
 			}
 
+			if ahead {
+				e.FSM.Event(ctx, "revealedAhead")
+				return
+			}
 			e.FSM.Event(ctx, "revealed")
 		},
+		"enter_revealNextWord": func(ctx context.Context, e *fsm.Event) {
+			// Word hint: reveal the whole word holding the next hidden char,
+			// for a single penalty, and move to its last character.
+			tempPos := s.Pos
+			for tempPos < len(s.Secret) && (s.ShouldIgnore(string(s.Secret[tempPos])) || s.Mask[tempPos] != '_') {
+				tempPos++
+			}
+
+			if tempPos < len(s.Secret) {
+				span := wordSpan{tempPos, tempPos + 1} // A symbol on its own
+				if idx := s.wordIndexAt(tempPos); idx >= 0 {
+					span = s.wordSpans()[idx]
+				}
+				copy(s.Mask[span.start:span.end], s.Secret[span.start:span.end])
+				s.Score.ScoreEvent("wordReveal")
+				s.emit(EventWordHint)
+				// The word is given, so an earlier mistake in it no longer blocks,
+				// nor counts against the next word
+				s.WrongLetter = false
+				s.errorsThisWord = 0
+				s.Pos = max(s.Pos, span.end-1)
+			}
+
+			e.FSM.Event(ctx, "revealed")
+		},
+		"enter_peekingLine": func(ctx context.Context, e *fsm.Event) {
+			// The line is only shown for a moment; the mask is left as it is
+			s.LinePeek = LinePeekSeconds
+			if s.Options.LimitHints {
+				s.HintsLeft--
+			}
+			s.Score.ScoreEvent("linePeek")
+			e.FSM.Event(ctx, "peeked")
+		},
 		"enter_updateMask": func(ctx context.Context, e *fsm.Event) {
-			s.Textarea.SetValue(string(s.Mask))
+			s.Display.SetValue(string(s.Mask))
 			e.FSM.Event(ctx, "advance")
 		},
 		"enter_advancing": func(ctx context.Context, e *fsm.Event) {
+			s.recordWordTiming()
 			s.Pos++
 			s.SkipIgnorable()
-			s.Textarea.SetValue(string(s.Mask))
+			s.Display.SetValue(string(s.Mask))
 			e.FSM.Event(ctx, "advanced")
 		},
 		"enter_updateScore": func(ctx context.Context, e *fsm.Event) {
 			// Score updated in previous events, just transition
 			// Force update textarea (to show red cursor if implemented in view based on WrongLetter)
-			s.Textarea.SetValue(string(s.Mask))
+			s.Display.SetValue(string(s.Mask))
 			e.FSM.Event(ctx, "scoreCalculated")
 		},
 		"enter_evaluating": func(ctx context.Context, e *fsm.Event) {
@@ -486,14 +864,16 @@ func getStateCallbacks(s *State) map[string]fsm.Callback {
 					s.Loss = true
 				} else {
 					s.Win = true
-					s.Score.ScoreEvent("messageBonus")
+					if !s.Options.MoreLines {
+						s.Score.ScoreEvent("messageBonus")
+					}
 				}
 				e.FSM.Event(ctx, "gameEnd")
 				return
 			}
 
 			// Also check score again (redundant but safe)
-			if s.Score.CurrentScore < 0 {
+			if s.BelowScoreFloor() {
 				s.Loss = true
 				e.FSM.Event(ctx, "gameEnd")
 				return
@@ -502,7 +882,30 @@ func getStateCallbacks(s *State) map[string]fsm.Callback {
 			e.FSM.Event(ctx, "wait")
 		},
 		"enter_endState": func(ctx context.Context, e *fsm.Event) {
+			// A line of a card played by line leaves its card to be finished
+			// by the lines after it
+			if s.Win && s.Options.MoreLines {
+				s.emit(EventWin)
+				return
+			}
+			if s.Win {
+				s.Score.SetDuration(s.Now().Sub(s.startedAt))
+				s.Score.ApplyMultiplier()
+				s.Score.SetPercentOfMax(s.MaxScore())
+				s.Score.SetWordOffsets(s.wordOffsets())
+			}
+			if !s.firstKeyAt.IsZero() {
+				s.Score.SetPlayTime(s.Now().Sub(s.firstKeyAt))
+			}
+			s.Score.SetWordTimings(s.SlowestWords(5))
+			s.Score.SetWordErrors(s.WordErrors())
+			s.Score.SetOutcome(s.outcome())
 			s.Score.SaveEntries()
+			if s.Win {
+				s.emit(EventWin)
+			} else {
+				s.emit(EventLoss)
+			}
 		},
 	}
 }
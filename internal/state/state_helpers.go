@@ -1,29 +1,51 @@
 package state
 
 import (
+	"go-mem/internal/scoring"
 	"regexp"
 	"slices"
+	"sort"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
+// Escaped brackets (\[ and \]) are swapped for single-byte placeholders while
+// bracketed regions are parsed, so they are neither matched nor counted.
+var (
+	bracketEscaper   = strings.NewReplacer(`\[`, "\x01", `\]`, "\x02")
+	bracketUnescaper = strings.NewReplacer("\x01", "[", "\x02", "]")
+	bracketQuoter    = strings.NewReplacer("[", `\[`, "]", `\]`)
+)
+
+var bracketContentsRe = regexp.MustCompile(`(?s)\[(.*?)\]`)
+
+// SetBracketedPositions strips the brackets from the secret and records the
+// rune index of every character that was inside them. The matches are walked
+// once, keeping a running count of the runes before each match and of the
+// bracket characters removed so far.
 func (s *State) SetBracketedPositions() {
-	bracketContentsRe := regexp.MustCompile(`(?s)\[(.*?)\]`)
-	bracketRe := regexp.MustCompile(`[^\[\]]`)
-	secretStr := string(s.Secret)
+	secretStr := bracketEscaper.Replace(string(s.Secret))
 	matches := bracketContentsRe.FindAllStringSubmatchIndex(secretStr, -1)
 
 	var positions []int
+	runeIdx, byteIdx, removed := 0, 0, 0
 	for _, match := range matches {
-		startMatch := match[2]
-		endMatch := match[3]
-		for i := startMatch; i < endMatch; i++ {
-			stringUpToMatch := secretStr[:startMatch]
-			previousBrackets := len(bracketRe.ReplaceAllString(stringUpToMatch, ""))
-			positions = append(positions, i-previousBrackets)
+		startMatch, endMatch := match[2], match[3]
+		// Runes up to the content, less the opening bracket itself
+		runeIdx += utf8.RuneCountInString(secretStr[byteIdx:startMatch])
+		removed++
+		for range secretStr[startMatch:endMatch] {
+			positions = append(positions, runeIdx-removed)
+			runeIdx++
 		}
+		// Skip the closing bracket
+		runeIdx++
+		removed++
+		byteIdx = match[1]
 	}
 	s.BracketedPositions = positions
-	s.Secret = []rune(bracketContentsRe.ReplaceAllString(secretStr, "$1"))
+	s.Secret = []rune(bracketUnescaper.Replace(bracketContentsRe.ReplaceAllString(secretStr, "$1")))
 }
 
 func (s *State) InitMask() {
@@ -39,8 +61,163 @@ func (s *State) InitMask() {
 	s.Mask = mask
 }
 
+// wordSpan is the [start, end) range of a run of letters/digits in the secret.
+// Apostrophes within a word, as in "don't", are part of it.
+type wordSpan struct {
+	start, end int
+}
+
+func (s *State) wordSpans() []wordSpan {
+	var words []wordSpan
+	inWord := false
+	start := 0
+
+	for i, ch := range s.Secret {
+		if isAlphanumeric(ch) || s.joinsWord(i) {
+			if !inWord {
+				start = i
+				inWord = true
+			}
+		} else {
+			if inWord {
+				words = append(words, wordSpan{start, i})
+				inWord = false
+			}
+		}
+	}
+	// Check last word
+	if inWord {
+		words = append(words, wordSpan{start, len(s.Secret)})
+	}
+	return words
+}
+
+// joinsWord reports whether the character at i is an apostrophe inside a word,
+// which keeps the two sides together as one word. With LegacyWordSplit it
+// splits them instead, as letters and digits are all that make up words.
+func (s *State) joinsWord(i int) bool {
+	if s.Options.LegacyWordSplit || i == 0 || i+1 >= len(s.Secret) {
+		return false
+	}
+	return isApostrophe(s.Secret[i]) && isAlphanumeric(s.Secret[i-1]) && isAlphanumeric(s.Secret[i+1])
+}
+
+// WordCount returns the number of words in a text, counted as runs of letters
+// and digits, with any apostrophes within them.
+func WordCount(text string) int {
+	s := State{Secret: []rune(text)}
+	return len(s.wordSpans())
+}
+
+// wordIndexAt returns the index of the word containing pos, or -1.
+func (s *State) wordIndexAt(pos int) int {
+	for i, w := range s.wordSpans() {
+		if pos >= w.start && pos < w.end {
+			return i
+		}
+	}
+	return -1
+}
+
+// MistakeDrill returns the parts of the secret where wrong characters were
+// typed: each word with a mistake, padded with up to context words either side.
+// Parts that overlap or touch are merged, and each part is on its own line.
+// Brackets are escaped so the result can be played as a new secret.
+// It returns "" if there were no mistakes.
+func (s *State) MistakeDrill(context int) string {
+	words := s.wordSpans()
+	if len(words) == 0 || len(s.ErrorPositions) == 0 {
+		return ""
+	}
+
+	// A mistake on a space or symbol counts against the word that follows it
+	marked := make([]bool, len(words))
+	for pos := range s.ErrorPositions {
+		idx := sort.Search(len(words), func(i int) bool { return words[i].end > pos })
+		marked[min(idx, len(words)-1)] = true
+	}
+
+	var parts []string
+	for i := 0; i < len(words); i++ {
+		if !marked[i] {
+			continue
+		}
+		lo, hi := max(i-context, 0), min(i+context, len(words)-1)
+		// Swallow any later mistakes whose context reaches this part
+		for j := i + 1; j < len(words) && j-context <= hi+1; j++ {
+			if marked[j] {
+				hi = min(j+context, len(words)-1)
+			}
+		}
+		parts = append(parts, bracketQuoter.Replace(string(s.Secret[words[lo].start:words[hi].end])))
+		i = hi
+	}
+	return strings.Join(parts, "\n")
+}
+
+// recordWordTiming stores the time spent on the word ending at Pos, if any.
+func (s *State) recordWordTiming() {
+	idx := s.wordIndexAt(s.Pos)
+	if idx < 0 || s.wordSpans()[idx].end != s.Pos+1 {
+		return // Not the last character of a word
+	}
+
+	now := s.Now()
+	if _, seen := s.WordDurations[idx]; !seen {
+		s.WordDurations[idx] = now.Sub(s.lastWordAt)
+		s.wordDoneAt[idx] = now.Sub(s.startedAt)
+	}
+	s.lastWordAt = now
+}
+
+// SlowestWords returns up to n completed words, slowest first.
+func (s *State) SlowestWords(n int) []scoring.WordTiming {
+	words := s.wordSpans()
+	timings := make([]scoring.WordTiming, 0, len(s.WordDurations))
+	for idx, d := range s.WordDurations {
+		if idx < 0 || idx >= len(words) {
+			continue
+		}
+		w := words[idx]
+		timings = append(timings, scoring.WordTiming{
+			Index:      idx,
+			Word:       string(s.Secret[w.start:w.end]),
+			DurationMs: d.Milliseconds(),
+		})
+	}
+
+	sort.Slice(timings, func(i, j int) bool {
+		if timings[i].DurationMs != timings[j].DurationMs {
+			return timings[i].DurationMs > timings[j].DurationMs
+		}
+		return timings[i].Index < timings[j].Index
+	})
+
+	if len(timings) > n {
+		timings = timings[:n]
+	}
+	return timings
+}
+
+func isAlphanumeric(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isApostrophe(r rune) bool {
+	return r == '\'' || r == '’'
+}
+
+// isPunctuation reports whether r is sentence punctuation, or a line break.
+// An ellipsis counts as a full stop, and punctuation without a key, such as
+// Japanese 、 and 。, counts too.
 func isPunctuation(r rune) bool {
-	return strings.ContainsRune(",.!?;:\n", r)
+	return strings.ContainsRune(",.!?;:\n", ASCIIEquivalent(r)) || hasNoKey(r)
+}
+
+// hasNoKey reports whether r is punctuation or a symbol beyond ASCII that no
+// ASCII key types, such as 、 or 「, so a keyboard can't type it.
+func hasNoKey(r rune) bool {
+	return r > unicode.MaxASCII && ASCIIEquivalent(r) == r && (unicode.IsPunct(r) || unicode.IsSymbol(r))
 }
 
 func IsExitRequested(ch string) bool {
@@ -55,15 +232,159 @@ func IsTabRequested(ch string) bool {
 	return ch == "tab"
 }
 
+// IsHintRequested reports whether ch asks for the next character to be revealed.
+// With strict symbols '?' is typed like any other character, so only ctrl+h works.
+func (s State) IsHintRequested(ch string) bool {
+	return ch == "ctrl+h" || (ch == "?" && !s.Options.StrictSymbols)
+}
+
+// IsWordHintRequested reports whether ch asks for the rest of the next word to be revealed.
+func IsWordHintRequested(ch string) bool {
+	return ch == "ctrl+w"
+}
+
+// IsLinePeekRequested reports whether ch asks for the current line to be shown
+// for a moment.
+func IsLinePeekRequested(ch string) bool {
+	return ch == "ctrl+l"
+}
+
+// LineSpan returns the [start, end) range in Secret of the line holding pos,
+// without its line break.
+func (s State) LineSpan(pos int) (start, end int) {
+	start, end = pos, pos
+	for start > 0 && s.Secret[start-1] != '\n' {
+		start--
+	}
+	for end < len(s.Secret) && s.Secret[end] != '\n' {
+		end++
+	}
+	return start, end
+}
+
 func (s State) ShouldIgnore(ch string) bool {
-	if len(ch) == 0 {
+	r, size := utf8.DecodeRuneInString(ch)
+	if size == 0 || size != len(ch) {
 		return false
 	}
 
-	isSpace := ch == " "
-	isNonQuestionMarkPunc := (isPunctuation(rune(ch[0])) && ch != "?")
+	// Spaces, no-break and full-width ones too, are given away unless they
+	// must be typed too
+	if ASCIIEquivalent(r) == ' ' {
+		return !s.Options.HideSpaces
+	}
+	// And so are line breaks, unless Enter must be pressed for them
+	if r == '\n' && s.Options.RequireEnter {
+		return false
+	}
+	// And digits, in any mode, when they needn't be typed
+	if s.Options.LenientDigits && unicode.IsDigit(r) {
+		return true
+	}
 
-	return isSpace || isNonQuestionMarkPunc
+	// Only whitespace is given away when symbols must be typed
+	if s.Options.StrictSymbols {
+		return unicode.IsSpace(r)
+	}
+
+	// Punctuation no key types can't be required
+	if hasNoKey(r) {
+		return true
+	}
+
+	// Sentence punctuation must be typed too; line breaks are still given away
+	if s.Options.RequirePunctuation {
+		return r == '\n'
+	}
+
+	return isPunctuation(r) && ASCIIEquivalent(r) != '?'
+}
+
+// IsPreviewing reports whether the unmasked read-through preview is showing.
+func (s State) IsPreviewing() bool {
+	return s.FSM != nil && s.FSM.Is("previewing")
+}
+
+// Progress returns the percentage of hideable characters that are revealed,
+// whether typed, hinted or given away by a game mode or brackets.
+// Characters that are never hidden, such as spaces, are not counted.
+func (s State) Progress() int {
+	hideable, revealed := s.revealCounts(false)
+	if hideable == 0 {
+		return 100
+	}
+	return revealed * 100 / hideable
+}
+
+// MaxScore returns the most this game could score, see scoring.MaxPossible:
+// every key it asks for typed right, with the word bonuses its mode gives.
+func (s State) MaxScore() int {
+	keys, words := s.perfectRun()
+	return s.Score.MaxPossible(keys, words, s.TimerEnabled, s.TimeLimit)
+}
+
+// perfectRun counts the keys a perfect game types and the words it earns a
+// word bonus for. In cloze mode only the blanks are typed. A recall is graded
+// by word rather than typed key by key, so it counts every letter and word.
+func (s State) perfectRun() (keys, words int) {
+	if s.Options.Recall {
+		inWord := false
+		for _, r := range s.Secret {
+			isLetter := unicode.IsLetter(r) || unicode.IsDigit(r)
+			if isLetter {
+				keys++
+				if !inWord {
+					words++
+				}
+			}
+			inWord = isLetter
+		}
+		return keys, words
+	}
+
+	for i, r := range s.Secret {
+		if s.ShouldIgnore(string(r)) || slices.Contains(s.BracketedPositions, i) {
+			continue
+		}
+		if s.Options.Cloze > 0 && !slices.ContainsFunc(s.clozeBlanks, func(w wordSpan) bool { return i >= w.start && i < w.end }) {
+			continue
+		}
+		keys++
+		if s.completesWord(i) {
+			words++
+		}
+	}
+	return keys, words
+}
+
+// HiddenShare returns the share, from 0 to 1, of the hideable characters that
+// are hidden. Bracketed text is part of the card rather than an assist, so it
+// isn't counted.
+func (s State) HiddenShare() float64 {
+	hideable, revealed := s.revealCounts(true)
+	if hideable == 0 {
+		return 1
+	}
+	return float64(hideable-revealed) / float64(hideable)
+}
+
+// revealCounts counts the hideable characters of the secret and how many of
+// them the mask reveals, leaving out bracketed positions if asked to. In cloze
+// mode only the blanks are counted.
+func (s State) revealCounts(skipBracketed bool) (hideable, revealed int) {
+	for i, ch := range s.Secret {
+		if s.ShouldIgnore(string(ch)) || (skipBracketed && slices.Contains(s.BracketedPositions, i)) {
+			continue
+		}
+		if s.Options.Cloze > 0 && !slices.ContainsFunc(s.clozeBlanks, func(w wordSpan) bool { return i >= w.start && i < w.end }) {
+			continue
+		}
+		hideable++
+		if i < len(s.Mask) && s.Mask[i] != '_' {
+			revealed++
+		}
+	}
+	return hideable, revealed
 }
 
 func (s State) IsAtEnd() bool {
@@ -74,31 +395,130 @@ func (s State) IsCorrectLetter(ch string) bool {
 	if s.Pos >= len(s.Secret) {
 		return false
 	}
-	return strings.ToLower(ch) == strings.ToLower(string(s.Secret[s.Pos]))
+	return SameChar(ch, s.Secret[s.Pos])
 }
 
 func (s *State) IsIncorrectLetter(ch string) bool {
 	if s.Pos >= len(s.Secret) {
 		return true
 	}
-	return strings.ToLower(ch) != strings.ToLower(string(s.Secret[s.Pos]))
+	return !SameChar(ch, s.Secret[s.Pos])
 }
 
+// typographic maps the quotes, dashes and ellipsis that word processors put
+// in to the ASCII a keyboard types for them.
+var typographic = map[rune]rune{
+	'‘': '\'', '’': '\'', '‚': '\'', '‛': '\'', '′': '\'',
+	'“': '"', '”': '"', '„': '"', '‟': '"', '″': '"',
+	'‐': '-', '‑': '-', '‒': '-', '–': '-', '—': '-', '―': '-', '−': '-',
+	'…': '.',
+}
+
+// ASCIIEquivalent returns the ASCII character typed for r: r itself, unless it
+// is a typographic quote, dash or ellipsis, the full-width form of an ASCII
+// character, or a space other than ' ', such as a no-break space.
+func ASCIIEquivalent(r rune) rune {
+	if a, ok := typographic[r]; ok {
+		return a
+	}
+	switch {
+	case r >= '！' && r <= '～':
+		return r - '！' + '!'
+	case r > unicode.MaxASCII && unicode.IsSpace(r):
+		return ' '
+	}
+	return r
+}
+
+// SameChar reports whether the typed character ch matches r, ignoring case.
+// Typographic quotes, dashes and ellipses match their ASCII, either way round.
+func SameChar(ch string, r rune) bool {
+	typed, size := utf8.DecodeRuneInString(ch)
+	if size == 0 || size != len(ch) {
+		return false
+	}
+	return unicode.ToLower(ASCIIEquivalent(typed)) == unicode.ToLower(ASCIIEquivalent(r))
+}
+
+// NormalizeTypography rewrites the typographic quotes, dashes and ellipses in
+// text as the ASCII typed for them, an ellipsis as three dots, along with
+// full-width characters and unusual spaces.
+func NormalizeTypography(text string) string {
+	return strings.Map(ASCIIEquivalent, strings.ReplaceAll(text, "…", "..."))
+}
+
+// GotCompletedWord reports whether the character at Pos, just typed, ends a
+// word: it is a word boundary itself, or the last character left to type.
 func (s State) GotCompletedWord() bool {
-	return !s.IsAtEnd() &&
-		(s.Secret[s.Pos] == ' ' || isPunctuation(s.Secret[s.Pos]))
+	if s.IsAtEnd() {
+		return false
+	}
+	return s.completesWord(s.Pos)
+}
+
+// completesWord reports whether typing the character at pos ends a word.
+func (s State) completesWord(pos int) bool {
+	// A line with more of its card to come ends its last word only where the
+	// card played whole would have its line break typed
+	if s.isLastToType(pos) && (!s.Options.MoreLines || !s.ShouldIgnore("\n")) {
+		return true
+	}
+	if s.Options.StrictSymbols {
+		return unicode.IsSpace(s.Secret[pos])
+	}
+	return ASCIIEquivalent(s.Secret[pos]) == ' ' || isPunctuation(s.Secret[pos])
+}
+
+// isLastToType reports whether everything after pos is given away, so the
+// character at pos is the last one the player types.
+func (s State) isLastToType(pos int) bool {
+	for i := pos + 1; i < len(s.Secret); i++ {
+		if !s.ShouldIgnore(string(s.Secret[i])) && !slices.Contains(s.BracketedPositions, i) {
+			return false
+		}
+	}
+	return true
 }
 
 func (s State) GotCorrectMessage() bool {
-	return string(s.Secret) == s.Textarea.Value()
+	return string(s.Secret) == s.Display.Value()
 }
 
 func (s State) IsGameOver() bool {
-	return (s.Pos >= len(s.Secret)) || s.Score.CurrentScore < 0
+	return (s.Pos >= len(s.Secret)) || s.BelowScoreFloor()
 }
 
 func (s State) LostGame() bool {
-	return s.Score.CurrentScore < 0 || (s.IsGameOver() && s.WrongLetter)
+	return s.BelowScoreFloor() || (s.IsGameOver() && s.WrongLetter)
+}
+
+// BelowScoreFloor reports whether the score has dropped low enough to lose the game.
+func (s State) BelowScoreFloor() bool {
+	return !s.Options.NoScoreFloor && s.Score.CurrentScore < s.Options.ScoreFloor
+}
+
+// HasHintsLeft reports whether a letter hint may still be asked for.
+func (s State) HasHintsLeft() bool {
+	return !s.Options.LimitHints || s.HintsLeft > 0
+}
+
+// NearScoreFloor reports whether one more wrong letter would drop the score
+// below the floor and lose the game.
+func (s State) NearScoreFloor() bool {
+	return !s.Options.NoScoreFloor && s.Score.CurrentScore+s.Score.Points("wrongLetter") < s.Options.ScoreFloor
+}
+
+// outcome returns how the game ended, as recorded with its score.
+func (s State) outcome() string {
+	switch {
+	case s.Win:
+		return scoring.OutcomeWon
+	case s.Abandoned:
+		return scoring.OutcomeAbandoned
+	case s.Revealed:
+		return scoring.OutcomeRevealed
+	}
+	return scoring.OutcomeLost
 }
 
 func (s State) WonGame() bool {
@@ -1,9 +1,18 @@
 package state
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"go-mem/internal/scoring"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+	"unicode"
 
 	"github.com/charmbracelet/bubbles/textarea"
 )
@@ -154,6 +163,128 @@ func TestState_WinLoss(t *testing.T) {
 	}
 }
 
+func TestState_ScoreFloor(t *testing.T) {
+	// Each mistake costs 50, from a score of 0
+	mistakes := func(opts GameOptions, n int) *State {
+		s := newPlayState("cat", opts)
+		s.Score.CurrentScore = 0
+		for range n {
+			s.FSM.Event(context.Background(), "input", "x")
+		}
+		return s
+	}
+
+	if s := mistakes(GameOptions{}, 1); !s.Loss {
+		t.Errorf("Expected the default floor of 0 to lose at %d", s.Score.CurrentScore)
+	}
+
+	floor := GameOptions{ScoreFloor: -200}
+	if s := mistakes(floor, 4); s.Loss || s.Score.CurrentScore != -200 {
+		t.Errorf("Expected to play on at the floor, got loss=%v at %d", s.Loss, s.Score.CurrentScore)
+	}
+	if s := mistakes(floor, 5); !s.Loss || !s.LostGame() {
+		t.Errorf("Expected to lose below the floor, got loss=%v at %d", s.Loss, s.Score.CurrentScore)
+	}
+
+	// Near the floor once the next mistake would lose the game
+	if s := mistakes(floor, 3); s.NearScoreFloor() {
+		t.Errorf("Expected a mistake at %d not to be near the floor", s.Score.CurrentScore)
+	}
+	if s := mistakes(floor, 4); !s.NearScoreFloor() {
+		t.Errorf("Expected a mistake at %d to be near the floor", s.Score.CurrentScore)
+	}
+
+	// Without a floor only finishing, the timer or a reveal ends the game
+	s := mistakes(GameOptions{NoScoreFloor: true}, 30)
+	if s.Loss || s.IsGameOver() || s.NearScoreFloor() {
+		t.Fatalf("Expected no loss without a floor, got loss at %d", s.Score.CurrentScore)
+	}
+	for _, r := range "cat" {
+		s.FSM.Event(context.Background(), "input", string(r))
+	}
+	if !s.Win {
+		t.Errorf("Expected to win from a score of %d, mask %q", s.Score.CurrentScore, string(s.Mask))
+	}
+}
+
+func TestState_CleanWordBonus(t *testing.T) {
+	// Spaces must be typed, so each one completes a word
+	s := newPlayState("ab cd ef gh", GameOptions{HideSpaces: true})
+	typeWord := func(keys string) (bonus int) {
+		for _, r := range keys {
+			s.FSM.Event(context.Background(), "input", string(r))
+		}
+		before := s.Score.CurrentScore
+		s.FSM.Event(context.Background(), "input", " ")
+		return s.Score.CurrentScore - before - 25 // Less the space itself
+	}
+
+	if got := typeWord("ab"); got != 250+100 {
+		t.Errorf("Expected a clean word to earn both bonuses, got %d", got)
+	}
+	if got := typeWord("cxd"); got != 250 {
+		t.Errorf("Expected a word with a mistake to earn only the word bonus, got %d", got)
+	}
+	// The mistake doesn't follow into the next word
+	if got := typeWord("ef"); got != 250+100 {
+		t.Errorf("Expected the next clean word to earn both bonuses, got %d", got)
+	}
+
+	// Nor does it when the word is finished other than by typing it: given
+	// away with ctrl+w, or by a lenient miss on its last key
+	lastKey := func(opts GameOptions, keys ...string) int {
+		s := newPlayState("ab cd", opts)
+		for _, k := range keys[:len(keys)-1] {
+			s.FSM.Event(context.Background(), "input", k)
+		}
+		before := s.Score.CurrentScore
+		s.FSM.Event(context.Background(), "input", keys[len(keys)-1])
+		if !s.Win {
+			t.Fatalf("%v: expected a win", keys)
+		}
+		return s.Score.CurrentScore - before
+	}
+	if got := lastKey(GameOptions{}, "a", "x", "ctrl+w", "c", "d"); got != 25+250+100+1000 {
+		t.Errorf("Expected a word typed clean after a word hint to earn both bonuses, got %d", got)
+	}
+	if got := lastKey(GameOptions{HideSpaces: true, Lenient: true}, "a", "b", "x", "c", "d"); got != 25+250+100+1000 {
+		t.Errorf("Expected a word typed clean after a lenient miss to earn both bonuses, got %d", got)
+	}
+}
+
+func TestState_LastWordBonus(t *testing.T) {
+	// The last letter earns its own 25, the word bonuses and the message bonus
+	lastKey := func(secret, keys string) int {
+		s := newPlayState(secret, GameOptions{})
+		for _, r := range keys[:len(keys)-1] {
+			s.FSM.Event(context.Background(), "input", string(r))
+		}
+		before := s.Score.CurrentScore
+		s.FSM.Event(context.Background(), "input", keys[len(keys)-1:])
+		if !s.Win {
+			t.Fatalf("%q: expected a win", secret)
+		}
+		return s.Score.CurrentScore - before
+	}
+
+	if got := lastKey("Short", "Short"); got != 25+250+100+1000 {
+		t.Errorf("Expected a single word card to earn one word bonus, got %d", got)
+	}
+	// Punctuation given away after the last word doesn't earn another
+	if got := lastKey("Short.", "Short"); got != 25+250+100+1000 {
+		t.Errorf("Expected trailing punctuation not to earn a second word bonus, got %d", got)
+	}
+	// A question mark is typed, so it completes the word instead of the letter before it
+	s := newPlayState("Why?", GameOptions{})
+	before := s.Score.CurrentScore
+	for _, r := range "Why?" {
+		s.FSM.Event(context.Background(), "input", string(r))
+	}
+	if got := s.Score.CurrentScore - before; got != 4*25+250+100+1000 {
+		t.Errorf("Expected \"Why?\" to earn one word bonus, got %d", got)
+	}
+}
+
 func TestState_TabJump(t *testing.T) {
 	// Secret: "A B C"
 	// Mask:   "_ _ _"
@@ -225,9 +356,10 @@ func TestState_TypeThrough_NoPenalty(t *testing.T) {
 	if !s.Win {
 		t.Error("Expected Win")
 	}
-	// Score should increase (Win bonus)
-	if s.Score.CurrentScore <= initialScore {
-		t.Errorf("Expected score increase, got %d", s.Score.CurrentScore)
+	// The whole card was revealed from the start, so the difficulty
+	// multiplier leaves nothing of the win bonus
+	if s.Score.CurrentScore != 0 {
+		t.Errorf("Expected a fully assisted win to score 0, got %d", s.Score.CurrentScore)
 	}
 }
 
@@ -237,6 +369,16 @@ type MockStorage struct{}
 func (m *MockStorage) LoadAll() ([]scoring.ScoreHistoryEntry, error)     { return nil, nil }
 func (m *MockStorage) SaveAll(entries []scoring.ScoreHistoryEntry) error { return nil }
 
+// historyStorage holds a score history in memory, for tests that depend on
+// past attempts.
+type historyStorage struct{ entries []scoring.ScoreHistoryEntry }
+
+func (h *historyStorage) LoadAll() ([]scoring.ScoreHistoryEntry, error) { return h.entries, nil }
+func (h *historyStorage) SaveAll(entries []scoring.ScoreHistoryEntry) error {
+	h.entries = entries
+	return nil
+}
+
 func TestState_SpaceSkipLogic(t *testing.T) {
 	// Reproduce/Verify logic for skipping spaces immediately
 	ta := textarea.New()
@@ -282,3 +424,1410 @@ func TestState_SpaceSkipLogic(t *testing.T) {
 		t.Errorf("After 'a', expected Pos 4 ('v'), got %d", s.Pos)
 	}
 }
+
+func TestState_WordTimings(t *testing.T) {
+	sc, _ := scoring.InitScoring("Hi all yo", "Title", &MockStorage{})
+	s := NewState("Hi all yo", 20, textarea.New(), *sc, GameOptions{})
+
+	// Fake clock advanced manually between keypresses
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.Now = func() time.Time { return now }
+
+	s.InitMask()
+	s.FSM.Event(context.Background(), "initGame")
+
+	typeWord := func(word string, perLetter time.Duration) {
+		for _, r := range word {
+			now = now.Add(perLetter)
+			s.FSM.Event(context.Background(), "input", string(r))
+		}
+	}
+
+	typeWord("Hi", time.Second)          // 2s
+	typeWord("all", 2*time.Second)       // 6s
+	typeWord("yo", 500*time.Millisecond) // 1s
+
+	if !s.Win {
+		t.Fatal("Expected Win")
+	}
+
+	expected := map[int]time.Duration{0: 2 * time.Second, 1: 6 * time.Second, 2: time.Second}
+	for idx, d := range expected {
+		if s.WordDurations[idx] != d {
+			t.Errorf("Word %d: expected %v, got %v", idx, d, s.WordDurations[idx])
+		}
+	}
+
+	slowest := s.SlowestWords(2)
+	if len(slowest) != 2 || slowest[0].Word != "all" || slowest[1].Word != "Hi" {
+		t.Errorf("Unexpected slowest words: %+v", slowest)
+	}
+
+	// Timings are attached to the score entry on game end
+	attached := s.Score.GetWordTimings()
+	if len(attached) != 3 || attached[0].DurationMs != 6000 {
+		t.Errorf("Unexpected attached timings: %+v", attached)
+	}
+
+	// So is the time taken for the whole text
+	if entry := s.Score.GetNScoreEntries(1)[0]; entry.DurationMs != 9000 {
+		t.Errorf("Expected a 9000ms duration, got %d", entry.DurationMs)
+	}
+}
+
+func TestState_SetBracketedPositions_Escaped(t *testing.T) {
+	s := NewState(`a \[b\] c`, 20, textarea.New(), scoring.Scoring{}, GameOptions{})
+	s.SetBracketedPositions()
+
+	if string(s.Secret) != "a [b] c" {
+		t.Errorf("Expected secret 'a [b] c', got '%s'", string(s.Secret))
+	}
+	if len(s.BracketedPositions) != 0 {
+		t.Errorf("Expected no bracketed positions, got %v", s.BracketedPositions)
+	}
+
+	// Literal brackets must be typed like any other character
+	s.InitMask()
+	if string(s.Mask) != "_ ___ _" {
+		t.Errorf("Expected mask '_ ___ _', got '%s'", string(s.Mask))
+	}
+
+	// Escaped and real brackets together
+	s = NewState(`a \[b\] [c]`, 20, textarea.New(), scoring.Scoring{}, GameOptions{})
+	s.SetBracketedPositions()
+
+	if string(s.Secret) != "a [b] c" {
+		t.Errorf("Expected secret 'a [b] c', got '%s'", string(s.Secret))
+	}
+	if len(s.BracketedPositions) != 1 || s.BracketedPositions[0] != 6 {
+		t.Errorf("Expected bracketed position [6], got %v", s.BracketedPositions)
+	}
+}
+
+// bracketedCard builds a card with n bracketed regions, returning it along
+// with the expected secret and bracketed positions.
+func bracketedCard(n int) (card, secret string, positions []int) {
+	var cb, sb strings.Builder
+	for i := 0; i < n; i++ {
+		cb.WriteString("word [hidden] ")
+		sb.WriteString("word ")
+		for j := 0; j < len("hidden"); j++ {
+			positions = append(positions, sb.Len()+j)
+		}
+		sb.WriteString("hidden ")
+	}
+	return cb.String(), sb.String(), positions
+}
+
+func TestState_SetBracketedPositions_ManyRegions(t *testing.T) {
+	card, expectedSecret, expectedPos := bracketedCard(500)
+	s := NewState(card, 20, textarea.New(), scoring.Scoring{}, GameOptions{})
+	s.SetBracketedPositions()
+
+	if string(s.Secret) != expectedSecret {
+		t.Fatalf("Secret mismatch for a card with 500 bracketed regions")
+	}
+	if len(s.BracketedPositions) != len(expectedPos) {
+		t.Fatalf("Expected %d bracketed positions, got %d", len(expectedPos), len(s.BracketedPositions))
+	}
+	for i, pos := range expectedPos {
+		if s.BracketedPositions[i] != pos {
+			t.Fatalf("Position mismatch at index %d: expected %d, got %d", i, pos, s.BracketedPositions[i])
+		}
+	}
+}
+
+func BenchmarkState_SetBracketedPositions(b *testing.B) {
+	card, _, _ := bracketedCard(500)
+	for b.Loop() {
+		s := &State{Secret: []rune(card)}
+		s.SetBracketedPositions()
+	}
+}
+
+// newPlayState sets up a state for the secret ready to receive input.
+func newPlayState(secret string, opts GameOptions) *State {
+	sc, _ := scoring.InitScoring(secret, "Title", &MockStorage{})
+	s := NewState(secret, 40, textarea.New(), *sc, opts)
+	s.SetBracketedPositions()
+	s.InitMask()
+	s.SkipIgnorable()
+	s.Score.CurrentScore = 1000 // Headroom for penalties
+	s.FSM.Event(context.Background(), "initGame")
+	return s
+}
+
+func TestState_StrictSymbols(t *testing.T) {
+	secret := "A well-known [fact], isn't it?"
+
+	tests := []struct {
+		name string
+		opts GameOptions
+		mask string
+	}{
+		// Hyphens, apostrophes and '?' are always typed; other punctuation is given away
+		{"default", GameOptions{}, "_ __________ fact, _____ ___"},
+		// Only whitespace is given away; brackets are still revealed
+		{"strict", GameOptions{StrictSymbols: true}, "_ __________ fact_ _____ ___"},
+	}
+	for _, tt := range tests {
+		s := newPlayState(secret, tt.opts)
+		if string(s.Mask) != tt.mask {
+			t.Errorf("%s: expected mask %q, got %q", tt.name, tt.mask, string(s.Mask))
+		}
+
+		// Typing every symbol exactly wins in both modes without errors
+		for _, r := range "Awell-known,isn'tit?" {
+			s.FSM.Event(context.Background(), "input", string(r))
+		}
+		if !s.Win || s.Score.ErrorCount != 0 {
+			t.Errorf("%s: expected a clean win, got win=%v errors=%d mask %q", tt.name, s.Win, s.Score.ErrorCount, string(s.Mask))
+		}
+	}
+
+	// Skipping the comma is an error in strict mode only
+	for _, strict := range []bool{false, true} {
+		s := newPlayState("Hi, you", GameOptions{StrictSymbols: strict})
+		for _, r := range "Hiy" {
+			s.FSM.Event(context.Background(), "input", string(r))
+		}
+		want := 0
+		if strict {
+			want = 1
+		}
+		if s.Score.ErrorCount != want {
+			t.Errorf("strict=%v: expected %d errors, got %d", strict, want, s.Score.ErrorCount)
+		}
+	}
+
+	// Word bonuses still split on spaces only: the hyphen doesn't end a word
+	s := newPlayState("well-known", GameOptions{StrictSymbols: true})
+	s.Pos = 4
+	if s.GotCompletedWord() {
+		t.Error("A hyphen should not complete a word in strict mode")
+	}
+}
+
+func TestState_StrictSymbols_HintKey(t *testing.T) {
+	s := newPlayState("Why?", GameOptions{StrictSymbols: true})
+
+	// '?' is a typable character, so it is a mistake rather than a hint
+	s.FSM.Event(context.Background(), "input", "?")
+	if s.Score.HintCount != 0 || s.Score.ErrorCount != 1 {
+		t.Errorf("Expected '?' to be an error, got hints=%d errors=%d", s.Score.HintCount, s.Score.ErrorCount)
+	}
+
+	s.FSM.Event(context.Background(), "input", "w")
+	s.FSM.Event(context.Background(), "input", "ctrl+h")
+	if s.Score.HintCount != 1 || s.Mask[1] != 'h' {
+		t.Errorf("Expected ctrl+h to reveal the next letter, got hints=%d mask %q", s.Score.HintCount, string(s.Mask))
+	}
+
+	// Without strict symbols '?' still asks for a hint
+	s = newPlayState("Why?", GameOptions{})
+	s.FSM.Event(context.Background(), "input", "?")
+	if s.Score.HintCount != 1 {
+		t.Errorf("Expected '?' to give a hint, got %d hints", s.Score.HintCount)
+	}
+}
+
+func TestState_HideSpaces(t *testing.T) {
+	s := newPlayState("A B", GameOptions{HideSpaces: true})
+	if string(s.Mask) != "___" {
+		t.Fatalf("Expected mask '___', got %q", string(s.Mask))
+	}
+
+	s.FSM.Event(context.Background(), "input", "a")
+	if s.Pos != 1 {
+		t.Fatalf("Expected to stop at the space, got Pos %d", s.Pos)
+	}
+
+	// The next letter doesn't skip the gap
+	s.FSM.Event(context.Background(), "input", "b")
+	if s.Pos != 1 || s.Score.ErrorCount != 1 {
+		t.Errorf("Expected an error at the space, got Pos %d errors %d", s.Pos, s.Score.ErrorCount)
+	}
+
+	s.FSM.Event(context.Background(), "input", " ")
+	if string(s.Mask) != "A _" || s.Pos != 2 {
+		t.Errorf("Expected the space to be typed, got mask %q Pos %d", string(s.Mask), s.Pos)
+	}
+
+	s.FSM.Event(context.Background(), "input", "b")
+	if !s.Win {
+		t.Errorf("Expected win, mask %q", string(s.Mask))
+	}
+
+	// Without the option spaces are revealed and skipped
+	s = newPlayState("A B", GameOptions{})
+	if string(s.Mask) != "_ _" {
+		t.Errorf("Expected mask '_ _', got %q", string(s.Mask))
+	}
+}
+
+func TestState_RequireEnter(t *testing.T) {
+	s := newPlayState("A\nB", GameOptions{RequireEnter: true})
+	if string(s.Mask) != "___" {
+		t.Fatalf("Expected the line break hidden too, got mask %q", string(s.Mask))
+	}
+
+	s.FSM.Event(context.Background(), "input", "a")
+	if s.Pos != 1 {
+		t.Fatalf("Expected to stop at the line break, got Pos %d", s.Pos)
+	}
+
+	// B isn't typeable until Enter is pressed
+	s.FSM.Event(context.Background(), "input", "b")
+	if s.Pos != 1 || s.Score.ErrorCount != 1 || s.Mask[2] != '_' {
+		t.Errorf("Expected an error at the line break, got Pos %d errors %d", s.Pos, s.Score.ErrorCount)
+	}
+
+	s.FSM.Event(context.Background(), "input", "enter")
+	if string(s.Mask) != "A\n_" || s.Pos != 2 {
+		t.Errorf("Expected Enter to type the line break, got mask %q Pos %d", string(s.Mask), s.Pos)
+	}
+
+	s.FSM.Event(context.Background(), "input", "b")
+	if !s.Win {
+		t.Errorf("Expected win, mask %q", string(s.Mask))
+	}
+
+	// Without the option line breaks are revealed and skipped
+	s = newPlayState("A\nB", GameOptions{})
+	s.FSM.Event(context.Background(), "input", "a")
+	if string(s.Mask) != "A\n_" || s.Pos != 2 {
+		t.Errorf("Expected the line break to be skipped, got mask %q Pos %d", string(s.Mask), s.Pos)
+	}
+}
+
+func TestState_TypographicCharacters(t *testing.T) {
+	secret := "“Don’t—stop” now…"
+
+	for _, opts := range []GameOptions{{}, {StrictSymbols: true}} {
+		s := newPlayState(secret, opts)
+
+		// The ellipsis is given away like a full stop, unless symbols must be typed
+		if got := s.Mask[len(s.Mask)-1] != '_'; got == opts.StrictSymbols {
+			t.Errorf("strict %v: unexpected mask %q", opts.StrictSymbols, string(s.Mask))
+		}
+
+		// Everything else is typed with the ASCII keys
+		for i := 0; i < 20 && !s.Win && !s.Loss; i++ {
+			s.FSM.Event(context.Background(), "input", string(ASCIIEquivalent(s.Secret[s.Pos])))
+		}
+		if !s.Win || s.Score.ErrorCount != 0 {
+			t.Errorf("strict %v: expected a clean win with ASCII keys, got mask %q with %d errors", opts.StrictSymbols, string(s.Mask), s.Score.ErrorCount)
+		}
+		if string(s.Mask) != secret {
+			t.Errorf("strict %v: expected the card shown as written, got %q", opts.StrictSymbols, string(s.Mask))
+		}
+	}
+
+	// The ASCII forms don't match other characters
+	if SameChar("'", '"') || SameChar("-", '’') || !SameChar("\"", '”') || !SameChar("'", '’') {
+		t.Error("Unexpected typographic matching")
+	}
+	if got := NormalizeTypography(secret); got != `"Don't-stop" now...` {
+		t.Errorf("Expected the text in ASCII, got %q", got)
+	}
+}
+
+func TestState_Layout(t *testing.T) {
+	layout := &KeyLayout{Name: "test", Map: map[rune]rune{'x': 'h', 'y': 'i', 'h': 'q'}}
+	s := newPlayState("Hi", GameOptions{Layout: layout})
+
+	// The physical 'x' types 'h'
+	s.FSM.Event(context.Background(), "input", "x")
+	if s.Pos != 1 || s.Score.ErrorCount != 0 {
+		t.Fatalf("Expected translated 'x' to match 'H', got Pos %d errors %d", s.Pos, s.Score.ErrorCount)
+	}
+
+	// The physical 'i' isn't remapped, but 'h' becomes 'q': both are wrong
+	s.FSM.Event(context.Background(), "input", "h")
+	if s.Score.ErrorCount != 1 || s.CurrentChar != "q" {
+		t.Errorf("Expected 'h' to be scored as the wrong letter 'q', got %q errors %d", s.CurrentChar, s.Score.ErrorCount)
+	}
+
+	// Control keys pass through untranslated
+	if layout.Translate("tab") != "tab" || layout.Translate("ctrl+r") != "ctrl+r" {
+		t.Error("Expected control keys to pass through")
+	}
+
+	// Of several keys typing the same character, the lowest is its key
+	dup := &KeyLayout{Name: "dup", Map: map[rune]rune{'m': 'z', 'c': 'z', 'x': 'z', 'b': 'z'}}
+	for range 20 {
+		if got := dup.Physical('z'); got != 'b' {
+			t.Fatalf("Expected 'b' as the key for 'z', got %q", got)
+		}
+	}
+
+	s.FSM.Event(context.Background(), "input", "y")
+	if !s.Win {
+		t.Errorf("Expected win, mask %q", string(s.Mask))
+	}
+}
+
+func TestParseLayout(t *testing.T) {
+	for spec, pairs := range builtinLayouts {
+		if len([]rune(pairs[0])) != len([]rune(pairs[1])) {
+			t.Errorf("%s: mismatched pair lengths", spec)
+		}
+	}
+
+	colemak, err := ParseLayout("qwerty-to-colemak")
+	if err != nil {
+		t.Fatalf("ParseLayout failed: %v", err)
+	}
+	if colemak.Name != "colemak" || colemak.Translate("k") != "e" || colemak.Translate("P") != ":" {
+		t.Errorf("Unexpected colemak translation: %+v", colemak.Name)
+	}
+	dvorak, _ := ParseLayout("qwerty-to-dvorak")
+	if dvorak.Translate("s") != "o" || dvorak.Translate("z") != ";" {
+		t.Error("Unexpected dvorak translation")
+	}
+
+	path := filepath.Join(t.TempDir(), "layout.txt")
+	os.WriteFile(path, []byte("a b\n\nc d\n"), 0644)
+	custom, err := ParseLayout("file:" + path)
+	if err != nil {
+		t.Fatalf("ParseLayout(file) failed: %v", err)
+	}
+	if custom.Translate("a") != "b" || custom.Translate("c") != "d" || custom.Translate("e") != "e" {
+		t.Errorf("Unexpected custom translation: %v", custom.Map)
+	}
+
+	os.WriteFile(path, []byte("a b\nabc d\n"), 0644)
+	if _, err := ParseLayout("file:" + path); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected an error on line 2, got %v", err)
+	}
+	if _, err := ParseLayout("azerty"); err == nil {
+		t.Error("Expected an error for an unknown layout")
+	}
+}
+
+func TestState_RequirePunctuation(t *testing.T) {
+	s := newPlayState("Hi. Why?", GameOptions{RequirePunctuation: true})
+	if string(s.Mask) != "___ ____" {
+		t.Fatalf("Expected punctuation to be masked, got %q", string(s.Mask))
+	}
+
+	s.FSM.Event(context.Background(), "input", "h")
+	s.FSM.Event(context.Background(), "input", "i")
+	if s.Pos != 2 {
+		t.Fatalf("Expected to stop at the period, got Pos %d", s.Pos)
+	}
+
+	// The wrong punctuation mark is scored like a wrong letter
+	s.FSM.Event(context.Background(), "input", "!")
+	if s.Score.ErrorCount != 1 || s.Pos != 2 {
+		t.Errorf("Expected '!' to be an error, got errors %d Pos %d", s.Score.ErrorCount, s.Pos)
+	}
+
+	s.FSM.Event(context.Background(), "input", ".")
+	if s.Pos != 4 || s.Mask[2] != '.' {
+		t.Fatalf("Expected the period to be typed, got Pos %d mask %q", s.Pos, string(s.Mask))
+	}
+
+	// '?' asks for a hint while it isn't the next character...
+	s.FSM.Event(context.Background(), "input", "?")
+	if s.Score.HintCount != 1 || s.Mask[4] != 'W' {
+		t.Errorf("Expected '?' to give a hint, got hints %d mask %q", s.Score.HintCount, string(s.Mask))
+	}
+
+	// ...and is typed when it is
+	s.FSM.Event(context.Background(), "input", "h")
+	s.FSM.Event(context.Background(), "input", "y")
+	s.FSM.Event(context.Background(), "input", "?")
+	if !s.Win || s.Score.HintCount != 1 || s.Score.ErrorCount != 1 {
+		t.Errorf("Expected a win, got win=%v hints %d errors %d mask %q", s.Win, s.Score.HintCount, s.Score.ErrorCount, string(s.Mask))
+	}
+
+	// Without the option the period is given away
+	s = newPlayState("Hi.", GameOptions{})
+	if string(s.Mask) != "__." {
+		t.Errorf("Expected the period to be revealed, got %q", string(s.Mask))
+	}
+}
+
+func TestState_MistakeDrill(t *testing.T) {
+	s := newPlayState("a b c d e f g h i [j]", GameOptions{})
+	if got := s.MistakeDrill(2); got != "" {
+		t.Errorf("Expected no drill without mistakes, got %q", got)
+	}
+
+	// Parts whose context touches are merged
+	s.ErrorPositions = map[int]bool{2: true, 8: true}
+	if got := s.MistakeDrill(1); got != "a b c d e f" {
+		t.Errorf("Expected merged part, got %q", got)
+	}
+	s.ErrorPositions = map[int]bool{0: true, 18: true}
+	if got := s.MistakeDrill(1); got != "a b\ni j" {
+		t.Errorf("Expected two parts, got %q", got)
+	}
+
+	// A mistake on a space counts against the next word
+	s.ErrorPositions = map[int]bool{3: true}
+	if got := s.MistakeDrill(0); got != "c" {
+		t.Errorf("Expected the word after the space, got %q", got)
+	}
+
+	// Literal brackets survive being played again
+	s = newPlayState(`x \[y\]`, GameOptions{})
+	s.ErrorPositions = map[int]bool{0: true}
+	if got := s.MistakeDrill(2); got != `x \[y` {
+		t.Errorf("Expected escaped bracket, got %q", got)
+	}
+}
+
+func TestState_Progress(t *testing.T) {
+	s := newPlayState("Hello", GameOptions{})
+	if p := s.Progress(); p != 0 {
+		t.Errorf("Expected 0%% at start, got %d%%", p)
+	}
+	for _, r := range "hel" {
+		s.FSM.Event(context.Background(), "input", string(r))
+	}
+	if p := s.Progress(); p != 60 {
+		t.Errorf("Expected 60%% after three letters, got %d%%", p)
+	}
+
+	// Spaces and punctuation aren't counted; bracketed text counts as revealed
+	s = newPlayState("[Hi], you!", GameOptions{})
+	if p := s.Progress(); p != 40 {
+		t.Errorf("Expected 40%% with the brackets revealed, got %d%%", p)
+	}
+}
+
+func TestState_Flash(t *testing.T) {
+	sc, _ := scoring.InitScoring("x", "Title", &MockStorage{})
+	s := NewState("Hi, [you] all", 40, textarea.New(), *sc, GameOptions{Flash: true, TimerLimit: 30})
+	s.SetBracketedPositions()
+	s.InitFlash()
+
+	if s.TimerEnabled {
+		t.Error("Expected no timer in flash mode")
+	}
+	if string(s.Mask) != "___ ___ ___" || s.FlashWordCount() != 3 {
+		t.Fatalf("Expected everything hidden, got %q with %d words", string(s.Mask), s.FlashWordCount())
+	}
+
+	s.FlashKey(" ")
+	s.FlashKey("x") // Ignored
+	s.FlashKey(" ")
+	if string(s.Mask) != "Hi, you ___" || s.FlashRevealed != 2 {
+		t.Errorf("Expected two words revealed, got %q (%d)", string(s.Mask), s.FlashRevealed)
+	}
+
+	s.FlashKey("backspace")
+	if string(s.Mask) != "Hi, ___ ___" || s.FlashRevealed != 1 {
+		t.Errorf("Expected the last word hidden, got %q (%d)", string(s.Mask), s.FlashRevealed)
+	}
+
+	// Not done until every word has been hidden again
+	s.FlashKey(" ")
+	s.FlashKey(" ")
+	s.FlashKey(" ") // Nothing left to reveal
+	if s.Win || s.FlashRevealed != 3 {
+		t.Fatalf("Expected all words revealed and no win yet, got win=%v (%d)", s.Win, s.FlashRevealed)
+	}
+	for range 3 {
+		s.FlashKey("backspace")
+	}
+	if !s.Win || string(s.Mask) != "___ ___ ___" {
+		t.Errorf("Expected completion, got win=%v mask %q", s.Win, string(s.Mask))
+	}
+	if s.Score.CurrentScore != 0 || s.Score.ErrorCount != 0 {
+		t.Errorf("Expected no scoring, got %d points %d errors", s.Score.CurrentScore, s.Score.ErrorCount)
+	}
+
+	// A card with nothing but whitespace is done at once
+	s = NewState(" \n ", 40, textarea.New(), *sc, GameOptions{Flash: true})
+	s.InitFlash()
+	if !s.Win {
+		t.Error("Expected a card without words to be finished")
+	}
+}
+
+func TestNewState_AutoTimerCPM(t *testing.T) {
+	secret := strings.Repeat("é", 90) // 180 bytes, 90 characters
+	sc, _ := scoring.InitScoring(secret, "Title", &MockStorage{})
+
+	tests := []struct {
+		cpm  int
+		want int
+	}{
+		{-1, 30},  // scoring.DefaultCPM
+		{90, 60},  // 1.5 chars/sec
+		{270, 20}, // 4.5 chars/sec
+	}
+	for _, tt := range tests {
+		s := NewState(secret, 40, textarea.New(), *sc, GameOptions{TimerLimit: -1, CPM: tt.cpm})
+		if s.TimeLimit != tt.want {
+			t.Errorf("cpm %d: expected %ds, got %ds", tt.cpm, tt.want, s.TimeLimit)
+		}
+	}
+}
+
+func TestNewState_AutoTimerWPMTarget(t *testing.T) {
+	secret := strings.Repeat("word, ", 49) + "end." // 50 words
+	sc, _ := scoring.InitScoring(secret, "Title", &MockStorage{})
+
+	tests := []struct {
+		wpm  int
+		want int
+	}{
+		{40, 75},  // 50 words / 40 wpm = 1.25 minutes
+		{100, 30}, // Half a minute
+		{120, 25},
+		{600, 10}, // 5s, raised to the minimum
+	}
+	for _, tt := range tests {
+		// The target takes precedence over the character rate
+		s := NewState(secret, 40, textarea.New(), *sc, GameOptions{TimerLimit: -1, CPM: 90, WPMTarget: tt.wpm})
+		if s.TimeLimit != tt.want {
+			t.Errorf("wpm %d: expected %ds, got %ds", tt.wpm, tt.want, s.TimeLimit)
+		}
+	}
+}
+
+func TestState_WordHint(t *testing.T) {
+	s := newPlayState("alpha beta gamma", GameOptions{})
+	for _, r := range "alphab" {
+		s.FSM.Event(context.Background(), "input", string(r))
+	}
+	before := s.Score.CurrentScore
+
+	s.FSM.Event(context.Background(), "input", "ctrl+w")
+	if string(s.Mask) != "alpha beta _____" {
+		t.Errorf("Expected 'beta' revealed, got mask %q", string(s.Mask))
+	}
+	if got := before - s.Score.CurrentScore; got != 200 {
+		t.Errorf("Expected a penalty of exactly 200 with no word bonus, got %d", got)
+	}
+	if s.Score.WordHintCount != 1 || s.Score.HintCount != 0 {
+		t.Errorf("Expected one word hint, got %d word hints and %d hints", s.Score.WordHintCount, s.Score.HintCount)
+	}
+
+	// Typing carries on at the next word
+	s.FSM.Event(context.Background(), "input", "g")
+	if s.Mask[11] != 'g' || s.Score.ErrorCount != 0 {
+		t.Errorf("Expected 'g' to be accepted, got mask %q errors %d", string(s.Mask), s.Score.ErrorCount)
+	}
+
+	// Revealing the last word finishes the card
+	s.FSM.Event(context.Background(), "input", "ctrl+w")
+	if !s.Win || string(s.Mask) != "alpha beta gamma" {
+		t.Errorf("Expected a win, got mask %q Pos %d", string(s.Mask), s.Pos)
+	}
+}
+
+func TestState_MaxHints(t *testing.T) {
+	s := newPlayState("abcdef", GameOptions{LimitHints: true, MaxHints: 2})
+	for range 2 {
+		s.FSM.Event(context.Background(), "input", "?")
+	}
+	if string(s.Mask) != "ab____" || s.HintsLeft != 0 || s.Score.HintCount != 2 {
+		t.Fatalf("Expected two hints, got mask %q with %d left", string(s.Mask), s.HintsLeft)
+	}
+
+	// The third does nothing, and costs nothing
+	score, pos := s.Score.CurrentScore, s.Pos
+	s.FSM.Event(context.Background(), "input", "ctrl+h")
+	if string(s.Mask) != "ab____" || s.Pos != pos || s.Score.CurrentScore != score || s.Score.ErrorCount != 0 {
+		t.Errorf("Expected no hint once they ran out, got mask %q at %d, score %d", string(s.Mask), s.Pos, s.Score.CurrentScore)
+	}
+	s.FSM.Event(context.Background(), "input", "c")
+	if string(s.Mask) != "abc___" {
+		t.Errorf("Expected typing to carry on, got mask %q", string(s.Mask))
+	}
+
+	// None at all
+	s = newPlayState("abc", GameOptions{LimitHints: true})
+	s.FSM.Event(context.Background(), "input", "?")
+	if string(s.Mask) != "___" || s.Score.HintCount != 0 {
+		t.Errorf("Expected no hints with a budget of 0, got mask %q", string(s.Mask))
+	}
+
+	// Without a limit they never run out
+	s = newPlayState("abcdef", GameOptions{})
+	for range 4 {
+		s.FSM.Event(context.Background(), "input", "?")
+	}
+	if string(s.Mask) != "abcd__" {
+		t.Errorf("Expected unlimited hints, got mask %q", string(s.Mask))
+	}
+}
+
+func TestState_Lenient(t *testing.T) {
+	s := newPlayState("cat dog", GameOptions{Lenient: true})
+	before := s.Score.CurrentScore
+
+	s.FSM.Event(context.Background(), "input", "x")
+	if s.Pos != 1 || s.WrongLetter {
+		t.Errorf("Expected to move past the mistake, got Pos %d WrongLetter %v", s.Pos, s.WrongLetter)
+	}
+	if s.Mask[0] != 'c' || !s.RevealedCharMistakes[0] {
+		t.Errorf("Expected 'c' revealed as a mistake, got mask %q", string(s.Mask))
+	}
+	if s.Score.ErrorCount != 1 || s.Score.CurrentScore != before-50 {
+		t.Errorf("Expected the usual penalty, got %d errors, score change %d", s.Score.ErrorCount, s.Score.CurrentScore-before)
+	}
+
+	// A mistake on the last letter still wins the card
+	for _, r := range "atdoz" {
+		s.FSM.Event(context.Background(), "input", string(r))
+	}
+	if !s.Win || string(s.Mask) != "cat dog" || s.Score.ErrorCount != 2 {
+		t.Errorf("Expected a win with 2 errors, got win=%v mask %q errors %d", s.Win, string(s.Mask), s.Score.ErrorCount)
+	}
+
+	// Without lenient mode the mistake blocks
+	s = newPlayState("cat", GameOptions{})
+	s.FSM.Event(context.Background(), "input", "x")
+	if s.Pos != 0 || !s.WrongLetter || s.Mask[0] != '_' {
+		t.Errorf("Expected to stay on the mistake, got Pos %d mask %q", s.Pos, string(s.Mask))
+	}
+}
+
+func TestState_NoTypeThrough(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   GameOptions
+		errors int
+	}{
+		// The doubled first letter is typed over for free
+		{"type-through", GameOptions{FirstLetter: true}, 0},
+		// The doubled first letter is checked against 'e' and is a mistake
+		{"strict", GameOptions{FirstLetter: true, NoTypeThrough: true}, 1},
+	}
+	for _, tt := range tests {
+		sc, _ := scoring.InitScoring("Tell me", "Title", &MockStorage{})
+		s := NewState("Tell me", 20, textarea.New(), *sc, tt.opts)
+		s.InitMask()
+		s.ApplyGameModes(tt.opts)
+		s.Score.CurrentScore = 1000
+		s.FSM.Event(context.Background(), "initGame")
+
+		// 'T' is revealed, typed once, then typed again by habit
+		s.FSM.Event(context.Background(), "input", "T")
+		s.FSM.Event(context.Background(), "input", "T")
+
+		if s.Score.ErrorCount != tt.errors {
+			t.Errorf("%s: expected %d errors, got %d", tt.name, tt.errors, s.Score.ErrorCount)
+		}
+		if s.WrongLetter != (tt.errors > 0) {
+			t.Errorf("%s: expected WrongLetter %v, got %v", tt.name, tt.errors > 0, s.WrongLetter)
+		}
+		if s.Pos != 1 {
+			t.Errorf("%s: expected Pos to stay at 1, got %d", tt.name, s.Pos)
+		}
+	}
+}
+
+func TestState_NumberLeniency(t *testing.T) {
+	s := newPlayState("2 cats", GameOptions{NumberLeniency: true})
+	for i, r := range "tw" {
+		s.FSM.Event(context.Background(), "input", string(r))
+		if s.Pos != 0 || s.Mask[0] != '_' || s.WrongLetter {
+			t.Fatalf("Key %d: expected the spelling to wait for the whole word, got Pos %d mask %q", i, s.Pos, string(s.Mask))
+		}
+	}
+	s.FSM.Event(context.Background(), "input", "o")
+	if s.Mask[0] != '2' || s.Pos != 2 || s.Score.ErrorCount != 0 {
+		t.Errorf("Expected \"two\" to type the 2, got Pos %d mask %q errors %d", s.Pos, string(s.Mask), s.Score.ErrorCount)
+	}
+
+	// A letter off the word is a mistake, and the spelling starts over
+	s = newPlayState("7", GameOptions{NumberLeniency: true})
+	for _, r := range "sx" {
+		s.FSM.Event(context.Background(), "input", string(r))
+	}
+	if !s.WrongLetter || s.Score.ErrorCount != 1 {
+		t.Errorf("Expected \"sx\" to be a mistake, got WrongLetter %v errors %d", s.WrongLetter, s.Score.ErrorCount)
+	}
+	for _, r := range "seven" {
+		s.FSM.Event(context.Background(), "input", string(r))
+	}
+	if !s.Win {
+		t.Errorf("Expected \"seven\" to win, got mask %q", string(s.Mask))
+	}
+
+	// Only single digits can be spelled, and only with the option
+	for _, tc := range []struct {
+		secret string
+		opts   GameOptions
+	}{
+		{"12", GameOptions{NumberLeniency: true}},
+		{"2", GameOptions{}},
+	} {
+		s = newPlayState(tc.secret, tc.opts)
+		s.FSM.Event(context.Background(), "input", "t")
+		if !s.WrongLetter {
+			t.Errorf("%q with %+v: expected 't' to be a mistake", tc.secret, tc.opts)
+		}
+	}
+}
+
+func TestState_ForgiveTypos(t *testing.T) {
+	s := newPlayState("cat", GameOptions{ForgiveTypos: true})
+	before := s.Score.CurrentScore
+
+	// 'x' is next to 'c': a small penalty, no mistake and no blocking
+	s.FSM.Event(context.Background(), "input", "x")
+	if s.Pos != 0 || s.WrongLetter || s.Mask[0] != '_' || s.ErrorPositions[0] {
+		t.Errorf("Expected a near miss to leave the cursor alone, got Pos %d WrongLetter %v mask %q", s.Pos, s.WrongLetter, string(s.Mask))
+	}
+	if s.Score.NearMissCount != 1 || s.Score.ErrorCount != 0 || s.Score.CurrentScore != before-20 {
+		t.Errorf("Expected one near miss costing 20, got %d near misses, %d errors, score change %d",
+			s.Score.NearMissCount, s.Score.ErrorCount, s.Score.CurrentScore-before)
+	}
+
+	// 'p' is nowhere near 'c': the usual mistake
+	before = s.Score.CurrentScore
+	s.FSM.Event(context.Background(), "input", "p")
+	if !s.WrongLetter || s.Score.ErrorCount != 1 || s.Score.CurrentScore != before-50 {
+		t.Errorf("Expected a full mistake, got WrongLetter %v, %d errors, score change %d", s.WrongLetter, s.Score.ErrorCount, s.Score.CurrentScore-before)
+	}
+
+	// Without the option a neighbouring key is a mistake too
+	s = newPlayState("cat", GameOptions{})
+	s.FSM.Event(context.Background(), "input", "x")
+	if !s.WrongLetter || s.Score.NearMissCount != 0 || s.Score.ErrorCount != 1 {
+		t.Errorf("Expected a mistake, got WrongLetter %v, %d near misses, %d errors", s.WrongLetter, s.Score.NearMissCount, s.Score.ErrorCount)
+	}
+}
+
+func TestState_ForgiveTyposWithLayout(t *testing.T) {
+	colemak, err := ParseLayout("qwerty-to-colemak")
+	if err != nil {
+		t.Fatalf("ParseLayout failed: %v", err)
+	}
+	s := newPlayState("t", GameOptions{ForgiveTypos: true, Layout: colemak})
+
+	// Colemak 't' is on the QWERTY 'f' key, and the 'g' key next to it types 'd'
+	s.FSM.Event(context.Background(), "input", "g")
+	if s.Score.NearMissCount != 1 || s.WrongLetter {
+		t.Errorf("Expected a near miss by key position, got %d near misses, WrongLetter %v", s.Score.NearMissCount, s.WrongLetter)
+	}
+
+	// The 'y' key types 'j', which is not next to 'f'
+	s.FSM.Event(context.Background(), "input", "y")
+	if s.Score.NearMissCount != 1 || !s.WrongLetter {
+		t.Errorf("Expected a mistake, got %d near misses, WrongLetter %v", s.Score.NearMissCount, s.WrongLetter)
+	}
+}
+
+func TestState_AutoHint(t *testing.T) {
+	s := newPlayState("abc", GameOptions{TimerLimit: 30, AutoHint: 3, LimitHints: true})
+	tick := func(n int) {
+		for range n {
+			s.FSM.Event(context.Background(), "tick")
+		}
+	}
+
+	// A key starts the idle count over
+	tick(2)
+	s.FSM.Event(context.Background(), "input", "a")
+	tick(2)
+	if s.Mask[1] != '_' || s.Score.HintCount != 0 {
+		t.Fatalf("Expected nothing revealed before 3 idle seconds, got mask %q", string(s.Mask))
+	}
+
+	before := s.Score.CurrentScore
+	tick(1)
+	if s.Mask[1] != 'b' || s.Pos != 2 || s.FSM.Current() != "idle" {
+		t.Fatalf("Expected 'b' revealed and the cursor past it, got mask %q Pos %d in %s", string(s.Mask), s.Pos, s.FSM.Current())
+	}
+	if s.Score.HintCount != 1 || s.Score.CurrentScore != before-50 || s.HintsLeft != 0 {
+		t.Errorf("Expected an auto hint costing 50 outside the hint limit, got %d hints, score change %d, %d left",
+			s.Score.HintCount, s.Score.CurrentScore-before, s.HintsLeft)
+	}
+
+	// The last character given away wins the card
+	tick(3)
+	if !s.Win || s.TimeRemaining != 22 {
+		t.Errorf("Expected the auto hint to finish the card with 22s left, got win=%v and %ds", s.Win, s.TimeRemaining)
+	}
+}
+
+func TestPickHintPosition(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   string
+		mask     string
+		pos      int
+		strategy string
+		want     int
+	}{
+		{"next", "the quiz", "___ ____", 4, HintNext, 4},
+		{"default is next", "the quiz", "___ ____", 4, "", 4},
+		{"rare", "the quiz", "___ ____", 4, HintRare, 7},
+		{"rare, the next letter is rarest", "the quiz", "the _u_z", 4, HintRare, 4},
+		{"rare ignores revealed letters", "fox", "__x", 0, HintRare, 0},
+		{"rare ties go to the first", "ann", "___", 0, HintRare, 1},
+		{"consonant", "easy", "____", 0, HintConsonant, 2},
+		{"consonant counts y", "aye", "a__", 1, HintConsonant, 1},
+		{"consonant, no consonant left", "idea", "i___", 1, HintConsonant, 1},
+		{"one hidden letter falls back to next", "quiz", "qui_", 3, HintRare, 3},
+		{"digits aren't letters", "a1b2", "____", 0, HintRare, 2},
+		{"a symbol is its own word", "(x)", "___", 0, HintRare, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PickHintPosition([]rune(tt.secret), []rune(tt.mask), tt.pos, tt.strategy)
+			if got != tt.want {
+				t.Errorf("PickHintPosition(%q, %q, %d, %q) = %d, want %d", tt.secret, tt.mask, tt.pos, tt.strategy, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestState_HintStrategy(t *testing.T) {
+	s := newPlayState("jazz", GameOptions{HintStrategy: HintRare})
+
+	// The rarest letter is revealed, and the cursor stays for the 'j'
+	s.FSM.Event(context.Background(), "input", "?")
+	if string(s.Mask) != "__z_" || s.Pos != 0 || s.Score.HintCount != 1 || s.FSM.Current() != "idle" {
+		t.Fatalf("Expected the first 'z' revealed with the cursor left at 0, got mask %q Pos %d, %d hints, in %s",
+			string(s.Mask), s.Pos, s.Score.HintCount, s.FSM.Current())
+	}
+	s.FSM.Event(context.Background(), "input", "j")
+	s.FSM.Event(context.Background(), "input", "?")
+	if string(s.Mask) != "j_zz" || s.Pos != 1 {
+		t.Fatalf("Expected the last 'z' revealed, got mask %q Pos %d", string(s.Mask), s.Pos)
+	}
+
+	// With one letter left hidden the hint falls back to the next one
+	for _, ch := range []string{"a", "z", "z"} {
+		s.FSM.Event(context.Background(), "input", ch)
+	}
+	if !s.Win {
+		t.Errorf("Expected the card won after typing the rest, got mask %q Pos %d", string(s.Mask), s.Pos)
+	}
+}
+
+func TestState_LinePeek(t *testing.T) {
+	s := newPlayState("ab\ncd ef\ngh", GameOptions{})
+	for _, ch := range []string{"a", "b"} {
+		s.FSM.Event(context.Background(), "input", ch)
+	}
+
+	before, mask := s.Score.CurrentScore, string(s.Mask)
+	s.FSM.Event(context.Background(), "input", "ctrl+l")
+	if s.LinePeek != LinePeekSeconds {
+		t.Errorf("Expected the line shown for %d ticks, got %d", LinePeekSeconds, s.LinePeek)
+	}
+	if s.Score.CurrentScore != before+s.Score.Points("hint") {
+		t.Errorf("Expected a hint-sized penalty, got a score change of %d", s.Score.CurrentScore-before)
+	}
+	if string(s.Mask) != mask || s.Pos != 3 || s.FSM.Current() != "idle" {
+		t.Errorf("Expected the mask and cursor left alone, got mask %q Pos %d in %s", string(s.Mask), s.Pos, s.FSM.Current())
+	}
+	if start, end := s.LineSpan(s.Pos); string(s.Secret[start:end]) != "cd ef" {
+		t.Errorf("Expected the current line to be %q, got %q", "cd ef", string(s.Secret[start:end]))
+	}
+
+	// With limited hints a peek uses one up, and once they are gone it does
+	// nothing
+	s = newPlayState("ab cd", GameOptions{LimitHints: true, MaxHints: 1})
+	s.FSM.Event(context.Background(), "input", "ctrl+l")
+	if s.LinePeek != LinePeekSeconds || s.HintsLeft != 0 {
+		t.Fatalf("Expected a peek using the last hint, got %d ticks with %d hints left", s.LinePeek, s.HintsLeft)
+	}
+	s.LinePeek = 0
+	before = s.Score.CurrentScore
+	s.FSM.Event(context.Background(), "input", "ctrl+l")
+	if s.LinePeek != 0 || s.Score.CurrentScore != before {
+		t.Errorf("Expected no peek without hints left, got %d ticks and a score change of %d", s.LinePeek, s.Score.CurrentScore-before)
+	}
+}
+
+func TestState_ForeignPunctuation(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		opts   GameOptions
+		keys   string // Typed in turn, one key per rune
+		mask   string // The mask at the start
+	}{
+		{"Japanese", "「元気」、元気。", GameOptions{}, "元気元気", "「__」、__。"},
+		{"Japanese requiring punctuation", "元気。", GameOptions{RequirePunctuation: true}, "元気", "__。"},
+		{"French no-break spaces", "Bonjour\u00a0: ça va\u00a0?", GameOptions{}, "Bonjourçava?", "_______\u00a0: __ __\u00a0_"},
+		{"no-break space typed as a space", "a\u00a0b", GameOptions{HideSpaces: true}, "a b", "___"},
+		{"full-width", "Ｈｉ！　ｙｏｕ？", GameOptions{}, "hiyou?", "__！　____"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newPlayState(tt.secret, tt.opts)
+			if string(s.Mask) != tt.mask {
+				t.Errorf("Expected the mask %q at the start, got %q", tt.mask, string(s.Mask))
+			}
+			for _, r := range tt.keys {
+				s.FSM.Event(context.Background(), "input", string(r))
+			}
+			if !s.Win || s.Score.ErrorCount != 0 {
+				t.Errorf("Expected the card won without errors, got win=%v, %d errors, mask %q Pos %d",
+					s.Win, s.Score.ErrorCount, string(s.Mask), s.Pos)
+			}
+		})
+	}
+
+	// Typed, they end a word for the word bonus
+	for _, tt := range []struct {
+		secret string
+		opts   GameOptions
+		keys   []string
+	}{
+		{"ab，cd", GameOptions{RequirePunctuation: true}, []string{"a", "b", ","}},
+		{"ab\u00a0cd", GameOptions{HideSpaces: true}, []string{"a", "b", " "}},
+	} {
+		s := newPlayState(tt.secret, tt.opts)
+		for _, key := range tt.keys {
+			s.FSM.Event(context.Background(), "input", key)
+		}
+		if want := 1000 + 3*s.Score.Points("rightLetter") + s.Score.Points("wordBonus") + s.Score.Points("cleanWordBonus"); s.Score.CurrentScore != want {
+			t.Errorf("%q: expected %d with a word bonus, got %d", tt.secret, want, s.Score.CurrentScore)
+		}
+	}
+}
+
+func TestState_LenientDigits(t *testing.T) {
+	tests := []struct {
+		name string
+		opts GameOptions
+		keys string
+		mask string
+	}{
+		{"digits typed", GameOptions{}, "Psalm231", "_____ __:_"},
+		{"digits given away", GameOptions{LenientDigits: true}, "Psalm", "_____ 23:1"},
+		{"digits typed anyway", GameOptions{LenientDigits: true}, "Psa2lm", "_____ 23:1"},
+		{"strict symbols", GameOptions{LenientDigits: true, StrictSymbols: true}, "Psalm:", "_____ 23_1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newPlayState("Psalm 23:1", tt.opts)
+			if string(s.Mask) != tt.mask {
+				t.Errorf("Expected the mask %q at the start, got %q", tt.mask, string(s.Mask))
+			}
+			if s.Score.Multiplier != 1 {
+				t.Errorf("Expected digits given away not to lower the multiplier, got %v", s.Score.Multiplier)
+			}
+			keys := []rune(tt.keys)
+			for i, r := range keys {
+				s.FSM.Event(context.Background(), "input", string(r))
+				if s.Win != (i == len(keys)-1) {
+					t.Fatalf("Expected the card won with the last key, got win=%v after key %d", s.Win, i+1)
+				}
+			}
+			if s.Score.ErrorCount != 0 || s.FSM.Current() != "endState" {
+				t.Errorf("Expected the card won without errors, got %d errors, in %s", s.Score.ErrorCount, s.FSM.Current())
+			}
+		})
+	}
+}
+
+func TestState_Events(t *testing.T) {
+	var buf bytes.Buffer
+	s := newPlayState("abc", GameOptions{TimerLimit: 30, Events: NewEventLog(&buf)})
+	clock := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.Now = func() time.Time { return clock }
+
+	s.FSM.Event(context.Background(), "tick")
+	// The hint moves past 'b', so typing it anyway is ignored without an event
+	for _, k := range []string{"a", "ctrl+h", "b", "x", "c"} {
+		s.FSM.Event(context.Background(), "input", k)
+	}
+
+	var events []Event
+	decoder := json.NewDecoder(&buf)
+	for decoder.More() {
+		var e Event
+		if err := decoder.Decode(&e); err != nil {
+			t.Fatalf("Failed to decode event %d: %v", len(events)+1, err)
+		}
+		events = append(events, e)
+	}
+
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	want := []string{EventTick, EventCorrect, EventHint, EventWrong, EventCorrect, EventWin}
+	if strings.Join(types, ",") != strings.Join(want, ",") {
+		t.Fatalf("Expected events %v, got %v", want, types)
+	}
+
+	if e := events[0]; e.TimeLeft != 29 || e.Score != 1000 || !e.Time.Equal(clock) {
+		t.Errorf("Expected the tick to carry the time left, score and time, got %+v", e)
+	}
+	if e := events[3]; e.Pos != 2 || e.Score >= events[2].Score {
+		t.Errorf("Expected the wrong letter at pos 2 to cost points, got %+v after %+v", e, events[2])
+	}
+	if e := events[5]; e.Score != s.Score.CurrentScore {
+		t.Errorf("Expected the win to carry the final score %d, got %d", s.Score.CurrentScore, e.Score)
+	}
+}
+
+func TestState_NoEvents(t *testing.T) {
+	// Without a log, nothing is written and nothing breaks
+	s := newPlayState("ab", GameOptions{})
+	s.FSM.Event(context.Background(), "input", "a")
+	s.FSM.Event(context.Background(), "input", "b")
+	if !s.Win {
+		t.Error("Expected a win without an event log")
+	}
+}
+
+func TestState_RevealLastLetters(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		opts   GameOptions
+		mask   string
+	}{
+		{"last letter", "Hello World", GameOptions{LastLetter: true}, "____o ____d"},
+		// Both ends of each word are given
+		{"with first letter", "Hello World", GameOptions{FirstLetter: true, LastLetter: true}, "H___o W___d"},
+		{"one-letter word", "a Hello", GameOptions{LastLetter: true}, "a ____o"},
+		// Bracketed text is shown as it is anyway
+		{"brackets", "Hi [aside] there", GameOptions{LastLetter: true}, "_i aside ____e"},
+	}
+	for _, tt := range tests {
+		sc, _ := scoring.InitScoring(tt.secret, "Title", &MockStorage{})
+		s := NewState(tt.secret, 20, textarea.New(), *sc, tt.opts)
+		s.SetBracketedPositions()
+		s.InitMask()
+		s.ApplyGameModes(tt.opts)
+
+		if got := string(s.Mask); got != tt.mask {
+			t.Errorf("%s: expected mask %q, got %q", tt.name, tt.mask, got)
+		}
+	}
+
+	// The cursor starts on the first hidden letter, and the revealed 'o' is
+	// typed over like the other revealed letters
+	opts := GameOptions{LastLetter: true}
+	sc, _ := scoring.InitScoring("Hello World", "Title", &MockStorage{})
+	s := NewState("Hello World", 20, textarea.New(), *sc, opts)
+	s.InitMask()
+	s.ApplyGameModes(opts)
+	s.Score.CurrentScore = 1000
+	s.FSM.Event(context.Background(), "initGame")
+	if s.Pos != 0 {
+		t.Fatalf("Expected Pos 0, got %d", s.Pos)
+	}
+	for _, ch := range "Hello" {
+		s.FSM.Event(context.Background(), "input", string(ch))
+	}
+	if s.Pos != 6 || s.Score.ErrorCount != 0 {
+		t.Errorf("Expected Pos 6 ('W') with no errors, got %d with %d", s.Pos, s.Score.ErrorCount)
+	}
+}
+
+func TestState_Adaptive(t *testing.T) {
+	secret := "Twenty letters here, and twenty more there"
+	hash := scoring.TextHash(secret)
+	revealed := func(history ...scoring.ScoreHistoryEntry) int {
+		sc, _ := scoring.InitScoring(secret, "Title", &historyStorage{history})
+		opts := GameOptions{Adaptive: true, Rand: rand.New(rand.NewSource(1))}
+		s := NewState(secret, 40, textarea.New(), *sc, opts)
+		s.InitMask()
+		s.ApplyGameModes(opts)
+		n := 0
+		for i, ch := range s.Secret {
+			if unicode.IsLetter(ch) && s.Mask[i] == ch {
+				n++
+			}
+		}
+		return n
+	}
+
+	fresh := revealed()
+	won := func(score int, percent float64) scoring.ScoreHistoryEntry {
+		return scoring.ScoreHistoryEntry{Hash: hash, Score: score, PercentOfMax: percent, Outcome: scoring.OutcomeWon}
+	}
+	strong := revealed(won(900, 95))
+	weak := revealed(won(400, 40))
+	lost := revealed(scoring.ScoreHistoryEntry{Hash: hash, Score: 100, Outcome: scoring.OutcomeLost})
+
+	if fresh == 0 || strong >= fresh {
+		t.Errorf("Expected a strong high score to reveal fewer letters than a new text, got %d and %d", strong, fresh)
+	}
+	if weak != fresh {
+		t.Errorf("Expected a weak win to reveal as many letters as a new text, got %d and %d", weak, fresh)
+	}
+	if lost <= fresh {
+		t.Errorf("Expected only losses to reveal more letters than a new text, got %d and %d", lost, fresh)
+	}
+
+	// A lost attempt scoring above the best win doesn't hide the win
+	if n := revealed(scoring.ScoreHistoryEntry{Hash: hash, Score: 950, Outcome: scoring.OutcomeLost}, won(900, 95)); n != strong {
+		t.Errorf("Expected the best win to decide despite a higher loss, got %d and %d", n, strong)
+	}
+
+	// A win scored at no share of the maximum, or history saved without
+	// outcomes, is neutral
+	if n := revealed(won(0, 0)); n != fresh {
+		t.Errorf("Expected a win without a share of the maximum to be neutral, got %d and %d", n, fresh)
+	}
+	if n := revealed(scoring.ScoreHistoryEntry{Hash: hash, Score: 100}); n != fresh {
+		t.Errorf("Expected an entry without an outcome to be neutral, got %d and %d", n, fresh)
+	}
+
+	// History for other texts doesn't count
+	if other := revealed(scoring.ScoreHistoryEntry{Hash: "other", Score: 900, PercentOfMax: 95}); other != fresh {
+		t.Errorf("Expected another text's history to be ignored, got %d and %d", other, fresh)
+	}
+}
+
+func TestState_Trouble(t *testing.T) {
+	secret := "one two three"
+	store := &historyStorage{}
+	play := func(opts GameOptions, keys string) *State {
+		sc, _ := scoring.InitScoring(secret, "Title", store)
+		s := NewState(secret, 40, textarea.New(), *sc, opts)
+		s.InitMask()
+		s.FSM.Event(context.Background(), "initGame")
+		s.Score.CurrentScore = 1000
+		for _, r := range keys {
+			s.FSM.Event(context.Background(), "input", string(r))
+		}
+		return s
+	}
+
+	// A card with no history isn't shaded
+	if s := play(GameOptions{}, ""); s.Trouble != nil {
+		t.Errorf("Expected no shading without history, got %v", s.Trouble)
+	}
+
+	// Two slips in "two" and one in "three" are saved with the attempt
+	play(GameOptions{}, "onetxwxothrxee")
+	if got := store.entries[0].WordErrors; len(got) != 2 || got[1] != 2 || got[2] != 1 {
+		t.Fatalf("Expected the word errors to be saved, got %v", got)
+	}
+
+	// The next attempt shades those words by how often they were mistyped
+	s := play(GameOptions{}, "")
+	if s.Trouble[0] != 0 || s.Trouble[4] != 2 || s.Trouble[6] != 2 || s.Trouble[3] != 0 || s.Trouble[8] != 1 {
+		t.Errorf("Expected \"two\" shaded more than \"three\", got %v", s.Trouble)
+	}
+	if s := play(GameOptions{NoConfidence: true}, ""); s.Trouble != nil {
+		t.Errorf("Expected no shading with NoConfidence, got %v", s.Trouble)
+	}
+}
+
+func TestState_Outcome(t *testing.T) {
+	tests := []struct {
+		name  string
+		score int
+		keys  []string
+		want  string
+	}{
+		{"won", 1000, []string{"a", "b", "c"}, scoring.OutcomeWon},
+		{"lost", 0, []string{"x"}, scoring.OutcomeLost},
+		{"revealed", 1000, []string{"a", "ctrl+r"}, scoring.OutcomeRevealed},
+		{"abandoned", 1000, []string{"a", "ctrl+c"}, scoring.OutcomeAbandoned},
+	}
+	for _, tt := range tests {
+		store := &historyStorage{}
+		sc, _ := scoring.InitScoring("abc", "Title", store)
+		s := NewState("abc", 20, textarea.New(), *sc, GameOptions{})
+		s.InitMask()
+		s.FSM.Event(context.Background(), "initGame")
+		s.Score.CurrentScore = tt.score
+		for _, k := range tt.keys {
+			s.FSM.Event(context.Background(), "input", k)
+		}
+
+		if len(store.entries) != 1 {
+			t.Fatalf("%s: expected one saved entry, got %d", tt.name, len(store.entries))
+		}
+		if got := store.entries[0].Outcome; got != tt.want {
+			t.Errorf("%s: expected outcome %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestState_RevealPenalty(t *testing.T) {
+	store := &historyStorage{}
+	sc, _ := scoring.InitScoring("abc", "Title", store)
+	s := NewState("abc", 20, textarea.New(), *sc, GameOptions{})
+	s.InitMask()
+	s.FSM.Event(context.Background(), "initGame")
+	s.Score.CurrentScore = 1000
+
+	s.FSM.Event(context.Background(), "input", "a")
+	s.FSM.Event(context.Background(), "input", "ctrl+r")
+	if !s.Revealed || s.Score.CurrentScore != 1000+25-500 {
+		t.Fatalf("Expected the reveal to cost 500, got %d", s.Score.CurrentScore)
+	}
+	if len(store.entries) != 1 || store.entries[0].Score != 525 || store.entries[0].Outcome != scoring.OutcomeRevealed {
+		t.Errorf("Expected the penalised score saved as revealed, got %+v", store.entries)
+	}
+}
+
+func TestState_Cloze(t *testing.T) {
+	secret := "The cat sat down"
+	words := strings.Fields(secret)
+
+	for seed := int64(0); seed < 10; seed++ {
+		opts := GameOptions{Cloze: 1, Rand: rand.New(rand.NewSource(seed))}
+		sc, _ := scoring.InitScoring(secret, "Title", &MockStorage{})
+		s := NewState(secret, 20, textarea.New(), *sc, opts)
+		s.InitMask()
+		s.ApplyGameModes(opts)
+		s.FSM.Event(context.Background(), "initGame")
+
+		// Exactly one word is hidden, and the cursor is on its first letter
+		maskWords := strings.Fields(string(s.Mask))
+		hidden := -1
+		for i, w := range maskWords {
+			if w == strings.Repeat("_", len(words[i])) {
+				if hidden >= 0 {
+					t.Fatalf("seed %d: expected one hidden word, got %q", seed, string(s.Mask))
+				}
+				hidden = i
+			} else if w != words[i] {
+				t.Fatalf("seed %d: expected %q to be shown, got %q", seed, words[i], w)
+			}
+		}
+		if hidden < 0 {
+			t.Fatalf("seed %d: expected a hidden word, got %q", seed, string(s.Mask))
+		}
+		if start := strings.Index(secret, words[hidden]); s.Pos != start {
+			t.Errorf("seed %d: expected Pos on the blank at %d, got %d", seed, start, s.Pos)
+		}
+
+		// Only the blank is typed and scored
+		for _, ch := range words[hidden] {
+			s.FSM.Event(context.Background(), "input", string(ch))
+		}
+		if !s.Win {
+			t.Fatalf("seed %d: expected filling the blank to win, mask %q", seed, string(s.Mask))
+		}
+		if s.Score.CorrectCount != len(words[hidden]) || s.Score.Multiplier != 1 {
+			t.Errorf("seed %d: expected %d letters at full value, got %d at x%.2f", seed, len(words[hidden]), s.Score.CorrectCount, s.Score.Multiplier)
+		}
+		// The letters and the card bonus; the word bonus depends on what follows
+		if want := len(words[hidden])*25 + 1000; s.Score.CurrentScore < want || s.MaxScore() < s.Score.CurrentScore {
+			t.Errorf("seed %d: expected a score from %d to %d, got %d", seed, want, s.MaxScore(), s.Score.CurrentScore)
+		}
+	}
+}
+
+func TestState_ApostrophesInWords(t *testing.T) {
+	secret := "don't stop can't won't"
+	newState := func(opts GameOptions) *State {
+		sc, _ := scoring.InitScoring(secret, "Title", &MockStorage{})
+		s := NewState(secret, 40, textarea.New(), *sc, opts)
+		s.InitMask()
+		s.ApplyGameModes(opts)
+		return s
+	}
+
+	spans := newState(GameOptions{}).wordSpans()
+	if want := []wordSpan{{0, 5}, {6, 10}, {11, 16}, {17, 22}}; !slices.Equal(spans, want) {
+		t.Errorf("Expected the apostrophes inside the words, got %v", spans)
+	}
+	if n := len(newState(GameOptions{LegacyWordSplit: true}).wordSpans()); n != 7 {
+		t.Errorf("Expected the legacy split to make 7 words, got %d", n)
+	}
+	if n := WordCount(secret); n != 4 {
+		t.Errorf("Expected 4 words, got %d", n)
+	}
+	// Apostrophes outside words don't join anything
+	if n := WordCount("'tis the rock 'n' roll"); n != 5 {
+		t.Errorf("Expected 5 words, got %d", n)
+	}
+
+	if got := string(newState(GameOptions{FirstLetter: true}).Mask); got != "d____ s___ c____ w____" {
+		t.Errorf("Expected one first letter per word, got %q", got)
+	}
+	if got := string(newState(GameOptions{FirstLetter: true, LegacyWordSplit: true}).Mask); got != "d___t s___ c___t w___t" {
+		t.Errorf("Expected the legacy split to reveal the letters after apostrophes, got %q", got)
+	}
+
+	// A random word is revealed whole, apostrophe and all
+	words := strings.Fields(secret)
+	for seed := int64(0); seed < 10; seed++ {
+		s := newState(GameOptions{NWords: 1, Rand: rand.New(rand.NewSource(seed))})
+		shown := 0
+		for i, w := range strings.Fields(string(s.Mask)) {
+			if w == words[i] {
+				shown++
+			} else if w != strings.Repeat("_", len(w)) {
+				t.Fatalf("seed %d: expected whole words revealed, got %q", seed, string(s.Mask))
+			}
+		}
+		if shown != 1 {
+			t.Errorf("seed %d: expected one word revealed, got %q", seed, string(s.Mask))
+		}
+	}
+
+	// The word bonus is only given at the three spaces and the end, never at an
+	// apostrophe: 22 letters at 25, 4 clean word bonuses at 350 and the message
+	// bonus of 1000
+	for _, legacy := range []bool{false, true} {
+		s := newPlayState(secret, GameOptions{HideSpaces: true, LegacyWordSplit: legacy})
+		before := s.Score.CurrentScore
+		for _, r := range secret {
+			s.FSM.Event(context.Background(), "input", string(r))
+		}
+		if !s.Win || s.Score.CurrentScore-before != 2950 {
+			t.Errorf("legacy=%v: expected a win scoring 2950, got win=%v scoring %d", legacy, s.Win, s.Score.CurrentScore-before)
+		}
+	}
+}
+
+func TestGhost(t *testing.T) {
+	// "ab cd": the words end at 2 and 5, and were done after 1s and 3s
+	g := NewGhost([]int{2, 5}, []int64{1000, 3000})
+
+	positions := []struct {
+		elapsed time.Duration
+		pos     int
+	}{
+		{0, 0},
+		{500 * time.Millisecond, 1},
+		{time.Second, 2},
+		{2 * time.Second, 3}, // Halfway through the second word's 3 runes
+		{3 * time.Second, 5},
+		{9 * time.Second, 5}, // Finished
+	}
+	for _, tt := range positions {
+		if got := g.PosAt(tt.elapsed); got != tt.pos {
+			t.Errorf("PosAt(%v): expected %d, got %d", tt.elapsed, tt.pos, got)
+		}
+	}
+
+	times := []struct {
+		pos     int
+		elapsed time.Duration
+	}{
+		{0, 0},
+		{1, 500 * time.Millisecond},
+		{2, time.Second},
+		{4, time.Second + 2*(2*time.Second)/3},
+		{5, 3 * time.Second},
+		{9, 3 * time.Second},
+	}
+	for _, tt := range times {
+		if got := g.TimeAt(tt.pos); got != tt.elapsed {
+			t.Errorf("TimeAt(%d): expected %v, got %v", tt.pos, tt.elapsed, got)
+		}
+	}
+
+	// Words given away take no time at all
+	g = NewGhost([]int{2, 5, 8}, []int64{1000, 1000, 4000})
+	if got := g.PosAt(time.Second); got != 2 {
+		t.Errorf("Expected the ghost at the end of the first word, got %d", got)
+	}
+	if got := g.PosAt(2500 * time.Millisecond); got != 6 {
+		t.Errorf("Expected the ghost halfway from the second word to the third, got %d", got)
+	}
+
+	// Without timings for every word there is no ghost
+	if NewGhost([]int{2, 5}, nil) != nil || NewGhost([]int{2, 5}, []int64{1000}) != nil || NewGhost(nil, nil) != nil {
+		t.Error("Expected no ghost without timings that fit the words")
+	}
+}
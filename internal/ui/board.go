@@ -0,0 +1,198 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Board holds what is needed to draw the masked text of a game.
+type Board struct {
+	Mask        []rune
+	Secret      []rune // Wrap points are chosen from the secret, so rows don't shift as the mask is revealed
+	Pos         int    // Index of the cursor, or -1 for none
+	WrongLetter bool   // The cursor is on a mistake
+	Bracketed   []int  // Always-revealed positions, drawn bold
+	Mistakes    map[int]bool
+	Ghost       int    // Index of the previous attempt being raced, or 0 for none, as it hasn't moved yet
+	Theme       *Theme // nil for DefaultTheme
+
+	// Trouble is how much the word at each position was mistyped in earlier
+	// attempts, from 0 for not at all to scoring.MaxTroubleLevel.
+	Trouble map[int]int
+}
+
+// WrapRows splits text into rows at most width columns wide, breaking at
+// newlines and, where a line is too long, after the last space that fits.
+// A word longer than the width is broken where it reaches it.
+// Each row is the [start, end) range of its runes in text; newlines belong
+// to no row. A width of 0 or less only breaks at newlines.
+func WrapRows(text []rune, width int) [][2]int {
+	var rows [][2]int
+	start, col, lastSpace := 0, 0, -1
+	for i := 0; i < len(text); i++ {
+		r := text[i]
+		if r == '\n' {
+			rows = append(rows, [2]int{start, i})
+			start, col, lastSpace = i+1, 0, -1
+			continue
+		}
+
+		w := lipgloss.Width(string(r))
+		for width > 0 && col+w > width && i > start {
+			// Break after the last space on the row, or right here if there is none
+			end := i
+			if lastSpace >= start {
+				end = lastSpace + 1
+			}
+			rows = append(rows, [2]int{start, end})
+			start, lastSpace = end, -1
+			col = 0
+			for _, r := range text[start:i] {
+				col += lipgloss.Width(string(r))
+			}
+		}
+
+		if r == ' ' {
+			lastSpace = i
+		}
+		col += w
+	}
+	return append(rows, [2]int{start, len(text)})
+}
+
+// RenderBoard draws the board line by line, soft-wrapped to width columns
+// (0 for no wrapping), styling the cursor, bracketed text and mistakes.
+// Line breaks are not drawn as runes, so every rune is styled by its index in
+// the whole mask, and a cursor on a line break, or a line break still hidden,
+// is drawn at the end of its line.
+// Runs of unstyled runes are rendered together, and each style is built once
+// per call, so drawing stays linear in the size of the card.
+func RenderBoard(b Board, width int) string {
+	layout := b.Secret
+	if len(layout) != len(b.Mask) {
+		layout = b.Mask
+	}
+
+	theme := b.Theme
+	if theme == nil {
+		t := DefaultTheme()
+		theme = &t
+	}
+
+	bracketed := make([]bool, len(b.Mask))
+	for _, i := range b.Bracketed {
+		if i >= 0 && i < len(bracketed) {
+			bracketed[i] = true
+		}
+	}
+	styles := make(map[cell]lipgloss.Style)
+	render := func(c cell, text string) string {
+		style, ok := styles[c]
+		if !ok {
+			style = c.style(theme)
+			styles[c] = style
+		}
+		return style.Render(text)
+	}
+
+	var sb strings.Builder
+	for row, span := range WrapRows(layout, width) {
+		if row > 0 {
+			sb.WriteString("\n")
+		}
+		for i := span[0]; i < span[1]; {
+			c := b.cellAt(i, bracketed)
+			end := i + 1
+			if c == (cell{}) {
+				for end < span[1] && b.cellAt(end, bracketed) == (cell{}) {
+					end++
+				}
+			}
+			sb.WriteString(render(c, string(b.Mask[i:end])))
+			i = end
+		}
+		if end := span[1]; end < len(b.Mask) && layout[end] == '\n' {
+			switch {
+			case b.Mask[end] == '_': // A line break still to be typed
+				sb.WriteString(render(b.cellAt(end, bracketed), "_"))
+			case end == b.Pos:
+				sb.WriteString(render(b.cellAt(end, bracketed), " "))
+			}
+		}
+	}
+	return sb.String()
+}
+
+// cell is what decides the style of a rune on the board, so that runes
+// styled alike can share a style.
+type cell struct {
+	ghost      bool
+	cursor     bool
+	onMistake  bool // The cursor is on a mistake, over a revealed rune
+	wrongBlock bool // The cursor is on a mistake, over a hidden rune
+	mistake    bool
+	hint       bool
+	trouble    int
+}
+
+// cellAt returns the cell of the rune at index i of the mask. bracketed
+// holds whether each index is always revealed.
+func (b Board) cellAt(i int, bracketed []bool) cell {
+	c := cell{
+		ghost:   b.Ghost > 0 && i == b.Ghost && i != b.Pos,
+		mistake: b.Mistakes[i],
+		hint:    bracketed[i],
+		trouble: b.Trouble[i],
+	}
+	if i == b.Pos {
+		switch {
+		case !b.WrongLetter:
+			c.cursor = true
+		case b.Mask[i] != '_':
+			c.onMistake = true
+		default:
+			c.wrongBlock = true
+		}
+	}
+	return c
+}
+
+// style returns the style of a cell.
+// Where styles overlap, the cursor wins over a mistake, and a mistake over a hint.
+// The ghost is never drawn over the cursor. Words mistyped before are
+// shaded beneath everything else.
+func (c cell) style(theme *Theme) lipgloss.Style {
+	style := lipgloss.NewStyle()
+
+	if c.ghost {
+		style = style.Inherit(theme.Ghost)
+	}
+
+	// Apply cursor style
+	switch {
+	case c.onMistake:
+		// If character is already revealed (visible), mark it as a mistake
+		style = style.Inherit(theme.Mistake)
+	case c.wrongBlock:
+		// Block cursor for hidden char
+		style = style.Inherit(theme.WrongCursor)
+	case c.cursor:
+		style = style.Inherit(theme.Cursor)
+	}
+
+	// Apply persistent mistake style
+	if c.mistake {
+		style = style.Inherit(theme.Mistake)
+	}
+
+	// Apply placeholder style
+	if c.hint {
+		style = style.Inherit(theme.Hint)
+	}
+
+	if c.trouble > 0 && len(theme.Trouble) > 0 {
+		style = style.Inherit(theme.Trouble[min(c.trouble, len(theme.Trouble))-1])
+	}
+	return style
+}
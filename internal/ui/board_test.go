@@ -0,0 +1,345 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// withANSI renders styles as escape codes for the duration of a test.
+func withANSI(t *testing.T) {
+	profile := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.ANSI)
+	t.Cleanup(func() { lipgloss.SetColorProfile(profile) })
+}
+
+const reverseOn = "\x1b[7m"
+
+func TestWrapRows(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+		want  []string
+	}{
+		{"the quick brown fox", 0, []string{"the quick brown fox"}},
+		{"the quick brown fox", 10, []string{"the quick ", "brown fox"}},
+		{"the quick brown fox", 9, []string{"the ", "quick ", "brown fox"}},
+		{"abcdefghij klm", 4, []string{"abcd", "efgh", "ij ", "klm"}},
+		{"ab cd\n\nef gh", 3, []string{"ab ", "cd", "", "ef ", "gh"}},
+	}
+	for _, tt := range tests {
+		text := []rune(tt.text)
+		var got []string
+		for _, row := range WrapRows(text, tt.width) {
+			got = append(got, string(text[row[0]:row[1]]))
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("WrapRows(%q, %d) = %q, expected %q", tt.text, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestRenderBoard_SoftWrap(t *testing.T) {
+	withANSI(t)
+
+	secret := []rune(strings.TrimSpace(strings.Repeat("lorem ipsum dolor sit amet ", 8))[:200])
+	mask := make([]rune, len(secret))
+	for i, r := range secret {
+		mask[i] = '_'
+		if r == ' ' {
+			mask[i] = ' '
+		}
+	}
+	// Reveal the first two rows' worth of text, and put the cursor on a letter well into the card
+	pos := 130
+	copy(mask, secret[:pos])
+
+	termWidth := 60
+	width := FitCardWidth(ComputeCardWidth(string(secret), BannerText("T", "src")), termWidth)
+	board := RenderBoard(Board{Mask: mask, Secret: secret, Pos: pos}, width)
+
+	lines := strings.Split(board, "\n")
+	if len(lines) < 4 {
+		t.Fatalf("Expected the 200-char line to wrap, got %d rows", len(lines))
+	}
+	plain := make([]string, len(lines))
+	for i, line := range lines {
+		plain[i] = stripANSI(line)
+		if w := lipgloss.Width(line); w > width {
+			t.Errorf("Row %d is %d columns wide, expected at most %d: %q", i, w, width, plain[i])
+		}
+	}
+
+	// Wrapping only inserts line breaks: the mask is otherwise unchanged
+	if strings.Join(plain, "") != string(mask) {
+		t.Errorf("Expected the rows to make up the mask, got %q", plain)
+	}
+
+	// The cursor is on the row and column where rune pos ended up
+	row, col := 0, pos
+	for col >= len([]rune(plain[row])) {
+		col -= len([]rune(plain[row]))
+		row++
+	}
+	for i, line := range lines {
+		idx := strings.Index(line, reverseOn)
+		if i != row {
+			if idx >= 0 {
+				t.Errorf("Unexpected cursor on row %d", i)
+			}
+			continue
+		}
+		if idx < 0 {
+			t.Fatalf("Expected the cursor on row %d: %q", row, line)
+		}
+		if got := len([]rune(stripANSI(line[:idx]))); got != col {
+			t.Errorf("Expected the cursor at column %d of row %d, got %d", col, row, got)
+		}
+	}
+
+	// The card border follows the wrapped width
+	card := RenderCard("T", "src", board, width)
+	for i, line := range strings.Split(card, "\n") {
+		if w := lipgloss.Width(line); w != width+CardChrome || w > termWidth {
+			t.Errorf("Card line %d is %d wide, expected %d", i, w, width+CardChrome)
+		}
+	}
+}
+
+// stripANSI removes the escape sequences added by styles.
+func stripANSI(s string) string {
+	var b strings.Builder
+	inEscape := false
+	for _, r := range s {
+		switch {
+		case r == '\x1b':
+			inEscape = true
+		case inEscape:
+			if r == 'm' {
+				inEscape = false
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func TestRenderBoard_MultiLineCursor(t *testing.T) {
+	withANSI(t)
+
+	secret := []rune("Roses are red\nViolets blue")
+	mask := []rune("Roses are red\nVi_____ ____")
+
+	tests := []struct {
+		name      string
+		pos       int
+		row, col  int
+		cursorRow string // Plain text of the row holding the cursor
+	}{
+		{"second line", 16, 1, 2, "Vi_____ ____"},
+		{"first line", 6, 0, 6, "Roses are red"},
+		// A cursor on the line break is drawn past the end of the first line
+		{"line break", 13, 0, 13, "Roses are red "},
+	}
+	for _, tt := range tests {
+		board := RenderBoard(Board{Mask: mask, Secret: secret, Pos: tt.pos}, 0)
+		lines := strings.Split(board, "\n")
+		if len(lines) != 2 {
+			t.Fatalf("%s: expected 2 lines, got %d", tt.name, len(lines))
+		}
+		for i, line := range lines {
+			idx := strings.Index(line, reverseOn)
+			if i != tt.row {
+				if idx >= 0 {
+					t.Errorf("%s: unexpected cursor on line %d", tt.name, i)
+				}
+				continue
+			}
+			if idx < 0 {
+				t.Fatalf("%s: expected the cursor on line %d: %q", tt.name, i, line)
+			}
+			if got := len([]rune(stripANSI(line[:idx]))); got != tt.col {
+				t.Errorf("%s: expected the cursor at column %d, got %d", tt.name, tt.col, got)
+			}
+			if got := stripANSI(line); got != tt.cursorRow {
+				t.Errorf("%s: expected line %q, got %q", tt.name, tt.cursorRow, got)
+			}
+		}
+	}
+
+	// A line break still to be typed is drawn as a blank at the end of its line
+	hidden := []rune("Roses are red_Vi_____ ____")
+	board := RenderBoard(Board{Mask: hidden, Secret: secret, Pos: 13}, 0)
+	if lines := strings.Split(board, "\n"); len(lines) != 2 || stripANSI(lines[0]) != "Roses are red_" || !strings.Contains(lines[0], reverseOn) {
+		t.Errorf("Expected the hidden line break under the cursor, got %q", board)
+	}
+
+	// Once the game is over there is no cursor, and the text is unchanged
+	final := RenderBoard(Board{Mask: secret, Secret: secret, Pos: -1}, 0)
+	if strings.Contains(final, reverseOn) || stripANSI(final) != string(secret) {
+		t.Errorf("Unexpected final render %q", final)
+	}
+}
+
+func TestRenderBoard_Ghost(t *testing.T) {
+	withANSI(t)
+	ghostOn := "\x1b[44m" // The default theme's blue background
+
+	board := Board{Mask: []rune("ab__"), Secret: []rune("abcd"), Pos: 2, Ghost: 3}
+	out := RenderBoard(board, 0)
+	if strings.Count(out, ghostOn) != 1 || strings.Index(out, ghostOn) < strings.Index(out, reverseOn) {
+		t.Errorf("Expected the ghost after the cursor, got %q", out)
+	}
+
+	// The ghost never hides the cursor, and isn't drawn before it has moved
+	for _, ghost := range []int{2, 0} {
+		board.Ghost = ghost
+		if out := RenderBoard(board, 0); strings.Contains(out, ghostOn) || !strings.Contains(out, reverseOn) {
+			t.Errorf("ghost %d: expected only the cursor, got %q", ghost, out)
+		}
+	}
+}
+
+func TestRenderBoard_Trouble(t *testing.T) {
+	profile := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.ANSI256)
+	t.Cleanup(func() { lipgloss.SetColorProfile(profile) })
+
+	shade := func(level int) string {
+		return fmt.Sprintf("\x1b[48;5;%dm", []int{52, 88, 124}[level-1]) // The default theme's backgrounds
+	}
+	board := Board{Mask: []rune("__ __ __"), Pos: -1, Trouble: map[int]int{0: 1, 1: 1, 6: 3, 7: 3}}
+	out := RenderBoard(board, 0)
+	if strings.Count(out, shade(1)) != 2 || strings.Count(out, shade(3)) != 2 || strings.Contains(out, shade(2)) {
+		t.Errorf("Expected the first word lightly shaded and the last deeply, got %q", out)
+	}
+
+	// The cursor on a mistake still shows over the shading
+	board.Pos, board.WrongLetter = 6, true
+	if out := RenderBoard(board, 0); !strings.Contains(out, "\x1b[101m_") || strings.Count(out, shade(3)) != 1 {
+		t.Errorf("Expected the wrong cursor over the shading, got %q", out)
+	}
+}
+
+// renderBoardPerRune is RenderBoard as it was before runs of runes were
+// batched, styling every rune on its own.
+func renderBoardPerRune(b Board, width int) string {
+	layout := b.Secret
+	if len(layout) != len(b.Mask) {
+		layout = b.Mask
+	}
+	theme := b.Theme
+	if theme == nil {
+		t := DefaultTheme()
+		theme = &t
+	}
+
+	cellStyle := func(i int) lipgloss.Style {
+		style := lipgloss.NewStyle()
+		if b.Ghost > 0 && i == b.Ghost && i != b.Pos {
+			style = style.Inherit(theme.Ghost)
+		}
+		if i == b.Pos {
+			switch {
+			case !b.WrongLetter:
+				style = style.Inherit(theme.Cursor)
+			case b.Mask[i] != '_':
+				style = style.Inherit(theme.Mistake)
+			default:
+				style = style.Inherit(theme.WrongCursor)
+			}
+		}
+		if b.Mistakes[i] {
+			style = style.Inherit(theme.Mistake)
+		}
+		if slices.Contains(b.Bracketed, i) {
+			style = style.Inherit(theme.Hint)
+		}
+		if level := b.Trouble[i]; level > 0 && len(theme.Trouble) > 0 {
+			style = style.Inherit(theme.Trouble[min(level, len(theme.Trouble))-1])
+		}
+		return style
+	}
+
+	var sb strings.Builder
+	for row, span := range WrapRows(layout, width) {
+		if row > 0 {
+			sb.WriteString("\n")
+		}
+		for i := span[0]; i < span[1]; i++ {
+			sb.WriteString(cellStyle(i).Render(string(b.Mask[i])))
+		}
+		if end := span[1]; end < len(b.Mask) && layout[end] == '\n' {
+			switch {
+			case b.Mask[end] == '_':
+				sb.WriteString(cellStyle(end).Render("_"))
+			case end == b.Pos:
+				sb.WriteString(cellStyle(end).Render(" "))
+			}
+		}
+	}
+	return sb.String()
+}
+
+func TestRenderBoard_SameAsPerRune(t *testing.T) {
+	profile := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.ANSI256)
+	t.Cleanup(func() { lipgloss.SetColorProfile(profile) })
+
+	secret := []rune("The quick brown fox\njumps over\tthe lazy dog.\n[Chorus] again and again")
+	mask := make([]rune, len(secret))
+	for i, r := range secret {
+		mask[i] = '_'
+		if i < 30 || r == ' ' || r == '\n' {
+			mask[i] = r
+		}
+	}
+	boards := []Board{
+		{Mask: mask, Secret: secret, Pos: 30, Bracketed: []int{48, 49, 50, 51, 52, 53, 54, 55},
+			Mistakes: map[int]bool{4: true, 5: true}, Ghost: 12, Trouble: map[int]int{35: 1, 36: 2, 37: 3}},
+		{Mask: mask, Secret: secret, Pos: 10, WrongLetter: true, Ghost: 40},
+		{Mask: mask, Secret: secret, Pos: 31, WrongLetter: true, Trouble: map[int]int{31: 3}},
+		{Mask: mask, Secret: secret, Pos: 19}, // On a line break
+		{Mask: mask, Secret: secret, Pos: -1},
+		{Mask: []rune("no secret"), Pos: 3},
+	}
+	for i, board := range boards {
+		for _, width := range []int{0, 8, 20} {
+			if got, want := RenderBoard(board, width), renderBoardPerRune(board, width); got != want {
+				t.Errorf("board %d, width %d:\ngot  %q\nwant %q", i, width, got, want)
+			}
+		}
+	}
+}
+
+func BenchmarkRenderBoard(b *testing.B) {
+	var sb strings.Builder
+	for sb.Len() < 5000 {
+		sb.WriteString("Now is the winter of our discontent\nMade glorious summer by this sun of York; ")
+	}
+	secret := []rune(sb.String())
+	mask := make([]rune, len(secret))
+	bracketed := []int{}
+	for i, r := range secret {
+		mask[i] = '_'
+		if i < len(secret)/2 || r == ' ' || r == '\n' {
+			mask[i] = r
+		}
+		if i%100 < 5 {
+			bracketed = append(bracketed, i)
+		}
+	}
+	board := Board{
+		Mask: mask, Secret: secret, Pos: len(secret) / 2, Bracketed: bracketed,
+		Mistakes: map[int]bool{10: true, 200: true}, Trouble: map[int]int{300: 1, 301: 1},
+	}
+	b.ResetTimer()
+	for b.Loop() {
+		RenderBoard(board, 80)
+	}
+}
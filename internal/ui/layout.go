@@ -0,0 +1,166 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// CardChrome is the number of columns the card adds around its content
+// (a border and a space of padding on each side).
+const CardChrome = 4
+
+// LongestLineLen returns the display width of the longest line in s.
+func LongestLineLen(s string) int {
+	maxLength := 0
+	for _, line := range strings.Split(s, "\n") {
+		if w := lipgloss.Width(line); w > maxLength {
+			maxLength = w
+		}
+	}
+	return maxLength
+}
+
+// BannerText returns the text shown in a card's banner.
+func BannerText(title, source string) string {
+	return "CARD: " + title + " | LOC: " + source
+}
+
+// ComputeCardWidth returns the content width of a card: wide enough for
+// both the longest line of the secret and the banner text.
+func ComputeCardWidth(secret, bannerText string) int {
+	width := LongestLineLen(secret)
+	if w := lipgloss.Width(bannerText); w > width {
+		width = w
+	}
+	return width
+}
+
+// minCardWidth is the narrowest content width a card is squeezed to.
+const minCardWidth = 20
+
+// FitCardWidth limits a card width to what fits in a terminal of termWidth
+// columns (0 means unknown). Long lines of the board are soft-wrapped to the
+// width by RenderBoard, and the banner truncates the source path to fit.
+func FitCardWidth(width int, termWidth int) int {
+	if termWidth <= 0 {
+		return width
+	}
+	maxWidth := max(termWidth-CardChrome, minCardWidth)
+	if width > maxWidth {
+		return maxWidth
+	}
+	return width
+}
+
+// RenderBanner renders the top border and title line of a card with the given
+// content width. A source path too long to fit is truncated in the middle.
+func RenderBanner(title, source string, width int) string {
+	text := BannerText(title, source)
+	if lipgloss.Width(text) > width {
+		avail := width - lipgloss.Width(BannerText(title, ""))
+		if avail >= 5 {
+			text = BannerText(title, TruncateMiddle(source, avail))
+		} else {
+			text = TruncateEnd("CARD: "+title, width)
+		}
+	}
+
+	padding := width - lipgloss.Width(text)
+	if padding < 0 {
+		padding = 0
+	}
+
+	top := "┏" + strings.Repeat("━", width+2) + "┓"
+	line := "┃ " + text + strings.Repeat(" ", padding) + " ┃"
+	return top + "\n" + line
+}
+
+// RenderCard renders a board beneath its banner in a box of the given content width.
+func RenderCard(title, source, board string, width int) string {
+	customBorder := lipgloss.ThickBorder()
+	customBorder.Top = "═"
+	customBorder.TopLeft = "┃"
+	customBorder.TopRight = "┃"
+
+	borderStyle := lipgloss.NewStyle().
+		Padding(0, 1).
+		Border(customBorder).
+		Width(width + 2) // Content plus padding, matching the banner
+
+	return RenderBanner(title, source, width) + "\n" + borderStyle.Render(board)
+}
+
+// RenderProgressBar draws done out of total as a bar width columns wide, in
+// filled blocks for what is done and shaded ones for the rest.
+func RenderProgressBar(done, total, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	filled := 0
+	if total > 0 {
+		filled = min(max(done, 0), total) * width / total
+	}
+	return strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+}
+
+// TruncateMiddle shortens s to at most width columns by replacing its middle with "…".
+func TruncateMiddle(s string, width int) string {
+	if lipgloss.Width(s) <= width {
+		return s
+	}
+	if width <= 1 {
+		return TruncateEnd(s, width)
+	}
+
+	runes := []rune(s)
+	keep := width - 1
+	leftWidth := (keep + 1) / 2
+	rightWidth := keep - leftWidth
+
+	var left strings.Builder
+	w := 0
+	for _, r := range runes {
+		rw := lipgloss.Width(string(r))
+		if w+rw > leftWidth {
+			break
+		}
+		left.WriteRune(r)
+		w += rw
+	}
+
+	right := []rune{}
+	w = 0
+	for i := len(runes) - 1; i >= 0; i-- {
+		rw := lipgloss.Width(string(runes[i]))
+		if w+rw > rightWidth {
+			break
+		}
+		right = append([]rune{runes[i]}, right...)
+		w += rw
+	}
+
+	return left.String() + "…" + string(right)
+}
+
+// TruncateEnd shortens s to at most width columns, ending with "…" when cut.
+func TruncateEnd(s string, width int) string {
+	if lipgloss.Width(s) <= width {
+		return s
+	}
+	if width <= 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	w := 0
+	for _, r := range s {
+		rw := lipgloss.Width(string(r))
+		if w+rw > width-1 {
+			break
+		}
+		b.WriteRune(r)
+		w += rw
+	}
+	return b.String() + "…"
+}
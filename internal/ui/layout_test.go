@@ -0,0 +1,156 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestRenderCard_Golden(t *testing.T) {
+	tests := []struct {
+		name      string
+		title     string
+		source    string
+		secret    string
+		termWidth int
+		expected  string
+	}{
+		{
+			name:   "secret wider than banner",
+			title:  "T",
+			source: "a",
+			secret: "The quick brown fox jumps",
+			expected: "" +
+				"┏━━━━━━━━━━━━━━━━━━━━━━━━━━━┓\n" +
+				"┃ CARD: T | LOC: a          ┃\n" +
+				"┃═══════════════════════════┃\n" +
+				"┃ The quick brown fox jumps ┃\n" +
+				"┗━━━━━━━━━━━━━━━━━━━━━━━━━━━┛",
+		},
+		{
+			name:   "banner wider than secret",
+			title:  "Short",
+			source: "a.txt",
+			secret: "Hello world\nHi",
+			expected: "" +
+				"┏━━━━━━━━━━━━━━━━━━━━━━━━━━┓\n" +
+				"┃ CARD: Short | LOC: a.txt ┃\n" +
+				"┃══════════════════════════┃\n" +
+				"┃ Hello world              ┃\n" +
+				"┃ Hi                       ┃\n" +
+				"┗━━━━━━━━━━━━━━━━━━━━━━━━━━┛",
+		},
+		{
+			name:      "long source truncated to terminal",
+			title:     "Title",
+			source:    "/very/long/path/to/cards/file.txt",
+			secret:    "Hi",
+			termWidth: 40,
+			expected: "" +
+				"┏━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━┓\n" +
+				"┃ CARD: Title | LOC: /very/lo…file.txt ┃\n" +
+				"┃══════════════════════════════════════┃\n" +
+				"┃ Hi                                   ┃\n" +
+				"┗━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━┛",
+		},
+		{
+			name:   "multibyte title",
+			title:  "Überschrift ✓",
+			source: "ü.txt",
+			secret: "Grüße",
+			expected: "" +
+				"┏━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━┓\n" +
+				"┃ CARD: Überschrift ✓ | LOC: ü.txt ┃\n" +
+				"┃══════════════════════════════════┃\n" +
+				"┃ Grüße                            ┃\n" +
+				"┗━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━┛",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			width := ComputeCardWidth(tt.secret, BannerText(tt.title, tt.source))
+			width = FitCardWidth(width, tt.termWidth)
+			got := RenderCard(tt.title, tt.source, tt.secret, width)
+
+			if got != tt.expected {
+				t.Errorf("Render mismatch.\nExpected:\n%s\nGot:\n%s", tt.expected, got)
+			}
+
+			// Every line must line up with the top border
+			lines := strings.Split(got, "\n")
+			for i, line := range lines {
+				if lipgloss.Width(line) != width+CardChrome {
+					t.Errorf("Line %d has width %d, expected %d: %q", i, lipgloss.Width(line), width+CardChrome, line)
+				}
+			}
+			if tt.termWidth > 0 && lipgloss.Width(lines[0]) > tt.termWidth {
+				t.Errorf("Card is wider than the terminal: %d > %d", lipgloss.Width(lines[0]), tt.termWidth)
+			}
+		})
+	}
+}
+
+func TestFitCardWidth_WrapsSecret(t *testing.T) {
+	secret := strings.Repeat("x", 50)
+	width := ComputeCardWidth(secret, BannerText("T", "src"))
+
+	if got := FitCardWidth(width, 30); got != 26 {
+		t.Errorf("Expected the card narrowed to the terminal, got %d", got)
+	}
+	if got := FitCardWidth(width, 10); got != minCardWidth {
+		t.Errorf("Expected the minimum width %d, got %d", minCardWidth, got)
+	}
+	if got := FitCardWidth(width, 0); got != 50 {
+		t.Errorf("Expected width unchanged for unknown terminal, got %d", got)
+	}
+}
+
+func TestTruncateMiddle(t *testing.T) {
+	tests := []struct {
+		input    string
+		width    int
+		expected string
+	}{
+		{"short", 10, "short"},
+		{"abcdefghij", 5, "ab…ij"},
+		{"abcdefghij", 6, "abc…ij"},
+		{"abcdefghij", 1, "…"},
+	}
+
+	for _, tt := range tests {
+		if got := TruncateMiddle(tt.input, tt.width); got != tt.expected {
+			t.Errorf("TruncateMiddle(%q, %d) = %q, expected %q", tt.input, tt.width, got, tt.expected)
+		}
+	}
+}
+
+func TestRenderBanner_TitleTooLong(t *testing.T) {
+	banner := RenderBanner("A very long title indeed", "src.txt", 16)
+	lines := strings.Split(banner, "\n")
+
+	if lines[1] != "┃ CARD: A very lo… ┃" {
+		t.Errorf("Unexpected banner line: %q", lines[1])
+	}
+}
+
+func TestRenderProgressBar(t *testing.T) {
+	tests := []struct {
+		done, total, width int
+		want               string
+	}{
+		{0, 4, 8, "░░░░░░░░"},
+		{1, 4, 8, "██░░░░░░"},
+		{3, 10, 8, "██░░░░░░"},
+		{4, 4, 8, "████████"},
+		{5, 4, 8, "████████"},
+		{1, 0, 4, "░░░░"},
+		{1, 2, 0, ""},
+	}
+	for _, tt := range tests {
+		if got := RenderProgressBar(tt.done, tt.total, tt.width); got != tt.want {
+			t.Errorf("RenderProgressBar(%d, %d, %d) = %q, want %q", tt.done, tt.total, tt.width, got, tt.want)
+		}
+	}
+}
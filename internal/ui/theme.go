@@ -0,0 +1,104 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Theme holds the styles the game is drawn with, so that they can be chosen
+// at startup.
+type Theme struct {
+	Name         string
+	Correct      lipgloss.Style // Wins and other good news
+	Error        lipgloss.Style // Losses and other bad news
+	Score        lipgloss.Style // The status line
+	Muted        lipgloss.Style // Secondary information
+	Bold         lipgloss.Style // Headings
+	Cursor       lipgloss.Style // The cursor
+	WrongCursor  lipgloss.Style // The cursor on a hidden character after a mistake
+	Mistake      lipgloss.Style // Characters revealed after a mistake, and the cursor on one
+	Hint         lipgloss.Style // Always-revealed (bracketed) text
+	Timer        lipgloss.Style // The time left
+	TimerWarning lipgloss.Style // The time left when it is running out
+	Ghost        lipgloss.Style // Where the previous attempt being raced is
+
+	// Trouble holds the backgrounds of words mistyped in earlier attempts,
+	// from a single slip to the most often.
+	Trouble []lipgloss.Style
+}
+
+// DefaultTheme returns the standard red/green theme.
+func DefaultTheme() Theme {
+	return Theme{
+		Name:         "default",
+		Correct:      lipgloss.NewStyle().Foreground(lipgloss.Color("10")),
+		Error:        lipgloss.NewStyle().Foreground(lipgloss.Color("9")),
+		Score:        lipgloss.NewStyle().Foreground(lipgloss.Color("11")),
+		Muted:        lipgloss.NewStyle().Foreground(lipgloss.Color("8")),
+		Bold:         lipgloss.NewStyle().Bold(true),
+		Cursor:       lipgloss.NewStyle().Reverse(true),
+		WrongCursor:  lipgloss.NewStyle().Background(lipgloss.Color("9")),
+		Mistake:      lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Underline(true),
+		Hint:         lipgloss.NewStyle().Bold(true),
+		Timer:        lipgloss.NewStyle().Foreground(lipgloss.Color("11")),
+		TimerWarning: lipgloss.NewStyle().Foreground(lipgloss.Color("9")),
+		Ghost:        lipgloss.NewStyle().Background(lipgloss.Color("4")),
+		Trouble:      troubleShades("52", "88", "124"),
+	}
+}
+
+// troubleShades returns the Trouble backgrounds of a theme, one per level.
+func troubleShades(colors ...string) []lipgloss.Style {
+	styles := make([]lipgloss.Style, len(colors))
+	for i, c := range colors {
+		styles[i] = lipgloss.NewStyle().Background(lipgloss.Color(c))
+	}
+	return styles
+}
+
+// ParseTheme returns the built-in theme with the given name.
+func ParseTheme(name string) (Theme, error) {
+	switch name {
+	case "default":
+		return DefaultTheme(), nil
+	case "high-contrast":
+		// Bright colors, and bold wherever something needs attention
+		return Theme{
+			Name:         name,
+			Correct:      lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Bold(true),
+			Error:        lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Bold(true),
+			Score:        lipgloss.NewStyle().Foreground(lipgloss.Color("15")).Bold(true),
+			Muted:        lipgloss.NewStyle().Foreground(lipgloss.Color("7")),
+			Bold:         lipgloss.NewStyle().Bold(true).Underline(true),
+			Cursor:       lipgloss.NewStyle().Reverse(true).Bold(true),
+			WrongCursor:  lipgloss.NewStyle().Background(lipgloss.Color("9")).Foreground(lipgloss.Color("15")).Bold(true),
+			Mistake:      lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Bold(true).Underline(true),
+			Hint:         lipgloss.NewStyle().Foreground(lipgloss.Color("15")).Bold(true),
+			Timer:        lipgloss.NewStyle().Foreground(lipgloss.Color("15")).Bold(true),
+			TimerWarning: lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Bold(true).Reverse(true),
+			Ghost:        lipgloss.NewStyle().Background(lipgloss.Color("12")).Foreground(lipgloss.Color("15")),
+			Trouble:      troubleShades("94", "130", "166"),
+		}, nil
+	case "colorblind":
+		// Blue and orange instead of green and red, and underlines as a cue
+		// that doesn't depend on color at all
+		return Theme{
+			Name:         name,
+			Correct:      lipgloss.NewStyle().Foreground(lipgloss.Color("33")),
+			Error:        lipgloss.NewStyle().Foreground(lipgloss.Color("208")).Bold(true),
+			Score:        lipgloss.NewStyle().Foreground(lipgloss.Color("15")),
+			Muted:        lipgloss.NewStyle().Foreground(lipgloss.Color("8")),
+			Bold:         lipgloss.NewStyle().Bold(true),
+			Cursor:       lipgloss.NewStyle().Reverse(true),
+			WrongCursor:  lipgloss.NewStyle().Background(lipgloss.Color("208")).Underline(true),
+			Mistake:      lipgloss.NewStyle().Foreground(lipgloss.Color("208")).Underline(true),
+			Hint:         lipgloss.NewStyle().Bold(true),
+			Timer:        lipgloss.NewStyle().Foreground(lipgloss.Color("33")),
+			TimerWarning: lipgloss.NewStyle().Foreground(lipgloss.Color("208")).Bold(true),
+			Ghost:        lipgloss.NewStyle().Background(lipgloss.Color("33")),
+			Trouble:      troubleShades("58", "94", "130"),
+		}, nil
+	}
+	return Theme{}, fmt.Errorf("unknown theme: %s (use default, high-contrast or colorblind)", name)
+}
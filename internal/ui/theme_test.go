@@ -0,0 +1,76 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTheme(t *testing.T) {
+	withANSI(t)
+
+	names := []string{"default", "high-contrast", "colorblind"}
+	rendered := make(map[string]string)
+	for _, name := range names {
+		theme, err := ParseTheme(name)
+		if err != nil {
+			t.Fatalf("ParseTheme(%q) failed: %v", name, err)
+		}
+
+		styles := map[string]string{
+			"correct":       theme.Correct.Render("x"),
+			"error":         theme.Error.Render("x"),
+			"score":         theme.Score.Render("x"),
+			"cursor":        theme.Cursor.Render("x"),
+			"wrong cursor":  theme.WrongCursor.Render("x"),
+			"mistake":       theme.Mistake.Render("x"),
+			"hint":          theme.Hint.Render("x"),
+			"timer warning": theme.TimerWarning.Render("x"),
+			"ghost":         theme.Ghost.Render("x"),
+		}
+		for role, out := range styles {
+			if out == "x" {
+				t.Errorf("%s: %s style is empty", name, role)
+			}
+		}
+		if styles["correct"] == styles["error"] {
+			t.Errorf("%s: correct and error look the same", name)
+		}
+		if styles["cursor"] == styles["wrong cursor"] {
+			t.Errorf("%s: the cursor looks the same after a mistake", name)
+		}
+		rendered[name] = strings.Join([]string{styles["correct"], styles["error"], styles["wrong cursor"]}, "")
+
+		// Every theme can draw a board with all of its styles in use
+		board := Board{
+			Mask:        []rune("ab_ d_"),
+			Secret:      []rune("abc de"),
+			Pos:         2,
+			WrongLetter: true,
+			Bracketed:   []int{0},
+			Mistakes:    map[int]bool{1: true},
+			Ghost:       4,
+			Theme:       &theme,
+		}
+		if out := RenderBoard(board, 0); stripANSI(out) != "ab_ d_" {
+			t.Errorf("%s: unexpected board %q", name, stripANSI(out))
+		}
+	}
+
+	for i, a := range names {
+		for _, b := range names[i+1:] {
+			if rendered[a] == rendered[b] {
+				t.Errorf("Themes %s and %s look the same", a, b)
+			}
+		}
+	}
+
+	// The colorblind theme doesn't rely on color for the wrong-letter cursor
+	cb, _ := ParseTheme("colorblind")
+	if !cb.WrongCursor.GetUnderline() {
+		t.Error("Expected the colorblind wrong-letter cursor to be underlined")
+	}
+
+	if _, err := ParseTheme("neon"); err == nil {
+		t.Error("Expected an error for an unknown theme")
+	}
+}
@@ -0,0 +1,44 @@
+package ui
+
+import (
+	"strings"
+	"unicode"
+)
+
+func capitalize(word string) string {
+	if len(word) == 0 {
+		return word
+	}
+	return strings.ToUpper(string(word[0])) + word[1:]
+}
+
+// TitleCaseToTitle turns a file name such as "psalm23TheLord" into "Psalm 23 The Lord".
+func TitleCaseToTitle(input string) string {
+	var result strings.Builder
+	lastCharType := 0 // 0: none, 1: letter, 2: digit
+
+	for i, r := range input {
+		currentCharType := 0
+		if unicode.IsUpper(r) {
+			currentCharType = 1
+		} else if unicode.IsLower(r) {
+			currentCharType = 1
+		} else if unicode.IsDigit(r) {
+			currentCharType = 2
+		}
+
+		if i > 0 && ((lastCharType == 1 && currentCharType == 2) || (lastCharType == 2 && currentCharType == 1) || (unicode.IsUpper(r) && unicode.IsLower(rune(input[i-1])))) {
+			result.WriteRune(' ')
+		}
+		result.WriteRune(r)
+		lastCharType = currentCharType
+	}
+
+	// Capitalize each word
+	words := strings.Fields(result.String())
+	for i, word := range words {
+		words[i] = capitalize(word)
+	}
+
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,21 @@
+package ui
+
+import "testing"
+
+func TestTitleCaseToTitle(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"psalm23", "Psalm 23"},
+		{"if-ByRudyardKipling", "If-By Rudyard Kipling"},
+		{"psalm119aleph", "Psalm 119 Aleph"},
+		{"lorem", "Lorem"},
+	}
+
+	for _, tt := range tests {
+		if got := TitleCaseToTitle(tt.input); got != tt.expected {
+			t.Errorf("TitleCaseToTitle(%q) = %q, expected %q", tt.input, got, tt.expected)
+		}
+	}
+}
@@ -1,86 +1,425 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 
 	"go-mem/internal/game"
+	"go-mem/internal/notify"
 	"go-mem/internal/scoring"
 	"go-mem/internal/state"
+	"go-mem/internal/ui"
+	"io"
+	"math/rand"
 	"os"
-	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
 	"time"
-	"unicode"
 
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/term"
 )
 
-var (
-	redStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))  // Red for incorrect inputs
-	greenStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("10")) // Green for correct input
-	scoreStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("11")) // Color for the score
-	boldStyle   = lipgloss.NewStyle().Bold(true)
-	cursorStyle = lipgloss.NewStyle().Reverse(true)
-)
+// loopPause is how long the result of a won card stays up before --loop starts it again.
+const loopPause = 2 * time.Second
+
+// nextCardPause is how long the result of a card stays up before the next
+// card starts, unless a key is pressed first.
+const nextCardPause = 2 * time.Second
 
 type LocalState struct {
 	Session       *game.Session
 	QuitNextCycle bool
 	Quitting      bool
+	TermWidth     int               // Terminal width from the last WindowSizeMsg, 0 if unknown
+	NoPeek        bool              // Hide the upcoming card titles in batch mode
+	Notice        string            // Shown above the card, e.g. after the card files were reloaded
+	Loop          bool              // A won single card is played again
+	Watcher       *game.CardWatcher // Reloads edited card files between cards, nil for none
+	Err           error             // Why the session stopped early, if it couldn't move on
+	Theme         ui.Theme
+	Wallclock     bool      // Time spent suspended with ctrl+z counts against the timer
+	ShowElapsed   bool      // Show the time since the session started in the status line
+	TrueScore     bool      // Show the score as it is, even below the score floor
+	StatusFile    string    // Where the session's progress is kept for status bars, "" for nowhere
+	ticking       bool      // Whether a tickCmd is in flight
+	statusFailed  bool      // Whether writing StatusFile has failed, which is only noted once
+	suspendedAt   time.Time // When ctrl+z suspended the program, zero while it isn't
 }
 
 type TickMsg time.Time
 type QuitMsg struct{}
 
+// AdvanceMsg moves on from a finished game once its result has been shown.
+// It is ignored if the game was already moved on from by a keypress.
+type AdvanceMsg struct {
+	Game *game.Game
+}
+
 func tickCmd() tea.Cmd {
 	return tea.Tick(time.Second, func(t time.Time) tea.Msg {
 		return TickMsg(t)
 	})
 }
 
-func initialModel(paths []string, opts state.GameOptions, randomize bool) (*LocalState, error) {
-	cards, err := game.LoadCards(paths)
+func initialModel(paths []string, loadOpts game.LoadOptions, opts state.GameOptions, order game.CardOrder, players []string, storage scoring.ScoreStorage) (*LocalState, error) {
+	cards, warnings, err := game.LoadCardsWithOptions(paths, loadOpts)
 	if err != nil {
 		return nil, err
 	}
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+	}
 	if len(cards) == 0 {
 		return nil, fmt.Errorf("no cards found in provided paths")
 	}
+	return newModel(cards, opts, order, players, storage)
+}
 
-	// Create the concrete storage implementation.
-	storage, err := scoring.NewJSONFileStorage()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create score storage: %w", err)
-	}
-
+// newModel creates the model for a session of cards that are already loaded.
+func newModel(cards []game.CardData, opts state.GameOptions, order game.CardOrder, players []string, storage scoring.ScoreStorage) (*LocalState, error) {
 	// Session handles scoring init per game.
+	rehashScores(cards, storage)
 
-	sess, err := game.NewSession(cards, opts, storage, randomize)
+	var sess *game.Session
+	var err error
+	if players != nil {
+		sess, err = game.NewVersusSession(cards, opts, storage, order, players)
+	} else {
+		sess, err = game.NewSession(cards, opts, storage, order)
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	return &LocalState{
 		Session: sess,
+		Theme:   ui.DefaultTheme(),
 	}, nil
 }
 
+// rehashScores moves the scores of cards saved before text hashes were
+// normalized to the cards' hashes now. It is only a warning if that fails, as
+// the cards can still be played.
+func rehashScores(cards []game.CardData, storage scoring.ScoreStorage) {
+	texts := make([]string, len(cards))
+	for i, c := range cards {
+		texts[i] = c.Content
+	}
+	if _, err := scoring.RehashEntries(storage, texts); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not move old scores to their new hashes: %v\n", err)
+	}
+}
+
+// runStale lists the cards in the paths given in args that haven't been
+// practised for --days, the stalest first. With --exec it prints nothing and
+// returns the stale cards instead, to be played as a session.
+func runStale(args []string, loadOpts game.LoadOptions, storage scoring.ScoreStorage) ([]game.CardData, error) {
+	fs := flag.NewFlagSet("stale", flag.ContinueOnError)
+	var days int
+	var exec bool
+	fs.IntVar(&days, "days", 7, "List the cards not played for this many days")
+	fs.BoolVar(&exec, "exec", false, "Play the stale cards instead of listing them")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if days < 0 {
+		return nil, fmt.Errorf("--days must be 0 or more, got %d", days)
+	}
+	if fs.NArg() == 0 {
+		return nil, fmt.Errorf("stale needs the card files or directories to check")
+	}
+
+	cards, _, err := game.LoadCardsWithOptions(fs.Args(), loadOpts)
+	if err != nil {
+		return nil, err
+	}
+	rehashScores(cards, storage)
+	entries, err := storage.LoadAll()
+	if err != nil && !errors.Is(err, scoring.ErrCorruptScores) {
+		return nil, fmt.Errorf("could not load scores: %w", err)
+	}
+
+	stale := game.StaleCards(cards, entries, time.Now(), days)
+	if exec {
+		play := []game.CardData{}
+		for _, s := range stale {
+			play = append(play, s.Card)
+		}
+		return play, nil
+	}
+
+	if len(stale) == 0 {
+		fmt.Printf("Every card has been played in the last %d days.\n", days)
+		return nil, nil
+	}
+	fmt.Printf("%-9s %6s  %s\n", "LAST", "BEST", "CARD")
+	for _, s := range stale {
+		last, best := "never", "-"
+		if s.Played {
+			last, best = fmt.Sprintf("%dd ago", s.DaysSince), fmt.Sprint(s.BestScore)
+		}
+		fmt.Printf("%-9s %6s  %s (%s)\n", last, best, s.Card.DisplayTitle(), s.Card.Source)
+	}
+	return nil, nil
+}
+
+// runStats reports how long the cards in the paths given in args have been
+// studied: in all, per attempt and in the last week, card by card and in total.
+func runStats(args []string, loadOpts game.LoadOptions, storage scoring.ScoreStorage) error {
+	if len(args) == 0 {
+		return fmt.Errorf("stats needs the card files or directories to report on")
+	}
+	cards, _, err := game.LoadCardsWithOptions(args, loadOpts)
+	if err != nil {
+		return err
+	}
+	rehashScores(cards, storage)
+	entries, err := storage.LoadAll()
+	if err != nil && !errors.Is(err, scoring.ErrCorruptScores) {
+		return fmt.Errorf("could not load scores: %w", err)
+	}
+
+	var total, week time.Duration
+	attempts, studied := 0, 0
+	fmt.Printf("%8s %8s %8s %8s  %s\n", "TOTAL", "AVERAGE", "WEEK", "ATTEMPTS", "CARD")
+	for _, s := range game.StudyTimes(cards, entries, time.Now()) {
+		fmt.Printf("%8s %8s %8s %8d  %s (%s)\n", studyDuration(s.Total), studyDuration(s.Average()),
+			studyDuration(s.ThisWeek), s.Attempts, s.Card.DisplayTitle(), s.Card.Source)
+		total += s.Total
+		week += s.ThisWeek
+		attempts += s.Attempts
+		if s.Attempts > 0 {
+			studied++
+		}
+	}
+
+	perCard := time.Duration(0)
+	if studied > 0 {
+		perCard = total / time.Duration(studied)
+	}
+	fmt.Printf("\nStudied for %s over %d attempts, %s per card studied, %s this week.\n",
+		studyDuration(total), attempts, studyDuration(perCard), studyDuration(week))
+	return nil
+}
+
+// studyDuration formats a study time to the second, or to the minute from an hour up, e.g. "12m05s".
+func studyDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d >= time.Hour {
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+// runHeadless plays a single card without the TUI and prints the result as JSON.
+func runHeadless(paths []string, loadOpts game.LoadOptions, opts state.GameOptions, input string, storage scoring.ScoreStorage) error {
+	cards, _, err := game.LoadCardsWithOptions(paths, loadOpts)
+	if err != nil {
+		return err
+	}
+	if len(cards) != 1 {
+		return fmt.Errorf("headless mode requires exactly one card, found %d", len(cards))
+	}
+
+	result, err := game.RunHeadless(cards[0], input, opts, storage)
+	if err != nil {
+		return err
+	}
+
+	out, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to encode result: %w", err)
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// runReplay plays back a key log against the cards it was recorded on, and
+// prints the result of each game as JSON. It fails if any game played out
+// differently from the recording.
+func runReplay(paths []string, loadOpts game.LoadOptions, logPath string, speed float64) error {
+	cards, _, err := game.LoadCardsWithOptions(paths, loadOpts)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(logPath)
+	if err != nil {
+		return fmt.Errorf("failed to open replay file: %w", err)
+	}
+	defer f.Close()
+
+	// Keys are only worth showing when they are played at a pace to follow
+	var progress io.Writer
+	if speed > 0 {
+		progress = os.Stderr
+	}
+	results, err := game.ReplayKeyLog(f, cards, speed, progress)
+	if err != nil {
+		return err
+	}
+
+	mismatches := 0
+	for _, res := range results {
+		out, err := json.Marshal(res)
+		if err != nil {
+			return fmt.Errorf("failed to encode result: %w", err)
+		}
+		fmt.Println(string(out))
+		if !res.Match {
+			mismatches++
+		}
+	}
+	if mismatches > 0 {
+		return fmt.Errorf("%d of %d games replayed differently from the recording", mismatches, len(results))
+	}
+	return nil
+}
+
+// runClear clears the score history, or with --hash just one text's scores,
+// after asking for confirmation unless --yes is given.
+func runClear(args []string, storage scoring.ScoreStorage) error {
+	fs := flag.NewFlagSet("clear", flag.ContinueOnError)
+	var yes bool
+	var hash string
+	fs.BoolVar(&yes, "yes", false, "Clear without asking for confirmation")
+	fs.BoolVar(&yes, "y", false, "Clear without asking for confirmation (shorthand)")
+	fs.StringVar(&hash, "hash", "", "Clear only the scores of the text with this hash")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments to clear: %s", strings.Join(fs.Args(), " "))
+	}
+
+	if l, ok := storage.(scoring.Locker); ok {
+		unlock, err := l.Lock()
+		if err != nil {
+			return fmt.Errorf("could not lock scores for clearing: %w", err)
+		}
+		defer unlock()
+	}
+	entries, err := storage.LoadAll()
+	if err != nil && !errors.Is(err, scoring.ErrCorruptScores) {
+		return fmt.Errorf("could not load scores: %w", err)
+	}
+
+	kept, removed := []scoring.ScoreHistoryEntry{}, len(entries)
+	what := "all score history"
+	if hash != "" {
+		kept, removed = scoring.RemoveEntries(entries, hash)
+		what = fmt.Sprintf("the scores of text %s", hash)
+	}
+	if removed == 0 {
+		fmt.Println("No score entries to remove.")
+		return nil
+	}
+
+	if !yes {
+		fmt.Fprintf(os.Stderr, "Remove %d score entries (%s)? [y/N] ", removed, what)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			fmt.Println("Nothing removed.")
+			return nil
+		}
+	}
+	if err := scoring.ReplaceAll(storage, kept); err != nil {
+		return fmt.Errorf("could not save scores: %w", err)
+	}
+	fmt.Printf("Removed %d score entries.\n", removed)
+	return nil
+}
+
+// scoresPassphrase returns the passphrase for encrypted scores, from
+// GOMEM_PASSPHRASE or else asked for on the terminal.
+func scoresPassphrase() (string, error) {
+	if passphrase := os.Getenv("GOMEM_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("set GOMEM_PASSPHRASE to the scores passphrase")
+	}
+	fmt.Fprint(os.Stderr, "Scores passphrase: ")
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return string(passphrase), nil
+}
+
+// encryptedStorage wraps file in encryption, checking the passphrase against
+// the existing scores straight away.
+func encryptedStorage(file *scoring.JSONFileStorage) (*scoring.EncryptedJSONStorage, error) {
+	passphrase, err := scoresPassphrase()
+	if err != nil {
+		return nil, err
+	}
+	storage, err := scoring.NewEncryptedJSONStorage(file, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := storage.LoadAll(); err != nil && !errors.Is(err, scoring.ErrCorruptScores) {
+		return nil, err
+	}
+	return storage, nil
+}
+
+// migrateEncrypt encrypts a plaintext scores file in place.
+func migrateEncrypt(file *scoring.JSONFileStorage) error {
+	passphrase, err := scoresPassphrase()
+	if err != nil {
+		return err
+	}
+	storage, err := scoring.NewEncryptedJSONStorage(file, passphrase)
+	if err != nil {
+		return err
+	}
+	n, err := storage.MigratePlaintext()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Encrypted %d score entries. Play with --encrypt-scores from now on.\n", n)
+	return nil
+}
+
+// warnIfUnsynced tells the player if the last scores couldn't be sent to
+// the score server. They are kept locally and sent with the next save.
+func warnIfUnsynced(storage scoring.ScoreStorage) {
+	if h, ok := storage.(*scoring.HTTPStorage); ok && h.SyncErr() != nil {
+		fmt.Fprintf(os.Stderr, "Warning: scores were saved locally but not synced: %v\n", h.SyncErr())
+	}
+}
+
 func noOp() tea.Msg {
 	return nil
 }
 
 func (s *LocalState) Init() tea.Cmd {
 	// Session initializes first game automatically
-	if s.Session.CurrentGame.State.TimerEnabled {
+	s.writeStatus()
+	if s.needsTick() {
+		s.ticking = true
 		return tickCmd()
 	}
 	return noOp
 }
 
+// needsTick reports whether the current game needs timer ticks (countdown,
+// preview, a line peek to hide or a ghost to move). No time passes at a chain prompt.
+func (s *LocalState) needsTick() bool {
+	if s.Session.ChainPrompt != nil {
+		return false
+	}
+	st := s.Session.CurrentGame.State
+	return st.TimerEnabled || st.IsPreviewing() || st.GhostPos() >= 0 || st.LinePeek > 0 || s.ShowElapsed
+}
+
 func (s *LocalState) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	currentGame := s.Session.CurrentGame
 
@@ -91,184 +430,464 @@ func (s *LocalState) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if s.Quitting {
 			return s, func() tea.Msg { return QuitMsg{} }
 		}
+		// No time passes while suspended; resuming starts the ticks again
+		if !s.suspendedAt.IsZero() {
+			s.ticking = false
+			return s, nil
+		}
+		// A finished game's result is showing; the next game starts the ticks again
+		if _, over := s.Session.Outcome(); over {
+			s.ticking = false
+			return s, nil
+		}
 		currentGame.HandleTick()
 		s.Session.Update() // Check for session loss or transition
-		if s.Session.IsSessionLoss() || s.Session.IsFinished() || currentGame.State.Win {
-			s.Quitting = true
-			return s, func() tea.Msg { return QuitMsg{} }
+		s.writeStatus()
+		if _, over := s.Session.Outcome(); over {
+			s.ticking = false
+			return s, s.afterGame()
+		}
+		if !s.needsTick() {
+			s.ticking = false
+			return s, nil
 		}
 		return s, tickCmd()
+	case AdvanceMsg:
+		if msg.Game != currentGame || s.Quitting {
+			return s, nil
+		}
+		return s, s.advance()
 	case tea.WindowSizeMsg:
-		// Resize logic should apply to current game
-		currentGame.State.Textarea.SetWidth(currentGame.State.CardWidth + 1)
-		lineCount := len(strings.Split(string(currentGame.State.Secret), "\n"))
-		currentGame.State.Textarea.SetHeight(lineCount)
+		s.TermWidth = msg.Width
+		s.resizeDisplay()
+	case tea.SuspendMsg:
+		s.suspend()
+	case tea.ResumeMsg:
+		return s, s.resume()
 	case tea.KeyMsg:
 		ch := msg.String()
 
 		// Handle exit request
 		if state.IsExitRequested(ch) {
+			// A game in progress ends through the game itself, which saves
+			// its score as abandoned. A card still behind its chain prompt
+			// hasn't been played.
+			if _, over := s.Session.Outcome(); !over && s.Session.ChainPrompt == nil {
+				currentGame.HandleKeyPress(ch)
+				s.Session.Update()
+			}
 			return s, tea.Quit
 		}
 
-		// Check if game over before processing?
-		if currentGame.State.Win || currentGame.State.Loss {
-			// If already over, maybe we are waiting to quit?
-			// Session update should have handled transitions.
-			// If we are here, maybe we are at the end of session?
-			// Note: With the loop refactor, we quit on any Win/Loss of the CURRENT game.
-			if s.Session.IsFinished() || s.Session.IsSessionLoss() || currentGame.State.Win {
-				s.Quitting = true
+		if ch == "ctrl+z" {
+			s.suspend()
+			return s, tea.Suspend
+		}
+
+		// A key skips the rest of the pause after a finished game
+		if _, over := s.Session.Outcome(); over {
+			if s.Quitting {
 				return s, func() tea.Msg { return QuitMsg{} }
 			}
+			return s, s.advance()
+		}
+
+		// The chain prompt takes the keys until it is answered
+		if s.Session.ChainPrompt != nil {
+			return s, s.answerChain(ch)
+		}
+
+		// ctrl+n skips the card in a batch, and does nothing for a single card
+		if ch == "ctrl+n" {
+			if !s.Session.CanSkip() {
+				return s, nil
+			}
+			return s, s.moveOn(s.Session.Skip)
 		}
 
 		currentGame.HandleKeyPress(ch)
 		s.Session.Update() // Check transitions
 
-		// Note: With loop refactor, we check for single game win too
-		if s.Session.IsSessionLoss() || s.Session.IsFinished() || currentGame.State.Win {
-			s.Quitting = true
-			return s, func() tea.Msg { return QuitMsg{} }
+		if _, over := s.Session.Outcome(); over {
+			return s, s.afterGame()
+		}
+		// A line peek needs ticks to hide it again, even without a timer
+		if currentGame.State.LinePeek > 0 && !s.ticking {
+			s.ticking = true
+			return s, tickCmd()
 		}
-
-		// If Session Update switched games (NextGame), View will handle rendering new game state.
 	}
 
 	return s, nil
 }
 
-func (s *LocalState) RenderBoard() string {
-	var b strings.Builder
-	// Render board for CURRENT game
-	g := s.Session.CurrentGame
-	mask := g.State.Mask
-	pos := g.State.Pos
-	bracketed := g.State.BracketedPositions
+// answerChain passes a key to the chain prompt. Once it is answered the
+// card's board is shown, with a notice of how the answer went, and its timer
+// starts.
+func (s *LocalState) answerChain(ch string) tea.Cmd {
+	word := s.Session.ChainPrompt.Word
+	answered, right := s.Session.ChainKey(ch)
+	if !answered {
+		return nil
+	}
+	points := s.Session.CurrentGame.State.Score.Points
+	notice := fmt.Sprintf("Chain: right, %+d", points("chainBonus"))
+	if !right {
+		notice = fmt.Sprintf("Chain: it was %q, %+d", word, points("chainMiss"))
+	}
+	s.Notice = strings.TrimPrefix(s.Notice+"; "+notice, "; ")
+	if s.needsTick() && !s.ticking {
+		s.ticking = true
+		return tickCmd()
+	}
+	return nil
+}
 
-	for i, r := range mask {
-		style := lipgloss.NewStyle()
+// suspend notes when the program was suspended, so that resuming knows how
+// long it was away.
+func (s *LocalState) suspend() {
+	if s.suspendedAt.IsZero() {
+		s.suspendedAt = time.Now()
+	}
+}
 
-		// Apply placeholder style (bold)
-		if slices.Contains(bracketed, i) {
-			style = style.Bold(true)
-		}
+// resume repaints the screen after a suspend and starts the ticks again. The
+// time spent suspended is ignored, unless the timer runs on the wall clock,
+// when each whole second of it is ticked off at once.
+func (s *LocalState) resume() tea.Cmd {
+	if s.suspendedAt.IsZero() {
+		return tea.ClearScreen
+	}
+	away := time.Since(s.suspendedAt)
+	s.suspendedAt = time.Time{}
 
-		// Apply persistent mistake style
-		if g.State.RevealedCharMistakes[i] {
-			style = style.Foreground(lipgloss.Color("9")).Underline(true)
+	if _, over := s.Session.Outcome(); s.Wallclock && !over {
+		for range int(away / time.Second) {
+			if !s.needsTick() {
+				break
+			}
+			s.Session.CurrentGame.HandleTick()
+			s.Session.Update()
+			if _, over := s.Session.Outcome(); over {
+				return tea.Batch(tea.ClearScreen, s.afterGame())
+			}
 		}
+	}
+	if s.needsTick() && !s.ticking {
+		s.ticking = true
+		return tea.Batch(tea.ClearScreen, tickCmd())
+	}
+	return tea.ClearScreen
+}
 
-		// Apply cursor style
-		if !g.State.Win && !g.State.Loss && i == pos {
-			if g.State.WrongLetter {
-				// If character is already revealed (visible), use Red Underline
-				if mask[i] != '_' {
-					style = style.Foreground(lipgloss.Color("9")).Underline(true)
-				} else {
-					// Red Block Cursor for hidden char
-					style = style.Background(lipgloss.Color("9"))
-				}
-			} else {
-				// Reverse video for normal cursor
-				style = style.Reverse(true)
-			}
+// afterGame decides what follows a game that has just ended. The session
+// ends straight away when there is nothing more to play; otherwise the
+// result stays up for a moment before the next card, or another attempt.
+func (s *LocalState) afterGame() tea.Cmd {
+	s.writeStatus()
+	if outcome, _ := s.Session.Outcome(); outcome != game.Continue && !s.replays() {
+		s.Quitting = true
+		return func() tea.Msg { return QuitMsg{} }
+	}
+
+	pause := nextCardPause
+	if s.replays() {
+		pause = loopPause
+	}
+	g := s.Session.CurrentGame
+	return tea.Tick(pause, func(time.Time) tea.Msg { return AdvanceMsg{Game: g} })
+}
+
+// replays reports whether the finished game is followed by another attempt
+// at the same card, in loop mode.
+func (s *LocalState) replays() bool {
+	if !s.Loop || s.Session.IsBatch || s.Session.IsVersus() {
+		return false
+	}
+	outcome, _ := s.Session.Outcome()
+	return outcome == game.SessionComplete && s.Session.CurrentGame.State.Win
+}
+
+// advance moves on from the finished game to the next card, or to another
+// attempt at the same card in loop mode, and starts its timer.
+func (s *LocalState) advance() tea.Cmd {
+	return s.moveOn(s.Session.AdvanceOrEnd)
+}
+
+// moveOn takes the session to its next game with step, which is
+// AdvanceOrEnd or Skip, and starts its timer.
+func (s *LocalState) moveOn(step func() (game.SessionOutcome, error)) tea.Cmd {
+	s.Notice = ""
+	var err error
+	if s.replays() {
+		err = s.Session.Replay()
+	} else {
+		if s.Watcher != nil && s.Watcher.Changed() {
+			s.Notice = reloadCards(s.Session, s.Watcher)
+		}
+		skipped := len(s.Session.Skipped)
+		var outcome game.SessionOutcome
+		outcome, err = step()
+		if notice := skippedNotice(s.Session.Skipped[skipped:]); notice != "" {
+			s.Notice = strings.TrimPrefix(s.Notice+"; "+notice, "; ")
+		}
+		if err == nil && outcome != game.Continue {
+			s.Quitting = true
+			return func() tea.Msg { return QuitMsg{} }
 		}
+	}
+	if err != nil {
+		s.Err = fmt.Errorf("error preparing next game: %w", err)
+		s.Quitting = true
+		return func() tea.Msg { return QuitMsg{} }
+	}
 
-		b.WriteString(style.Render(string(r)))
+	s.resizeDisplay()
+	s.writeStatus()
+	if s.needsTick() && !s.ticking {
+		s.ticking = true
+		return tickCmd()
 	}
-	return b.String()
+	return nil
 }
 
-func (s *LocalState) View() string {
-	g := s.Session.CurrentGame
+// writeStatus writes the session's progress to the status file, if there is
+// one. It is only a convenience, so a failure is noted once and otherwise
+// ignored.
+func (s *LocalState) writeStatus() {
+	if s.StatusFile == "" {
+		return
+	}
+	if err := s.Session.WriteStatus(s.StatusFile); err != nil && !s.statusFailed {
+		s.statusFailed = true
+		s.Notice = strings.TrimPrefix(s.Notice+"; Could not write the status file: "+err.Error(), "; ")
+	}
+}
 
-	// Determine which card to display.
-	// If session is finished, show the last card (the one just completed).
-	var card game.CardData
-	if s.Session.IsFinished() {
-		if len(s.Session.Cards) > 0 {
-			card = s.Session.Cards[len(s.Session.Cards)-1]
+// resizeDisplay fits the current game's textarea to its card.
+func (s *LocalState) resizeDisplay() {
+	st := s.Session.CurrentGame.State
+	if ta, ok := st.Display.(*state.TextareaDisplay); ok {
+		ta.SetWidth(st.CardWidth + 1)
+		lineCount := 1
+		for _, r := range st.Secret {
+			if r == '\n' {
+				lineCount++
+			}
 		}
-	} else {
-		card = s.Session.Cards[s.Session.CurrentIndex]
+		ta.SetHeight(lineCount)
 	}
+}
 
-	// 1. Render Banner
-	secretMessageStr := string(g.State.Secret)
+// RenderBoard renders the current game's board, soft-wrapped to width columns.
+// For a card played by line, the lines before and after the one being played
+// are drawn around it.
+func (s *LocalState) RenderBoard(width int) string {
+	before, after := s.Session.LineContext()
+	if before == nil && after == nil {
+		return s.renderGame(width)
+	}
 
-	smLongestLineLen := longestLineLen(secretMessageStr)
+	var rows []string
+	for _, line := range before {
+		rows = append(rows, ui.RenderBoard(ui.Board{Mask: []rune(line), Pos: -1, Theme: &s.Theme}, width))
+	}
+	rows = append(rows, s.renderGame(width))
+	for _, line := range after {
+		rows = append(rows, ui.RenderBoard(ui.Board{Mask: []rune(line), Pos: -1, Theme: &s.Theme}, width))
+	}
+	return strings.Join(rows, "\n")
+}
 
-	var textTitle string
-	if card.Title != "" {
-		textTitle = card.Title
-	} else {
-		fileExt := filepath.Ext(card.Source)
-		textTitle = titleCaseToTitle(filepath.Base(strings.TrimSuffix(card.Source, fileExt)))
-		if card.TotalParts > 1 {
-			textTitle = fmt.Sprintf("%s #%d", textTitle, card.PartIndex)
+// renderGame renders the board of the current game alone.
+func (s *LocalState) renderGame(width int) string {
+	st := s.Session.CurrentGame.State
+
+	// During the preview the whole text is shown unmasked
+	if st.IsPreviewing() {
+		return ui.RenderBoard(ui.Board{Mask: st.Secret, Pos: -1, Theme: &s.Theme}, width)
+	}
+
+	// A recall attempt is shown as typed, with the cursor after it
+	if st.Options.Recall && !st.Win {
+		typed := append(slices.Clone(st.RecallInput), ' ')
+		return ui.RenderBoard(ui.Board{Mask: typed, Pos: len(st.RecallInput), Theme: &s.Theme}, width)
+	}
+
+	board := ui.Board{
+		Mask:        st.Mask,
+		Secret:      st.Secret,
+		Pos:         st.Pos,
+		WrongLetter: st.WrongLetter,
+		Bracketed:   st.BracketedPositions,
+		Mistakes:    st.RevealedCharMistakes,
+		Trouble:     st.Trouble,
+		Theme:       &s.Theme,
+	}
+	if st.Win || st.Loss || st.Options.Flash {
+		board.Pos = -1
+	}
+	if ghost := st.GhostPos(); ghost > 0 {
+		board.Ghost = ghost
+	}
+	// A line peeked at with Ctrl+L is drawn in full, its hidden letters as hints
+	if st.LinePeek > 0 && !st.Win && !st.Loss {
+		board.Mask = slices.Clone(st.Mask)
+		board.Bracketed = slices.Clone(st.BracketedPositions)
+		start, end := st.LineSpan(st.Pos)
+		for i := start; i < end; i++ {
+			if board.Mask[i] == '_' {
+				board.Mask[i] = st.Secret[i]
+				board.Bracketed = append(board.Bracketed, i)
+			}
 		}
 	}
+	return ui.RenderBoard(board, width)
+}
 
-	bannerTxt := fmt.Sprintf("┃ CARD: %s | LOC: %s", textTitle, card.Source)
+// RenderSummary renders the per-card breakdown shown when a batch completes.
+func (s *LocalState) RenderSummary() string {
+	results := s.Session.Results
+	if len(results) == 0 {
+		return ""
+	}
 
-	cardWidth := smLongestLineLen + 1
-	if len(bannerTxt) > cardWidth {
-		cardWidth = len(bannerTxt) + 1
+	titleWidth := len("CARD")
+	for _, r := range results {
+		if len(r.Title) > titleWidth {
+			titleWidth = len(r.Title)
+		}
 	}
 
-	// Ensure banner padding matches
-	paddingNeeded := cardWidth - len(bannerTxt) + 4
-	if paddingNeeded < 0 {
-		paddingNeeded = 0
+	var b strings.Builder
+	b.WriteString("\n")
+	b.WriteString(s.Theme.Bold.Render(fmt.Sprintf("%-*s  %7s  %6s  %5s  %s", titleWidth, "CARD", "SCORE", "ERRORS", "HINTS", "HIGH")))
+	b.WriteString("\n")
+	for _, r := range results {
+		high := ""
+		if r.HighScore {
+			high = "*"
+		}
+		b.WriteString(fmt.Sprintf("%-*s  %7d  %6d  %5d  %s\n", titleWidth, r.Title, r.Score, r.Errors, r.Hints, high))
+	}
+	if s.Session.ChainScore != 0 {
+		b.WriteString(fmt.Sprintf("%-*s  %7d\n", titleWidth, "CHAIN", s.Session.ChainScore))
 	}
-	bannerTxt += strings.Repeat(" ", paddingNeeded) + "┃"
+	b.WriteString(fmt.Sprintf("%-*s  %7d  %6d\n", titleWidth, "TOTAL", s.Session.TotalScore, s.Session.TotalErrors()))
+	return b.String()
+}
 
-	borderBarThick := strings.Repeat("━", cardWidth+1)
-	bannerBorderTop := "┏" + borderBarThick + "┓"
+func (s *LocalState) View() string {
+	if s.Session.ChainPrompt != nil {
+		return s.renderChainPrompt()
+	}
+	g := s.Session.CurrentGame
 
-	bannerDisplay := bannerBorderTop + "\n" + bannerTxt
+	// Determine which card to display.
+	// If session is finished, show the last card (the one just completed).
+	cardIndex := s.Session.CurrentIndex
+	if s.Session.IsFinished() {
+		cardIndex = len(s.Session.Cards) - 1
+	}
+	var card game.CardData
+	if cardIndex >= 0 {
+		card = s.Session.Cards[cardIndex]
+	}
+
+	// 1. Size the card: the banner may widen it, but not past the terminal.
+	// A card played by line is sized by all of its lines.
+	secretMessageStr := string(g.State.Secret)
+	if s.Session.Lines != nil {
+		secretMessageStr = card.Content
+	}
+	textTitle := s.Session.TitleFor(cardIndex)
+	if s.Session.Mode != "" {
+		textTitle += " | Mode: " + s.Session.Mode
+	}
+	cardWidth := ui.ComputeCardWidth(secretMessageStr, ui.BannerText(textTitle, card.Source))
+	cardWidth = ui.FitCardWidth(cardWidth, s.TermWidth)
 
 	// Initial message / Previous attempts
 	// Shown before the board
 	var introMsg string
-	if g.State.Score.GetAttempts() > 0 {
+	if g.State.Options.Flash {
+		introMsg = "\nSpace reveals the next word, backspace hides the last one. Hide every word to finish.\n"
+	} else if g.State.Score.GetAttempts() > 0 {
 		introMsg = fmt.Sprintf("\nAttempt: %d | High score (this text): %d\n", g.State.Score.GetAttempts()+1, g.State.Score.GetHighScore().Score)
 	} else {
 		introMsg = "\nThis is your first try with this text! Good luck!\n"
 	}
 
-	// 2. Render Board
-	customBorder := lipgloss.ThickBorder()
-	customBorder.Top = "═"
-	customBorder.TopLeft = "┃"
-	customBorder.TopRight = "┃"
+	if g.State.Options.Recall && !g.State.Win && !g.State.IsPreviewing() {
+		introMsg += "Type the text from memory. Enter starts a new line, Ctrl+D hands it in.\n"
+	}
 
-	borderStyle := lipgloss.NewStyle().
-		Padding(0, 1).
-		Border(customBorder).
-		Width(cardWidth + 1) // Match manual header width
+	if s.Notice != "" {
+		introMsg = "\n" + s.Theme.Muted.Render(s.Notice) + introMsg
+	}
 
-	display := introMsg + "\n" + bannerDisplay + "\n" + borderStyle.Render(s.RenderBoard())
+	// 2. Render Banner and Board
+	display := introMsg + "\n" + ui.RenderCard(textTitle, card.Source, s.RenderBoard(cardWidth), cardWidth)
 
 	// 3. Status Line
-	displayScore := g.State.Score.CurrentScore
-	if displayScore < 0 {
-		displayScore = 0
+	displayScore := shownScore(g.State, s.TrueScore)
+
+	statusLine := ""
+	if s.Session.IsVersus() {
+		statusLine = "PLAYER: " + s.Session.PlayerName() + " | "
+	}
+	// The score turns red when one more wrong letter would lose the card
+	scoreStr := fmt.Sprintf("%d / ~%d", displayScore, s.Session.MaxScore())
+	if g.State.NearScoreFloor() {
+		scoreStr = s.Theme.Error.Render(scoreStr)
+	}
+	statusLine += "SCORE: " + scoreStr + " | " +
+		"HINTS: " + hintCount(g.State) + " | " +
+		"WORD HINTS: " + fmt.Sprint(g.State.Score.WordHintCount) + " | " +
+		"ERRORS: " + fmt.Sprint(g.State.Score.ErrorCount) + " | " +
+		fmt.Sprintf("ACC: %.0f%%", g.State.Score.Accuracy()) + " | " +
+		fmt.Sprintf("PROGRESS: %d%%", g.State.Progress())
+	if g.State.Options.ForgiveTypos {
+		statusLine += " | NEAR: " + fmt.Sprint(g.State.Score.NearMissCount)
+	}
+	if lead, ok := g.State.GhostLead(); ok {
+		statusLine += " | GHOST: " + ghostLead(lead)
 	}
 
-	statusLine := "SCORE: " + fmt.Sprint(displayScore) + " | " +
-		"HINTS: " + fmt.Sprint(g.State.Score.HintCount) + " | " +
-		"ERRORS: " + fmt.Sprint(g.State.Score.ErrorCount)
+	if s.Session.Lines != nil {
+		statusLine += fmt.Sprintf(" | LINE %d/%d", s.Session.Line+1, len(s.Session.Lines))
+	}
 
 	// Batch Mode Indicator
 	if s.Session.IsBatch {
 		statusLine += fmt.Sprintf(" | CARD %d/%d", s.Session.CurrentIndex+1, len(s.Session.Cards))
-		statusLine += fmt.Sprintf(" | TOTAL: %d", s.Session.TotalScore)
+		if s.Session.IsVersus() {
+			statusLine += fmt.Sprintf(" | TOTAL: %d", s.Session.PlayerTotals[s.Session.CurrentPlayer])
+		} else {
+			statusLine += fmt.Sprintf(" | TOTAL: %d", s.Session.TotalScore)
+		}
+	}
+
+	if g.State.Options.Flash {
+		statusLine = fmt.Sprintf("word %d/%d revealed", g.State.FlashRevealed, g.State.FlashWordCount())
+		if s.Session.IsBatch {
+			statusLine += fmt.Sprintf(" | CARD %d/%d", s.Session.CurrentIndex+1, len(s.Session.Cards))
+		}
+	}
+
+	if g.State.Options.Recall && !g.State.Win {
+		statusLine = fmt.Sprintf("WORDS TYPED: %d", len(strings.Fields(string(g.State.RecallInput))))
+		if s.Session.IsBatch {
+			statusLine += fmt.Sprintf(" | CARD %d/%d", s.Session.CurrentIndex+1, len(s.Session.Cards))
+		}
+	}
+
+	if g.State.Options.Layout != nil {
+		statusLine += " | LAYOUT: " + g.State.Options.Layout.Name
 	}
 
 	if g.State.TimerEnabled {
-		timeColor := lipgloss.Color("11")
+		timeStyle := s.Theme.Timer
 
 		totalLimit := float64(g.State.TimeLimit)
 		// If batch, we want "1/3 of ORIGINAL total time".
@@ -279,42 +898,68 @@ func (s *LocalState) View() string {
 
 		// Use Game TimeRemaining (which is synced to session)
 		if float64(g.State.TimeRemaining) <= totalLimit/3.0 {
-			timeColor = lipgloss.Color("9")
+			timeStyle = s.Theme.TimerWarning
 		}
 
-		timeStyle := lipgloss.NewStyle().Foreground(timeColor)
 		minutes := g.State.TimeRemaining / 60
 		seconds := g.State.TimeRemaining % 60
 		timeStr := fmt.Sprintf("%02d:%02d", minutes, seconds)
-		statusLine += " | TIME: " + timeStyle.Render(timeStr)
+		if g.State.GraceRemaining > 0 {
+			statusLine += fmt.Sprintf(" | TIME starts in %d…", g.State.GraceRemaining)
+		} else {
+			statusLine += " | TIME: " + timeStyle.Render(timeStr)
+		}
+		if g.State.Options.WPMTarget > 0 {
+			statusLine += fmt.Sprintf(" | TARGET: %d WPM", g.State.Options.WPMTarget)
+		}
 	}
 
-	display += "\n" + scoreStyle.Render(statusLine+"\n")
+	if s.ShowElapsed {
+		elapsed := int(s.Session.Elapsed().Seconds())
+		statusLine += fmt.Sprintf(" | ELAPSED: %02d:%02d", elapsed/60, elapsed%60)
+	}
+
+	if g.State.IsPreviewing() {
+		statusLine += fmt.Sprintf(" | PREVIEW: %ds (press any key to start)", g.State.PreviewRemaining)
+	}
+
+	display += "\n" + s.Theme.Score.Render(statusLine+"\n")
+	if s.Session.IsBatch && !s.Session.IsVersus() {
+		display += s.renderBatchProgress(cardWidth) + "\n"
+	}
+	display += s.renderPeek(cardIndex)
 
 	// Final Messages (Loss/Win)
 	if g.State.Loss {
-		finalScore := g.State.Score.CurrentScore
-		if finalScore < 0 {
-			finalScore = 0
-		}
-		scoreStr := fmt.Sprintf("Final score: %d", finalScore)
+		finalScore := shownScore(g.State, s.TrueScore)
+		scoreStr := fmt.Sprintf("Final score: %d (%s)", finalScore, scoreBreakdown(g))
 
 		if g.State.Revealed {
-			display += "\n" + redStyle.Render("Card revealed with CTRL-R! "+scoreStr) + "\n"
+			display += "\n" + s.Theme.Error.Render("Card revealed with CTRL-R! "+scoreStr) + "\n"
 		} else if g.State.TimerEnabled && g.State.TimeRemaining <= 0 {
-			display += "\n" + redStyle.Render("Time's up! "+scoreStr) + "\n"
+			display += "\n" + s.Theme.Error.Render("Time's up! "+scoreStr) + "\n"
 		} else {
-			display += "\n" + redStyle.Render("Game over! "+scoreStr) + "\n"
+			display += "\n" + s.Theme.Error.Render("Game over! "+scoreStr) + "\n"
 		}
+
+		if outcome, _ := s.Session.Outcome(); outcome == game.SessionLost && s.Session.IsBatch {
+			display += s.Theme.Error.Render(fmt.Sprintf("Batch ended on card %d/%d. Total Score: %d", s.Session.CurrentIndex+1, len(s.Session.Cards), s.Session.TotalScore)) + "\n"
+		}
+	} else if g.State.Win && g.State.Options.Flash {
+		display += "\n" + s.Theme.Correct.Render("Every word has been revealed and hidden again. Ready to recall it?") + "\n"
 	} else if g.State.Win {
-		// Use IsLastGame for the final batch message
-		if s.Session.IsLastGame() {
+		if outcome, _ := s.Session.Outcome(); outcome == game.SessionComplete && !s.Session.IsVersus() {
 			if s.Session.IsBatch {
-				display += "\n" + greenStyle.Render(fmt.Sprintf("Batch Complete! Total Score: %d", s.Session.TotalScore)) + "\n"
+				display += "\n" + s.Theme.Correct.Render(fmt.Sprintf("Batch Complete! Total Score: %d", s.Session.TotalScore)) + "\n"
+				display += s.RenderSummary()
 			} else {
-				display += "\n" + greenStyle.Render(fmt.Sprintf("Congratulations! Final score: %d", g.State.Score.CurrentScore)) + "\n"
+				display += "\n" + s.Theme.Correct.Render(fmt.Sprintf("Congratulations! Final score: %d (%s)", g.State.Score.CurrentScore, scoreBreakdown(g))) + "\n"
 				if g.State.Score.GotHighScore() {
-					display += "\nYou got a high score!"
+					if g.State.Score.TiedHighScore() {
+						display += "\nMatched your best!"
+					} else {
+						display += "\nNew record!"
+					}
 					numPrevious := g.State.Score.GetNumPrevious()
 					if numPrevious > 0 {
 						if numPrevious <= 5 {
@@ -325,65 +970,210 @@ func (s *LocalState) View() string {
 						topScores := g.State.Score.GetNScoreEntries(5)
 						for _, entry := range topScores {
 							display += fmt.Sprintf("\n  * %d on %s", entry.Score, entry.Timestamp)
+							if entry.Accuracy > 0 {
+								display += fmt.Sprintf(" (%.0f%% accuracy)", entry.Accuracy)
+							}
 						}
 					}
 					display += "\n"
+				} else if g.State.Score.Accuracy() < g.State.Score.MinAccuracy {
+					display += "\n" + s.Theme.Muted.Render(fmt.Sprintf("Below %.0f%% accuracy, so not a high score.", g.State.Score.MinAccuracy)) + "\n"
 				}
 			}
 		} else {
 			// Intermediate card in batch
-			display += "\n" + greenStyle.Render(fmt.Sprintf("Congratulations! Card Score: %d", g.State.Score.CurrentScore)) + "\n"
+			display += "\n" + s.Theme.Correct.Render(fmt.Sprintf("Congratulations! Card Score: %d (%s)", g.State.Score.CurrentScore, scoreBreakdown(g))) + "\n"
 		}
+
+		display += renderSlowestWords(g)
+
+		if s.Loop && !s.Session.IsBatch && !s.Session.IsVersus() {
+			display += "\n" + s.Theme.Muted.Render("Starting another attempt... press Ctrl+C to stop.") + "\n"
+		}
+	}
+
+	if s.Session.IsVersus() && (g.State.Win || g.State.Loss) {
+		display += s.RenderVersus()
+	}
+
+	if outcome, over := s.Session.Outcome(); over && outcome == game.Continue && !s.Quitting {
+		display += "\n" + s.Theme.Muted.Render("Continuing in a moment... press any key to go on now.") + "\n"
 	}
 
 	return display
 }
 
-func capitalize(word string) string {
-	if len(word) == 0 {
-		return word
+// renderChainPrompt asks for the first word of the next card, in place of its
+// board, in chain mode.
+func (s *LocalState) renderChainPrompt() string {
+	p := s.Session.ChainPrompt
+	display := "\n"
+	if s.Notice != "" {
+		display += s.Theme.Muted.Render(s.Notice) + "\n"
+	}
+	display += fmt.Sprintf("\nCard %d/%d is next. What is its first word?\n", s.Session.CurrentIndex+1, len(s.Session.Cards))
+	if p.Missed {
+		display += s.Theme.Error.Render("Not quite, one more try.") + "\n"
+	}
+	display += "\n> " + p.Typed + "_\n"
+	display += "\n" + s.Theme.Muted.Render("Enter or space hands it in.") + "\n"
+	return display
+}
+
+// renderBatchProgress renders the cards played so far in a batch as a bar as
+// wide as the card together with how long the rest should take.
+func (s *LocalState) renderBatchProgress(width int) string {
+	done, total := s.Session.CardProgress()
+	label := fmt.Sprintf(" %d/%d cards | ETA: ", done, total)
+	if eta, ok := s.Session.ETA(s.Session.Now()); ok {
+		secs := int(eta.Round(time.Second).Seconds())
+		label += fmt.Sprintf("%02d:%02d", secs/60, secs%60)
+	} else {
+		label += "—"
+	}
+	bar := ui.RenderProgressBar(done, total, width-ui.LongestLineLen(label))
+	return s.Theme.Muted.Render(bar + label)
+}
+
+// renderPeek shows the title of the next card in a batch, and how many follow it.
+func (s *LocalState) renderPeek(cardIndex int) string {
+	if !s.Session.IsBatch || s.NoPeek {
+		return ""
+	}
+	next := cardIndex + 1
+	if next >= len(s.Session.Cards) {
+		return ""
 	}
-	return strings.ToUpper(string(word[0])) + word[1:]
+	peek := "Next: " + s.Session.TitleFor(next)
+	if more := len(s.Session.Cards) - next - 1; more > 0 {
+		peek += fmt.Sprintf(" (and %d more)", more)
+	}
+	return s.Theme.Muted.Render(peek) + "\n"
 }
 
-func titleCaseToTitle(input string) string {
-	var result strings.Builder
-	lastCharType := 0 // 0: none, 1: letter, 2: digit
+// RenderVersus renders the player comparison shown after the last turn on a
+// card, and the overall standings once the whole session is over.
+func (s *LocalState) RenderVersus() string {
+	sess := s.Session
+	if !sess.IsLastTurnOfCard() {
+		return "\n" + s.Theme.Bold.Render("Next up: "+sess.NextPlayerName()) + "\n"
+	}
 
-	for i, r := range input {
-		currentCharType := 0
-		if unicode.IsUpper(r) {
-			currentCharType = 1
-		} else if unicode.IsLower(r) {
-			currentCharType = 1
-		} else if unicode.IsDigit(r) {
-			currentCharType = 2
+	var b strings.Builder
+	b.WriteString("\n" + s.Theme.Bold.Render("This card:") + "\n")
+	for _, name := range sess.Players {
+		score := "-"
+		for _, r := range sess.Results {
+			if r.Index == sess.CurrentIndex && r.Player == name {
+				score = fmt.Sprint(r.Score)
+			}
 		}
+		b.WriteString(fmt.Sprintf("  %s: %s\n", name, score))
+	}
 
-		if i > 0 && ((lastCharType == 1 && currentCharType == 2) || (lastCharType == 2 && currentCharType == 1) || (unicode.IsUpper(r) && unicode.IsLower(rune(input[i-1])))) {
-			result.WriteRune(' ')
+	if outcome, _ := sess.Outcome(); outcome != game.SessionComplete {
+		return b.String()
+	}
+
+	b.WriteString("\n" + s.Theme.Bold.Render("Final standings:") + "\n")
+	best := 0
+	for i, name := range sess.Players {
+		b.WriteString(fmt.Sprintf("  %s: %d\n", name, sess.PlayerTotals[i]))
+		if sess.PlayerTotals[i] > sess.PlayerTotals[best] {
+			best = i
+		}
+	}
+	tie := false
+	for i, total := range sess.PlayerTotals {
+		if i != best && total == sess.PlayerTotals[best] {
+			tie = true
 		}
-		result.WriteRune(r)
-		lastCharType = currentCharType
 	}
+	if tie {
+		b.WriteString(s.Theme.Correct.Render("It's a tie!") + "\n")
+	} else {
+		b.WriteString(s.Theme.Correct.Render(sess.Players[best]+" wins!") + "\n")
+	}
+	return b.String()
+}
+
+// reloadCards loads the watched card files again and swaps in the cards still
+// to come, returning a notice for the next card.
+func reloadCards(session *game.Session, watcher *game.CardWatcher) string {
+	cards, err := watcher.Load()
+	if err != nil {
+		return fmt.Sprintf("cards not reloaded: %v", err)
+	}
+	changed, err := session.Reload(cards)
+	if err != nil {
+		return fmt.Sprintf("cards reloaded (%d changed), time limit not updated: %v", changed, err)
+	}
+	return fmt.Sprintf("cards reloaded (%d changed)", changed)
+}
+
+// skippedNotice returns a notice for cards skipped for the daily attempt
+// limit, or "" if there were none.
+func skippedNotice(titles []string) string {
+	if len(titles) == 0 {
+		return ""
+	}
+	return "skipped, already played too often today: " + strings.Join(titles, ", ")
+}
 
-	// Capitalize each word
-	words := strings.Fields(result.String())
-	for i, word := range words {
-		words[i] = capitalize(word)
+// hintCount describes the letter hints taken in a game, or how many are left
+// when they are limited, e.g. "2/5 left".
+func hintCount(st *state.State) string {
+	if st.Options.LimitHints {
+		return fmt.Sprintf("%d/%d left", st.HintsLeft, st.Options.MaxHints)
 	}
+	return fmt.Sprint(st.Score.HintCount)
+}
 
-	return strings.Join(words, " ")
+// ghostLead describes how far ahead of the ghost a game is, e.g. "+2.3s ahead".
+func ghostLead(lead time.Duration) string {
+	if lead < 0 {
+		return fmt.Sprintf("−%.1fs behind", -lead.Seconds())
+	}
+	return fmt.Sprintf("+%.1fs ahead", lead.Seconds())
 }
 
-func longestLineLen(s string) int {
-	maxLength := 0
-	for _, line := range strings.Split(s, "\n") {
-		if len(line) > maxLength {
-			maxLength = len(line)
-		}
+// shownScore returns the score to show for a game, which is never below the
+// score floor it is lost at unless trueScore is set.
+func shownScore(st *state.State, trueScore bool) int {
+	if st.Options.NoScoreFloor || trueScore {
+		return st.Score.CurrentScore
 	}
-	return maxLength
+	return max(st.Score.CurrentScore, st.Options.ScoreFloor)
+}
+
+// scoreBreakdown describes how a card was played, for the final score line.
+func scoreBreakdown(g *game.Game) string {
+	breakdown := fmt.Sprintf("accuracy %.0f%%", g.State.Score.Accuracy())
+	if g.State.Options.Recall {
+		grade := g.State.RecallGrade
+		breakdown = fmt.Sprintf("%.0f%% recalled, %d word edits", grade.Similarity()*100, grade.Edits)
+	}
+	if g.State.Win {
+		breakdown += fmt.Sprintf(", %.0f%% of max", g.State.Score.PercentOfMax(g.State.MaxScore()))
+	}
+	if g.State.Options.ForgiveTypos {
+		breakdown += fmt.Sprintf(", %d near misses", g.State.Score.NearMissCount)
+	}
+	return breakdown
+}
+
+// renderSlowestWords lists the three words that took the longest to complete.
+func renderSlowestWords(g *game.Game) string {
+	slowest := g.State.SlowestWords(3)
+	if len(slowest) == 0 {
+		return ""
+	}
+
+	var parts []string
+	for _, w := range slowest {
+		parts = append(parts, fmt.Sprintf("%s (%.1fs)", w.Word, float64(w.DurationMs)/1000))
+	}
+	return "\nSlowest words: " + strings.Join(parts, ", ") + "\n"
 }
 
 type timerFlag int
@@ -427,6 +1217,58 @@ func (t *timerFlag) Set(s string) error {
 
 func (t *timerFlag) IsBoolFlag() bool { return true }
 
+type previewFlag int
+
+func (p *previewFlag) String() string {
+	return fmt.Sprint(int(*p))
+}
+
+func (p *previewFlag) Set(s string) error {
+	if s == "true" {
+		*p = 10 // Default preview length
+		return nil
+	}
+	if s == "false" {
+		*p = 0
+		return nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 0 {
+		return fmt.Errorf("invalid preview length: %s (use seconds)", s)
+	}
+	*p = previewFlag(v)
+	return nil
+}
+
+func (p *previewFlag) IsBoolFlag() bool { return true }
+
+// scoreFloorFlag is the --score-floor value: a score of 0 or below, or
+// "none" for no floor at all.
+type scoreFloorFlag struct {
+	floor int
+	none  bool
+}
+
+func (f *scoreFloorFlag) String() string {
+	if f.none {
+		return "none"
+	}
+	return fmt.Sprint(f.floor)
+}
+
+func (f *scoreFloorFlag) Set(s string) error {
+	if s == "none" {
+		*f = scoreFloorFlag{none: true}
+		return nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v > 0 {
+		return fmt.Errorf("invalid score floor: %s (use 0 or below, or none)", s)
+	}
+	*f = scoreFloorFlag{floor: v}
+	return nil
+}
+
 type strictIntFlag int
 
 func (i *strictIntFlag) String() string {
@@ -447,14 +1289,90 @@ func (i *strictIntFlag) Set(s string) error {
 
 func (i *strictIntFlag) IsBoolFlag() bool { return true }
 
+// listFlag collects the values of a flag that may be given more than once.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
 func main() {
 	// defaults
 	var tFlag timerFlag = -1 // Default to auto
 	var noTimer bool
+	var cpm int
+	var wpmTarget int
+	var grace int
+	var wallclockTimer bool
+	var showElapsed bool
+	var seed int64
+	var profile string
+	var encryptScores bool
+	var readOnly bool
+	var eventsPath string
+	var statusFile string
+	var recordPath string
+	var replayPath string
+	var replaySpeed float64
+	var storageKind string
+	var storageURL string
+	var webhookURL string
+	var webhookSecret string
 	var firstLetter bool
+	var lastLetter bool
 	var nRandom strictIntFlag
+	var adaptive bool
 	var nWords strictIntFlag
+	var cloze strictIntFlag
+	var legacyWordSplit bool
+	var ghost bool
+	var noConfidence bool
 	var randomCards bool
+	var reverse bool
+	var sortKey string
+	var preview previewFlag
+	var strictSymbols bool
+	var hideSpaces bool
+	var requirePunctuation bool
+	var requireEnter bool
+	var byLine bool
+	var layoutSpec string
+	var themeName string
+	var flash bool
+	var mode string
+	var lenient bool
+	var forgiveTypos bool
+	var numberLeniency bool
+	var lenientDigits bool
+	var minAccuracy float64
+	var scoreFloor scoreFloorFlag
+	var trueScore bool
+	var maxAttemptsPerDay int
+	var maxHints int
+	var hintStrategy string
+	var autoHint int
+	var mixedModes bool
+	var typeThrough bool
+	var noPeek bool
+	var drillMistakes bool
+	var chain bool
+	var watch bool
+	var loop bool
+	var format string
+	var separator string
+	var markdown bool
+	var normalizeUnicode bool
+	var tags listFlag
+	var anyTags listFlag
+	var headless bool
+	var versus bool
+	var players string
+	var input string
 	var showUpdate bool
 	var showRemove bool
 
@@ -462,22 +1380,101 @@ func main() {
 	flag.Var(&tFlag, "timer", "Set countdown timer (e.g. 30 or 1:30). Default is auto based on length.")
 	flag.Var(&tFlag, "t", "Set countdown timer (shorthand)")
 
+	flag.IntVar(&cpm, "cpm", -1, "Typing rate in characters per minute that the auto timer allows for (default 180)")
+	flag.IntVar(&wpmTarget, "wpm-target", 0, "Set the auto timer so that finishing in time means typing N words per minute")
 	flag.BoolVar(&noTimer, "notimer", false, "Disable the timer")
 	flag.BoolVar(&noTimer, "nt", false, "Disable the timer (shorthand)")
+	flag.IntVar(&grace, "grace", 0, "Seconds before the timer starts on each card, ended early by the first correct letter")
+	flag.BoolVar(&wallclockTimer, "wallclock-timer", false, "Keep the timer running while suspended with Ctrl+Z")
+	flag.BoolVar(&showElapsed, "show-elapsed", false, "Show the time since the session started in the status line")
 
 	// Game mode flags
 	flag.BoolVar(&firstLetter, "first-letter", false, "Reveal the first letter of each word")
 	flag.BoolVar(&firstLetter, "fl", false, "Reveal the first letter of each word (shorthand)")
+	flag.BoolVar(&lastLetter, "last-letter", false, "Reveal the last letter of each word")
+	flag.BoolVar(&lastLetter, "ll", false, "Reveal the last letter of each word (shorthand)")
 
 	flag.Var(&nRandom, "n-random", "Reveal N random letters")
 	flag.Var(&nRandom, "nr", "Reveal N random letters (shorthand)")
 
+	flag.BoolVar(&mixedModes, "mixed-modes", false, "Give each card a random assist: first letters, random letters or none")
+
+	flag.BoolVar(&adaptive, "adaptive", false, "Reveal fewer random letters after strong scores, more after losses")
+
 	flag.Var(&nWords, "n-words", "Reveal N random words")
 	flag.Var(&nWords, "nfw", "Reveal N random words (shorthand)")
+	flag.Var(&cloze, "cloze", "Reveal everything but N random words")
+	flag.BoolVar(&legacyWordSplit, "legacy-word-split", false, "Split words at apostrophes, as older versions did")
+
+	flag.BoolVar(&strictSymbols, "strict-symbols", false, "Mask punctuation too, so every symbol must be typed (hint with ctrl+h)")
+	flag.BoolVar(&hideSpaces, "hide-spaces", false, "Mask spaces too, so they must be typed")
+	flag.BoolVar(&requirePunctuation, "require-punctuation", false, "Mask sentence punctuation (.,!?;:) too, so it must be typed")
+	flag.BoolVar(&requireEnter, "require-enter", false, "Mask line breaks too, so Enter must be pressed at the end of each line")
+	flag.BoolVar(&byLine, "by-line", false, "Play each card a line at a time, keeping the lines done in view")
+	flag.StringVar(&layoutSpec, "layout", "", "Translate keys: qwerty-to-colemak, qwerty-to-dvorak or file:<path>")
+
+	flag.BoolVar(&lenient, "lenient", false, "Wrong letters cost points but don't need correcting")
+	flag.BoolVar(&forgiveTypos, "forgive-typos", false, "A key next to the right one costs less and isn't counted as a mistake")
+	flag.BoolVar(&lenientDigits, "lenient-digits", false, "Give digits away like punctuation, so numbers needn't be typed")
+	flag.BoolVar(&numberLeniency, "number-leniency", false, "Accept a single digit spelled out, e.g. \"two\" for 2")
+	flag.Var(&scoreFloor, "score-floor", "Lose the game when the score drops below this (0 or less), or none to never lose for a low score")
+	flag.Var(&scoreFloor, "loss-threshold", "Lose the game when the score drops below this (same as --score-floor)")
+	flag.BoolVar(&trueScore, "true-score", false, "Show the score as it is, even below the score floor")
+	flag.BoolVar(&ghost, "ghost", false, "Race a marker showing where your best previous attempt was at the same time")
+	flag.BoolVar(&noConfidence, "no-confidence", false, "Don't shade the words you mistyped in earlier attempts")
+	flag.Float64Var(&minAccuracy, "min-accuracy", 0, "Accuracy percentage an attempt needs to count as a high score")
+	flag.IntVar(&maxAttemptsPerDay, "max-attempts-per-day", 0, "Don't play a card more than N times a day; batches skip it (default: no limit)")
+	flag.IntVar(&maxHints, "max-hints", 0, "Allow only N letter hints per card, 0 for none (default: no limit)")
+	flag.StringVar(&hintStrategy, "hint-strategy", state.HintNext, "Which letter of the word a hint reveals: next, rare or consonant")
+	flag.IntVar(&autoHint, "auto-hint", 0, "Reveal the next character after N seconds without a key, for less than a hint (timed games only)")
+	flag.BoolVar(&typeThrough, "strict-typethrough", true, "Letters just revealed before the cursor can be typed over without being checked")
+	flag.BoolVar(&flash, "flash", false, "Study mode: space reveals the next word, backspace hides the last one")
+	flag.StringVar(&mode, "mode", "type", "Game type: type, or recall to type the whole text from memory and have it graded")
+
+	flag.Var(&preview, "preview", "Show the full text for N seconds (default 10) before it is masked")
+
+	flag.Int64Var(&seed, "seed", -1, "Seed for random reveals and card order, to replay a session (default: time-based)")
 
 	flag.BoolVar(&randomCards, "random-cards", false, "Randomize presentation order of cards")
 	flag.BoolVar(&randomCards, "rc", false, "Randomize presentation order of cards (shorthand)")
 	flag.BoolVar(&randomCards, "random", false, "Randomize presentation order of cards (shorthand)")
+	flag.BoolVar(&reverse, "reverse", false, "Present cards in reverse order")
+	flag.StringVar(&sortKey, "sort", "", "Sort cards by title, length or source")
+	flag.BoolVar(&noPeek, "no-peek", false, "Don't show the next card's title in batch mode")
+	flag.StringVar(&themeName, "theme", "default", "Colors: default, high-contrast or colorblind")
+	flag.BoolVar(&drillMistakes, "drill-mistakes", false, "After a card won with errors, replay just the mistyped parts")
+	flag.BoolVar(&chain, "chain", false, "Before each card after the first, ask for its first word from memory")
+	flag.BoolVar(&watch, "watch", false, "Reload the card files between cards when they change")
+	flag.BoolVar(&loop, "loop", false, "Play a single card again after each win, until Ctrl+C")
+
+	flag.StringVar(&format, "format", "", "Card file format: text, anki-tsv, json or csv (default: detect by extension)")
+	flag.Var(&tags, "tag", "Only play cards with this tag (repeatable, all must match)")
+	flag.Var(&anyTags, "any-tag", "Only play cards with at least one of these tags (repeatable)")
+	flag.StringVar(&separator, "separator", "", "Regex for the line that separates cards in a file (default: three or more dashes)")
+	flag.BoolVar(&markdown, "markdown", false, "Also start a card at each ## heading, titled with the heading")
+	flag.BoolVar(&normalizeUnicode, "normalize-unicode", false, "Rewrite typographic quotes, dashes and ellipses in cards as ASCII")
+	flag.BoolVar(&normalizeUnicode, "normalize-typography", false, "Rewrite typographic quotes, dashes and ellipses in cards as ASCII (same as --normalize-unicode)")
+
+	// Versus flags
+	flag.BoolVar(&versus, "versus", false, "Hot-seat mode: each card is played by every player in turn")
+	flag.StringVar(&players, "players", "Player 1,Player 2", "Comma-separated player names for versus mode")
+
+	flag.StringVar(&profile, "profile", "", "Keep scores in a separate history for this profile")
+	flag.StringVar(&storageKind, "storage", "file", "Where scores are kept: file, or http to sync them with a score server")
+	flag.StringVar(&storageURL, "storage-url", "", "URL of the score server for --storage=http")
+	flag.StringVar(&webhookURL, "webhook", "", "POST a JSON summary of the session to this URL when it ends")
+	flag.StringVar(&webhookSecret, "webhook-secret", "", "Sign the --webhook summary with an HMAC-SHA256 keyed with this secret")
+	flag.BoolVar(&encryptScores, "encrypt-scores", false, "Keep the score history encrypted with a passphrase (GOMEM_PASSPHRASE or prompted)")
+	flag.BoolVar(&readOnly, "read-only", false, "Show previous scores but never write to the score history")
+
+	// Headless flags
+	flag.StringVar(&statusFile, "status-file", "", "Keep the session's progress in this file as JSON, for status bars")
+	flag.StringVar(&eventsPath, "events", "", "Write game events as JSON lines to this file, or - for stderr")
+	flag.BoolVar(&headless, "headless", false, "Play a card non-interactively and print the result as JSON")
+	flag.StringVar(&input, "input", "", "Text to type in headless mode")
+	flag.StringVar(&recordPath, "record", "", "Write every key pressed to this file, to play back with --replay")
+	flag.StringVar(&replayPath, "replay", "", "Play back a --record file against its cards and check the scores match")
+	flag.Float64Var(&replaySpeed, "replay-speed", 0, "Replay at this multiple of the recorded pace, 0 for as fast as possible")
 
 	// Meta flags
 	flag.BoolVar(&showUpdate, "update", false, "Show update instructions")
@@ -487,13 +1484,83 @@ func main() {
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [options] <path-to-file> [more files...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [--profile=NAME] migrate-encrypt\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [--profile=NAME] clear [--yes] [--hash=HASH]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [options] stale [--days=N] [--exec] <file|dir>...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [--profile=NAME] stats <file|dir>...\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "\nOptions:\n")
 		fmt.Fprintf(os.Stderr, "    -t, --timer[=value]    Set countdown timer (e.g. 30 or 1:30). Default is auto based on length.\n")
+		fmt.Fprintf(os.Stderr, "        --cpm=N            Characters per minute the auto timer allows for new cards (default 180)\n")
+		fmt.Fprintf(os.Stderr, "        --wpm-target=N     Set the auto timer to a goal speed of N words per minute\n")
 		fmt.Fprintf(os.Stderr, "   -nt, --notimer          Disable the timer\n")
+		fmt.Fprintf(os.Stderr, "        --grace=N          Start each card's timer after N seconds, or at the first correct letter\n")
+		fmt.Fprintf(os.Stderr, "        --wallclock-timer  Keep the timer running while suspended with Ctrl+Z\n")
+		fmt.Fprintf(os.Stderr, "        --show-elapsed     Show the time since the session started in the status line\n")
 		fmt.Fprintf(os.Stderr, "   -fl, --first-letter     Reveal the first letter of each word\n")
+		fmt.Fprintf(os.Stderr, "   -ll, --last-letter      Reveal the last letter of each word\n")
 		fmt.Fprintf(os.Stderr, "   -nr, --n-random=N       Reveal N random letters\n")
+		fmt.Fprintf(os.Stderr, "        --mixed-modes      Give each card a random assist: first letters, random letters or none\n")
+		fmt.Fprintf(os.Stderr, "        --adaptive         Reveal fewer random letters after strong scores, more after losses\n")
 		fmt.Fprintf(os.Stderr, "  -nfw, --n-words=N        Reveal N random words\n")
+		fmt.Fprintf(os.Stderr, "        --cloze=N          Hide only N random words, showing the rest as context\n")
+		fmt.Fprintf(os.Stderr, "        --legacy-word-split  Split words at apostrophes (don't is don + t)\n")
+		fmt.Fprintf(os.Stderr, "        --strict-symbols   Mask punctuation too; use ctrl+h for hints\n")
+		fmt.Fprintf(os.Stderr, "        --hide-spaces      Mask spaces too, so word lengths aren't given away\n")
+		fmt.Fprintf(os.Stderr, "        --require-punctuation  Mask .,!?;: too, so they must be typed\n")
+		fmt.Fprintf(os.Stderr, "        --require-enter    Press Enter at the end of each line to move on to the next\n")
+		fmt.Fprintf(os.Stderr, "        --by-line          Play each card a line at a time, keeping the lines done in view\n")
+		fmt.Fprintf(os.Stderr, "        --layout=SPEC      Translate keys: qwerty-to-colemak, qwerty-to-dvorak or file:<path>\n")
+		fmt.Fprintf(os.Stderr, "        --lenient          Wrong letters cost points but are revealed instead of blocking\n")
+		fmt.Fprintf(os.Stderr, "        --forgive-typos    Keys next to the right one cost less and aren't mistakes\n")
+		fmt.Fprintf(os.Stderr, "        --lenient-digits   Give digits away like punctuation, so numbers needn't be typed\n")
+		fmt.Fprintf(os.Stderr, "        --number-leniency  Accept a single digit spelled out, e.g. \"two\" for 2\n")
+		fmt.Fprintf(os.Stderr, "        --score-floor=N    Lose when the score drops below N (default 0), or none to never lose for it\n")
+		fmt.Fprintf(os.Stderr, "        --loss-threshold=N Same as --score-floor\n")
+		fmt.Fprintf(os.Stderr, "        --true-score       Show the score as it is, even below the score floor\n")
+		fmt.Fprintf(os.Stderr, "        --ghost            Race your best previous attempt at the card\n")
+		fmt.Fprintf(os.Stderr, "        --no-confidence    Don't shade the words you mistyped in earlier attempts\n")
+		fmt.Fprintf(os.Stderr, "        --min-accuracy=N   Only count attempts at least N%% accurate as high scores\n")
+		fmt.Fprintf(os.Stderr, "        --max-attempts-per-day=N  Refuse, or skip in a batch, cards played N times today\n")
+		fmt.Fprintf(os.Stderr, "        --max-hints=N      Allow only N letter hints per card, 0 for none\n")
+		fmt.Fprintf(os.Stderr, "        --hint-strategy=S  Which letter of the word a hint reveals: next, rare or consonant\n")
+		fmt.Fprintf(os.Stderr, "        --auto-hint=N      Reveal the next character after N idle seconds (timed games only)\n")
+		fmt.Fprintf(os.Stderr, "        --strict-typethrough=false  Check every key against the next character, even after a reveal\n")
+		fmt.Fprintf(os.Stderr, "        --flash            Study mode: space reveals words, backspace hides them (no scoring)\n")
+		fmt.Fprintf(os.Stderr, "        --mode=recall      Type the whole text from memory after a preview, graded by word\n")
+		fmt.Fprintf(os.Stderr, "        --preview[=N]      Show the full text for N seconds (default 10) before masking\n")
 		fmt.Fprintf(os.Stderr, "   -rc, --random-cards     Randomize order of cards (Batch Mode only)\n")
+		fmt.Fprintf(os.Stderr, "        --seed=N           Seed for random reveals and card order, to replay a session\n")
+		fmt.Fprintf(os.Stderr, "        --reverse          Present cards last to first (Batch Mode only)\n")
+		fmt.Fprintf(os.Stderr, "        --sort=KEY         Sort cards by title, length or source (Batch Mode only)\n")
+		fmt.Fprintf(os.Stderr, "        --no-peek          Hide the upcoming card titles (Batch Mode only)\n")
+		fmt.Fprintf(os.Stderr, "        --theme=NAME       Colors: default, high-contrast or colorblind\n")
+		fmt.Fprintf(os.Stderr, "        --drill-mistakes   Replay the mistyped parts of a card right after it\n")
+		fmt.Fprintf(os.Stderr, "        --chain            Recall the first word of each next card before it is shown\n")
+		fmt.Fprintf(os.Stderr, "        --watch            Pick up edits to the card files between cards\n")
+		fmt.Fprintf(os.Stderr, "        --loop             Replay a single card after each win until Ctrl+C\n")
+		fmt.Fprintf(os.Stderr, "        --format=FORMAT    Card file format: text, anki-tsv, json or csv (default: by extension)\n")
+		fmt.Fprintf(os.Stderr, "        --tag=TAG          Only play cards tagged TAG (repeatable, all must match)\n")
+		fmt.Fprintf(os.Stderr, "        --any-tag=TAG      Only play cards with any of the given tags (repeatable)\n")
+		fmt.Fprintf(os.Stderr, "        --separator=REGEX  Line pattern that separates cards in a file (default: -{3,})\n")
+		fmt.Fprintf(os.Stderr, "        --markdown         Start a card at each ## heading, titled with the heading text\n")
+		fmt.Fprintf(os.Stderr, "        --normalize-unicode  Show typographic quotes, dashes and ellipses as ASCII\n")
+		fmt.Fprintf(os.Stderr, "        --normalize-typography  Same as --normalize-unicode\n")
+		fmt.Fprintf(os.Stderr, "        --versus           Hot-seat mode: each card is played by every player in turn\n")
+		fmt.Fprintf(os.Stderr, "        --players=NAMES    Comma-separated player names for --versus (default: Player 1,Player 2)\n")
+		fmt.Fprintf(os.Stderr, "        --profile=NAME     Keep scores in a separate history for profile NAME\n")
+		fmt.Fprintf(os.Stderr, "        --storage=KIND     Keep scores in a file (default) or sync them over http\n")
+		fmt.Fprintf(os.Stderr, "        --storage-url=URL  Score server for --storage=http (token in GOMEM_STORAGE_TOKEN)\n")
+		fmt.Fprintf(os.Stderr, "        --webhook=URL      POST a summary of the session to URL when it ends\n")
+		fmt.Fprintf(os.Stderr, "        --webhook-secret=S Sign the webhook summary with an HMAC keyed with S\n")
+		fmt.Fprintf(os.Stderr, "        --encrypt-scores   Encrypt the score history with a passphrase (GOMEM_PASSPHRASE)\n")
+		fmt.Fprintf(os.Stderr, "        --read-only        Show previous scores but never save any\n")
+		fmt.Fprintf(os.Stderr, "        --status-file=FILE Keep the session's progress in FILE as JSON, for status bars\n")
+		fmt.Fprintf(os.Stderr, "        --events=FILE      Write game events as JSON lines to FILE (- for stderr)\n")
+		fmt.Fprintf(os.Stderr, "        --headless         Play non-interactively and print the result as JSON\n")
+		fmt.Fprintf(os.Stderr, "        --input=TEXT       Text to type in headless mode\n")
+		fmt.Fprintf(os.Stderr, "        --record=FILE      Write every key pressed to FILE, to play back with --replay\n")
+		fmt.Fprintf(os.Stderr, "        --replay=FILE      Play back a recording against its cards and check the scores\n")
+		fmt.Fprintf(os.Stderr, "        --replay-speed=X   Replay at X times the recorded pace (default: as fast as possible)\n")
 		fmt.Fprintf(os.Stderr, "    -u, --update           Show update instructions\n")
 		fmt.Fprintf(os.Stderr, "    -r, --remove           Show uninstall instructions\n")
 		fmt.Fprintf(os.Stderr, "    -h, --help             Show this help message\n")
@@ -501,6 +1568,14 @@ func main() {
 
 	flag.Parse()
 
+	// Hints are only limited when --max-hints is given, as 0 turns them off
+	limitHints := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "max-hints" {
+			limitHints = true
+		}
+	})
+
 	if showUpdate {
 		fmt.Println("Thank you for using go-mem!  To update the app yourself, simply run:")
 		fmt.Println("  $ curl -fsSL https://raw.githubusercontent.com/ArkieCoder/go-mem/master/install.sh | bash")
@@ -529,59 +1604,384 @@ func main() {
 		return
 	}
 
+	if readOnly && (args[0] == "migrate-encrypt" || args[0] == "clear") {
+		fmt.Fprintf(os.Stderr, "Error: %s can't be used with --read-only\n", args[0])
+		os.Exit(1)
+	}
+
+	if len(args) == 1 && args[0] == "migrate-encrypt" {
+		fileStorage, err := scoring.NewProfileStorage(profile)
+		if err == nil {
+			err = migrateEncrypt(fileStorage)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	orderFlags := 0
+	for _, set := range []bool{randomCards, reverse, sortKey != ""} {
+		if set {
+			orderFlags++
+		}
+	}
+	if orderFlags > 1 {
+		fmt.Fprintln(os.Stderr, "Error: only one of --random-cards, --reverse and --sort can be used")
+		os.Exit(1)
+	}
+	if chain && randomCards {
+		fmt.Fprintln(os.Stderr, "Warning: --chain is off with --random-cards, as the order of the cards is what it practises")
+		chain = false
+	}
+	order := game.InOrder
+	if randomCards {
+		order = game.RandomOrder
+	} else if reverse {
+		order = game.ReverseOrder
+	} else if sortKey != "" {
+		var err error
+		if order, err = game.ParseSortOrder(sortKey); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var layout *state.KeyLayout
+	if layoutSpec != "" {
+		var err error
+		if layout, err = state.ParseLayout(layoutSpec); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	theme, err := ui.ParseTheme(themeName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if cpm == 0 || cpm < -1 {
+		fmt.Fprintf(os.Stderr, "Error: invalid --cpm value %d (must be positive)\n", cpm)
+		os.Exit(1)
+	}
+
+	if wpmTarget < 0 {
+		fmt.Fprintf(os.Stderr, "Error: invalid --wpm-target value %d (must be positive)\n", wpmTarget)
+		os.Exit(1)
+	}
+	if wpmTarget > 0 && noTimer {
+		fmt.Fprintf(os.Stderr, "Error: --wpm-target sets the timer, so it can't be used with --notimer\n")
+		os.Exit(1)
+	}
+	if wpmTarget > 0 && tFlag > 0 {
+		fmt.Fprintf(os.Stderr, "Error: --wpm-target sets the timer, so it can't be used with a fixed --timer\n")
+		os.Exit(1)
+	}
+
+	if autoHint < 0 {
+		fmt.Fprintf(os.Stderr, "Error: invalid --auto-hint value %d (must not be negative)\n", autoHint)
+		os.Exit(1)
+	}
+	if autoHint > 0 && noTimer {
+		fmt.Fprintf(os.Stderr, "Error: --auto-hint runs on the timer, so it can't be used with --notimer\n")
+		os.Exit(1)
+	}
+
+	if grace < 0 {
+		fmt.Fprintf(os.Stderr, "Error: invalid --grace value %d (must not be negative)\n", grace)
+		os.Exit(1)
+	}
+	if minAccuracy < 0 || minAccuracy > 100 {
+		fmt.Fprintf(os.Stderr, "Error: invalid --min-accuracy value %g (must be between 0 and 100)\n", minAccuracy)
+		os.Exit(1)
+	}
+
+	if maxAttemptsPerDay < 0 {
+		fmt.Fprintf(os.Stderr, "Error: invalid --max-attempts-per-day value %d (must not be negative)\n", maxAttemptsPerDay)
+		os.Exit(1)
+	}
+	if maxHints < 0 {
+		fmt.Fprintf(os.Stderr, "Error: invalid --max-hints value %d (must not be negative)\n", maxHints)
+		os.Exit(1)
+	}
+	if !slices.Contains(state.HintStrategies, hintStrategy) {
+		fmt.Fprintf(os.Stderr, "Error: unknown hint strategy: %s (use next, rare or consonant)\n", hintStrategy)
+		os.Exit(1)
+	}
+	if maxAttemptsPerDay > 0 && versus {
+		fmt.Fprintln(os.Stderr, "Error: --max-attempts-per-day can't be used with --versus")
+		os.Exit(1)
+	}
+
+	if recordPath != "" && (headless || replayPath != "") {
+		fmt.Fprintln(os.Stderr, "Error: --record can't be used with --headless or --replay")
+		os.Exit(1)
+	}
+	var webhook *notify.Webhook
+	if webhookURL != "" {
+		var err error
+		if webhook, err = notify.NewWebhook(webhookURL, webhookSecret); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	} else if webhookSecret != "" {
+		fmt.Fprintln(os.Stderr, "Error: --webhook-secret needs --webhook")
+		os.Exit(1)
+	}
+
+	if replaySpeed < 0 {
+		fmt.Fprintf(os.Stderr, "Error: invalid --replay-speed value %g (must not be negative)\n", replaySpeed)
+		os.Exit(1)
+	}
+
+	recall := mode == "recall"
+	if mode != "type" && !recall {
+		fmt.Fprintf(os.Stderr, "Error: unknown mode: %s (use type or recall)\n", mode)
+		os.Exit(1)
+	}
+	if recall && flash {
+		fmt.Fprintln(os.Stderr, "Error: --flash can't be used with --mode=recall")
+		os.Exit(1)
+	}
+	// The text is only ever seen in the preview, so recall always has one
+	if recall && preview == 0 {
+		preview = 10
+	}
+
+	// Everything random is drawn from one seeded source, so a run can be replayed
+	if seed == -1 {
+		seed = time.Now().UnixNano()
+	}
+	if randomCards || nRandom > 0 || mixedModes || adaptive || nWords > 0 || cloze > 0 {
+		fmt.Fprintf(os.Stderr, "Seed: %d (replay with --seed=%d)\n", seed, seed)
+	}
+
 	// Determine effective timer limit
 	timerLimit := int(tFlag)
-	if noTimer {
+	if noTimer || flash || recall {
 		timerLimit = 0
 	}
 
 	opts := state.GameOptions{
-		TimerLimit:  timerLimit,
-		FirstLetter: firstLetter,
-		NRandom:     int(nRandom),
-		NWords:      int(nWords),
+		TimerLimit:         timerLimit,
+		FirstLetter:        firstLetter,
+		LastLetter:         lastLetter,
+		NRandom:            int(nRandom),
+		Adaptive:           adaptive,
+		NWords:             int(nWords),
+		Cloze:              int(cloze),
+		LegacyWordSplit:    legacyWordSplit,
+		Ghost:              ghost,
+		NoConfidence:       noConfidence,
+		Preview:            int(preview),
+		StrictSymbols:      strictSymbols,
+		HideSpaces:         hideSpaces,
+		RequirePunctuation: requirePunctuation,
+		RequireEnter:       requireEnter,
+		ByLine:             byLine,
+		Layout:             layout,
+		Flash:              flash,
+		Recall:             recall,
+		Lenient:            lenient,
+		ForgiveTypos:       forgiveTypos,
+		NumberLeniency:     numberLeniency,
+		LenientDigits:      lenientDigits,
+		NoTypeThrough:      !typeThrough,
+		MinAccuracy:        minAccuracy,
+		ScoreFloor:         scoreFloor.floor,
+		NoScoreFloor:       scoreFloor.none,
+		MaxAttemptsPerDay:  maxAttemptsPerDay,
+		LimitHints:         limitHints,
+		MaxHints:           maxHints,
+		AutoHint:           autoHint,
+		HintStrategy:       hintStrategy,
+		MixedModes:         mixedModes,
+		CPM:                cpm,
+		WPMTarget:          wpmTarget,
+		Grace:              grace,
+		Rand:               rand.New(rand.NewSource(seed)),
 	}
 
-	// Create the initial model
-	model, err := initialModel(args, opts, randomCards)
+	// Stream game events for overlays; the TUI owns stdout, so stderr is the alternative
+	if eventsPath == "-" {
+		opts.Events = state.NewEventLog(os.Stderr)
+	} else if eventsPath != "" {
+		eventsFile, err := os.OpenFile(eventsPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to open events file: %v\n", err)
+			os.Exit(1)
+		}
+		defer eventsFile.Close()
+		opts.Events = state.NewEventLog(eventsFile)
+	}
+
+	if recordPath != "" {
+		recordFile, err := os.Create(recordPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to create record file: %v\n", err)
+			os.Exit(1)
+		}
+		defer recordFile.Close()
+		opts.Record = state.NewKeyLog(recordFile)
+	}
+
+	loadOpts := game.LoadOptions{
+		Format:           format,
+		Separator:        separator,
+		Markdown:         markdown,
+		Tags:             tags,
+		AnyTags:          anyTags,
+		NormalizeUnicode: normalizeUnicode,
+	}
+
+	// Create the concrete storage implementation.
+	fileStorage, err := scoring.NewProfileStorage(profile)
 	if err != nil {
-		fmt.Printf("Error initializing model: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: failed to create score storage: %v\n", err)
 		os.Exit(1)
 	}
+	var storage scoring.ScoreStorage = fileStorage
+	switch {
+	case storageKind == "http" && encryptScores:
+		fmt.Fprintln(os.Stderr, "Error: --encrypt-scores can't be used with --storage=http")
+		os.Exit(1)
+	case storageKind == "http" && storageURL == "":
+		fmt.Fprintln(os.Stderr, "Error: --storage=http needs --storage-url")
+		os.Exit(1)
+	case storageKind == "http":
+		// The local scores file is the cache for when the server can't be reached
+		if storage, err = scoring.NewHTTPStorage(storageURL, os.Getenv("GOMEM_STORAGE_TOKEN"), fileStorage); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer warnIfUnsynced(storage)
+	case storageKind != "file":
+		fmt.Fprintf(os.Stderr, "Error: unknown storage: %s (use file or http)\n", storageKind)
+		os.Exit(1)
+	case encryptScores:
+		if storage, err = encryptedStorage(fileStorage); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if readOnly {
+		storage = scoring.NewReadOnlyStorage(storage)
+	}
 
-	// Main Loop: Run one program per card
-	session := model.Session
-	for {
-		// Create a fresh model wrapper for the current session state
-		currentModel := &LocalState{
-			Session: session,
+	if args[0] == "clear" {
+		if err := runClear(args[1:], storage); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
 		}
+		return
+	}
 
-		p := tea.NewProgram(currentModel)
-		_, err := p.Run()
+	if args[0] == "stats" {
+		if err := runStats(args[1:], loadOpts, storage); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// stale --exec plays the stale cards it finds in place of the paths
+	var staleCards []game.CardData
+	if args[0] == "stale" {
+		cards, err := runStale(args[1:], loadOpts, storage)
 		if err != nil {
-			fmt.Printf("Error starting the program: %v\n", err)
-			break
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
 		}
+		if cards == nil {
+			return
+		}
+		if len(cards) == 0 {
+			fmt.Println("No stale cards to play.")
+			return
+		}
+		if watch {
+			fmt.Fprintln(os.Stderr, "Error: --watch can't be used with stale --exec")
+			os.Exit(1)
+		}
+		staleCards = cards
+	}
 
-		// Check for loss
-		if session.IsSessionLoss() {
-			// If revealed (gave up), continue to next card. Otherwise (timer), end session.
-			if !session.CurrentGame.State.Revealed {
-				break
+	if replayPath != "" {
+		if err := runReplay(args, loadOpts, replayPath, replaySpeed); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if headless {
+		if err := runHeadless(args, loadOpts, opts, input, storage); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Create the initial model
+	var playerNames []string
+	if versus {
+		playerNames = []string{}
+		for _, name := range strings.Split(players, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				playerNames = append(playerNames, name)
 			}
 		}
+	}
+	var model *LocalState
+	if staleCards != nil {
+		model, err = newModel(staleCards, opts, order, playerNames, storage)
+	} else {
+		model, err = initialModel(args, loadOpts, opts, order, playerNames, storage)
+	}
+	if err != nil {
+		fmt.Printf("Error initializing model: %v\n", err)
+		os.Exit(1)
+	}
 
-		// Advance to next card
-		session.CurrentIndex++
-		if session.IsFinished() {
-			break
+	var watcher *game.CardWatcher
+	if watch {
+		if watcher, err = game.NewCardWatcher(args, loadOpts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
 		}
+	}
 
-		// Prepare next game
-		if err := session.NextGame(); err != nil {
-			fmt.Printf("Error preparing next game: %v\n", err)
-			break
+	// One program plays the whole session, moving from card to card itself
+	model.Session.DrillMistakes = drillMistakes
+	model.Session.Chain = chain
+	model.NoPeek = noPeek || chain // The next card's title could give its first word away
+	model.Loop = loop
+	model.Wallclock = wallclockTimer
+	model.ShowElapsed = showElapsed
+	model.TrueScore = trueScore
+	model.Watcher = watcher
+	model.Theme = theme
+	model.StatusFile = statusFile
+	model.Notice = skippedNotice(model.Session.Skipped)
+
+	_, runErr := tea.NewProgram(model).Run()
+	// The summary is sent however the session ended
+	if webhook != nil {
+		if err := webhook.Send(notify.NewSessionReport(model.Session, time.Now())); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not send the session summary: %v\n", err)
 		}
 	}
+	if runErr != nil {
+		fmt.Printf("Error starting the program: %v\n", runErr)
+		return
+	}
+	if statusFile != "" {
+		os.Remove(statusFile)
+	}
+	if model.Err != nil {
+		fmt.Printf("%v\n", model.Err)
+	}
 }
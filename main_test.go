@@ -0,0 +1,483 @@
+package main
+
+import (
+	"encoding/json"
+	"go-mem/internal/game"
+	"go-mem/internal/scoring"
+	"go-mem/internal/state"
+	"go-mem/internal/ui"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// memStorage keeps scores in memory.
+type memStorage struct {
+	entries []scoring.ScoreHistoryEntry
+}
+
+func (m *memStorage) LoadAll() ([]scoring.ScoreHistoryEntry, error) { return m.entries, nil }
+func (m *memStorage) SaveAll(entries []scoring.ScoreHistoryEntry) error {
+	m.entries = entries
+	return nil
+}
+
+// newTestModel returns a model for a session of the given cards.
+func newTestModel(t *testing.T, opts state.GameOptions, contents ...string) *LocalState {
+	t.Helper()
+	var cards []game.CardData
+	for _, c := range contents {
+		cards = append(cards, game.CardData{Content: c, Title: c})
+	}
+	sess, err := game.NewSession(cards, opts, &memStorage{}, game.InOrder)
+	if err != nil {
+		t.Fatalf("NewSession returned error: %v", err)
+	}
+	return &LocalState{Session: sess}
+}
+
+// typeKeys sends each character of keys to the model, returning the last command.
+func typeKeys(m *LocalState, keys string) tea.Cmd {
+	var cmd tea.Cmd
+	for _, r := range keys {
+		_, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	return cmd
+}
+
+// isQuit reports whether cmd asks the program to quit.
+func isQuit(cmd tea.Cmd) bool {
+	if cmd == nil {
+		return false
+	}
+	_, ok := cmd().(QuitMsg)
+	return ok
+}
+
+func TestModel_AdvancesWithinProgram(t *testing.T) {
+	m := newTestModel(t, state.GameOptions{TimerLimit: 0}, "ab", "cd")
+	m.Init()
+	first := m.Session.CurrentGame
+
+	cmd := typeKeys(m, "ab")
+	if !first.State.Win {
+		t.Fatal("Expected the first card to be won")
+	}
+	if cmd == nil || m.Quitting {
+		t.Fatal("Expected a pause before the next card, not a quit")
+	}
+	if m.Session.CurrentGame != first {
+		t.Error("Expected the result of the first card to stay up during the pause")
+	}
+
+	// The pause ends and the next card starts in the same program
+	m.Update(AdvanceMsg{Game: first})
+	second := m.Session.CurrentGame
+	if m.Session.CurrentIndex != 1 || second == first {
+		t.Fatalf("Expected the second card after the pause, at index %d", m.Session.CurrentIndex)
+	}
+
+	// A stale pause from the first card doesn't skip the second
+	m.Update(AdvanceMsg{Game: first})
+	if m.Session.CurrentGame != second {
+		t.Error("Expected a stale AdvanceMsg to be ignored")
+	}
+
+	// Winning the last card ends the session
+	if cmd := typeKeys(m, "cd"); !isQuit(cmd) || !m.Quitting {
+		t.Error("Expected the program to quit after the last card")
+	}
+	if m.Session.TotalScore != m.Session.Results[0].Score+m.Session.Results[1].Score || len(m.Session.Results) != 2 {
+		t.Errorf("Expected both cards in the results, got %+v", m.Session.Results)
+	}
+}
+
+func TestModel_KeySkipsPause(t *testing.T) {
+	m := newTestModel(t, state.GameOptions{}, "ab", "cd")
+	typeKeys(m, "ab")
+
+	typeKeys(m, "x")
+	if m.Session.CurrentIndex != 1 {
+		t.Fatalf("Expected a keypress to start the next card, at index %d", m.Session.CurrentIndex)
+	}
+	// The key only ended the pause; it wasn't typed into the new card
+	if m.Session.CurrentGame.State.Score.ErrorCount != 0 {
+		t.Error("Expected the key that ended the pause not to count as a mistake")
+	}
+}
+
+func TestModel_RevealThenContinue(t *testing.T) {
+	m := newTestModel(t, state.GameOptions{}, "ab", "cd")
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+	if !m.Session.CurrentGame.State.Revealed || cmd == nil || m.Quitting {
+		t.Fatal("Expected Ctrl+R to give up the card and pause before the next one")
+	}
+	m.Update(AdvanceMsg{Game: m.Session.CurrentGame})
+	if m.Session.CurrentIndex != 1 {
+		t.Errorf("Expected the next card after revealing one, at index %d", m.Session.CurrentIndex)
+	}
+}
+
+func TestModel_LossEndsSession(t *testing.T) {
+	m := newTestModel(t, state.GameOptions{}, "ab", "cd")
+	// The score drops below zero on the first mistake
+	if cmd := typeKeys(m, "x"); !isQuit(cmd) || !m.Quitting {
+		t.Fatal("Expected a loss to end the session")
+	}
+	if outcome, _ := m.Session.Outcome(); outcome != game.SessionLost {
+		t.Errorf("Expected the session to be lost, got %v", outcome)
+	}
+}
+
+func TestModel_TimerAndResizeAcrossCards(t *testing.T) {
+	m := newTestModel(t, state.GameOptions{TimerLimit: 60}, "ab", "a much longer card")
+	if m.Init() == nil || !m.ticking {
+		t.Fatal("Expected the timed first card to start ticking")
+	}
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+
+	typeKeys(m, "ab")
+	// The tick in flight arrives during the pause, and stops
+	if _, cmd := m.Update(TickMsg{}); cmd != nil || m.ticking {
+		t.Error("Expected ticks to stop while the result is showing")
+	}
+	if m.Session.TimeRemaining != 60 {
+		t.Errorf("Expected no time to be taken during the pause, got %d left", m.Session.TimeRemaining)
+	}
+
+	// The next card restarts them, and is sized for itself
+	_, cmd := m.Update(AdvanceMsg{Game: m.Session.CurrentGame})
+	if cmd == nil || !m.ticking {
+		t.Error("Expected the next timed card to start ticking again")
+	}
+	st := m.Session.CurrentGame.State
+	ta := st.Display.(*state.TextareaDisplay)
+	// Width leaves out the one-column prompt
+	if ta.Width() != st.CardWidth {
+		t.Errorf("Expected the textarea to fit the new card (%d), got %d", st.CardWidth, ta.Width())
+	}
+	if m.TermWidth != 120 {
+		t.Errorf("Expected the terminal width to be kept across cards, got %d", m.TermWidth)
+	}
+}
+
+func TestModel_LoopReplays(t *testing.T) {
+	m := newTestModel(t, state.GameOptions{}, "ab")
+	m.Loop = true
+	first := m.Session.CurrentGame
+
+	if cmd := typeKeys(m, "ab"); cmd == nil || m.Quitting {
+		t.Fatal("Expected a pause before the next attempt, not a quit")
+	}
+	m.Update(AdvanceMsg{Game: first})
+	if m.Session.CurrentGame == first || m.Session.CurrentGame.State.Win {
+		t.Error("Expected a fresh attempt at the card")
+	}
+}
+
+func TestModel_Recall(t *testing.T) {
+	m := newTestModel(t, state.GameOptions{Recall: true}, "ab cd", "ef")
+	typeKeys(m, "ab")
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	typeKeys(m, "cd")
+	if view := m.View(); !strings.Contains(view, "WORDS TYPED: 2") || !strings.Contains(view, "Ctrl+D") {
+		t.Errorf("Expected the recall status and instructions, got:\n%s", view)
+	}
+
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlD})
+	st := m.Session.CurrentGame.State
+	if !st.Win || cmd == nil || m.Quitting {
+		t.Fatal("Expected Ctrl+D to hand in the card and pause before the next one")
+	}
+	if view := m.View(); !strings.Contains(view, "100% recalled") {
+		t.Errorf("Expected the grade in the result, got:\n%s", view)
+	}
+	if m.Session.TotalScore != st.MaxScore() {
+		t.Errorf("Expected a perfect recall to score %d, got %d", st.MaxScore(), m.Session.TotalScore)
+	}
+}
+
+// suspendFor sends the messages of a ctrl+z suspend lasting away, with the
+// tick in flight arriving while suspended, and returns the command of resuming.
+func suspendFor(t *testing.T, m *LocalState, away time.Duration) tea.Cmd {
+	t.Helper()
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlZ})
+	if cmd == nil {
+		t.Fatal("Expected Ctrl+Z to suspend the program")
+	}
+	if _, ok := cmd().(tea.SuspendMsg); !ok {
+		t.Fatal("Expected Ctrl+Z to suspend the program")
+	}
+	m.suspendedAt = m.suspendedAt.Add(-away)
+	m.Update(tea.SuspendMsg{})
+	if _, cmd := m.Update(TickMsg{}); cmd != nil || m.ticking {
+		t.Error("Expected ticks to stop while suspended")
+	}
+	_, cmd = m.Update(tea.ResumeMsg{})
+	return cmd
+}
+
+func TestModel_SuspendIgnoresTimeAway(t *testing.T) {
+	m := newTestModel(t, state.GameOptions{TimerLimit: 60}, "ab")
+	m.Init()
+
+	cmd := suspendFor(t, m, 5*time.Second)
+	if m.Session.CurrentGame.State.TimeRemaining != 60 {
+		t.Errorf("Expected no time taken while suspended, got %d left", m.Session.CurrentGame.State.TimeRemaining)
+	}
+	if cmd == nil || !m.ticking {
+		t.Error("Expected resuming to repaint and start ticking again")
+	}
+
+	// The timer carries on from where it stopped
+	m.Update(TickMsg{})
+	if m.Session.TimeRemaining != 59 {
+		t.Errorf("Expected 59 seconds left after a tick, got %d", m.Session.TimeRemaining)
+	}
+}
+
+func TestModel_SuspendWallclockTimer(t *testing.T) {
+	m := newTestModel(t, state.GameOptions{TimerLimit: 60}, "ab")
+	m.Wallclock = true
+	m.Init()
+
+	cmd := suspendFor(t, m, 5500*time.Millisecond)
+	if m.Session.TimeRemaining != 55 {
+		t.Errorf("Expected the 5 whole seconds away taken off the timer, got %d left", m.Session.TimeRemaining)
+	}
+	if cmd == nil || !m.ticking {
+		t.Error("Expected resuming to repaint and start ticking again")
+	}
+
+	// Staying away longer than the time left loses the card
+	if cmd := suspendFor(t, m, time.Minute); cmd == nil || !m.Quitting {
+		t.Error("Expected the session to end when the timer ran out while suspended")
+	}
+	if !m.Session.CurrentGame.State.Loss {
+		t.Error("Expected the card to be lost")
+	}
+}
+
+func TestModel_ShowElapsed(t *testing.T) {
+	m := newTestModel(t, state.GameOptions{}, "ab", "cd")
+	m.ShowElapsed = true
+	now := m.Session.StartedAt
+	m.Session.Now = func() time.Time { return now }
+	if cmd := m.Init(); cmd == nil || !m.ticking {
+		t.Error("Expected an untimed session to tick to keep the elapsed time up to date")
+	}
+
+	now = now.Add(3*time.Minute + 12*time.Second)
+	if got := m.Session.Elapsed(); got != 192*time.Second {
+		t.Errorf("Expected 192s elapsed, got %v", got)
+	}
+	if view := m.View(); !strings.Contains(view, "ELAPSED: 03:12") || strings.Contains(view, "TIME:") {
+		t.Errorf("Expected the elapsed time without a countdown, got:\n%s", view)
+	}
+
+	// Alongside the countdown
+	m = newTestModel(t, state.GameOptions{TimerLimit: 60}, "ab")
+	m.ShowElapsed = true
+	m.Session.Now = func() time.Time { return m.Session.StartedAt.Add(75 * time.Second) }
+	if view := m.View(); !strings.Contains(view, "TIME:") || !strings.Contains(view, "ELAPSED: 01:15") {
+		t.Errorf("Expected the elapsed time next to the countdown, got:\n%s", view)
+	}
+}
+
+func TestModel_TrueScore(t *testing.T) {
+	m := newTestModel(t, state.GameOptions{ScoreFloor: -200}, "abc")
+	m.Session.CurrentGame.State.Score.CurrentScore = -150
+	if view := m.View(); !strings.Contains(view, "SCORE: -150 /") {
+		t.Errorf("Expected the score above the floor to be shown as it is, got:\n%s", view)
+	}
+
+	// Below the floor the score shown stops at it, unless the true score is wanted
+	m.Session.CurrentGame.State.Score.CurrentScore = -250
+	if view := m.View(); !strings.Contains(view, "SCORE: -200 /") {
+		t.Errorf("Expected the score to stop at the floor, got:\n%s", view)
+	}
+	m.TrueScore = true
+	if view := m.View(); !strings.Contains(view, "SCORE: -250 /") {
+		t.Errorf("Expected the true score, got:\n%s", view)
+	}
+}
+
+func TestModel_SkipCard(t *testing.T) {
+	m := newTestModel(t, state.GameOptions{}, "ab", "cd")
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlN})
+	if m.Session.CurrentIndex != 1 || m.Quitting {
+		t.Fatalf("Expected ctrl+n to skip to the second card, at %d", m.Session.CurrentIndex)
+	}
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlN}); !m.Quitting || cmd == nil {
+		t.Error("Expected skipping the last card to end the session")
+	}
+
+	// A single card carries on
+	m = newTestModel(t, state.GameOptions{}, "ab")
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlN}); cmd != nil || m.Quitting || m.Session.CurrentIndex != 0 {
+		t.Error("Expected ctrl+n to do nothing for a single card")
+	}
+}
+
+func TestModel_BatchProgressWidth(t *testing.T) {
+	m := newTestModel(t, state.GameOptions{}, "ab", "cd")
+	for _, width := range []int{40, 60} {
+		if got := ui.LongestLineLen(m.renderBatchProgress(width)); got != width {
+			t.Errorf("Expected the progress line %d wide with its label, got %d", width, got)
+		}
+	}
+}
+
+func TestModel_QuitSavesAbandoned(t *testing.T) {
+	tests := []struct {
+		name string
+		opts state.GameOptions
+		keys string
+	}{
+		{"typing", state.GameOptions{}, "a"},
+		{"preview", state.GameOptions{Preview: 5}, ""},
+		{"recall", state.GameOptions{Recall: true}, "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memStorage{}
+			sess, err := game.NewSession([]game.CardData{{Content: "abc", Title: "abc"}}, tt.opts, store, game.InOrder)
+			if err != nil {
+				t.Fatalf("NewSession returned error: %v", err)
+			}
+			m := &LocalState{Session: sess}
+			typeKeys(m, tt.keys)
+
+			_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlC})
+			if cmd == nil {
+				t.Fatal("Expected ctrl+c to quit")
+			}
+			if _, ok := cmd().(tea.QuitMsg); !ok {
+				t.Fatal("Expected ctrl+c to quit")
+			}
+			if len(store.entries) != 1 || store.entries[0].Outcome != scoring.OutcomeAbandoned {
+				t.Fatalf("Expected the game to be saved as abandoned, got %+v", store.entries)
+			}
+
+			// Quitting after the game has ended saves nothing more
+			m.Update(tea.KeyMsg{Type: tea.KeyCtrlC})
+			if len(store.entries) != 1 {
+				t.Errorf("Expected a single entry, got %d", len(store.entries))
+			}
+		})
+	}
+}
+
+func TestModel_ByLine(t *testing.T) {
+	m := newTestModel(t, state.GameOptions{ByLine: true}, "ab\ncd\nef")
+	typeKeys(m, "ab")
+	if m.Quitting || m.Session.Line != 1 {
+		t.Fatalf("Expected winning the first line to move on to the second, at line %d", m.Session.Line)
+	}
+	view := m.View()
+	if !strings.Contains(view, "ab") || !strings.Contains(view, "LINE 2/3") {
+		t.Errorf("Expected the first line kept in view, got:\n%s", view)
+	}
+
+	typeKeys(m, "cd")
+	if cmd := typeKeys(m, "ef"); !isQuit(cmd) {
+		t.Error("Expected the last line to end the card")
+	}
+}
+
+func TestModel_Chain(t *testing.T) {
+	m := newTestModel(t, state.GameOptions{TimerLimit: 60}, "ab", "cd")
+	m.Session.Chain = true
+	typeKeys(m, "ab")
+
+	// Going on from the first card asks for the second's first word, with the timer stopped
+	if cmd := typeKeys(m, "x"); cmd != nil || m.Session.ChainPrompt == nil {
+		t.Fatalf("Expected the chain prompt with no ticks, got prompt %+v", m.Session.ChainPrompt)
+	}
+	if view := m.View(); !strings.Contains(view, "What is its first word?") || strings.Contains(view, "SCORE:") {
+		t.Errorf("Expected the prompt in place of the board, got %q", view)
+	}
+
+	// ctrl+n is a key like any other at the prompt
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlN})
+	if m.Session.CurrentIndex != 1 || m.Session.ChainPrompt == nil {
+		t.Fatalf("Expected to stay at the prompt, at card %d", m.Session.CurrentIndex)
+	}
+
+	typeKeys(m, "cd")
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.Session.ChainPrompt != nil || cmd == nil || !m.ticking {
+		t.Fatal("Expected the answer to show the board and start the timer")
+	}
+	if view := m.View(); !strings.Contains(view, "Chain: right, +200") || !strings.Contains(view, "SCORE:") {
+		t.Errorf("Expected the board with the chain bonus noted, got %q", view)
+	}
+}
+
+func TestModel_StatusFile(t *testing.T) {
+	m := newTestModel(t, state.GameOptions{TimerLimit: 0}, "ab", "cd")
+	m.StatusFile = filepath.Join(t.TempDir(), "status.json")
+	status := func() game.Status {
+		t.Helper()
+		data, err := os.ReadFile(m.StatusFile)
+		if err != nil {
+			t.Fatalf("Could not read the status file: %v", err)
+		}
+		var st game.Status
+		if err := json.Unmarshal(data, &st); err != nil {
+			t.Fatalf("Status file is not JSON: %v", err)
+		}
+		return st
+	}
+
+	m.Init()
+	if st := status(); st.Card != 1 || st.Total != 2 || st.Outcome != "playing" {
+		t.Errorf("Expected the first card to be playing, got %+v", st)
+	}
+	first := m.Session.CurrentGame
+	typeKeys(m, "ab")
+	if st := status(); st.Outcome != "won" {
+		t.Errorf("Expected the first card to be won, got %+v", st)
+	}
+	m.Update(AdvanceMsg{Game: first})
+	if st := status(); st.Card != 2 || st.Outcome != "playing" {
+		t.Errorf("Expected the second card to be playing, got %+v", st)
+	}
+
+	// A status file that can't be written is noted once, and play goes on
+	m.StatusFile = filepath.Join(t.TempDir(), "missing", "status.json")
+	m.Update(TickMsg(time.Now()))
+	m.Update(TickMsg(time.Now()))
+	if strings.Count(m.Notice, "Could not write") != 1 {
+		t.Errorf("Expected the failure to be noted once, got notice %q", m.Notice)
+	}
+}
+
+func TestModel_LinePeek(t *testing.T) {
+	m := newTestModel(t, state.GameOptions{TimerLimit: 0}, "ab\ncd")
+	m.Init()
+	m.Session.CurrentGame.State.Score.CurrentScore = 1000
+
+	// The peek starts ticks to hide it again, though the game is untimed
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlL})
+	if cmd == nil || !m.ticking {
+		t.Fatal("Expected a tick to be started for the peek")
+	}
+	if board := m.RenderBoard(0); !strings.Contains(board, "ab") || strings.Contains(board, "cd") {
+		t.Errorf("Expected only the current line shown in full, got %q", board)
+	}
+	if mask := string(m.Session.CurrentGame.State.Mask); mask != "__\n__" {
+		t.Errorf("Expected the mask left alone, got %q", mask)
+	}
+
+	for range state.LinePeekSeconds {
+		m.Update(TickMsg(time.Now()))
+	}
+	if board := m.RenderBoard(0); strings.Contains(board, "ab") || m.ticking {
+		t.Errorf("Expected the line hidden again and the ticks stopped, got %q", board)
+	}
+}